@@ -93,13 +93,12 @@ func (body *Body) GenerateDigest() (digest *Digest, err error) {
 	digest.BlockHash = body.Hash
 
 	for _, claim := range body.Claims.At {
-		data, err := claim.MarshalBinary()
+		claimHash, err := claim.Hash()
 		if err != nil {
 			return nil, err
 		}
 
-		digest.ClaimsHashes.At = append(
-			digest.ClaimsHashes.At, hash.NewSHA256Container(data))
+		digest.ClaimsHashes.At = append(digest.ClaimsHashes.At, claimHash)
 	}
 
 	for _, tsl := range body.TSLs.At {
@@ -115,6 +114,33 @@ func (body *Body) GenerateDigest() (digest *Digest, err error) {
 	return
 }
 
+// ClaimsByHashes returns claims of the block, which hashes are present in "hashes".
+// Claims are returned in the same order as hashes.
+// Returns errors.NotFound in case if at least one claim is absent in the block.
+func (body *Body) ClaimsByHashes(hashes []hash.SHA256Container) (claims []*geo.Claim, err error) {
+	index := make(map[hash.SHA256Container]*geo.Claim, len(body.Claims.At))
+	for _, claim := range body.Claims.At {
+		claimHash, err := claim.Hash()
+		if err != nil {
+			return nil, err
+		}
+
+		index[claimHash] = claim
+	}
+
+	claims = make([]*geo.Claim, 0, len(hashes))
+	for _, key := range hashes {
+		claim, isPresent := index[key]
+		if !isPresent {
+			return nil, errors.NotFound
+		}
+
+		claims = append(claims, claim)
+	}
+
+	return
+}
+
 func (body *Body) MarshalBinary() (data []byte, err error) {
 	blockHashData, err := body.Hash.MarshalBinary()
 	if err != nil {
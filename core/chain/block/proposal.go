@@ -0,0 +1,167 @@
+package block
+
+import (
+	"bytes"
+	"geo-observers-blockchain/core/common"
+	"geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/geo"
+	"geo-observers-blockchain/core/utils"
+)
+
+// Proposal combines all the data, that observer proposes to be included into the next block:
+// time frame index, in which proposal was generated, claims and TSLs sets, and the digest of the block candidate.
+// It is intended for the debugging of the case, when two observers has produced different blocks:
+// serialized proposals of both observers could be compared by the operators (see Equal()).
+// Proposal is not exchanged over the network: digests broadcasts carry block.Digest only.
+type Proposal struct {
+	FrameIndex uint16
+	Claims     *geo.Claims
+	TSLs       *geo.TSLs
+	Digest     *Digest
+}
+
+// Format:
+// 2B - Frame index.
+// 4B - Claims data size.
+// NB - Claims data.
+// 4B - TSLs data size.
+// NB - TSLs data.
+// NB - Digest data.
+func (p *Proposal) MarshalBinary() (data []byte, err error) {
+	if p.Claims == nil || p.TSLs == nil || p.Digest == nil {
+		return nil, errors.NilInternalDataStructure
+	}
+
+	claimsData, err := p.Claims.MarshalBinary()
+	if err != nil {
+		return
+	}
+
+	tslsData, err := p.TSLs.MarshalBinary()
+	if err != nil {
+		return
+	}
+
+	digestData, err := p.Digest.MarshalBinary()
+	if err != nil {
+		return
+	}
+
+	data = utils.ChainByteSlices(
+		utils.MarshalUint16(p.FrameIndex),
+		utils.MarshalUint32(uint32(len(claimsData))),
+		claimsData,
+		utils.MarshalUint32(uint32(len(tslsData))),
+		tslsData,
+		digestData)
+
+	return
+}
+
+func (p *Proposal) UnmarshalBinary(data []byte) (err error) {
+	const (
+		offsetFrameIndex = 0
+		offsetClaimsSize = offsetFrameIndex + common.Uint16ByteSize
+		offsetClaimsData = offsetClaimsSize + common.Uint32ByteSize
+	)
+
+	if len(data) < offsetClaimsData {
+		return errors.InvalidDataFormat
+	}
+
+	p.FrameIndex, err = utils.UnmarshalUint16(data[offsetFrameIndex:offsetClaimsSize])
+	if err != nil {
+		return
+	}
+
+	claimsDataSize, err := utils.UnmarshalUint32(data[offsetClaimsSize:offsetClaimsData])
+	if err != nil {
+		return
+	}
+
+	offsetTSLsSize := uint64(offsetClaimsData) + uint64(claimsDataSize)
+	offsetTSLsData := offsetTSLsSize + common.Uint32ByteSize
+	if uint64(len(data)) < offsetTSLsData {
+		return errors.InvalidDataFormat
+	}
+
+	tslsDataSize, err := utils.UnmarshalUint32(data[offsetTSLsSize:offsetTSLsData])
+	if err != nil {
+		return
+	}
+
+	offsetDigestData := offsetTSLsData + uint64(tslsDataSize)
+	if uint64(len(data)) < offsetDigestData {
+		return errors.InvalidDataFormat
+	}
+
+	p.Claims = &geo.Claims{}
+	err = p.Claims.UnmarshalBinary(data[offsetClaimsData:offsetTSLsSize])
+	if err != nil {
+		return
+	}
+
+	p.TSLs = &geo.TSLs{}
+	err = p.TSLs.UnmarshalBinary(data[offsetTSLsData:offsetDigestData])
+	if err != nil {
+		return
+	}
+
+	p.Digest = &Digest{}
+	err = p.Digest.UnmarshalBinary(data[offsetDigestData:])
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Equal returns true if both proposals contains the same data.
+// Claims and TSLs are compared as sets: order of the records does not matter.
+func (p *Proposal) Equal(other *Proposal) (equal bool, err error) {
+	if other == nil {
+		return false, errors.NilParameter
+	}
+
+	if p.FrameIndex != other.FrameIndex {
+		return false, nil
+	}
+
+	canonicalBinary := func(proposal *Proposal) (data []byte, err error) {
+		if proposal.Claims == nil || proposal.TSLs == nil {
+			return nil, errors.NilInternalDataStructure
+		}
+
+		// Sets are copied to not to change the order of the records of the original proposal.
+		canonical := &Proposal{
+			FrameIndex: proposal.FrameIndex,
+			Claims:     &geo.Claims{At: append([]*geo.Claim{}, proposal.Claims.At...)},
+			TSLs:       &geo.TSLs{At: append([]*geo.TSL{}, proposal.TSLs.At...)},
+			Digest:     proposal.Digest,
+		}
+
+		err = canonical.Claims.Sort()
+		if err != nil {
+			return
+		}
+
+		err = canonical.TSLs.Sort()
+		if err != nil {
+			return
+		}
+
+		return canonical.MarshalBinary()
+	}
+
+	data, err := canonicalBinary(p)
+	if err != nil {
+		return
+	}
+
+	otherData, err := canonicalBinary(other)
+	if err != nil {
+		return
+	}
+
+	return bytes.Equal(data, otherData), nil
+}
@@ -0,0 +1,136 @@
+package block
+
+import (
+	"geo-observers-blockchain/core/common/types/hash"
+	"geo-observers-blockchain/core/common/types/transactions"
+	"geo-observers-blockchain/core/geo"
+	"testing"
+)
+
+func newTestProposal(t *testing.T, recordsCount int) *Proposal {
+	proposal := &Proposal{
+		FrameIndex: 3,
+		Claims:     &geo.Claims{},
+		TSLs:       &geo.TSLs{},
+		Digest: &Digest{
+			Index:               10,
+			AuthorObserverIndex: 3,
+			BlockHash:           hash.NewSHA256Container([]byte("block")),
+		},
+	}
+
+	for i := 0; i < recordsCount; i++ {
+		txID, err := transactions.NewRandomTxID(uint64(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		claim := &geo.Claim{TxUUID: txID, Members: &geo.ClaimMembers{}}
+		_ = claim.Members.Add(geo.NewClaimMember(uint16(i)))
+		_ = proposal.Claims.Add(claim)
+
+		tsl := &geo.TSL{TxUUID: txID, Members: &geo.TSLMembers{}}
+		_ = tsl.Members.Add(geo.NewTSLMember(uint16(i)))
+		_ = proposal.TSLs.Add(tsl)
+
+		_ = proposal.Digest.ClaimsHashes.Add(hash.NewSHA256Container(txID.Bytes[:]))
+	}
+
+	return proposal
+}
+
+func TestProposal_MarshalBinary_RoundTrip(t *testing.T) {
+	proposal := newTestProposal(t, 3)
+
+	data, err := proposal.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := &Proposal{}
+	err = restored.UnmarshalBinary(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if restored.FrameIndex != proposal.FrameIndex ||
+		restored.Claims.Count() != 3 ||
+		restored.TSLs.Count() != 3 ||
+		restored.Digest.Index != proposal.Digest.Index ||
+		restored.Digest.ClaimsHashes.Count() != 3 {
+		t.Fatal()
+	}
+
+	equal, err := proposal.Equal(restored)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !equal {
+		t.Fatal("restored proposal must be equal to the original one")
+	}
+}
+
+func TestProposal_UnmarshalBinary_Truncated(t *testing.T) {
+	data, err := newTestProposal(t, 1).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = (&Proposal{}).UnmarshalBinary(data[:10])
+	if err == nil {
+		t.Fatal()
+	}
+}
+
+// Creates a proposal with claims and TSLs in reversed order
+// and checks that it is considered equal to the original one.
+func TestProposal_Equal_Reordered(t *testing.T) {
+	proposal := newTestProposal(t, 4)
+
+	reordered := &Proposal{
+		FrameIndex: proposal.FrameIndex,
+		Claims:     &geo.Claims{},
+		TSLs:       &geo.TSLs{},
+		Digest:     proposal.Digest,
+	}
+	for i := len(proposal.Claims.At) - 1; i >= 0; i-- {
+		_ = reordered.Claims.Add(proposal.Claims.At[i])
+		_ = reordered.TSLs.Add(proposal.TSLs.At[i])
+	}
+
+	equal, err := proposal.Equal(reordered)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !equal {
+		t.Fatal("reordered proposal must be equal to the original one")
+	}
+
+	// Original order must be preserved.
+	if proposal.Claims.At[0] != reordered.Claims.At[3] {
+		t.Fatal()
+	}
+}
+
+func TestProposal_Equal_Different(t *testing.T) {
+	proposal := newTestProposal(t, 2)
+	other := newTestProposal(t, 2)
+
+	equal, err := proposal.Equal(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if equal {
+		t.Fatal("proposals with different claims must not be equal")
+	}
+
+	other = newTestProposal(t, 0)
+	other.FrameIndex = 4
+	equal, _ = proposal.Equal(other)
+	if equal {
+		t.Fatal()
+	}
+}
@@ -25,6 +25,9 @@ var (
 // improve: write 1-2 bytes for empty block, now empty block contains 68B.
 type Chain struct {
 	storage *storage.AppendOnlyStorage
+
+	// Cache of the TSLs block numbers (see BlockWithTSL()).
+	tslsPresence *presenceIndex
 }
 
 func NewChain(datFilePath string) (chain *Chain, err error) {
@@ -33,7 +36,10 @@ func NewChain(datFilePath string) (chain *Chain, err error) {
 		return
 	}
 
-	chain = &Chain{storage: storageHandler}
+	chain = &Chain{
+		storage:      storageHandler,
+		tslsPresence: newPresenceIndex(settings.ChainTSLsPresenceIndexSize),
+	}
 	err = chain.ensureGenesisBlockPresence()
 	return
 }
@@ -72,7 +78,17 @@ func (chain *Chain) BlockWithClaim(TxID *transactions.TxID) (blockNumber uint64,
 
 // BlockWithTSL returns number of block in which tsl with specified transaction has been included.
 // If no tsl with specified transaction is present in chain - returns 0.
+// Results are cached (see presenceIndex).
 func (chain *Chain) BlockWithTSL(TxID *transactions.TxID) (blockNumber uint64, err error) {
+	return chain.tslsPresence.BlockNumber(TxID, chain.lookupBlockWithTSL)
+}
+
+// TSLsPresenceIndexStats returns counters of the TSLs presence cache.
+func (chain *Chain) TSLsPresenceIndexStats() PresenceIndexStats {
+	return chain.tslsPresence.Stats()
+}
+
+func (chain *Chain) lookupBlockWithTSL(TxID *transactions.TxID) (blockNumber uint64, err error) {
 	// todo: add indexing and remove ugly linear search
 
 	var totalBlocksCount = chain.Height()
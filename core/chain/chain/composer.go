@@ -2,8 +2,10 @@ package chain
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"geo-observers-blockchain/core/chain/block"
+	"geo-observers-blockchain/core/common"
 	"geo-observers-blockchain/core/common/errors"
 	"geo-observers-blockchain/core/common/types/hash"
 	"geo-observers-blockchain/core/network/communicator/observers/requests"
@@ -35,6 +37,9 @@ type Composer struct {
 	internalEventsBus chan interface{}
 
 	observers *external.Reporter
+
+	// Controls stopping of the internal events loop (see Stop()).
+	lifecycle common.Lifecycle
 }
 
 func NewComposer(reporter *external.Reporter) (composer *Composer) {
@@ -61,8 +66,14 @@ func (c *Composer) Run(globalErrorsFlow chan<- error) {
 		}
 	}
 
+	stop := c.lifecycle.Start()
+	defer c.lifecycle.Done()
+
 	for {
 		select {
+		case <-stop:
+			return
+
 		case event := <-c.internalEventsBus:
 			processErrorIfAny(
 				c.processInternalEvent(event))
@@ -70,6 +81,12 @@ func (c *Composer) Run(globalErrorsFlow chan<- error) {
 	}
 }
 
+// Stop interrupts internal events loop and waits until it is finished.
+// Implements common.Subsystem.
+func (c *Composer) Stop(ctx context.Context) error {
+	return c.lifecycle.Stop(ctx)
+}
+
 // SyncChain initialises chain synchronisation with majority of other observers.
 // In case if majority of the observers would agree on some version of the chain (reach consensus) -
 // current chain would be synchronised with the majority even in case if current chain version DIFFERS
@@ -0,0 +1,119 @@
+package chain
+
+import (
+	"container/list"
+	"geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/common/types/transactions"
+	"sync"
+)
+
+// PresenceIndexStats is a snapshot of the presence index counters.
+type PresenceIndexStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Size      int    `json:"size"`
+}
+
+// presenceIndex caches numbers of the blocks, in which transactions has been included.
+// It is bounded: in case if it is full - the least recently queried transaction is evicted.
+//
+// Only positive results are cached: chain is append only, so the block number of the transaction
+// never changes, but the transaction, that is absent now, might be included into the next block.
+// Cache misses are resolved via the authoritative lookup (the chain itself).
+type presenceIndex struct {
+	maxSize int
+
+	mutex sync.Mutex
+
+	// Most recently queried records are at the front.
+	order   *list.List
+	records map[transactions.TxID]*list.Element
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+type presenceRecord struct {
+	txID        transactions.TxID
+	blockNumber uint64
+}
+
+func newPresenceIndex(maxSize int) *presenceIndex {
+	return &presenceIndex{
+		maxSize: maxSize,
+		order:   list.New(),
+		records: make(map[transactions.TxID]*list.Element),
+	}
+}
+
+// BlockNumber returns number of the block, that contains the transaction.
+// In case if there is no cached record - "lookup" is used, and it's positive result is cached.
+// Lookup is called outside of the index lock.
+func (i *presenceIndex) BlockNumber(
+	txID *transactions.TxID, lookup func(*transactions.TxID) (uint64, error)) (blockNumber uint64, err error) {
+
+	if txID == nil {
+		err = errors.NilParameter
+		return
+	}
+
+	i.mutex.Lock()
+	element, isPresent := i.records[*txID]
+	if isPresent {
+		i.hits++
+		i.order.MoveToFront(element)
+		blockNumber = element.Value.(*presenceRecord).blockNumber
+		i.mutex.Unlock()
+		return
+	}
+
+	i.misses++
+	i.mutex.Unlock()
+
+	blockNumber, err = lookup(txID)
+	if err != nil || blockNumber == 0 {
+		return
+	}
+
+	i.add(txID, blockNumber)
+	return
+}
+
+func (i *presenceIndex) Stats() PresenceIndexStats {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	return PresenceIndexStats{
+		Hits:      i.hits,
+		Misses:    i.misses,
+		Evictions: i.evictions,
+		Size:      i.order.Len(),
+	}
+}
+
+func (i *presenceIndex) add(txID *transactions.TxID, blockNumber uint64) {
+	if i.maxSize <= 0 {
+		return
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	// Record might be added by the concurrent lookup.
+	element, isPresent := i.records[*txID]
+	if isPresent {
+		i.order.MoveToFront(element)
+		return
+	}
+
+	for i.order.Len() >= i.maxSize {
+		oldest := i.order.Back()
+		i.order.Remove(oldest)
+		delete(i.records, oldest.Value.(*presenceRecord).txID)
+		i.evictions++
+	}
+
+	i.records[*txID] = i.order.PushFront(&presenceRecord{txID: *txID, blockNumber: blockNumber})
+}
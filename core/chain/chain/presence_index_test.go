@@ -0,0 +1,86 @@
+package chain
+
+import (
+	"geo-observers-blockchain/core/common/types/transactions"
+	"testing"
+)
+
+// Overflows the index and checks that the least recently queried records are evicted,
+// and that the counters track hits, misses and evictions.
+func TestPresenceIndex_Eviction(t *testing.T) {
+	index := newPresenceIndex(2)
+
+	txIDs := make([]*transactions.TxID, 3)
+	blocks := make(map[transactions.TxID]uint64)
+	for i := range txIDs {
+		txID, err := transactions.NewRandomTxID(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		txIDs[i] = txID
+		blocks[*txID] = uint64(i + 1)
+	}
+
+	lookupsCount := 0
+	lookup := func(txID *transactions.TxID) (uint64, error) {
+		lookupsCount++
+		return blocks[*txID], nil
+	}
+
+	check := func(i int, expectedLookupsCount int) {
+		blockNumber, err := index.BlockNumber(txIDs[i], lookup)
+		if err != nil || blockNumber != uint64(i+1) {
+			t.Fatal("invalid block number")
+		}
+
+		if lookupsCount != expectedLookupsCount {
+			t.Fatal("unexpected lookup")
+		}
+	}
+
+	check(0, 1) // miss
+	check(1, 2) // miss
+	check(0, 2) // hit, 1 is now the least recently queried
+	check(2, 3) // miss, 1 is evicted
+	check(0, 3) // hit
+	check(2, 3) // hit
+	check(1, 4) // miss, 0 is evicted
+	check(0, 5) // miss
+
+	stats := index.Stats()
+	if stats.Hits != 3 || stats.Misses != 5 || stats.Evictions != 3 || stats.Size != 2 {
+		t.Fatal("invalid counters")
+	}
+}
+
+// Checks that absent transactions are not cached
+// (they might be included into the chain later).
+func TestPresenceIndex_AbsentIsNotCached(t *testing.T) {
+	index := newPresenceIndex(2)
+	txID, err := transactions.NewRandomTxID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blockNumber := uint64(0)
+	lookup := func(*transactions.TxID) (uint64, error) {
+		return blockNumber, nil
+	}
+
+	result, err := index.BlockNumber(txID, lookup)
+	if err != nil || result != 0 {
+		t.Fatal()
+	}
+
+	blockNumber = 5
+	result, err = index.BlockNumber(txID, lookup)
+	if err != nil || result != 5 {
+		t.Fatal("absent transaction must not be cached")
+	}
+
+	stats := index.Stats()
+	if stats.Hits != 0 || stats.Misses != 2 || stats.Size != 1 {
+		t.Fatal("invalid counters")
+	}
+}
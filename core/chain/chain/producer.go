@@ -2,12 +2,16 @@ package chain
 
 import (
 	"bytes"
+	"context"
+	e "crypto/ecdsa"
 	"fmt"
 	"geo-observers-blockchain/core/chain/block"
 	"geo-observers-blockchain/core/chain/pool"
 	"geo-observers-blockchain/core/chain/signatures"
+	"geo-observers-blockchain/core/common"
 	"geo-observers-blockchain/core/common/errors"
 	"geo-observers-blockchain/core/common/types/hash"
+	"geo-observers-blockchain/core/crypto/ecdsa"
 	"geo-observers-blockchain/core/crypto/keystore"
 	"geo-observers-blockchain/core/geo"
 	geoRequests "geo-observers-blockchain/core/network/communicator/geo/api/v0/requests"
@@ -37,6 +41,8 @@ type Producer struct {
 	IncomingRequestsCandidateDigest          chan *requests.CandidateDigestBroadcast
 	OutgoingResponsesCandidateDigestApprove  chan *responses.CandidateDigestApprove
 	IncomingResponsesCandidateDigestApprove  chan *responses.CandidateDigestApprove
+	OutgoingResponsesCandidateDigestReject   chan *responses.CandidateDigestReject
+	IncomingResponsesCandidateDigestReject   chan *responses.CandidateDigestReject
 	OutgoingRequestsBlockSignaturesBroadcast chan *requests.BlockSignaturesBroadcast
 	IncomingRequestsBlockSignatures          chan *requests.BlockSignaturesBroadcast
 	IncomingRequestsChainTop                 chan *requests.ChainTop
@@ -49,6 +55,7 @@ type Producer struct {
 	GEORequestsLastBlockHeight chan *geoRequests.LastBlockNumber
 	GEORequestsClaimIsPresent  chan *geoRequests.ClaimIsPresent
 	GEORequestsTSLIsPresent    chan *geoRequests.TSLIsPresent
+	GEORequestsTSLsArePresent  chan *geoRequests.TSLsArePresent
 	GEORequestsTSLGet          chan *geoRequests.TSLGet
 	GEORequestsTxStates        chan *geoRequests.TxsStates
 
@@ -63,6 +70,26 @@ type Producer struct {
 
 	chain     *Chain
 	nextBlock *block.Signed
+
+	// Digests of the next block, signed by the observers during the current round.
+	// Is recreated each time when the next block is replaced, signatures are verified on adding.
+	digests *signatures.DigestsCollector
+
+	// Chain has been synchronised with the other observers,
+	// so the GEO node requests could be responded with the data of the chain.
+	isChainSynced bool
+
+	// Digest, that references claims absent in the pool.
+	// Missing claims are requested from the proposer,
+	// and the digest is processed once more after some delay.
+	pendingDigest *requests.CandidateDigestBroadcast
+
+	// Receives reports of the remote observers, that has sent invalid signatures
+	// (see SetMisbehaviourReporter()). Might be nil.
+	misbehaviour external.MisbehaviourReporter
+
+	// Controls stopping of the internal events loop (see Stop()).
+	lifecycle common.Lifecycle
 }
 
 func NewProducer(
@@ -75,6 +102,8 @@ func NewProducer(
 		IncomingRequestsCandidateDigest:          make(chan *requests.CandidateDigestBroadcast, settings.ObserversMaxCount-1),
 		OutgoingResponsesCandidateDigestApprove:  make(chan *responses.CandidateDigestApprove, 1),
 		IncomingResponsesCandidateDigestApprove:  make(chan *responses.CandidateDigestApprove, settings.ObserversMaxCount-1),
+		OutgoingResponsesCandidateDigestReject:   make(chan *responses.CandidateDigestReject, 1),
+		IncomingResponsesCandidateDigestReject:   make(chan *responses.CandidateDigestReject, settings.ObserversMaxCount-1),
 		OutgoingRequestsBlockSignaturesBroadcast: make(chan *requests.BlockSignaturesBroadcast, 1),
 		IncomingRequestsBlockSignatures:          make(chan *requests.BlockSignaturesBroadcast, 1),
 		IncomingRequestsChainTop:                 make(chan *requests.ChainTop, 1),
@@ -87,6 +116,7 @@ func NewProducer(
 		GEORequestsLastBlockHeight: make(chan *geoRequests.LastBlockNumber, 1),
 		GEORequestsClaimIsPresent:  make(chan *geoRequests.ClaimIsPresent, 1),
 		GEORequestsTSLIsPresent:    make(chan *geoRequests.TSLIsPresent, 1),
+		GEORequestsTSLsArePresent:  make(chan *geoRequests.TSLsArePresent, 1),
 		GEORequestsTSLGet:          make(chan *geoRequests.TSLGet, 1),
 		GEORequestsTxStates:        make(chan *geoRequests.TxsStates, 1),
 
@@ -102,7 +132,16 @@ func NewProducer(
 	return
 }
 
+// SetMisbehaviourReporter makes the producer to report the remote observers, that has sent invalid signatures.
+// Must be called before Run().
+func (p *Producer) SetMisbehaviourReporter(reporter external.MisbehaviourReporter) {
+	p.misbehaviour = reporter
+}
+
 func (p *Producer) Run(globalErrorsFlow chan<- error) {
+	stop := p.lifecycle.Start()
+	defer p.lifecycle.Done()
+
 	go p.composer.Run(globalErrorsFlow)
 
 	conf, err := p.reporter.GetCurrentConfiguration()
@@ -117,7 +156,14 @@ func (p *Producer) Run(globalErrorsFlow chan<- error) {
 		panic(err)
 	}
 
-	syncResult := <-p.composer.SyncChain(p.chain)
+	var syncResult *EventSynchronizationFinished
+	select {
+	case <-stop:
+		return
+
+	case syncResult = <-p.composer.SyncChain(p.chain):
+	}
+
 	if syncResult.Error != nil {
 		globalErrorsFlow <- errors.SyncFailed
 		return
@@ -142,6 +188,7 @@ func (p *Producer) Run(globalErrorsFlow chan<- error) {
 		// todo: report fatal error instead of panic
 		panic(err)
 	}
+	p.isChainSynced = true
 
 	go p.poolClaims.Run(globalErrorsFlow)
 	go p.poolTSLs.Run(globalErrorsFlow)
@@ -150,6 +197,9 @@ func (p *Producer) Run(globalErrorsFlow chan<- error) {
 		select {
 		// todo: add case when observers configuration has changed
 
+		case <-stop:
+			return
+
 		case tick := <-p.IncomingEventTimeFrameEnded:
 			p.handleErrorIfAny(
 				p.processTick(tick, conf))
@@ -170,6 +220,10 @@ func (p *Producer) Run(globalErrorsFlow chan<- error) {
 			p.handleErrorIfAny(p.processGEOTSLIsPresentRequest(
 				reqTSLIsPresent))
 
+		case reqTSLsArePresent := <-p.GEORequestsTSLsArePresent:
+			p.handleErrorIfAny(p.processGEOTSLsArePresentRequest(
+				reqTSLsArePresent))
+
 		case reqTSLGet := <-p.GEORequestsTSLGet:
 			p.handleErrorIfAny(p.processGEOTSLGetRequest(
 				reqTSLGet))
@@ -181,6 +235,13 @@ func (p *Producer) Run(globalErrorsFlow chan<- error) {
 	}
 }
 
+// Stop interrupts internal events loop and waits until it is finished.
+// Composer and pools, that are launched by the producer, must be stopped separately.
+// Implements common.Subsystem.
+func (p *Producer) Stop(ctx context.Context) error {
+	return p.lifecycle.Stop(ctx)
+}
+
 func (p *Producer) processChainTopRequest(request *requests.ChainTop, conf *external.Configuration) (err error) {
 	requestedBlock, err := p.chain.BlockAt(request.LastBlockIndex)
 	if err != nil {
@@ -237,9 +298,18 @@ func (p *Producer) processBlockGenerationFlow(tick *ticker.EventTimeFrameEnd,
 		Body:       candidate,
 		Signatures: signatures.NewIndexedObserversSignatures(settings.ObserversMaxCount),
 	}
+	p.digests = signatures.NewDigestsCollector()
 
 	// Signing the block
 	sig, err := p.keystore.SignHash(p.nextBlock.Body.Hash)
+	if err != nil {
+		return
+	}
+
+	err = p.digests.Add(conf.CurrentObserverIndex, p.nextBlock.Body.Hash, sig, p.keystore.PublicKey())
+	if err != nil {
+		return
+	}
 	p.nextBlock.Signatures.At[conf.CurrentObserverIndex] = sig
 
 	err = p.distributeCandidateDigests()
@@ -279,7 +349,18 @@ func (p *Producer) processValidationFlow(
 			}
 			continue
 
+		case <-p.pendingDigestRetry():
+			request := p.pendingDigest
+			p.pendingDigest = nil
+
+			err = p.processIncomingDigest(request, tick, observersConf)
+			if err != nil {
+				// todo: report error and DO NOT STOP THE METHOD
+			}
+			continue
+
 		case <-time.After(p.finalStageTimeLeft(tick)):
+			p.pendingDigest = nil
 			return
 		}
 	}
@@ -309,6 +390,17 @@ func (p *Producer) processIncomingDigest(
 		return
 	}
 
+	missingClaims, err := p.missingClaims(request.Digest)
+	if err != nil {
+		return
+	}
+
+	if len(missingClaims) > 0 {
+		// Digest can't be matched until missing claims would be received from the proposer.
+		p.pendingDigest = request
+		return p.rejectDigest(request, missingClaims, conf)
+	}
+
 	candidate, err := p.generateBlockCandidateFromDigest(request.Digest, conf)
 	if err != nil {
 		return
@@ -341,6 +433,51 @@ func (p *Producer) processIncomingCandidateDigestApprove(
 	return
 }
 
+// processIncomingCandidateDigestReject sends claims, requested by the observer,
+// that was not able to match the digest of the proposed block.
+// Claims are sent via the claims pool broadcast flow, so the observer would add them into it's pool.
+func (p *Producer) processIncomingCandidateDigestReject(
+	response *responses.CandidateDigestReject,
+	conf *external.Configuration) (err error) {
+
+	if response == nil || conf == nil {
+		return errors.NilParameter
+	}
+
+	if p.nextBlock == nil {
+		return errors.InvalidBlockCandidateDigestReject
+	}
+
+	if !conf.Registry().IsValidIndex(response.ObserverIndex()) ||
+		response.ObserverIndex() == conf.CurrentObserverIndex {
+		return errors.InvalidBlockCandidateDigestReject
+	}
+
+	claims, err := p.nextBlock.Body.ClaimsByHashes(response.MissingClaimsHashes.At)
+	if err != nil {
+		// Observer requests claims, that are not included into the proposed block.
+		return errors.InvalidBlockCandidateDigestReject
+	}
+
+	for _, claim := range claims {
+		select {
+		case p.poolClaims.OutgoingRequestsInstanceBroadcast <- requests.NewPoolInstanceBroadcast(
+			[]uint16{response.ObserverIndex()}, claim):
+		default:
+			return errors.ChannelTransferringFailed
+		}
+	}
+
+	if settings.OutputBlocksProducerDebug {
+		p.log().WithFields(log.Fields{
+			"ObserverIndex": response.ObserverIndex(),
+			"ClaimsCount":   len(claims),
+		}).Debug("Missing claims sent")
+	}
+
+	return
+}
+
 func (p *Producer) processReceivedBlockSignatures(
 	request *requests.BlockSignaturesBroadcast,
 	tick *ticker.EventTimeFrameEnd,
@@ -359,6 +496,8 @@ func (p *Producer) processFinalStage() error {
 	// Signed generation period or block validation period has been finished.
 	// Even if proposed block is present and has collected some signatures - it MUST be dropped.
 	p.nextBlock = nil
+	p.digests = nil
+	p.pendingDigest = nil
 	return nil
 }
 
@@ -424,6 +563,55 @@ func (p *Producer) generateBlockCandidateFromPool(
 	return p.generateBlockCandidate(tsls, claims, conf, conf.CurrentObserverIndex)
 }
 
+// missingClaims returns hashes of the claims, that are referenced by the digest, but are absent in the pool.
+func (p *Producer) missingClaims(digest *block.Digest) (missing []hash.SHA256Container, err error) {
+	results, errorsChannel := p.poolClaims.MissingInstances(digest.ClaimsHashes.At)
+
+	select {
+	case missing = <-results:
+		err = <-errorsChannel
+
+	case <-time.After(time.Second):
+		err = errors.ClaimsPoolReadFailed
+	}
+
+	return
+}
+
+// rejectDigest reports to the proposer, that the digest can't be approved,
+// until missing claims would be received.
+func (p *Producer) rejectDigest(
+	request *requests.CandidateDigestBroadcast,
+	missingClaims []hash.SHA256Container,
+	conf *external.Configuration) (err error) {
+
+	select {
+	case p.OutgoingResponsesCandidateDigestReject <- responses.NewCandidateDigestReject(
+		request, conf.CurrentObserverIndex, missingClaims):
+	default:
+		err = errors.ChannelTransferringFailed
+	}
+
+	if settings.OutputBlocksProducerDebug {
+		p.log().WithFields(log.Fields{
+			"Index":              request.Digest.Index,
+			"MissingClaimsCount": len(missingClaims),
+		}).Debug("Candidate digest rejected, missing claims requested")
+	}
+
+	return
+}
+
+// pendingDigestRetry returns channel, that fires when pending digest must be processed once more.
+// Returns nil channel (that never fires) in case if there is no pending digest.
+func (p *Producer) pendingDigestRetry() <-chan time.Time {
+	if p.pendingDigest == nil {
+		return nil
+	}
+
+	return time.After(settings.ProducerMissingClaimsRetryPeriod)
+}
+
 // todo: this method is very similar to the the original block generation
 func (p *Producer) generateBlockCandidateFromDigest(
 	digest *block.Digest, conf *external.Configuration) (candidate *block.Body, err error) {
@@ -536,6 +724,7 @@ func (p *Producer) approveBlockCandidateAndPropagateSignature(
 		Body:       candidate,
 		Signatures: signatures.NewIndexedObserversSignatures(settings.ObserversMaxCount),
 	}
+	p.digests = signatures.NewDigestsCollector()
 
 	signature, err := p.keystore.SignHash(p.nextBlock.Body.Hash)
 	if err != nil {
@@ -612,6 +801,15 @@ func (p *Producer) collectResponsesAndProcessConsensus(
 				return nil
 			}
 
+		case responseReject := <-p.IncomingResponsesCandidateDigestReject:
+			err = p.processIncomingCandidateDigestReject(responseReject, conf)
+			if err == errors.InvalidBlockCandidateDigestReject {
+				// Ignore current response, but process the rest.
+				err = nil
+			}
+
+			return
+
 		case <-time.After(p.finalStageTimeLeft(tick)):
 			_ = p.processFinalStage()
 			return ErrLoopBreak
@@ -726,7 +924,7 @@ func (p *Producer) validateBlockCandidateDigestAndRelatedBlockCandidate(
 func (p *Producer) validateCandidateDigestSignatureResponse(
 	response *responses.CandidateDigestApprove, conf *external.Configuration) (err error) {
 
-	if p.nextBlock == nil {
+	if p.nextBlock == nil || p.digests == nil {
 		if settings.OutputBlocksProducerDebug {
 			p.log().Debug(
 				"validateCandidateDigestSignatureResponse: " +
@@ -736,7 +934,8 @@ func (p *Producer) validateCandidateDigestSignatureResponse(
 		return errors.InvalidBlockCandidateDigestApprove
 	}
 
-	if len(conf.Observers) < int(response.ObserverIndex()+1) {
+	remoteObserver, err := conf.Registry().ObserverByIndex(response.ObserverIndex())
+	if err != nil {
 		if settings.OutputBlocksProducerDebug {
 			p.log().Debug(
 				"validateCandidateDigestSignatureResponse: " +
@@ -746,7 +945,6 @@ func (p *Producer) validateCandidateDigestSignatureResponse(
 		return errors.InvalidBlockCandidateDigestApprove
 	}
 
-	remoteObserver := conf.Observers[response.ObserverIndex()]
 	if !settings.Conf.Debug {
 		// Prevent validation of it's own signatures,
 		// except in debug mode.
@@ -756,12 +954,13 @@ func (p *Producer) validateCandidateDigestSignatureResponse(
 		}
 	}
 
-	isValid := p.keystore.CheckExternalSignature(
-		p.nextBlock.Body.Hash, response.Signature, remoteObserver.PubKey)
-
-	if !isValid {
+	// Signature is verified by the digests collector.
+	// It also prevents the observer from signing several different digests during the round.
+	err = p.digests.Add(response.ObserverIndex(), p.nextBlock.Body.Hash, &response.Signature, remoteObserver.PubKey)
+	if err != nil {
 		if settings.OutputBlocksProducerDebug {
 			p.log().WithFields(log.Fields{
+				"Error":               err,
 				"RemoteObserverIndex": response.ObserverIndex(),
 				"PubKey":              remoteObserver.PubKey.X.String() + "; " + remoteObserver.PubKey.Y.String(),
 				"PubKey (S)":          response.Signature.S,
@@ -772,6 +971,7 @@ func (p *Producer) validateCandidateDigestSignatureResponse(
 					"received signature is not related to the generated block, or observer")
 		}
 
+		external.ReportMisbehaviour(p.misbehaviour, conf, response.ObserverIndex())
 		return errors.InvalidBlockCandidateDigestApprove
 	}
 
@@ -790,22 +990,39 @@ func (p *Producer) validateBlockSignaturesRequest(
 		return errors.InvalidBlockSignatures
 	}
 
+	signatures := make([]ecdsa.Signature, 0, len(request.Signatures.At))
+	pubKeys := make([]*e.PublicKey, 0, len(request.Signatures.At))
 	for i, sig := range request.Signatures.At {
 		if sig == nil {
 			continue
 		}
 
-		observer := conf.Observers[i]
-		isValid := p.keystore.CheckExternalSignature(p.nextBlock.Body.Hash, *sig, observer.PubKey)
+		pubKey, err := conf.Registry().PubKeyByIndex(uint16(i))
+		if err != nil {
+			return errors.InvalidBlockSignatures
+		}
+
+		signatures = append(signatures, *sig)
+		pubKeys = append(pubKeys, pubKey)
+	}
+
+	results, err := p.keystore.CheckExternalSignaturesBatch(p.nextBlock.Body.Hash, signatures, pubKeys)
+	if err != nil {
+		return errors.InvalidBlockSignatures
+	}
+
+	for i, isValid := range results {
 		if isValid == false {
 			if settings.OutputBlocksProducerDebug {
 				p.log().WithFields(log.Fields{
 					"BlockHash":  p.nextBlock.Body.Hash.Hex(),
-					"PubKey (S)": sig.S,
-					"PubKey (R)": sig.R,
+					"PubKey (S)": signatures[i].S,
+					"PubKey (R)": signatures[i].R,
 				}).Debug("validateBlockSignaturesRequest: signature check failed")
 			}
 
+			// Observer, that has broadcast the signatures, must have verified them.
+			external.ReportMisbehaviour(p.misbehaviour, conf, request.ObserverIndex())
 			return errors.InvalidBlockSignatures
 		}
 	}
@@ -864,6 +1081,7 @@ func (p *Producer) processReceivedBlockHashBroadcast(request *requests.BlockHash
 	p.log().Debug("Collision detected") // todo: add blocks hashes
 
 	// Load last generated block data
+	p.isChainSynced = false
 	syncResult := <-p.composer.SyncChain(p.chain)
 	if syncResult.Error != nil {
 		err = errors.SyncFailed
@@ -876,6 +1094,7 @@ func (p *Producer) processReceivedBlockHashBroadcast(request *requests.BlockHash
 		// todo: report fatal error instead of panic
 		return err
 	}
+	p.isChainSynced = true
 
 	p.log().Debug("Additional chain sync done") // todo: add blocks hashes
 	return
@@ -941,6 +1160,7 @@ func (p *Producer) commitBlock(tick *ticker.EventTimeFrameEnd) (err error) {
 	}
 
 	p.nextBlock = nil
+	p.digests = nil
 	return
 }
 
@@ -54,7 +54,20 @@ func (p *Producer) processGEOClaimIsPresentRequest(req *geoRequests.ClaimIsPrese
 	return
 }
 
+// processGEOTSLIsPresentRequest responds with the presence info of the TSL.
+// Internal errors and not synchronised chain are reported to the GEO node via the response status
+// (see geoResponses.TSLPresenceStatus), so the node does not treat such TSL as absent.
 func (p *Producer) processGEOTSLIsPresentRequest(req *geoRequests.TSLIsPresent) (err error) {
+	if !p.isChainSynced {
+		req.ResponseChannel() <- geoResponses.NewTSLIsPresentWithStatus(geoResponses.TSLStatusNotSynced)
+		return
+	}
+
+	reportInternalError := func(err error) error {
+		req.ResponseChannel() <- geoResponses.NewTSLIsPresentWithStatus(geoResponses.TSLStatusInternalError)
+		return err
+	}
+
 	resultsChannel, errorsChannel := p.poolTSLs.ContainsInstance(req.TxID)
 
 	presentInPool := false
@@ -63,21 +76,67 @@ func (p *Producer) processGEOTSLIsPresentRequest(req *geoRequests.TSLIsPresent)
 		presentInPool = result
 
 	case err := <-errorsChannel:
-		return p.reportGEORequestError(req.RequestWithResponse, err)
+		return reportInternalError(err)
 
 	case <-time.After(time.Second * 2):
-		return p.reportGEORequestError(req.RequestWithResponse, errors.TimeoutFired)
+		return reportInternalError(errors.TimeoutFired)
 	}
 
 	blockNumber, err := p.chain.BlockWithTSL(req.TxID)
 	if err != nil {
-		return p.reportGEORequestError(req.RequestWithResponse, err)
+		return reportInternalError(err)
 	}
 
-	response := &geoResponses.TSLIsPresent{
-		PresentInBlock: blockNumber,
-		PresentInPool:  presentInPool,
+	req.ResponseChannel() <- geoResponses.NewTSLIsPresent(presentInPool, blockNumber)
+	return
+}
+
+// processGEOTSLsArePresentRequest responds with the presence info of each one requested TSL.
+// Statuses are reported per item in the same way as processGEOTSLIsPresentRequest() does.
+func (p *Producer) processGEOTSLsArePresentRequest(req *geoRequests.TSLsArePresent) (err error) {
+	response := &geoResponses.TSLsArePresent{
+		At: make([]*geoResponses.TSLIsPresent, len(req.TxIDs.At)),
+	}
+
+	respondWithStatus := func(status geoResponses.TSLPresenceStatus) {
+		for i := range response.At {
+			response.At[i] = geoResponses.NewTSLIsPresentWithStatus(status)
+		}
+		req.ResponseChannel() <- response
 	}
+
+	if !p.isChainSynced {
+		respondWithStatus(geoResponses.TSLStatusNotSynced)
+		return
+	}
+
+	// All the transactions are checked against the pool at once,
+	// so the request costs only one round trip to the pool, regardless of its size.
+	var presentInPool []bool
+	resultsChannel, errorsChannel := p.poolTSLs.ContainsInstances(req.TxIDs.At)
+	select {
+	case presentInPool = <-resultsChannel:
+	case err = <-errorsChannel:
+	case <-time.After(time.Second * 2):
+		err = errors.TimeoutFired
+	}
+
+	if err != nil || len(presentInPool) != len(req.TxIDs.At) {
+		respondWithStatus(geoResponses.TSLStatusInternalError)
+		return
+	}
+
+	for i, TxID := range req.TxIDs.At {
+		blockNumber, err := p.chain.BlockWithTSL(TxID)
+		if err != nil {
+			// Failure of one lookup must not affect the statuses of the rest transactions.
+			response.At[i] = geoResponses.NewTSLIsPresentWithStatus(geoResponses.TSLStatusInternalError)
+			continue
+		}
+
+		response.At[i] = geoResponses.NewTSLIsPresent(presentInPool[i], blockNumber)
+	}
+
 	req.ResponseChannel() <- response
 	return
 }
@@ -0,0 +1,60 @@
+package chain
+
+import (
+	"geo-observers-blockchain/core/common/types/transactions"
+	geoRequests "geo-observers-blockchain/core/network/communicator/geo/api/v0/requests"
+	geoResponses "geo-observers-blockchain/core/network/communicator/geo/api/v0/responses"
+	"testing"
+)
+
+// Until the chain is synchronised, presence of the TSL can't be checked:
+// GEO node must receive "not synced" status instead of "absent".
+func TestProducer_TSLIsPresent_NotSynced(t *testing.T) {
+	TxID, err := transactions.NewRandomTxID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Producer{}
+	req := geoRequests.NewTSLIsPresent(TxID)
+	err = p.processGEOTSLIsPresentRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := (<-req.ResponseChannel()).(*geoResponses.TSLIsPresent)
+	if response.Status != geoResponses.TSLStatusNotSynced || response.PresentInPool || response.PresentInBlock != 0 {
+		t.Fatal()
+	}
+}
+
+// The "not synced" status must be reported for each one requested TSL,
+// and no lookup must be done before (the producer has no pool and no chain attached).
+func TestProducer_TSLsArePresent_NotSynced(t *testing.T) {
+	TxIDs := make([]*transactions.TxID, 3)
+	for i := range TxIDs {
+		TxID, err := transactions.NewRandomTxID(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		TxIDs[i] = TxID
+	}
+
+	p := &Producer{}
+	req := geoRequests.NewTSLsArePresent(TxIDs)
+	err := p.processGEOTSLsArePresentRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := (<-req.ResponseChannel()).(*geoResponses.TSLsArePresent)
+	if len(response.At) != len(TxIDs) {
+		t.Fatal(len(response.At))
+	}
+
+	for _, item := range response.At {
+		if item.Status != geoResponses.TSLStatusNotSynced || item.PresentInPool || item.PresentInBlock != 0 {
+			t.Fatal()
+		}
+	}
+}
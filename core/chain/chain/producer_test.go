@@ -0,0 +1,64 @@
+package chain
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"geo-observers-blockchain/core/chain/block"
+	"geo-observers-blockchain/core/chain/signatures"
+	"geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/common/types/hash"
+	"geo-observers-blockchain/core/crypto/keystore"
+	observersNet "geo-observers-blockchain/core/network/communicator/observers"
+	"geo-observers-blockchain/core/network/communicator/observers/responses"
+	"geo-observers-blockchain/core/network/external"
+	"geo-observers-blockchain/core/settings"
+	"testing"
+	"time"
+)
+
+// Checks that the observer, that has approved the block candidate with the signature,
+// that does not belong to it, is reported to the blacklist.
+func TestProducer_CandidateDigestApprove_ForgedSignatureReported(t *testing.T) {
+	defaultConf := settings.Conf
+	settings.Conf = &settings.Settings{}
+	defer func() { settings.Conf = defaultConf }()
+
+	ks, err := keystore.NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	remoteKey, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := external.NewConfiguration(0, []*external.Observer{
+		external.NewObserver("10.0.0.1", 3000, ks.PublicKey()),
+		external.NewObserver("10.0.0.2", 3000, &remoteKey.PublicKey),
+	})
+
+	blacklist := observersNet.NewBlacklist(1, time.Minute, time.Minute)
+	p := &Producer{
+		keystore:  ks,
+		nextBlock: &block.Signed{Body: &block.Body{Hash: hash.NewSHA256Container([]byte("block"))}},
+		digests:   signatures.NewDigestsCollector(),
+	}
+	p.SetMisbehaviourReporter(blacklist)
+
+	// Signature is made with the key of the current observer, not with the key of the remote one.
+	forged, err := ks.SignHash(p.nextBlock.Body.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = p.validateCandidateDigestSignatureResponse(responses.NewCandidateDigestApprove(nil, 1, *forged), conf)
+	if err != errors.InvalidBlockCandidateDigestApprove {
+		t.Fatal(err)
+	}
+
+	if !blacklist.IsBlacklisted("10.0.0.2") {
+		t.Fatal("observer with forged signature must be blacklisted")
+	}
+}
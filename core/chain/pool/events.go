@@ -26,3 +26,15 @@ type EventInstanceIsPresentRequest struct {
 	Result chan bool
 	TxID   *transactions.TxID
 }
+
+type EventInstancesArePresentRequest struct {
+	Errors  chan error
+	Results chan []bool
+	TxIDs   []*transactions.TxID
+}
+
+type EventMissingInstancesRequest struct {
+	Errors  chan error
+	Results chan []hash.SHA256Container
+	Hashes  []hash.SHA256Container
+}
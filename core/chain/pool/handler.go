@@ -1,7 +1,9 @@
 package pool
 
 import (
+	"context"
 	"encoding"
+	"geo-observers-blockchain/core/common"
 	"geo-observers-blockchain/core/common/errors"
 	"geo-observers-blockchain/core/common/types/hash"
 	"geo-observers-blockchain/core/common/types/transactions"
@@ -47,8 +49,19 @@ type Handler struct {
 	// Instances collection handler.
 	pool *Pool
 
+	// Revision of the observers configuration, that was used on the previous events processing round.
+	// Is used to detect configuration changes.
+	confRevision uint64
+
 	// External observers configuration reporter.
 	reporter *external.Reporter
+
+	// Receives reports of the remote observers, that has sent invalid instances
+	// (see SetMisbehaviourReporter()). Might be nil.
+	misbehaviour external.MisbehaviourReporter
+
+	// Controls stopping of the internal events loop (see Stop()).
+	lifecycle common.Lifecycle
 }
 
 func NewHandler(reporter *external.Reporter) *Handler {
@@ -70,12 +83,20 @@ func NewHandler(reporter *external.Reporter) *Handler {
 			chan interface{},
 			1), // one event per round might be processed, no need for more.
 
-		pool:     NewPool(),
+		pool:     NewPool(settings.PoolMaxRecords),
 		reporter: reporter,
 	}
 }
 
+// SetMisbehaviourReporter makes the handler to report the remote observers, that has sent invalid instances.
+// Must be called before Run().
+func (h *Handler) SetMisbehaviourReporter(reporter external.MisbehaviourReporter) {
+	h.misbehaviour = reporter
+}
+
 func (h *Handler) Run(globalErrorsFlow chan<- error) {
+	stop := h.lifecycle.Start()
+	defer h.lifecycle.Done()
 
 	processErrorIfAny := func(err error) {
 		if err != nil {
@@ -90,7 +111,12 @@ func (h *Handler) Run(globalErrorsFlow chan<- error) {
 			return
 		}
 
+		h.processConfigurationChangeIfAny(conf)
+
 		select {
+		case <-stop:
+			return
+
 		case instance := <-h.IncomingInstances:
 			processErrorIfAny(
 				h.processNewInstance(instance, conf))
@@ -105,7 +131,7 @@ func (h *Handler) Run(globalErrorsFlow chan<- error) {
 
 		case _ = <-time.After(kTimeoutItemsSynchronisation):
 			processErrorIfAny(
-				h.processItemsSynchronisation())
+				h.processItemsSynchronisation(conf))
 
 		case event := <-h.internalEventsBus:
 			h.processInternalEvent(event)
@@ -113,6 +139,12 @@ func (h *Handler) Run(globalErrorsFlow chan<- error) {
 	}
 }
 
+// Stop interrupts internal events loop and waits until it is finished.
+// Implements common.Subsystem.
+func (h *Handler) Stop(ctx context.Context) error {
+	return h.lifecycle.Stop(ctx)
+}
+
 // BlockReadyInstances returns channel with items of the pool,
 // that has been approved to be synchronised by the majority of the observers pools.
 // This instances are ready to be included into the next block.
@@ -171,6 +203,57 @@ func (h *Handler) ContainsInstance(
 	return
 }
 
+// ContainsInstances reports for each one transaction from the "TxIDs" if it is present in the pool.
+// Unlike ContainsInstance(), requires only one round trip to the pool, regardless of the transactions count.
+func (h *Handler) ContainsInstances(
+	TxIDs []*transactions.TxID) (results chan []bool, errors chan error) {
+	errors = make(chan error, 1)
+	results = make(chan []bool, 1)
+
+	h.internalEventsBus <- &EventInstancesArePresentRequest{
+		Errors:  errors,
+		Results: results,
+		TxIDs:   TxIDs,
+	}
+
+	return
+}
+
+// MissingInstances returns hashes of the instances, that are absent in the pool.
+// Is used to detect which instances must be requested from the block proposer.
+func (h *Handler) MissingInstances(
+	hashes []hash.SHA256Container) (results chan []hash.SHA256Container, errors chan error) {
+	errors = make(chan error, 1)
+	results = make(chan []hash.SHA256Container, 1)
+
+	h.internalEventsBus <- &EventMissingInstancesRequest{
+		Errors:  errors,
+		Results: results,
+		Hashes:  hashes,
+	}
+
+	return
+}
+
+// Stats is a snapshot of the pool state.
+type Stats struct {
+	RecordsCount int `json:"records"`
+
+	// See Pool.ApprovalHistogram().
+	ApprovalHistogram []int `json:"approval_histogram"`
+}
+
+// Stats returns current state of the pool.
+// It is safe to call this method from any goroutine.
+func (h *Handler) Stats() (stats Stats) {
+	stats.ApprovalHistogram = h.pool.ApprovalHistogram()
+	for _, count := range stats.ApprovalHistogram {
+		stats.RecordsCount += count
+	}
+
+	return
+}
+
 // processNewInstance handles newly received claim or TSL from the GEO node:
 // validates it for the correctness, adds to the pool and
 // tries to broadcast the instance to the rest of observers.
@@ -178,6 +261,11 @@ func (h *Handler) processNewInstance(i instance, conf *external.Configuration) (
 	// todo: add instance validation here.
 	//       (attach crypto-backend, that is able to process lamport signatures)
 
+	identity, err := conf.Registry().IdentityByIndex(conf.CurrentObserverIndex)
+	if err != nil {
+		return
+	}
+
 	record, err := h.pool.Add(i)
 	if err != nil {
 		return
@@ -185,14 +273,14 @@ func (h *Handler) processNewInstance(i instance, conf *external.Configuration) (
 
 	// Mark record as approved by the observers,
 	// that has added it to the pool.
-	record.Approves[conf.CurrentObserverIndex] = true
+	record.Approve(identity)
 
 	// Setting this flag to true indicates that pool must try to
 	// sync it's items with the rest observers ASAP.
 	h.hasUnapprovedItems = true
 
-	h.log().WithField("PoolSize", len(h.pool.index)).Debug("Instance added")
-	return h.requestRecordBroadcast(record)
+	h.log().WithField("PoolSize", h.pool.Count()).Debug("Instance added")
+	return h.requestRecordBroadcast(record, conf)
 }
 
 // processNewInstanceRequest handles newly received claim or TSL from the external observer:
@@ -208,8 +296,19 @@ func (h *Handler) processNewInstanceRequest(
 		return
 	}
 
-	// todo: add instance validation here.
+	senderIdentity, err := conf.Registry().IdentityByIndex(r.ObserverIndex())
+	if err != nil {
+		return
+	}
+
+	// todo: add lamport signatures validation here.
 	//       (attach crypto-backend, that is able to process lamport signatures)
+	err = validate(r.Instance.(instance))
+	if err != nil {
+		// Observers must broadcast only the instances, that has been validated by them.
+		external.ReportMisbehaviour(h.misbehaviour, conf, r.ObserverIndex())
+		return
+	}
 
 	data, err := r.Instance.(encoding.BinaryMarshaler).MarshalBinary()
 	if err != nil {
@@ -228,15 +327,17 @@ func (h *Handler) processNewInstanceRequest(
 			return
 		}
 
-		for i := range record.Approves {
-			record.Approves[i] = true
+		for _, observer := range conf.Observers {
+			if observer != nil {
+				record.Approve(observer.Identity())
+			}
 		}
 
 	} else if err == errors.Collision {
 		// In case if record is present - than it seems that request has been received
 		// from the observer, that repeats it's request.
 		// In this case - only vote of this observer must be rewritten.
-		record.Approves[r.ObserverIndex()] = true
+		record.Approve(senderIdentity)
 	}
 
 	// Send approve to the observer, that has generated the request.
@@ -262,23 +363,38 @@ func (h *Handler) processNewInstanceResponse(
 		return
 	}
 
-	record.Approves[r.ObserverIndex()] = true
-	return
+	return record.ApproveFrom(conf.Registry(), int(r.ObserverIndex()), true)
+}
+
+// processConfigurationChangeIfAny discards votes of the observers,
+// that are not present in the new observers configuration.
+func (h *Handler) processConfigurationChangeIfAny(conf *external.Configuration) {
+	if conf.Revision == h.confRevision {
+		return
+	}
+
+	h.confRevision = conf.Revision
+	discarded := h.pool.DiscardInactiveVotes(conf.Registry())
+	if discarded > 0 {
+		// Some records might lose majority of approves, so they must be synchronised once more.
+		h.hasUnapprovedItems = true
+		h.log().WithField("DiscardedVotes", discarded).Info("Observers configuration changed")
+	}
 }
 
 // processItemsSynchronisation is launched from time to time.
 // Checks if pool has items that need to be synchronized with some external observers.
 // For each such item processItemsSynchronisation tries to perform synchronization flow.
-func (h *Handler) processItemsSynchronisation() (err error) {
+func (h *Handler) processItemsSynchronisation(conf *external.Configuration) (err error) {
 	if h.hasUnapprovedItems == false {
 		return
 	}
 
 	anyItemsAreNotInSync := false
-	for _, record := range h.pool.index {
+	for _, record := range h.pool.Records() {
 		if record.IsMajorityApprovesCollected() == false {
 			anyItemsAreNotInSync = true
-			err = h.requestRecordBroadcast(record)
+			err = h.requestRecordBroadcast(record, conf)
 			if err != nil {
 				return
 			}
@@ -294,13 +410,18 @@ func (h *Handler) processItemsSynchronisation() (err error) {
 
 // requestRecordBroadcast checks which observers has not approved which items,
 // and sens them to corresponding observers.
+// Approves are mapped to the indexes of the observers of the current configuration.
 // todo: think what to do with the items, that can't be sync too long.
-func (h *Handler) requestRecordBroadcast(record *Record) (err error) {
+func (h *Handler) requestRecordBroadcast(record *Record, conf *external.Configuration) (err error) {
 	record.LastSyncAttempt = time.Now()
 
-	destinationObservers := make([]uint16, 0, settings.ObserversMaxCount)
-	for i := 0; i < settings.ObserversMaxCount; i++ {
-		if record.Approves[i] == false {
+	destinationObservers := make([]uint16, 0, len(conf.Observers))
+	for i, observer := range conf.Observers {
+		if observer == nil {
+			continue
+		}
+
+		if !record.IsApprovedBy(observer.Identity()) {
 			destinationObservers = append(destinationObservers, uint16(i))
 		}
 	}
@@ -330,21 +451,19 @@ func (h *Handler) processInternalEvent(event interface{}) {
 	case *EventInstanceIsPresentRequest:
 		h.containsInstance(event.(*EventInstanceIsPresentRequest))
 
+	case *EventInstancesArePresentRequest:
+		h.containsInstances(event.(*EventInstancesArePresentRequest))
+
+	case *EventMissingInstancesRequest:
+		h.missingInstances(event.(*EventMissingInstancesRequest))
+
 	default:
 		h.log().Error("Unexpected event type occurred: ", reflect.TypeOf(event).String())
 	}
 }
 
 func (h *Handler) blockReadyItems(event *EventBlockReadyInstancesRequest) {
-	blockReadyItems := &instances{}
-
-	for _, record := range h.pool.index {
-		if record.IsMajorityApprovesCollected() == true {
-			blockReadyItems.At = append(blockReadyItems.At, record.Instance)
-		}
-	}
-
-	event.Results <- blockReadyItems
+	event.Results <- &instances{At: h.pool.ApprovedInstances()}
 }
 
 func (h *Handler) blockReadyItemsByHashes(event *EventBlockReadyInstancesByHashesRequest) {
@@ -372,15 +491,17 @@ func (h *Handler) dropItemsByHashes(event *EventItemsDroppingRequest) {
 }
 
 func (h *Handler) containsInstance(event *EventInstanceIsPresentRequest) {
-	for _, instance := range h.pool.index {
-		if instance.Instance.TxID().Compare(event.TxID) {
-			event.Result <- true
-			event.Errors <- nil
-			return
-		}
-	}
+	event.Result <- h.pool.ContainsTxIDs([]*transactions.TxID{event.TxID})[0]
+	event.Errors <- nil
+}
+
+func (h *Handler) containsInstances(event *EventInstancesArePresentRequest) {
+	event.Results <- h.pool.ContainsTxIDs(event.TxIDs)
+	event.Errors <- nil
+}
 
-	event.Result <- false
+func (h *Handler) missingInstances(event *EventMissingInstancesRequest) {
+	event.Results <- h.pool.MissingHashes(event.Hashes)
 	event.Errors <- nil
 }
 
@@ -5,7 +5,9 @@ import (
 	"geo-observers-blockchain/core/common/errors"
 	"geo-observers-blockchain/core/common/types/hash"
 	"geo-observers-blockchain/core/common/types/transactions"
+	"geo-observers-blockchain/core/network/external"
 	"geo-observers-blockchain/core/settings"
+	"sync"
 	"time"
 )
 
@@ -15,74 +17,280 @@ type instance interface {
 	TxID() *transactions.TxID
 }
 
+// validatable is implemented by the instances, that are able to check their own semantic correctness
+// (for example, geo.Claim). Such instances are validated before being added to the pool.
+type validatable interface {
+	Validate() error
+}
+
 type instances struct {
 	At []instance
 }
 
+// Vote is the response of the external observer to the record synchronisation request.
+type Vote uint8
+
+const (
+	// No response has been received from the observer yet.
+	// Unset votes are counted neither as approves, nor as rejects.
+	VoteUnset Vote = iota
+	VoteApprove
+	VoteReject
+)
+
 type Record struct {
 	Instance instance
 
-	//Approves collected from external observers.
-	Approves [settings.KObserversMaxCount]bool
+	// Type of the instance (constants.DataTypeRequestClaimBroadcast or constants.DataTypeRequestTSLBroadcast).
+	// Zero for the instances of unknown types.
+	DataType uint8
+
+	// Votes collected from external observers (observers that has not responded yet are absent).
+	// Votes are keyed by the observers identities (not by their indexes),
+	// so they remain attributed to the same observers even if configuration has been changed
+	// and indexes of the observers has been shifted.
+	Votes map[external.ObserverIdentity]Vote
+
+	// Time when the vote of each observer has been received.
+	// Is used for the votes audit.
+	VotesReceived map[external.ObserverIdentity]time.Time
+
+	// Protects Votes and VotesReceived: votes are set by the network handler,
+	// while the records might be inspected concurrently (see Pool.ApprovalHistogram()).
+	votesMutex sync.RWMutex
 
 	// Time of last attempt to send this Record to the external observers.
 	LastSyncAttempt time.Time
+
+	// Time when the record has been created.
+	Created time.Time
+}
+
+func NewRecord(instance instance) *Record {
+	// Instances of unknown types are still accepted, but are not distinguishable by the type.
+	dataType, _ := instanceDataType(instance)
+
+	return &Record{
+		Instance:      instance,
+		DataType:      dataType,
+		Votes:         make(map[external.ObserverIdentity]Vote),
+		VotesReceived: make(map[external.ObserverIdentity]time.Time),
+		Created:       time.Now(),
+	}
+}
+
+// Approve marks the record as approved by the observer with the identity specified.
+// Empty identity is ignored.
+func (r *Record) Approve(identity external.ObserverIdentity) {
+	if identity == "" {
+		return
+	}
+
+	r.vote(identity, VoteApprove)
+}
+
+// Reject marks the record as explicitly rejected by the observer with the identity specified.
+// Empty identity is ignored.
+func (r *Record) Reject(identity external.ObserverIdentity) {
+	if identity == "" {
+		return
+	}
+
+	r.vote(identity, VoteReject)
+}
+
+// ApproveFrom sets the vote of the observer with the index specified.
+// The index is resolved to the observer identity via the registry of the current configuration.
+// Returns errors.InvalidObserverIndex in case if there is no such observer in configuration.
+func (r *Record) ApproveFrom(registry *external.ObserverRegistry, observerIndex int, approved bool) (err error) {
+	if observerIndex < 0 || observerIndex >= settings.ObserversMaxCount {
+		return errors.InvalidObserverIndex
+	}
+
+	identity, err := registry.IdentityByIndex(uint16(observerIndex))
+	if err != nil {
+		return
+	}
+
+	if approved {
+		r.vote(identity, VoteApprove)
+	} else {
+		r.vote(identity, VoteReject)
+	}
+	return
+}
+
+// DiscardInactiveVotes removes votes of the observers, that are absent in the registry specified.
+// Must be called on each configuration change, so the votes of the observers,
+// that has left the configuration, are not counted anymore.
+// Returns amount of removed votes.
+func (r *Record) DiscardInactiveVotes(registry *external.ObserverRegistry) (count int) {
+	r.votesMutex.Lock()
+	defer r.votesMutex.Unlock()
+
+	for identity := range r.Votes {
+		if !registry.ContainsIdentity(identity) {
+			delete(r.Votes, identity)
+			delete(r.VotesReceived, identity)
+			count++
+		}
+	}
+
+	return
+}
+
+func (r *Record) vote(identity external.ObserverIdentity, vote Vote) {
+	r.votesMutex.Lock()
+	defer r.votesMutex.Unlock()
+
+	r.Votes[identity] = vote
+	r.VotesReceived[identity] = time.Now()
+}
+
+// VoteOf returns vote of the observer with the identity specified.
+// Returns VoteUnset in case if observer has not responded yet.
+func (r *Record) VoteOf(identity external.ObserverIdentity) Vote {
+	r.votesMutex.RLock()
+	defer r.votesMutex.RUnlock()
+
+	return r.Votes[identity]
 }
 
+func (r *Record) IsApprovedBy(identity external.ObserverIdentity) bool {
+	return r.VoteOf(identity) == VoteApprove
+}
+
+// age returns the time the record is present in the pool.
+// Records without creation time are aged by the last synchronisation attempt.
+// If none of these times are known - the age is considered unknown (ok is false).
+func (r *Record) age(now time.Time) (age time.Duration, ok bool) {
+	since := r.Created
+	if since.IsZero() {
+		since = r.LastSyncAttempt
+	}
+
+	if since.IsZero() {
+		return 0, false
+	}
+
+	return now.Sub(since), true
+}
+
+// IsMajorityApprovesCollected returns true if consensus count of observers has approved the record.
+// Unset votes and rejects are not counted.
 func (r *Record) IsMajorityApprovesCollected() bool {
-	var (
-		positiveVotesPresent = 0
-		negativeVotesPresent = 0
-	)
+	return r.ApprovesCount() >= settings.ObserversConsensusCount
+}
 
-	for _, vote := range r.Approves {
-		if vote == true {
-			positiveVotesPresent++
-			if positiveVotesPresent >= settings.ObserversConsensusCount {
-				return true
-			}
+// IsMajorityRejectsCollected returns true if so many observers has explicitly rejected the record,
+// that consensus count of approves could not be collected anymore.
+// Observers that has not responded yet are not considered as rejecting ones.
+func (r *Record) IsMajorityRejectsCollected() bool {
+	return r.RejectsCount() > settings.ObserversMaxCount-settings.ObserversConsensusCount
+}
 
-		} else {
-			negativeVotesPresent++
-			if negativeVotesPresent >= settings.ObserversMaxCount-settings.ObserversConsensusCount {
-				return false
-			}
+// ApprovesCount returns amount of positive votes collected.
+func (r *Record) ApprovesCount() int {
+	return r.votesCount(VoteApprove)
+}
+
+// RejectsCount returns amount of explicit negative votes collected.
+func (r *Record) RejectsCount() int {
+	return r.votesCount(VoteReject)
+}
+
+func (r *Record) votesCount(expected Vote) (count int) {
+	r.votesMutex.RLock()
+	defer r.votesMutex.RUnlock()
+
+	for _, vote := range r.Votes {
+		if vote == expected {
+			count++
 		}
 	}
 
-	return false
+	return
 }
 
+// Pool is safe for concurrent use:
+// it is accessed from the network receive path and from the block assembly path simultaneously.
 type Pool struct {
+	// If true - the oldest record, that has not collected majority of approves yet,
+	// is evicted from the full pool, when the new record is added.
+	// Otherwise - new records are rejected when the pool is full.
+	EvictOnFull bool
+
 	index map[hash.SHA256Container]*Record
+	mutex sync.RWMutex
+
+	// Max amount of records in the pool. Zero means no limit.
+	maxRecords int
 }
 
-func NewPool() *Pool {
+// NewPool creates the pool, that could contain up to "maxRecords" records.
+// Zero "maxRecords" means no limit.
+func NewPool(maxRecords int) *Pool {
 	return &Pool{
-		index: make(map[hash.SHA256Container]*Record),
+		EvictOnFull: settings.PoolEvictOnFull,
+		index:       make(map[hash.SHA256Container]*Record),
+		maxRecords:  maxRecords,
+	}
+}
+
+// validate checks semantic correctness of the instance, in case if it is able to validate itself (see validatable).
+func validate(instance instance) error {
+	if v, isValidatable := instance.(validatable); isValidatable {
+		return v.Validate()
 	}
+
+	return nil
 }
 
+// Add creates the record for the instance.
+// Instances, that are able to validate themselves (see validatable), are validated first.
+// Returns errors.Collision in case if the same instance is already present.
+// In case if the pool is full - the oldest record without majority of approves is evicted (see EvictOnFull),
+// otherwise (or if there is no such record) errors.MaxCountReached is returned.
 func (pool *Pool) Add(instance instance) (record *Record, err error) {
+	err = validate(instance)
+	if err != nil {
+		return
+	}
+
 	data, err := instance.MarshalBinary()
 	if err != nil {
 		return
 	}
 
 	key := hash.NewSHA256Container(data)
-	_, err = pool.ByHash(&key)
-	if err != errors.NotFound {
+	record = NewRecord(instance)
+
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	err = pool.insert(key, record)
+	if err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// insert adds the record with the key specified to the index,
+// according to the rules of Add() (collisions, max records count and eviction).
+// Must be called under the mutex.
+func (pool *Pool) insert(key hash.SHA256Container, record *Record) (err error) {
+	_, isPresent := pool.index[key]
+	if isPresent {
 		// Exactly the same item is already present in the pool.
 		// It must not be replaced by the new value, to prevent votes dropping.
-		err = errors.Collision
-		return
-
-	} else {
-		err = nil
+		return errors.Collision
 	}
 
-	record = &Record{
-		Instance: instance,
+	if pool.maxRecords > 0 && len(pool.index) >= pool.maxRecords {
+		if !pool.EvictOnFull || !pool.evictOldestUnapproved() {
+			return errors.MaxCountReached
+		}
 	}
 
 	pool.index[key] = record
@@ -90,10 +298,16 @@ func (pool *Pool) Add(instance instance) (record *Record, err error) {
 }
 
 func (pool *Pool) Remove(hash *hash.SHA256Container) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
 	delete(pool.index, *hash)
 }
 
 func (pool *Pool) ByHash(hash *hash.SHA256Container) (record *Record, err error) {
+	pool.mutex.RLock()
+	defer pool.mutex.RUnlock()
+
 	record, isPresent := pool.index[*hash]
 	if !isPresent {
 		return nil, errors.NotFound
@@ -101,3 +315,230 @@ func (pool *Pool) ByHash(hash *hash.SHA256Container) (record *Record, err error)
 
 	return
 }
+
+// ByHashOfType returns the record with the hash specified,
+// only in case if its instance is of the data type specified.
+// Returns errors.NotFound otherwise.
+func (pool *Pool) ByHashOfType(hash *hash.SHA256Container, dataType uint8) (record *Record, err error) {
+	record, err = pool.ByHash(hash)
+	if err != nil {
+		return
+	}
+
+	if record.DataType != dataType {
+		return nil, errors.NotFound
+	}
+
+	return
+}
+
+// CountOfType returns amount of records with the instances of the data type specified.
+func (pool *Pool) CountOfType(dataType uint8) (count int) {
+	pool.ForEach(func(_ hash.SHA256Container, r *Record) bool {
+		if r.DataType == dataType {
+			count++
+		}
+
+		return true
+	})
+
+	return
+}
+
+// Count returns amount of records present in the pool.
+func (pool *Pool) Count() int {
+	pool.mutex.RLock()
+	defer pool.mutex.RUnlock()
+
+	return len(pool.index)
+}
+
+// Records returns snapshot of the records present in the pool.
+// The pool itself could be safely modified while the snapshot is iterated.
+func (pool *Pool) Records() (records []*Record) {
+	pool.mutex.RLock()
+	defer pool.mutex.RUnlock()
+
+	records = make([]*Record, 0, len(pool.index))
+	for _, record := range pool.index {
+		records = append(records, record)
+	}
+
+	return
+}
+
+// ForEach calls "f" for each record of the pool, until "f" returns false.
+// Records are iterated under the read lock,
+// so "f" must not call back into the pool (it would lead to the deadlock on any modification attempt).
+// Use Records() in case if the pool must be modified during iteration.
+func (pool *Pool) ForEach(f func(hash hash.SHA256Container, r *Record) bool) {
+	pool.mutex.RLock()
+	defer pool.mutex.RUnlock()
+
+	for key, record := range pool.index {
+		if !f(key, record) {
+			return
+		}
+	}
+}
+
+// ApprovedInstances returns instances of all records, that has collected majority of approves.
+func (pool *Pool) ApprovedInstances() (approved []instance) {
+	pool.ForEach(func(_ hash.SHA256Container, r *Record) bool {
+		if r.IsMajorityApprovesCollected() {
+			approved = append(approved, r.Instance)
+		}
+
+		return true
+	})
+
+	return
+}
+
+// EvictOlderThan removes records, that are present in the pool for longer than "d",
+// but still has not collected majority of approves (are not synchronised with other observers).
+// Records that has collected majority of approves are not affected.
+// Returns amount of removed records. Is intended to be called periodically.
+func (pool *Pool) EvictOlderThan(d time.Duration) (count int) {
+	now := time.Now()
+
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	for key, record := range pool.index {
+		if record.IsMajorityApprovesCollected() {
+			continue
+		}
+
+		age, ok := record.age(now)
+		if ok && age > d {
+			delete(pool.index, key)
+			count++
+		}
+	}
+
+	return
+}
+
+// DiscardInactiveVotes removes votes of the observers, that are absent in the registry specified,
+// from all records of the pool (see Record.DiscardInactiveVotes).
+// Returns amount of removed votes.
+func (pool *Pool) DiscardInactiveVotes(registry *external.ObserverRegistry) (count int) {
+	pool.ForEach(func(_ hash.SHA256Container, r *Record) bool {
+		count += r.DiscardInactiveVotes(registry)
+		return true
+	})
+
+	return
+}
+
+// evictOldestUnapproved removes the oldest record, that has not collected majority of approves.
+// Records of unknown age are considered as the oldest ones.
+// Returns false if there is no such record. Must be called under the write lock.
+func (pool *Pool) evictOldestUnapproved() bool {
+	var (
+		now        = time.Now()
+		oldestKey  hash.SHA256Container
+		oldestAge  time.Duration
+		isSelected = false
+	)
+
+	for key, record := range pool.index {
+		if record.IsMajorityApprovesCollected() {
+			continue
+		}
+
+		age, ok := record.age(now)
+		if !ok {
+			delete(pool.index, key)
+			return true
+		}
+
+		if !isSelected || age > oldestAge {
+			oldestKey, oldestAge, isSelected = key, age, true
+		}
+	}
+
+	if isSelected {
+		delete(pool.index, oldestKey)
+	}
+
+	return isSelected
+}
+
+// MissingHashes returns hashes from the "hashes", for which there are no records in the pool.
+func (pool *Pool) MissingHashes(hashes []hash.SHA256Container) (missing []hash.SHA256Container) {
+	pool.mutex.RLock()
+	defer pool.mutex.RUnlock()
+
+	for _, key := range hashes {
+		_, isPresent := pool.index[key]
+		if !isPresent {
+			missing = append(missing, key)
+		}
+	}
+
+	return
+}
+
+// ContainsTxIDs reports for each transaction from the "TxIDs" if there is a record of it in the pool.
+// The pool is scanned only once, regardless of how many transactions are requested.
+func (pool *Pool) ContainsTxIDs(TxIDs []*transactions.TxID) (present []bool) {
+	present = make([]bool, len(TxIDs))
+	if len(TxIDs) == 0 {
+		return
+	}
+
+	requested := make(map[[transactions.TxIDBinarySize]byte][]int, len(TxIDs))
+	for i, TxID := range TxIDs {
+		if TxID == nil {
+			continue
+		}
+
+		requested[TxID.Bytes] = append(requested[TxID.Bytes], i)
+	}
+
+	pool.ForEach(func(_ hash.SHA256Container, r *Record) bool {
+		TxID := r.Instance.TxID()
+		if TxID == nil {
+			return true
+		}
+
+		indexes, isRequested := requested[TxID.Bytes]
+		if !isRequested {
+			return true
+		}
+
+		for _, i := range indexes {
+			present[i] = true
+		}
+
+		delete(requested, TxID.Bytes)
+		return len(requested) > 0
+	})
+
+	return
+}
+
+// ApprovalHistogram returns amount of records grouped by the approves count.
+// Element with index N (N < consensus count) contains amount of records with exactly N approves,
+// the last element contains amount of records, that has collected consensus count of approves or more.
+// Is intended for monitoring of the pool convergence.
+// It is safe to call this method from any goroutine: votes of each record are read under the record's lock.
+func (pool *Pool) ApprovalHistogram() (histogram []int) {
+	histogram = make([]int, settings.ObserversConsensusCount+1)
+
+	pool.mutex.RLock()
+	defer pool.mutex.RUnlock()
+
+	for _, record := range pool.index {
+		approves := record.ApprovesCount()
+		if approves > settings.ObserversConsensusCount {
+			approves = settings.ObserversConsensusCount
+		}
+
+		histogram[approves]++
+	}
+
+	return
+}
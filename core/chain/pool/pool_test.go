@@ -0,0 +1,759 @@
+package pool
+
+import (
+	"geo-observers-blockchain/core/chain/block"
+	"geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/common/types/hash"
+	"geo-observers-blockchain/core/common/types/transactions"
+	"geo-observers-blockchain/core/geo"
+	observersNet "geo-observers-blockchain/core/network/communicator/observers"
+	"geo-observers-blockchain/core/network/communicator/observers/constants"
+	"geo-observers-blockchain/core/network/communicator/observers/requests"
+	"geo-observers-blockchain/core/network/communicator/observers/responses"
+	"geo-observers-blockchain/core/network/external"
+	"geo-observers-blockchain/core/network/external/externaltest"
+	"geo-observers-blockchain/core/settings"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestInstance(t *testing.T) instance {
+	txID, err := transactions.NewRandomTxID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claim := &geo.Claim{TxUUID: txID, Members: &geo.ClaimMembers{}}
+	_ = claim.Members.Add(geo.NewClaimMember(0))
+	return claim
+}
+
+func newTestTSL(t *testing.T) instance {
+	txID, err := transactions.NewRandomTxID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tsl := &geo.TSL{TxUUID: txID, Members: &geo.TSLMembers{}}
+	_ = tsl.Members.Add(geo.NewTSLMember(0))
+	return tsl
+}
+
+// Adds records with various approves count and checks the histogram.
+func TestPool_ApprovalHistogram(t *testing.T) {
+	defaultConsensusCount := settings.ObserversConsensusCount
+	settings.ObserversConsensusCount = 3
+	defer func() { settings.ObserversConsensusCount = defaultConsensusCount }()
+
+	pool := NewPool(0)
+	for _, approvesCount := range []int{0, 0, 1, 2, 2, 2, 3, 5} {
+		record, err := pool.Add(newTestInstance(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < approvesCount; i++ {
+			record.Approve(external.ObserverIdentity(strconv.Itoa(i)))
+		}
+	}
+
+	histogram := pool.ApprovalHistogram()
+	expected := []int{2, 1, 3, 2}
+	if len(histogram) != len(expected) {
+		t.Fatal()
+	}
+
+	for i := range expected {
+		if histogram[i] != expected[i] {
+			t.Fatal("invalid histogram")
+		}
+	}
+}
+
+// Votes are set by the network handler, while the histogram is requested by the monitoring concurrently
+// (must be run with the race detector).
+func TestPool_ApprovalHistogram_Concurrent(t *testing.T) {
+	pool := NewPool(0)
+	record, err := pool.Add(newTestInstance(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			record.Approve(external.ObserverIdentity(strconv.Itoa(i)))
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		pool.ApprovalHistogram()
+	}
+	<-done
+
+	if record.ApprovesCount() != 100 {
+		t.Fatal()
+	}
+}
+
+// Simulates lagging approver, that lacks one of the claims of the proposed block:
+// approver rejects the digest with the missing claim hash,
+// proposer sends the claim back via the pool broadcast request,
+// after which approver is able to collect all claims of the digest.
+func TestHandler_MissingInstances_DigestRejectFlow(t *testing.T) {
+	const (
+		proposerIndex = 0
+		approverIndex = 1
+	)
+
+	body := &block.Body{
+		AuthorObserverIndex: proposerIndex,
+		Claims:              &geo.Claims{},
+		TSLs:                &geo.TSLs{},
+	}
+	for i := 0; i < 2; i++ {
+		_ = body.Claims.Add(newTestInstance(t).(*geo.Claim))
+	}
+
+	digest, err := body.GenerateDigest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	approver := NewHandler(nil)
+	_, err = approver.pool.Add(body.Claims.At[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	missingInstances := func() []hash.SHA256Container {
+		event := &EventMissingInstancesRequest{
+			Errors:  make(chan error, 1),
+			Results: make(chan []hash.SHA256Container, 1),
+			Hashes:  digest.ClaimsHashes.At,
+		}
+
+		approver.processInternalEvent(event)
+		if <-event.Errors != nil {
+			t.Fatal()
+		}
+		return <-event.Results
+	}
+
+	// Approver rejects the digest.
+	missing := missingInstances()
+	if len(missing) != 1 || missing[0] != digest.ClaimsHashes.At[1] {
+		t.Fatal("missing claim is not detected")
+	}
+
+	data, err := responses.NewCandidateDigestReject(
+		requests.NewCandidateDigestBroadcast(digest), approverIndex, missing).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reject := &responses.CandidateDigestReject{}
+	err = reject.UnmarshalBinary(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Proposer responds with the missing claims.
+	claims, err := body.ClaimsByHashes(reject.MissingClaimsHashes.At)
+	if err != nil || len(claims) != 1 {
+		t.Fatal()
+	}
+
+	data, err = requests.NewPoolInstanceBroadcast([]uint16{reject.ObserverIndex()}, claims[0]).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	delivery := &requests.PoolInstanceBroadcast{}
+	err = delivery.UnmarshalBinary(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delivery.SetObserverIndex(proposerIndex)
+
+	conf := external.NewConfiguration(0, externaltest.NewObservers(t, 2))
+	conf.CurrentObserverIndex = approverIndex
+
+	err = approver.processNewInstanceRequest(delivery, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Approver is now able to match the digest.
+	if len(missingInstances()) != 0 {
+		t.Fatal("claim is still missing")
+	}
+
+	event := &EventBlockReadyInstancesByHashesRequest{
+		Instances: make(chan *instances, 1),
+		Errors:    make(chan error, 1),
+		Hashes:    digest.ClaimsHashes.At,
+	}
+	approver.processInternalEvent(event)
+	select {
+	case items := <-event.Instances:
+		if len(items.At) != 2 {
+			t.Fatal()
+		}
+
+	case err := <-event.Errors:
+		t.Fatal(err)
+	}
+}
+
+// Collects votes, then reorders observers in configuration (their indexes are shifted),
+// and collects one more vote: all votes must remain attributed to the observers, that has sent them.
+func TestHandler_Approves_ObserversReindexed(t *testing.T) {
+	observers := externaltest.NewObservers(t, 4)
+	conf := external.NewConfiguration(0, observers)
+	conf.CurrentObserverIndex = 0
+
+	handler := NewHandler(nil)
+	i := newTestInstance(t)
+	err := handler.processNewInstance(i, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-handler.OutgoingRequestsInstanceBroadcast
+
+	data, err := i.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := hash.NewSHA256Container(data)
+
+	approve := func(observerIndex uint16, conf *external.Configuration) {
+		response := responses.NewPoolInstanceBroadcastApprove(nil, observerIndex, &key)
+		err := handler.processNewInstanceResponse(response, conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Observer 1 approves the record.
+	approve(1, conf)
+
+	// Configuration has been changed: the order of the observers is reversed.
+	reindexed := external.NewConfiguration(1, []*external.Observer{
+		observers[3], observers[2], observers[1], observers[0]})
+	reindexed.CurrentObserverIndex = 3
+
+	// Observer 2 (now with index 1) approves the record.
+	approve(1, reindexed)
+
+	record, err := handler.pool.ByHash(&key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if record.ApprovesCount() != 3 ||
+		!record.IsApprovedBy(observers[0].Identity()) ||
+		!record.IsApprovedBy(observers[1].Identity()) ||
+		!record.IsApprovedBy(observers[2].Identity()) ||
+		record.IsApprovedBy(observers[3].Identity()) {
+		t.Fatal("votes are attributed to the wrong observers")
+	}
+
+	// Only observer 3 (now with index 0) must be requested for the approve.
+	err = handler.requestRecordBroadcast(record, reindexed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := <-handler.OutgoingRequestsInstanceBroadcast
+	if len(request.DestinationObservers()) != 1 || request.DestinationObservers()[0] != 0 {
+		t.Fatal("invalid destination observers")
+	}
+}
+
+// Checks that the observer, that has broadcast invalid instance, is reported to the blacklist,
+// and the instance is not added to the pool.
+func TestHandler_ProcessNewInstanceRequest_InvalidInstanceReported(t *testing.T) {
+	observers := externaltest.NewObservers(t, 3)
+	observers[1].Host = "10.0.0.2"
+	observers[2].Host = "10.0.0.3"
+	conf := external.NewConfiguration(0, observers)
+
+	blacklist := observersNet.NewBlacklist(1, time.Minute, time.Minute)
+	handler := NewHandler(nil)
+	handler.SetMisbehaviourReporter(blacklist)
+
+	invalid := requests.NewPoolInstanceBroadcast(nil,
+		&geo.Claim{TxUUID: transactions.NewEmptyTxID(), Members: &geo.ClaimMembers{}})
+	invalid.SetObserverIndex(1)
+
+	err := handler.processNewInstanceRequest(invalid, conf)
+	if err == nil || handler.pool.Count() != 0 {
+		t.Fatal("invalid instance must be rejected")
+	}
+
+	if !blacklist.IsBlacklisted("10.0.0.2") {
+		t.Fatal("observer, that has sent invalid instance, must be blacklisted")
+	}
+
+	valid := requests.NewPoolInstanceBroadcast(nil, newTestInstance(t))
+	valid.SetObserverIndex(2)
+
+	err = handler.processNewInstanceRequest(valid, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if blacklist.IsBlacklisted("10.0.0.3") {
+		t.Fatal()
+	}
+}
+
+// Checks that invalid claims are not added to the pool.
+func TestPool_Add_Validation(t *testing.T) {
+	pool := NewPool(0)
+	_, err := pool.Add(&geo.Claim{TxUUID: transactions.NewEmptyTxID(), Members: &geo.ClaimMembers{}})
+	if err != geo.ErrEmptyTxID {
+		t.Fatal("invalid claim must be rejected")
+	}
+
+	if len(pool.index) != 0 {
+		t.Fatal()
+	}
+}
+
+// Hammers the pool from several goroutines simultaneously.
+// Is intended to be run with the race detector enabled (go test -race).
+func TestPool_ConcurrentAccess(t *testing.T) {
+	const (
+		workers    = 8
+		iterations = 100
+	)
+
+	// Instances are generated upfront: test helpers must not call t.Fatal from the non-test goroutines.
+	keys := make([][]hash.SHA256Container, workers)
+	items := make([][]instance, workers)
+	for w := 0; w < workers; w++ {
+		for i := 0; i < iterations; i++ {
+			item := newTestInstance(t)
+			data, err := item.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			items[w] = append(items[w], item)
+			keys[w] = append(keys[w], hash.NewSHA256Container(data))
+		}
+	}
+
+	pool := NewPool(0)
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			for i := 0; i < iterations; i++ {
+				_, _ = pool.Add(items[w][i])
+				_, _ = pool.ByHash(&keys[w][i])
+				_ = pool.MissingHashes(keys[w][:i+1])
+				_ = pool.Records()
+
+				if i%2 == 0 {
+					pool.Remove(&keys[w][i])
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if pool.Count() != workers*iterations/2 {
+		t.Fatal()
+	}
+}
+
+// Checks that all records are iterated and that iteration stops when the callback returns false.
+func TestPool_ForEach(t *testing.T) {
+	pool := NewPool(0)
+	for i := 0; i < 5; i++ {
+		_, err := pool.Add(newTestInstance(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	visited := make(map[hash.SHA256Container]bool)
+	pool.ForEach(func(key hash.SHA256Container, r *Record) bool {
+		visited[key] = true
+		return true
+	})
+	if len(visited) != 5 {
+		t.Fatal("all records must be visited")
+	}
+
+	calls := 0
+	pool.ForEach(func(key hash.SHA256Container, r *Record) bool {
+		calls++
+		return calls < 2
+	})
+	if calls != 2 {
+		t.Fatal("iteration must be stopped early")
+	}
+}
+
+// Checks that only records with majority of approves are reported as approved.
+func TestPool_ApprovedInstances(t *testing.T) {
+	defaultConsensusCount := settings.ObserversConsensusCount
+	settings.ObserversConsensusCount = 2
+	defer func() { settings.ObserversConsensusCount = defaultConsensusCount }()
+
+	pool := NewPool(0)
+	var expected []instance
+	for _, approvesCount := range []int{0, 1, 2, 3} {
+		record, err := pool.Add(newTestInstance(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < approvesCount; i++ {
+			record.Approve(external.ObserverIdentity(strconv.Itoa(i)))
+		}
+
+		if approvesCount >= 2 {
+			expected = append(expected, record.Instance)
+		}
+	}
+
+	approved := pool.ApprovedInstances()
+	if len(approved) != len(expected) {
+		t.Fatal()
+	}
+
+	for _, e := range expected {
+		isPresent := false
+		for _, a := range approved {
+			if a == e {
+				isPresent = true
+			}
+		}
+
+		if !isPresent {
+			t.Fatal("approved instance is missing")
+		}
+	}
+}
+
+// Collects majority of approves, then one of the approvers leaves the configuration.
+// Its vote must be discounted and the majority must be lost.
+func TestRecord_DiscardInactiveVotes(t *testing.T) {
+	defaultConsensusCount := settings.ObserversConsensusCount
+	settings.ObserversConsensusCount = 2
+	defer func() { settings.ObserversConsensusCount = defaultConsensusCount }()
+
+	observers := externaltest.NewObservers(t, 3)
+	conf := external.NewConfiguration(0, observers)
+
+	pool := NewPool(0)
+	record, err := pool.Add(newTestInstance(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if record.ApproveFrom(conf.Registry(), len(observers), true) != errors.InvalidObserverIndex {
+		t.Fatal("vote of unknown observer must be rejected")
+	}
+	if record.ApproveFrom(conf.Registry(), -1, true) != errors.InvalidObserverIndex {
+		t.Fatal("vote of unknown observer must be rejected")
+	}
+
+	for _, index := range []int{0, 2} {
+		err = record.ApproveFrom(conf.Registry(), index, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !record.IsMajorityApprovesCollected() {
+		t.Fatal()
+	}
+	if record.VotesReceived[observers[2].Identity()].IsZero() {
+		t.Fatal("vote time must be recorded")
+	}
+
+	// Observer 2 leaves, the rest observers remain (with shifted indexes).
+	newConf := external.NewConfiguration(1, []*external.Observer{observers[1], observers[0]})
+	if pool.DiscardInactiveVotes(newConf.Registry()) != 1 {
+		t.Fatal()
+	}
+
+	if record.IsMajorityApprovesCollected() ||
+		!record.IsApprovedBy(observers[0].Identity()) ||
+		record.IsApprovedBy(observers[2].Identity()) {
+		t.Fatal("vote of the left observer must be discounted")
+	}
+}
+
+// Checks that the record without enough responses is distinguished from the rejected one.
+func TestRecord_Votes(t *testing.T) {
+	defaultMaxCount := settings.ObserversMaxCount
+	defaultConsensusCount := settings.ObserversConsensusCount
+	settings.ObserversMaxCount = 4
+	settings.ObserversConsensusCount = 3
+	defer func() {
+		settings.ObserversMaxCount = defaultMaxCount
+		settings.ObserversConsensusCount = defaultConsensusCount
+	}()
+
+	// Not enough votes yet: nothing is decided.
+	record := NewRecord(newTestInstance(t))
+	record.Approve("0")
+	record.Reject("1")
+	if record.IsMajorityApprovesCollected() || record.IsMajorityRejectsCollected() {
+		t.Fatal("record must be undecided")
+	}
+	if record.VoteOf("1") != VoteReject || record.VoteOf("2") != VoteUnset {
+		t.Fatal()
+	}
+
+	// The rest of the observers approve.
+	record.Approve("2")
+	record.Approve("3")
+	if !record.IsMajorityApprovesCollected() || record.IsMajorityRejectsCollected() {
+		t.Fatal("record must be approved")
+	}
+
+	// Majority rejected: consensus count of approves could not be collected anymore.
+	record = NewRecord(newTestInstance(t))
+	record.Approve("0")
+	record.Reject("1")
+	record.Reject("2")
+	if record.IsMajorityApprovesCollected() || !record.IsMajorityRejectsCollected() {
+		t.Fatal("record must be rejected")
+	}
+
+	// Vote might be changed by the observer.
+	record.Approve("2")
+	if record.IsMajorityRejectsCollected() || record.ApprovesCount() != 2 || record.RejectsCount() != 1 {
+		t.Fatal()
+	}
+}
+
+// Fills the pool up to the capacity and checks that new records are rejected.
+func TestPool_Add_RejectOnFull(t *testing.T) {
+	pool := NewPool(2)
+	pool.EvictOnFull = false
+
+	for i := 0; i < 2; i++ {
+		_, err := pool.Add(newTestInstance(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := pool.Add(newTestInstance(t))
+	if err != errors.MaxCountReached || pool.Count() != 2 {
+		t.Fatal("new record must be rejected")
+	}
+}
+
+// Fills the pool up to the capacity and checks that the oldest unapproved record is evicted.
+// Approved records must never be evicted.
+func TestPool_Add_EvictOnFull(t *testing.T) {
+	defaultConsensusCount := settings.ObserversConsensusCount
+	settings.ObserversConsensusCount = 1
+	defer func() { settings.ObserversConsensusCount = defaultConsensusCount }()
+
+	pool := NewPool(3)
+	pool.EvictOnFull = true
+
+	add := func(age time.Duration, approved bool) *Record {
+		record, err := pool.Add(newTestInstance(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		record.Created = time.Now().Add(-age)
+		if approved {
+			record.Approve("observer")
+		}
+		return record
+	}
+
+	approved := add(time.Hour*2, true)
+	oldest := add(time.Hour, false)
+	newest := add(time.Minute, false)
+
+	added := add(0, false)
+	if pool.Count() != 3 || isTestRecordPresent(t, pool, oldest) {
+		t.Fatal("the oldest unapproved record must be evicted")
+	}
+
+	for _, record := range []*Record{approved, newest, added} {
+		if !isTestRecordPresent(t, pool, record) {
+			t.Fatal("record must remain in the pool")
+		}
+	}
+
+	// Pool full of approved records: nothing to evict.
+	for _, record := range []*Record{newest, added} {
+		record.Approve("observer")
+	}
+
+	_, err := pool.Add(newTestInstance(t))
+	if err != errors.MaxCountReached {
+		t.Fatal("new record must be rejected")
+	}
+}
+
+// Fills the pool with claims and TSLs and checks that records are distinguished by the type.
+func TestPool_MixedTypes(t *testing.T) {
+	pool := NewPool(0)
+	var claim, tsl *Record
+	for i := 0; i < 3; i++ {
+		record, err := pool.Add(newTestInstance(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		claim = record
+	}
+
+	for i := 0; i < 2; i++ {
+		record, err := pool.Add(newTestTSL(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tsl = record
+	}
+
+	if pool.CountOfType(constants.DataTypeRequestClaimBroadcast) != 3 ||
+		pool.CountOfType(constants.DataTypeRequestTSLBroadcast) != 2 ||
+		pool.CountOfType(constants.DataTypeRequestDigestBroadcast) != 0 {
+		t.Fatal("invalid count of type")
+	}
+
+	for _, c := range []struct {
+		record   *Record
+		dataType uint8
+		other    uint8
+	}{
+		{claim, constants.DataTypeRequestClaimBroadcast, constants.DataTypeRequestTSLBroadcast},
+		{tsl, constants.DataTypeRequestTSLBroadcast, constants.DataTypeRequestClaimBroadcast},
+	} {
+		data, err := c.record.Instance.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		key := hash.NewSHA256Container(data)
+		record, err := pool.ByHashOfType(&key, c.dataType)
+		if err != nil || record != c.record {
+			t.Fatal("record must be found by its own type")
+		}
+
+		_, err = pool.ByHashOfType(&key, c.other)
+		if err != errors.NotFound {
+			t.Fatal("record must not be found by the other type")
+		}
+	}
+}
+
+// Adds fresh, stale and approved stale records and checks that only unapproved stale records are evicted.
+func TestPool_EvictOlderThan(t *testing.T) {
+	defaultConsensusCount := settings.ObserversConsensusCount
+	settings.ObserversConsensusCount = 1
+	defer func() { settings.ObserversConsensusCount = defaultConsensusCount }()
+
+	pool := NewPool(0)
+	add := func(age time.Duration, approved bool) *Record {
+		record, err := pool.Add(newTestInstance(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		record.Created = time.Now().Add(-age)
+		if approved {
+			record.Approve("observer")
+		}
+		return record
+	}
+
+	fresh := add(time.Second, false)
+	stale := add(time.Hour, false)
+	approved := add(time.Hour, true)
+
+	staleBySyncAttempt := add(0, false)
+	staleBySyncAttempt.Created = time.Time{}
+	staleBySyncAttempt.LastSyncAttempt = time.Now().Add(-time.Hour)
+
+	unknownAge := add(0, false)
+	unknownAge.Created = time.Time{}
+
+	if pool.EvictOlderThan(time.Minute) != 2 {
+		t.Fatal()
+	}
+
+	for _, record := range []*Record{fresh, approved, unknownAge} {
+		if !isTestRecordPresent(t, pool, record) {
+			t.Fatal("record must remain in the pool")
+		}
+	}
+
+	for _, record := range []*Record{stale, staleBySyncAttempt} {
+		if isTestRecordPresent(t, pool, record) {
+			t.Fatal("record must be evicted")
+		}
+	}
+
+	if pool.EvictOlderThan(time.Minute) != 0 {
+		t.Fatal()
+	}
+}
+
+func isTestRecordPresent(t *testing.T, pool *Pool, record *Record) bool {
+	data, err := record.Instance.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := hash.NewSHA256Container(data)
+	_, err = pool.ByHash(&key)
+	return err == nil
+}
+
+func TestHandler_ContainsInstances(t *testing.T) {
+	handler := NewHandler(nil)
+
+	present := newTestTSL(t)
+	_, err := handler.pool.Add(present)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	absent := newTestTSL(t)
+	TxIDs := []*transactions.TxID{absent.TxID(), present.TxID(), nil, present.TxID()}
+
+	event := &EventInstancesArePresentRequest{
+		Errors:  make(chan error, 1),
+		Results: make(chan []bool, 1),
+		TxIDs:   TxIDs,
+	}
+	handler.processInternalEvent(event)
+	if <-event.Errors != nil {
+		t.Fatal()
+	}
+
+	results := <-event.Results
+	expected := []bool{false, true, false, true}
+	if len(results) != len(expected) {
+		t.Fatal(len(results))
+	}
+
+	for i := range expected {
+		if results[i] != expected[i] {
+			t.Fatal(i)
+		}
+	}
+}
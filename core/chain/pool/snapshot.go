@@ -0,0 +1,284 @@
+package pool
+
+import (
+	"geo-observers-blockchain/core/common"
+	"geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/common/types/hash"
+	"geo-observers-blockchain/core/network/external"
+	"geo-observers-blockchain/core/settings"
+	"geo-observers-blockchain/core/utils"
+	"io"
+	"time"
+)
+
+const (
+	// Max size of the binary representation of one instance in the snapshot.
+	// Snapshot is considered malformed if greater size is declared.
+	kSnapshotInstanceMaxSize = 1024 * 1024 * 4
+
+	// Max size of the observer identity in the snapshot.
+	kSnapshotIdentityMaxSize = 1024
+)
+
+// Snapshot writes all records of the pool (instances, votes and timestamps) to the writer,
+// so the pool could be restored after the observer restart (see Restore()).
+//
+// Format:
+//
+//	snapshot: [records count: uint32] [record]...
+//	record:   [data type: uint8] [instance size: uint32] [instance]
+//	          [created: uint64] [last sync attempt: uint64] [votes count: uint16] [vote]...
+//	vote:     [identity size: uint16] [identity] [vote: uint8] [received: uint64]
+//
+// Times are stored as unix nanoseconds, zero time is stored as 0.
+func (pool *Pool) Snapshot(w io.Writer) (err error) {
+	records := pool.Records()
+
+	_, err = w.Write(utils.MarshalUint32(uint32(len(records))))
+	if err != nil {
+		return
+	}
+
+	for _, record := range records {
+		err = writeRecord(w, record)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// Restore reads records, written by Snapshot(), from the reader and adds them to the pool.
+// Instances are created by the factory, according to the stored data type
+// (in case if factory is nil - NewInstance is used).
+// Restored records are validated and inserted in the same way as by Add():
+// in case if the snapshot is malformed or contains invalid instance - the pool is left untouched.
+// Records, that are already present in the pool, are left untouched.
+// In case if the pool is full (and no record could be evicted, see EvictOnFull) -
+// the rest records are dropped and errors.MaxCountReached is returned.
+func (pool *Pool) Restore(r io.Reader, factory InstancesFactory) (err error) {
+	if factory == nil {
+		factory = NewInstance
+	}
+
+	countData := make([]byte, common.Uint32ByteSize)
+	err = readSnapshotData(r, countData)
+	if err != nil {
+		return
+	}
+
+	count, err := utils.UnmarshalUint32(countData)
+	if err != nil {
+		return
+	}
+
+	// Records are inserted in the order of the snapshot,
+	// so the eviction (if any) is the same as it would be on adding them one by one.
+	keys := make([]hash.SHA256Container, 0, count)
+	records := make([]*Record, 0, count)
+	for i := uint32(0); i < count; i++ {
+		key, record, err := readRecord(r, factory)
+		if err != nil {
+			return err
+		}
+
+		err = validate(record.Instance)
+		if err != nil {
+			return err
+		}
+
+		keys = append(keys, key)
+		records = append(records, record)
+	}
+
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	for i, record := range records {
+		err = pool.insert(keys[i], record)
+		if err == errors.Collision {
+			err = nil
+			continue
+		}
+
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func writeRecord(w io.Writer, record *Record) (err error) {
+	dataType, err := instanceDataType(record.Instance)
+	if err != nil {
+		return
+	}
+
+	instanceData, err := record.Instance.MarshalBinary()
+	if err != nil {
+		return
+	}
+
+	data := utils.ChainByteSlices(
+		[]byte{dataType},
+		utils.MarshalUint32(uint32(len(instanceData))),
+		instanceData,
+		marshalSnapshotTime(record.Created),
+		marshalSnapshotTime(record.LastSyncAttempt))
+
+	// Votes might be set concurrently with the snapshot writing.
+	record.votesMutex.RLock()
+	data = append(data, utils.MarshalUint16(uint16(len(record.Votes)))...)
+	for identity, vote := range record.Votes {
+		data = append(data, utils.ChainByteSlices(
+			utils.MarshalUint16(uint16(len(identity))),
+			[]byte(identity),
+			[]byte{byte(vote)},
+			marshalSnapshotTime(record.VotesReceived[identity]))...)
+	}
+	record.votesMutex.RUnlock()
+
+	_, err = w.Write(data)
+	return
+}
+
+func readRecord(r io.Reader, factory InstancesFactory) (key hash.SHA256Container, record *Record, err error) {
+	header := make([]byte, 1+common.Uint32ByteSize)
+	err = readSnapshotData(r, header)
+	if err != nil {
+		return
+	}
+
+	i := factory(header[0])
+	if i == nil {
+		err = errors.InvalidDataFormat
+		return
+	}
+
+	instanceSize, err := utils.UnmarshalUint32(header[1:])
+	if err != nil {
+		return
+	}
+
+	if instanceSize > kSnapshotInstanceMaxSize {
+		err = errors.InvalidDataFormat
+		return
+	}
+
+	instanceData := make([]byte, instanceSize)
+	err = readSnapshotData(r, instanceData)
+	if err != nil {
+		return
+	}
+
+	err = i.UnmarshalBinary(instanceData)
+	if err != nil {
+		return
+	}
+
+	record = NewRecord(i)
+	key = hash.NewSHA256Container(instanceData)
+
+	timesData := make([]byte, common.Uint64ByteSize*2+common.Uint16ByteSize)
+	err = readSnapshotData(r, timesData)
+	if err != nil {
+		return
+	}
+
+	record.Created, err = unmarshalSnapshotTime(timesData[:common.Uint64ByteSize])
+	if err != nil {
+		return
+	}
+
+	record.LastSyncAttempt, err = unmarshalSnapshotTime(timesData[common.Uint64ByteSize : common.Uint64ByteSize*2])
+	if err != nil {
+		return
+	}
+
+	votesCount, err := utils.UnmarshalUint16(timesData[common.Uint64ByteSize*2:])
+	if err != nil {
+		return
+	}
+
+	if int(votesCount) > settings.ObserversMaxCount {
+		err = errors.InvalidDataFormat
+		return
+	}
+
+	for v := 0; v < int(votesCount); v++ {
+		err = readVote(r, record)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func readVote(r io.Reader, record *Record) (err error) {
+	sizeData := make([]byte, common.Uint16ByteSize)
+	err = readSnapshotData(r, sizeData)
+	if err != nil {
+		return
+	}
+
+	identitySize, err := utils.UnmarshalUint16(sizeData)
+	if err != nil {
+		return
+	}
+
+	if identitySize == 0 || identitySize > kSnapshotIdentityMaxSize {
+		return errors.InvalidDataFormat
+	}
+
+	data := make([]byte, int(identitySize)+1+common.Uint64ByteSize)
+	err = readSnapshotData(r, data)
+	if err != nil {
+		return
+	}
+
+	identity := external.ObserverIdentity(data[:identitySize])
+	vote := Vote(data[identitySize])
+	if vote != VoteApprove && vote != VoteReject {
+		return errors.InvalidDataFormat
+	}
+
+	received, err := unmarshalSnapshotTime(data[identitySize+1:])
+	if err != nil {
+		return
+	}
+
+	record.Votes[identity] = vote
+	record.VotesReceived[identity] = received
+	return
+}
+
+func marshalSnapshotTime(t time.Time) []byte {
+	if t.IsZero() {
+		return utils.MarshalUint64(0)
+	}
+
+	return utils.MarshalUint64(uint64(t.UnixNano()))
+}
+
+func unmarshalSnapshotTime(data []byte) (t time.Time, err error) {
+	nanoseconds, err := utils.UnmarshalUint64(data)
+	if err != nil || nanoseconds == 0 {
+		return
+	}
+
+	return time.Unix(0, int64(nanoseconds)), nil
+}
+
+// readSnapshotData fills the buffer from the reader.
+// Reports end of the stream as errors.InvalidDataFormat.
+func readSnapshotData(r io.Reader, buffer []byte) (err error) {
+	_, err = io.ReadFull(r, buffer)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = errors.InvalidDataFormat
+	}
+
+	return
+}
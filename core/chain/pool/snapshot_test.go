@@ -0,0 +1,186 @@
+package pool
+
+import (
+	"bytes"
+	"geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/common/types/hash"
+	"geo-observers-blockchain/core/common/types/transactions"
+	"geo-observers-blockchain/core/geo"
+	"testing"
+	"time"
+)
+
+// Snapshots populated pool and restores it into the fresh one.
+func TestPool_SnapshotRestore(t *testing.T) {
+	pool := NewPool(0)
+	for i := 0; i < 3; i++ {
+		record, err := pool.Add(newTestInstance(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		record.Approve("0")
+		if i > 0 {
+			record.Reject("1")
+			record.LastSyncAttempt = time.Now()
+		}
+	}
+
+	buffer := &bytes.Buffer{}
+	err := pool.Snapshot(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewPool(0)
+	err = restored.Restore(buffer, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if restored.Count() != pool.Count() {
+		t.Fatal()
+	}
+
+	pool.ForEach(func(key hash.SHA256Container, original *Record) bool {
+		record, err := restored.ByHash(&key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !original.Instance.TxID().Compare(record.Instance.TxID()) ||
+			!original.Created.Equal(record.Created) ||
+			!original.LastSyncAttempt.Equal(record.LastSyncAttempt) ||
+			len(original.Votes) != len(record.Votes) {
+			t.Fatal("restored record differs from the original one")
+		}
+
+		for identity, vote := range original.Votes {
+			if record.VoteOf(identity) != vote ||
+				!original.VotesReceived[identity].Equal(record.VotesReceived[identity]) {
+				t.Fatal("restored vote differs from the original one")
+			}
+		}
+
+		return true
+	})
+}
+
+// Checks that truncated snapshot is rejected and the pool is left untouched.
+func TestPool_Restore_Truncated(t *testing.T) {
+	pool := NewPool(0)
+	for i := 0; i < 2; i++ {
+		_, err := pool.Add(newTestInstance(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buffer := &bytes.Buffer{}
+	err := pool.Snapshot(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := buffer.Bytes()
+	restored := NewPool(0)
+	err = restored.Restore(bytes.NewReader(data[:len(data)-1]), nil)
+	if err != errors.InvalidDataFormat {
+		t.Fatal(err)
+	}
+
+	if restored.Count() != 0 {
+		t.Fatal("pool must be left untouched")
+	}
+}
+
+// Checks that unknown data type is rejected by the factory.
+func TestPool_Restore_UnknownDataType(t *testing.T) {
+	pool := NewPool(0)
+	_, err := pool.Add(newTestInstance(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buffer := &bytes.Buffer{}
+	err = pool.Snapshot(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = NewPool(0).Restore(buffer, func(dataType uint8) instance { return nil })
+	if err != errors.InvalidDataFormat {
+		t.Fatal(err)
+	}
+}
+
+// Checks that the snapshot, that is larger than the records limit of the pool,
+// is restored according to the same rules as Add() does.
+func TestPool_Restore_MaxRecords(t *testing.T) {
+	pool := NewPool(0)
+	for i := 0; i < 5; i++ {
+		_, err := pool.Add(newTestInstance(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buffer := &bytes.Buffer{}
+	err := pool.Snapshot(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := buffer.Bytes()
+
+	rejecting := NewPool(3)
+	rejecting.EvictOnFull = false
+	err = rejecting.Restore(bytes.NewReader(data), nil)
+	if err != errors.MaxCountReached {
+		t.Fatal(err)
+	}
+
+	if rejecting.Count() != 3 {
+		t.Fatal("records limit must not be exceeded")
+	}
+
+	evicting := NewPool(3)
+	evicting.EvictOnFull = true
+	err = evicting.Restore(bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if evicting.Count() != 3 {
+		t.Fatal("records limit must not be exceeded")
+	}
+}
+
+// Checks that the snapshot with invalid instance is rejected and the pool is left untouched.
+func TestPool_Restore_Validation(t *testing.T) {
+	pool := NewPool(0)
+	_, err := pool.Add(newTestInstance(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Invalid claim could not be added via Add(), so it is written into the index directly.
+	invalid := &geo.Claim{TxUUID: transactions.NewEmptyTxID(), Members: &geo.ClaimMembers{}}
+	_ = invalid.Members.Add(geo.NewClaimMember(0))
+	pool.index[hash.NewSHA256Container([]byte("invalid"))] = NewRecord(invalid)
+
+	buffer := &bytes.Buffer{}
+	err = pool.Snapshot(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewPool(0)
+	err = restored.Restore(buffer, nil)
+	if err != geo.ErrEmptyTxID {
+		t.Fatal(err)
+	}
+
+	if restored.Count() != 0 {
+		t.Fatal("pool must be left untouched")
+	}
+}
@@ -0,0 +1,41 @@
+package pool
+
+import (
+	"geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/geo"
+	"geo-observers-blockchain/core/network/communicator/observers/constants"
+)
+
+// InstancesFactory creates empty instance of the type, that corresponds to the data type specified.
+// Returns nil in case if data type is unknown.
+type InstancesFactory func(dataType uint8) instance
+
+// NewInstance is the default InstancesFactory: it creates claims and TSLs.
+func NewInstance(dataType uint8) instance {
+	switch dataType {
+	case constants.DataTypeRequestClaimBroadcast:
+		return geo.NewClaim()
+
+	case constants.DataTypeRequestTSLBroadcast:
+		return geo.NewTSL()
+
+	default:
+		return nil
+	}
+}
+
+// instanceDataType returns discriminator of the instance type.
+// The same data types as for the instances broadcasting are used.
+// Returns errors.InvalidDataFormat in case if the type of the instance is unknown.
+func instanceDataType(i instance) (dataType uint8, err error) {
+	switch i.(type) {
+	case *geo.Claim:
+		return constants.DataTypeRequestClaimBroadcast, nil
+
+	case *geo.TSL:
+		return constants.DataTypeRequestTSLBroadcast, nil
+
+	default:
+		return 0, errors.InvalidDataFormat
+	}
+}
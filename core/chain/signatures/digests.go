@@ -0,0 +1,130 @@
+package signatures
+
+import (
+	"bytes"
+	e "crypto/ecdsa"
+	"geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/common/types/hash"
+	"geo-observers-blockchain/core/crypto/ecdsa"
+	"geo-observers-blockchain/core/settings"
+	"geo-observers-blockchain/core/utils"
+	"sort"
+)
+
+var (
+	ErrInvalidDigestSignature = utils.Error("signatures", "digest signature is invalid")
+)
+
+// DigestsCollector collects block candidates digests (block hashes), signed by the observers during one round.
+// Is used to detect if the digest of the current observer diverges from the digest,
+// that has been signed by the majority of observers, so the reconciliation might be started.
+type DigestsCollector struct {
+	signed map[uint16]hash.SHA256Container
+}
+
+// DigestDisagreement reports divergence of the digest of the current observer
+// from the most signed digest, along with the observers, that has signed each one of them.
+type DigestDisagreement struct {
+	OwnDigest      hash.SHA256Container
+	MajorityDigest hash.SHA256Container
+
+	// Indexes of the observers, that has signed corresponding digest (in ascending order).
+	OwnDigestSigners      []uint16
+	MajorityDigestSigners []uint16
+}
+
+func NewDigestsCollector() *DigestsCollector {
+	return &DigestsCollector{
+		signed: make(map[uint16]hash.SHA256Container),
+	}
+}
+
+// Add remembers the digest, signed by the observer.
+// The signature is verified with the public key of the observer first:
+// in case if it does not match - ErrInvalidDigestSignature is returned and the digest is not remembered.
+// Each observer is expected to sign only one digest during the round:
+// in case if other digest is reported for the same observer - errors.Collision is returned,
+// and the first one digest is preserved.
+func (c *DigestsCollector) Add(
+	observerIndex uint16, digest hash.SHA256Container, signature *ecdsa.Signature, pubKey *e.PublicKey) (err error) {
+
+	if int(observerIndex) >= settings.ObserversMaxCount {
+		return errors.InvalidObserverIndex
+	}
+
+	if signature == nil || !signature.Verify(pubKey, digest.Bytes[:]) {
+		return ErrInvalidDigestSignature
+	}
+
+	previous, isPresent := c.signed[observerIndex]
+	if isPresent {
+		if previous != digest {
+			return errors.Collision
+		}
+
+		return
+	}
+
+	c.signed[observerIndex] = digest
+	return
+}
+
+// MajorityDigest returns the digest, that has been signed by the biggest amount of observers,
+// and indexes of the observers, that has signed it.
+// In case if several digests are signed by the same amount of observers -
+// the digest with the lowest hash is returned (the choice must be the same on all observers).
+func (c *DigestsCollector) MajorityDigest() (digest hash.SHA256Container, signers []uint16, err error) {
+	if len(c.signed) == 0 {
+		err = errors.EmptySequence
+		return
+	}
+
+	signersByDigest := c.signersByDigest()
+	for candidate, candidateSigners := range signersByDigest {
+		if len(candidateSigners) > len(signers) ||
+			(len(candidateSigners) == len(signers) && bytes.Compare(candidate.Bytes[:], digest.Bytes[:]) < 0) {
+
+			digest = candidate
+			signers = candidateSigners
+		}
+	}
+
+	return
+}
+
+// Disagreement compares digest of the current observer with the majority digest.
+// Returns nil in case if digests are equal.
+func (c *DigestsCollector) Disagreement(
+	ownDigest hash.SHA256Container) (disagreement *DigestDisagreement, err error) {
+
+	majorityDigest, majoritySigners, err := c.MajorityDigest()
+	if err != nil {
+		return
+	}
+
+	if majorityDigest == ownDigest {
+		return
+	}
+
+	disagreement = &DigestDisagreement{
+		OwnDigest:             ownDigest,
+		MajorityDigest:        majorityDigest,
+		OwnDigestSigners:      c.signersByDigest()[ownDigest],
+		MajorityDigestSigners: majoritySigners,
+	}
+
+	return
+}
+
+func (c *DigestsCollector) signersByDigest() (signers map[hash.SHA256Container][]uint16) {
+	signers = make(map[hash.SHA256Container][]uint16)
+	for observerIndex, digest := range c.signed {
+		signers[digest] = append(signers[digest], observerIndex)
+	}
+
+	for _, indexes := range signers {
+		sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+	}
+
+	return
+}
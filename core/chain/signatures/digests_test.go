@@ -0,0 +1,143 @@
+package signatures
+
+import (
+	"geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/common/types/hash"
+	"geo-observers-blockchain/core/crypto/keystore"
+	"reflect"
+	"testing"
+)
+
+// testSigners signs the digests on behalf of the observers (each observer has it's own key)
+// and adds them to the collector.
+type testSigners map[uint16]*keystore.KeyStore
+
+func (s testSigners) add(
+	t *testing.T, collector *DigestsCollector, observerIndex uint16, digest hash.SHA256Container) error {
+
+	ks, isPresent := s[observerIndex]
+	if !isPresent {
+		var err error
+		ks, err = keystore.NewInMemory()
+		if err != nil {
+			t.Fatal(err)
+		}
+		s[observerIndex] = ks
+	}
+
+	signature, err := ks.SignHash(digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return collector.Add(observerIndex, digest, signature, ks.PublicKey())
+}
+
+// Current observer's digest is signed by the minority of observers:
+// disagreement must be reported with the correct signers partition.
+func TestDigestsCollector_Disagreement_Minority(t *testing.T) {
+	var (
+		ownDigest      = hash.NewSHA256Container([]byte("own"))
+		majorityDigest = hash.NewSHA256Container([]byte("majority"))
+		otherDigest    = hash.NewSHA256Container([]byte("other"))
+	)
+
+	signers := testSigners{}
+	collector := NewDigestsCollector()
+	for _, i := range []uint16{0, 5} {
+		_ = signers.add(t, collector, i, ownDigest)
+	}
+	for _, i := range []uint16{4, 1, 3, 6} {
+		_ = signers.add(t, collector, i, majorityDigest)
+	}
+	_ = signers.add(t, collector, 2, otherDigest)
+
+	disagreement, err := collector.Disagreement(ownDigest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if disagreement == nil ||
+		disagreement.OwnDigest != ownDigest ||
+		disagreement.MajorityDigest != majorityDigest {
+		t.Fatal("disagreement must be reported")
+	}
+
+	if !reflect.DeepEqual(disagreement.OwnDigestSigners, []uint16{0, 5}) ||
+		!reflect.DeepEqual(disagreement.MajorityDigestSigners, []uint16{1, 3, 4, 6}) {
+		t.Fatal("invalid signers partition")
+	}
+}
+
+// No disagreement must be reported in case if own digest is the majority one.
+func TestDigestsCollector_Disagreement_Majority(t *testing.T) {
+	ownDigest := hash.NewSHA256Container([]byte("own"))
+
+	signers := testSigners{}
+	collector := NewDigestsCollector()
+	_, err := collector.Disagreement(ownDigest)
+	if err != errors.EmptySequence {
+		t.Fatal()
+	}
+
+	_ = signers.add(t, collector, 0, ownDigest)
+	_ = signers.add(t, collector, 1, ownDigest)
+	_ = signers.add(t, collector, 2, hash.NewSHA256Container([]byte("other")))
+
+	disagreement, err := collector.Disagreement(ownDigest)
+	if err != nil || disagreement != nil {
+		t.Fatal()
+	}
+}
+
+// Observer must not be able to sign several different digests.
+func TestDigestsCollector_Add_Collision(t *testing.T) {
+	signers := testSigners{}
+	collector := NewDigestsCollector()
+	if signers.add(t, collector, 0, hash.NewSHA256Container([]byte("first"))) != nil {
+		t.Fatal()
+	}
+
+	if signers.add(t, collector, 0, hash.NewSHA256Container([]byte("second"))) != errors.Collision {
+		t.Fatal()
+	}
+
+	if signers.add(t, collector, 0, hash.NewSHA256Container([]byte("first"))) != nil {
+		t.Fatal()
+	}
+}
+
+// Digest, signed with the key other than the key of the observer, must be rejected,
+// so it could not affect the majority digest.
+func TestDigestsCollector_Add_Forged(t *testing.T) {
+	digest := hash.NewSHA256Container([]byte("forged"))
+
+	observer, err := keystore.NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forger, err := keystore.NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signature, err := forger.SignHash(digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collector := NewDigestsCollector()
+	if collector.Add(0, digest, signature, observer.PublicKey()) != ErrInvalidDigestSignature {
+		t.Fatal("forged digest must be rejected")
+	}
+
+	if collector.Add(0, digest, nil, observer.PublicKey()) != ErrInvalidDigestSignature {
+		t.Fatal("digest without signature must be rejected")
+	}
+
+	_, _, err = collector.MajorityDigest()
+	if err != errors.EmptySequence {
+		t.Fatal("rejected digest must not be remembered")
+	}
+}
@@ -0,0 +1,67 @@
+package common
+
+import (
+	"net"
+	"sync"
+)
+
+// ConnectionsTracker keeps the accepted connections of the listening component (see observers.Receiver),
+// so they might be closed on the component stop, and the component might wait until all of them are handled.
+// Zero value is ready to use.
+type ConnectionsTracker struct {
+	mutex       sync.Mutex
+	connections map[net.Conn]struct{}
+	isClosed    bool
+
+	handlers sync.WaitGroup
+}
+
+// Add registers the connection, which handler is about to be started.
+// In case if the tracker has been already closed - the connection is closed and false is returned,
+// so no handler must be started.
+func (t *ConnectionsTracker) Add(conn net.Conn) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.isClosed {
+		_ = conn.Close()
+		return false
+	}
+
+	if t.connections == nil {
+		t.connections = make(map[net.Conn]struct{})
+	}
+
+	t.connections[conn] = struct{}{}
+	t.handlers.Add(1)
+	return true
+}
+
+// Done must be called by the handler of the connection on exit.
+func (t *ConnectionsTracker) Done(conn net.Conn) {
+	t.mutex.Lock()
+	delete(t.connections, conn)
+	t.mutex.Unlock()
+
+	t.handlers.Done()
+}
+
+// CloseAll closes all tracked connections and waits until their handlers are finished.
+// Connections, that are added after this call, are closed immediately.
+func (t *ConnectionsTracker) CloseAll() {
+	t.mutex.Lock()
+	t.isClosed = true
+	connections := make([]net.Conn, 0, len(t.connections))
+	for conn := range t.connections {
+		connections = append(connections, conn)
+	}
+	t.mutex.Unlock()
+
+	// Closing might take some time (for example, TLS connections are notifying the remote side),
+	// so it is done without the lock.
+	for _, conn := range connections {
+		_ = conn.Close()
+	}
+
+	t.handlers.Wait()
+}
@@ -69,7 +69,8 @@ func SendErrorIfAny(err error, errors chan error) {
 
 var (
 	// Configuration
-	InvalidObserverIndex = errors.New("invalid observer index")
+	InvalidObserverIndex  = errors.New("invalid observer index")
+	UnknownObserverPubKey = errors.New("unknown observer public key")
 
 	// Common
 	SuspiciousOperation = errors.New("suspicious operation")
@@ -102,6 +103,7 @@ var (
 	InvalidDataFormat  = errors.New("invalid data format occurred")
 	BufferDiscarding   = errors.New("buffer can't be discarded")
 	UnexpectedDataType = errors.New("unexpected data type occurred in incoming data stream")
+	UnsupportedVersion = errors.New("unsupported protocol version")
 
 	// chain
 	InvalidBlockHeight = errors.New("invalid block height")
@@ -115,6 +117,7 @@ var (
 	InvalidBlockCandidateDigest        = errors.New("invalid block candidate digest")
 	InvalidTimeFrame                   = errors.New("invalid time frame")
 	InvalidBlockCandidateDigestApprove = errors.New("invalid block candidate digest approve")
+	InvalidBlockCandidateDigestReject  = errors.New("invalid block candidate digest reject")
 	InvalidBlockSignatures             = errors.New("invalid block signatures")
 
 	// GEO Nodes receiver
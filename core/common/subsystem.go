@@ -0,0 +1,106 @@
+package common
+
+import (
+	"context"
+	"sync"
+)
+
+// Subsystem is implemented by the long-running components of the observer
+// (ticker, pools, network senders, etc), so they might be stopped uniformly.
+type Subsystem interface {
+	// Stop interrupts the component and waits until it is stopped.
+	// Returns ctx.Err() in case if ctx is done before the component has been stopped.
+	Stop(ctx context.Context) error
+}
+
+// StopAll stops all subsystems one by one.
+// All subsystems are requested to stop even if some of them has failed.
+// Returns the first occurred error.
+func StopAll(ctx context.Context, subsystems ...Subsystem) (err error) {
+	for _, subsystem := range subsystems {
+		if subsystem == nil {
+			continue
+		}
+
+		e := subsystem.Stop(ctx)
+		if e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+// Lifecycle controls stopping of the component with internal events loop.
+// Zero value is ready to use.
+type Lifecycle struct {
+	mutex sync.Mutex
+
+	// Closed when events loop must be stopped.
+	stop chan struct{}
+
+	// Closed when events loop has been stopped.
+	done chan struct{}
+
+	isStarted       bool
+	isStopRequested bool
+	isDone          bool
+}
+
+// Start must be called by the events loop on it's start.
+// Returned channel is closed when the loop must be stopped.
+func (l *Lifecycle) Start() (stop <-chan struct{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.init()
+	l.isStarted = true
+	return l.stop
+}
+
+// Done must be called by the events loop on exit.
+func (l *Lifecycle) Done() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.init()
+	if !l.isDone {
+		l.isDone = true
+		close(l.done)
+	}
+}
+
+// Stop requests events loop to stop and waits until it is done.
+// In case if events loop has not been started - returns immediately.
+func (l *Lifecycle) Stop(ctx context.Context) error {
+	l.mutex.Lock()
+	l.init()
+	if !l.isStopRequested {
+		l.isStopRequested = true
+		close(l.stop)
+	}
+
+	isStarted, done := l.isStarted, l.done
+	l.mutex.Unlock()
+
+	if !isStarted {
+		return nil
+	}
+
+	select {
+	case <-done:
+		return nil
+
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *Lifecycle) init() {
+	if l.stop == nil {
+		l.stop = make(chan struct{})
+		l.done = make(chan struct{})
+	}
+}
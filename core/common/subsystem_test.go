@@ -0,0 +1,83 @@
+package common
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type testSubsystem struct {
+	lifecycle Lifecycle
+	stopped   chan struct{}
+}
+
+func newTestSubsystem() *testSubsystem {
+	return &testSubsystem{stopped: make(chan struct{})}
+}
+
+func (s *testSubsystem) Run() {
+	stop := s.lifecycle.Start()
+	defer s.lifecycle.Done()
+
+	for {
+		select {
+		case <-stop:
+			close(s.stopped)
+			return
+
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func (s *testSubsystem) Stop(ctx context.Context) error {
+	return s.lifecycle.Stop(ctx)
+}
+
+// Stops several running subsystems and one not started subsystem via the common interface,
+// and checks that each one of them has been stopped.
+func TestStopAll(t *testing.T) {
+	running := []*testSubsystem{newTestSubsystem(), newTestSubsystem(), newTestSubsystem()}
+	subsystems := []Subsystem{newTestSubsystem()}
+	for _, subsystem := range running {
+		// Loop is marked as started before the stop request,
+		// otherwise stop might be requested before the goroutine start.
+		subsystem.lifecycle.Start()
+		go subsystem.Run()
+		subsystems = append(subsystems, subsystem)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	err := StopAll(ctx, subsystems...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, subsystem := range running {
+		select {
+		case <-subsystem.stopped:
+		default:
+			t.Fatal("subsystem is not stopped")
+		}
+	}
+
+	// Repeated stop is allowed.
+	if StopAll(ctx, subsystems...) != nil {
+		t.Fatal()
+	}
+}
+
+// Subsystem, that does not finish it's loop, must not block the stop forever.
+func TestLifecycle_Stop_Timeout(t *testing.T) {
+	lifecycle := &Lifecycle{}
+	lifecycle.Start()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	if lifecycle.Stop(ctx) != context.DeadlineExceeded {
+		t.Fatal()
+	}
+}
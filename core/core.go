@@ -1,8 +1,11 @@
 package core
 
 import (
+	"context"
+	"crypto/ecdsa"
 	"geo-observers-blockchain/core/chain/chain"
 	"geo-observers-blockchain/core/chain/pool"
+	"geo-observers-blockchain/core/common"
 	"geo-observers-blockchain/core/crypto/keystore"
 	"geo-observers-blockchain/core/geo"
 	geoNet "geo-observers-blockchain/core/network/communicator/geo"
@@ -16,7 +19,10 @@ import (
 	"geo-observers-blockchain/core/ticker"
 	"geo-observers-blockchain/core/utils"
 	log "github.com/sirupsen/logrus"
+	"os"
+	"os/signal"
 	"reflect"
+	"syscall"
 	"time"
 )
 
@@ -34,23 +40,37 @@ type Core struct {
 }
 
 func New() (core *Core, err error) {
-	k, err := keystore.New()
+	err = ticker.ValidateSettings()
+	if err != nil {
+		return
+	}
+
+	keyPath := keystore.DefaultKeyPath
+	if settings.Conf != nil && settings.Conf.KeyStore.Path != "" {
+		keyPath = settings.Conf.KeyStore.Path
+	}
+
+	k, err := keystore.New(keyPath, "")
 	if err != nil {
 		return
 	}
 
 	reporter := external.NewReporter(k)
+	blacklist := observersNet.NewDefaultBlacklist()
 	poolTSLs := pool.NewHandler(reporter)
 	poolClaims := pool.NewHandler(reporter)
 	composer := chain.NewComposer(reporter)
 	producer, err := chain.NewProducer(reporter, k, poolTSLs, poolClaims, composer)
+	if err != nil {
+		return
+	}
 
 	core = &Core{
 		keystore:              k,
 		ticker:                ticker.New(reporter),
 		observersConfReporter: reporter,
-		senderObservers:       observersNet.NewSender(reporter),
-		receiverObservers:     observersNet.NewReceiver(),
+		senderObservers:       observersNet.NewSender(reporter, blacklist),
+		receiverObservers:     observersNet.NewReceiver(blacklist),
 		receiverGEONodes:      geoNet.New(),
 		poolClaims:            poolClaims,
 		poolTSLs:              poolTSLs,
@@ -58,12 +78,87 @@ func New() (core *Core, err error) {
 		composer:              composer,
 	}
 
+	core.ticker.SetRoundTripTimes(core.senderObservers)
+
+	// Observers, that sends invalid data, are reported to the same blacklist,
+	// that is used by the network layer for dropping their messages and connections.
+	core.ticker.SetMisbehaviourReporter(blacklist)
+	core.poolClaims.SetMisbehaviourReporter(blacklist)
+	core.poolTSLs.SetMisbehaviourReporter(blacklist)
+	core.blocksProducer.SetMisbehaviourReporter(blacklist)
+
+	if settings.ObserversAuthenticationEnabled {
+		core.enableObserversAuthentication()
+	}
+
+	if settings.ObserversTLSEnabled {
+		err = core.enableObserversTLS()
+	}
+
+	return
+}
+
+// enableObserversAuthentication makes the observers to prove their identities to each other on connect.
+// Incoming connections are accepted only from the observers of the current configuration.
+func (c *Core) enableObserversAuthentication() {
+	c.senderObservers.EnableAuthentication(c.keystore)
+	c.receiverObservers.EnableAuthentication(c.keystore, func() (*external.ObserverRegistry, error) {
+		conf, err := c.observersConfReporter.GetCurrentConfiguration()
+		if err != nil {
+			return nil, err
+		}
+
+		return conf.Registry(), nil
+	})
+}
+
+// enableObserversTLS protects connections between observers with mutual TLS.
+// Incoming connections are accepted only from the observers of the current configuration.
+func (c *Core) enableObserversTLS() (err error) {
+	certificate, err := c.keystore.TLSCertificate()
+	if err != nil {
+		return
+	}
+
+	isKnownPeer := func(pubKey *ecdsa.PublicKey) bool {
+		conf, err := c.observersConfReporter.GetCurrentConfiguration()
+		if err != nil {
+			return false
+		}
+
+		_, err = conf.Registry().IndexByPubKey(pubKey)
+		return err == nil
+	}
+
+	c.senderObservers.EnableTLS(certificate)
+	c.receiverObservers.EnableTLS(observersNet.ServerTLSConfig(certificate, isKnownPeer))
 	return
 }
 
+// Stop stops all long-running subsystems of the observer.
+// Incoming data flows are stopped first, so the processing subsystems does not receive new data during the stop.
+// All subsystems are requested to stop, even if some of them fails.
+func (c *Core) Stop(ctx context.Context) error {
+	return common.StopAll(ctx,
+		c.receiverGEONodes,
+		c.receiverObservers,
+		c.blocksProducer,
+		c.composer,
+		c.ticker,
+		c.poolClaims,
+		c.poolTSLs,
+		c.senderObservers)
+}
+
+// Run launches all subsystems and processes their errors until SIGINT or SIGTERM is received.
+// On signal all subsystems are stopped (see Stop()), and the method returns.
 func (c *Core) Run() {
 	globalErrorsFlow := make(chan error, 128)
 
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
 	c.initNetwork(globalErrorsFlow)
 	c.initProcessing(globalErrorsFlow)
 
@@ -76,6 +171,40 @@ func (c *Core) Run() {
 					//c.log().Warn(err)
 				}
 			}
+
+		case <-signals:
+			c.shutdown(globalErrorsFlow)
+			return
+		}
+	}
+}
+
+// shutdown stops all subsystems in settings.ShutdownTimeout.
+// Errors of the subsystems are drained during the stop,
+// otherwise the subsystems, that are reporting errors on exit, might block.
+func (c *Core) shutdown(globalErrorsFlow chan error) {
+	c.log().Info("Shutting down")
+
+	ctx, cancel := context.WithTimeout(context.Background(), settings.ShutdownTimeout)
+	defer cancel()
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- c.Stop(ctx)
+	}()
+
+	for {
+		select {
+		case <-globalErrorsFlow:
+
+		case err := <-stopped:
+			if err != nil {
+				c.log().Error("Not all subsystems has been stopped in time: ", err)
+				return
+			}
+
+			c.log().Info("Stopped")
+			return
 		}
 	}
 }
@@ -160,6 +289,13 @@ func (c *Core) dispatchDataFlows(globalErrorsFlow chan error) {
 				processTransferringFail(outgoingResponseCandidateDigestApprove, c.senderObservers)
 			}
 
+		case outgoingResponseCandidateDigestReject := <-c.blocksProducer.OutgoingResponsesCandidateDigestReject:
+			select {
+			case c.senderObservers.OutgoingResponses <- outgoingResponseCandidateDigestReject:
+			default:
+				processTransferringFail(outgoingResponseCandidateDigestReject, c.senderObservers)
+			}
+
 		case outgoingRequestBlockSignaturesBroadcast := <-c.blocksProducer.OutgoingRequestsBlockSignaturesBroadcast:
 			select {
 			case c.senderObservers.OutgoingRequests <- outgoingRequestBlockSignaturesBroadcast:
@@ -275,9 +411,7 @@ func (c *Core) processIncomingRequest(r requests.Request) (err error) {
 
 	switch r.(type) {
 	case *requests.SynchronisationTimeFrames:
-		select {
-		case c.ticker.IncomingRequestsTimeFrames <- r.(*requests.SynchronisationTimeFrames):
-		default:
+		if c.ticker.EnqueueRequest(r) != nil {
 			processTransferringFail(r, c.ticker)
 		}
 
@@ -323,9 +457,7 @@ func (c *Core) processIncomingRequest(r requests.Request) (err error) {
 		}
 
 	case *requests.TimeFrameCollision:
-		select {
-		case c.ticker.IncomingRequestsTimeFrameCollision <- r.(*requests.TimeFrameCollision):
-		default:
+		if c.ticker.EnqueueRequest(r) != nil {
 			processTransferringFail(r, c.ticker)
 		}
 
@@ -388,6 +520,13 @@ func (c *Core) processIncomingGEONodeRequest(r geoRequestsCommon.Request) (err e
 			processTransferringFail(r, c.blocksProducer)
 		}
 
+	case *geoRequests.TSLsArePresent:
+		select {
+		case c.blocksProducer.GEORequestsTSLsArePresent <- r.(*geoRequests.TSLsArePresent):
+		default:
+			processTransferringFail(r, c.blocksProducer)
+		}
+
 	case *geoRequests.TSLGet:
 		select {
 		case c.blocksProducer.GEORequestsTSLGet <- r.(*geoRequests.TSLGet):
@@ -452,6 +591,13 @@ func (c *Core) processIncomingResponse(r responses.Response) (err error) {
 			processTransferringFail(r, c.blocksProducer)
 		}
 
+	case *responses.CandidateDigestReject:
+		select {
+		case c.blocksProducer.IncomingResponsesCandidateDigestReject <- r.(*responses.CandidateDigestReject):
+		default:
+			processTransferringFail(r, c.blocksProducer)
+		}
+
 	case *responses.ChainTop:
 		select {
 		case c.composer.IncomingResponsesChainTop <- r.(*responses.ChainTop):
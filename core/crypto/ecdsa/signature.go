@@ -1,13 +1,29 @@
 package ecdsa
 
 import (
-	"geo-observers-blockchain/core/common"
+	e "crypto/ecdsa"
+	"crypto/elliptic"
 	"geo-observers-blockchain/core/common/errors"
-	"geo-observers-blockchain/core/settings"
-	"geo-observers-blockchain/core/utils"
 	"math/big"
 )
 
+const (
+	// P-521 scalars are 521 bits long, so 66 bytes are needed to store each one of R and S.
+	SignatureScalarBytesSize = 66
+
+	// Format:
+	// 66B - R (big endian, zero padded).
+	// 66B - S (big endian, zero padded).
+	SignatureBinarySize = SignatureScalarBytesSize * 2
+)
+
+var (
+	// Order of the curve, that is used for signing, and it's half.
+	// Signatures with S greater than the half of the order are not canonical (see IsCanonical()).
+	curveOrder     = elliptic.P521().Params().N
+	curveHalfOrder = new(big.Int).Rsh(curveOrder, 1)
+)
+
 type Signature struct {
 	R *big.Int
 	S *big.Int
@@ -18,157 +34,65 @@ func (s *Signature) MarshalBinary() (data []byte, err error) {
 		return nil, errors.NilInternalDataStructure
 	}
 
-	var (
-		rDataBinary = s.R.Bytes()
-		rSizeBinary = utils.MarshalUint16(uint16(len(rDataBinary)))
-
-		sDataBinary = s.S.Bytes()
-		sSizeBinary = utils.MarshalUint16(uint16(len(sDataBinary)))
-	)
+	if !isValidScalar(s.R) || !isValidScalar(s.S) {
+		return nil, errors.InvalidDataFormat
+	}
 
-	return utils.ChainByteSlices(
-		rSizeBinary, sSizeBinary, rDataBinary, sDataBinary), nil
+	data = make([]byte, SignatureBinarySize)
+	s.R.FillBytes(data[:SignatureScalarBytesSize])
+	s.S.FillBytes(data[SignatureScalarBytesSize:])
+	return
 }
 
+// UnmarshalBinary accepts exactly SignatureBinarySize bytes:
+// trailing data is rejected, otherwise the same signature would have several binary representations.
 func (s *Signature) UnmarshalBinary(data []byte) (err error) {
-	if data == nil {
+	if len(data) != SignatureBinarySize {
 		return errors.InvalidDataFormat
 	}
 
-	const (
-		sizeFieldLength = 2
-		rSizeOffset     = 0
-		sSizeOffset     = rSizeOffset + sizeFieldLength
-		dataOffset      = sSizeOffset + sizeFieldLength
+	var (
+		rValue = new(big.Int).SetBytes(data[:SignatureScalarBytesSize])
+		sValue = new(big.Int).SetBytes(data[SignatureScalarBytesSize:])
 	)
 
-	rSize, err := utils.UnmarshalUint16(data[rSizeOffset:sSizeOffset])
-	if err != nil {
-		return
-	}
-	if rSize == 0 {
-		err = errors.InvalidDataFormat
-		return
-	}
-
-	sSize, err := utils.UnmarshalUint16(data[sSizeOffset:dataOffset])
-	if err != nil {
-		return
-	}
-	if sSize == 0 {
-		err = errors.InvalidDataFormat
-		return
-	}
-
-	if uint16(len(data)) < rSize+sSize+(sizeFieldLength*2) {
-		err = errors.InvalidDataFormat
-		return
+	if rValue.Sign() == 0 || sValue.Sign() == 0 {
+		return errors.InvalidDataFormat
 	}
 
-	s.S = big.NewInt(0)
-	s.R = big.NewInt(0)
-
-	s.R.SetBytes(data[dataOffset : dataOffset+rSize])
-	s.S.SetBytes(data[dataOffset+rSize : dataOffset+rSize+sSize])
+	s.R = rValue
+	s.S = sValue
 	return
 }
 
-//---------------------------------------------------------------------------------------------------------------------
-
-var (
-	SignaturesMaxCount = settings.ObserversMaxCount
-)
-
-type Signatures struct {
-	At []Signature
+// IsCanonical returns true if the signature is in the low-S form (S <= N/2, where N is the curve order).
+// For each valid signature (R, S) the signature (R, N-S) is valid as well,
+// so only one of them (the low-S one) is accepted, otherwise the signature might be changed
+// by the third party without invalidating it.
+func (s *Signature) IsCanonical() bool {
+	return s.S != nil && s.S.Sign() > 0 && s.S.Cmp(curveHalfOrder) <= 0
 }
 
-func (s *Signatures) Count() uint16 {
-	return uint16(len(s.At))
-}
-
-func (s *Signatures) Add(sig Signature) error {
-	if s.Count() < uint16(SignaturesMaxCount) {
-		s.At = append(s.At, sig)
-		return nil
+// Canonicalize converts the signature to the low-S form (see IsCanonical()).
+// Signature remains valid.
+func (s *Signature) Canonicalize() {
+	if s.S != nil && s.S.Cmp(curveHalfOrder) > 0 {
+		s.S = new(big.Int).Sub(curveOrder, s.S)
 	}
-
-	return errors.MaxCountReached
 }
 
-// Format:
-// 2B - Total signatures count.
-// [2B, 2B, ... 2B] - At sizes.
-// [NB, NB, ... NB] - At bodies.
-func (s *Signatures) MarshalBinary() (data []byte, err error) {
-	var (
-		initialDataSize = common.Uint16ByteSize + // Total signatures count.
-			common.Uint16ByteSize*s.Count() // At sizes fields.
-	)
-
-	data = make([]byte, 0, initialDataSize)
-	data = append(data, utils.MarshalUint16(s.Count())...)
-	signaturesBodies := make([][]byte, 0, s.Count())
-
-	for _, signature := range s.At {
-		sigBinary, err := signature.MarshalBinary()
-		if err != nil {
-			return nil, err
-		}
-
-		// Skip empty signature, if any.
-		if len(sigBinary) == 0 {
-			continue
-		}
-
-		// Append signature size directly to the data stream.
-		data = append(utils.MarshalUint16(uint16(len(sigBinary))))
-
-		// ClaimsHashes would be attached to the data after all signaturesBodies size fields would be written.
-		signaturesBodies = append(signaturesBodies, sigBinary)
+// Verify returns true if the signature of the digest is valid for the public key specified,
+// and is in the canonical low-S form (see IsCanonical()).
+// All signatures verifications must be done via this method,
+// otherwise the non canonical signature might be accepted in one place and rejected in other.
+func (s *Signature) Verify(pubKey *e.PublicKey, digest []byte) bool {
+	if pubKey == nil || s.R == nil || !s.IsCanonical() {
+		return false
 	}
 
-	data = append(data, utils.ChainByteSlices(signaturesBodies...)...)
-	return
+	return e.Verify(pubKey, digest, s.R, s.S)
 }
 
-func (s *Signatures) UnmarshalBinary(data []byte) (err error) {
-	count, err := utils.UnmarshalUint16(data[:common.Uint16ByteSize])
-	if err != nil {
-		return
-	}
-
-	s.At = make([]Signature, count, count)
-	if count == 0 {
-		return
-	}
-
-	signaturesSizes := make([]uint16, 0, count)
-
-	var i uint16
-	var offset uint16 = common.Uint16ByteSize
-	for i = 0; i < count; i++ {
-		signatureSize, err := utils.UnmarshalUint16(data[offset : offset+common.Uint16ByteSize])
-		if err != nil {
-			return err
-		}
-		if signatureSize == 0 {
-			err = errors.InvalidDataFormat
-		}
-
-		signaturesSizes = append(signaturesSizes, signatureSize)
-	}
-
-	offset = common.Uint16ByteSize
-	for i = 0; i < count; i++ {
-		signatureSize := signaturesSizes[i]
-		err = s.At[i].UnmarshalBinary(data[offset : offset+signatureSize])
-		if err != nil {
-			return
-		}
-
-		offset += signatureSize
-	}
-
-	return
+func isValidScalar(i *big.Int) bool {
+	return i.Sign() > 0 && i.BitLen() <= SignatureScalarBytesSize*8
 }
@@ -0,0 +1,80 @@
+package ecdsa
+
+import (
+	"geo-observers-blockchain/core/common/errors"
+	"math/big"
+	"testing"
+)
+
+func TestSignature_UnmarshalBinary_TooShortData(t *testing.T) {
+	sig := &Signature{}
+	err := sig.UnmarshalBinary([]byte{0, 0, 0, 0, 0, 0})
+	if err != errors.InvalidDataFormat {
+		t.Fatal()
+	}
+}
+
+// Data with trailing bytes must be rejected, even if it's prefix is a valid signature.
+func TestSignature_UnmarshalBinary_TooLongData(t *testing.T) {
+	sig := &Signature{R: big.NewInt(1), S: big.NewInt(1)}
+	data, err := sig.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := &Signature{}
+	err = restored.UnmarshalBinary(append(data, 0))
+	if err != errors.InvalidDataFormat {
+		t.Fatal("data with trailing bytes must be rejected")
+	}
+}
+
+// Signature must be marshalled into the fixed size binary representation, regardless of R and S sizes.
+func TestSignature_MarshalBinary_FixedSize(t *testing.T) {
+	sig := &Signature{R: big.NewInt(1), S: new(big.Int).Sub(curveOrder, big.NewInt(1))}
+	data, err := sig.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data) != SignatureBinarySize || data[SignatureScalarBytesSize-1] != 1 {
+		t.Fatal()
+	}
+
+	restored := &Signature{}
+	err = restored.UnmarshalBinary(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if restored.R.Cmp(sig.R) != 0 || restored.S.Cmp(sig.S) != 0 {
+		t.Fatal()
+	}
+
+	// Scalars, that does not fit into the binary representation, must be rejected.
+	sig.S = new(big.Int).Lsh(big.NewInt(1), SignatureScalarBytesSize*8)
+	_, err = sig.MarshalBinary()
+	if err != errors.InvalidDataFormat {
+		t.Fatal()
+	}
+}
+
+// High-S signature must be converted to the low-S form, low-S signature must be left untouched.
+func TestSignature_Canonicalize(t *testing.T) {
+	highS := new(big.Int).Add(curveHalfOrder, big.NewInt(1))
+	sig := &Signature{R: big.NewInt(1), S: new(big.Int).Set(highS)}
+	if sig.IsCanonical() {
+		t.Fatal("high-S signature must not be canonical")
+	}
+
+	sig.Canonicalize()
+	if !sig.IsCanonical() || sig.S.Cmp(new(big.Int).Sub(curveOrder, highS)) != 0 {
+		t.Fatal()
+	}
+
+	lowS := new(big.Int).Set(sig.S)
+	sig.Canonicalize()
+	if sig.S.Cmp(lowS) != 0 {
+		t.Fatal("canonical signature must be left untouched")
+	}
+}
@@ -0,0 +1,119 @@
+package ecdsa
+
+import (
+	"geo-observers-blockchain/core/common"
+	"geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/settings"
+	"geo-observers-blockchain/core/utils"
+)
+
+var (
+	SignaturesMaxCount = settings.ObserversMaxCount
+)
+
+type Signatures struct {
+	At []Signature
+}
+
+func (s *Signatures) Count() uint16 {
+	return uint16(len(s.At))
+}
+
+func (s *Signatures) Add(sig Signature) error {
+	if s.Count() < uint16(SignaturesMaxCount) {
+		s.At = append(s.At, sig)
+		return nil
+	}
+
+	return errors.MaxCountReached
+}
+
+// Format:
+// 2B - Total signatures count.
+// [2B, 2B, ... 2B] - At sizes.
+// [NB, NB, ... NB] - At bodies.
+func (s *Signatures) MarshalBinary() (data []byte, err error) {
+	var (
+		initialDataSize = common.Uint16ByteSize + // Total signatures count.
+			common.Uint16ByteSize*s.Count() // At sizes fields.
+	)
+
+	data = make([]byte, 0, initialDataSize)
+	data = append(data, utils.MarshalUint16(s.Count())...)
+	signaturesBodies := make([][]byte, 0, s.Count())
+
+	for _, signature := range s.At {
+		sigBinary, err := signature.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+
+		// Skip empty signature, if any.
+		if len(sigBinary) == 0 {
+			continue
+		}
+
+		// Append signature size directly to the data stream.
+		data = append(data, utils.MarshalUint16(uint16(len(sigBinary)))...)
+
+		// ClaimsHashes would be attached to the data after all signaturesBodies size fields would be written.
+		signaturesBodies = append(signaturesBodies, sigBinary)
+	}
+
+	data = append(data, utils.ChainByteSlices(signaturesBodies...)...)
+	return
+}
+
+func (s *Signatures) UnmarshalBinary(data []byte) (err error) {
+	if len(data) < common.Uint16ByteSize {
+		return errors.InvalidDataFormat
+	}
+
+	count, err := utils.UnmarshalUint16(data[:common.Uint16ByteSize])
+	if err != nil {
+		return
+	}
+
+	if int(count) > SignaturesMaxCount || len(data) < common.Uint16ByteSize*(int(count)+1) {
+		return errors.InvalidDataFormat
+	}
+
+	s.At = make([]Signature, count, count)
+	if count == 0 {
+		return
+	}
+
+	signaturesSizes := make([]uint16, 0, count)
+
+	var i uint16
+	var offset = common.Uint16ByteSize
+	for i = 0; i < count; i++ {
+		signatureSize, err := utils.UnmarshalUint16(data[offset : offset+common.Uint16ByteSize])
+		if err != nil {
+			return err
+		}
+		if signatureSize == 0 {
+			return errors.InvalidDataFormat
+		}
+
+		signaturesSizes = append(signaturesSizes, signatureSize)
+		offset += common.Uint16ByteSize
+	}
+
+	// Bodies follows the sizes fields.
+	for i = 0; i < count; i++ {
+		signatureSize := int(signaturesSizes[i])
+		if len(data) < offset+signatureSize {
+			return errors.InvalidDataFormat
+		}
+
+		err = s.At[i].UnmarshalBinary(data[offset : offset+signatureSize])
+		if err != nil {
+			return
+		}
+
+		offset += signatureSize
+	}
+
+	return
+}
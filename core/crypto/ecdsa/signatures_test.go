@@ -3,24 +3,13 @@ package ecdsa
 import (
 	"bytes"
 	"geo-observers-blockchain/core/common"
-	"geo-observers-blockchain/core/common/types"
+	"geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/utils"
 	"math/big"
 	"math/rand"
 	"testing"
 )
 
-func TestSignature_UnmarshalBinary_TooShortData(t *testing.T) {
-	sig := &Signature{}
-	err := sig.UnmarshalBinary([]byte{0, 0, 0, 0, 0, 0})
-	if err != common.ErrInvalidDataFormat {
-		t.Fatal()
-	}
-}
-
-// Note:
-// MarshallBinary() and UnmarshalBinary methods are checked further.
-
-// --------------------------------------------------------------------------------------------------------------------
 func TestSignatures_Add_Max(t *testing.T) {
 	signatures := &Signatures{}
 	for i := 0; i < SignaturesMaxCount; i++ {
@@ -31,7 +20,7 @@ func TestSignatures_Add_Max(t *testing.T) {
 	}
 
 	err := signatures.Add(Signature{})
-	if err != common.ErrMaxCountReached {
+	if err != errors.MaxCountReached {
 		t.Fatal("signatures list must restrict max count of elements")
 	}
 }
@@ -61,7 +50,7 @@ func TestSignatures_MarshallBinary_NoData(t *testing.T) {
 
 	// Empty serialised signatures must be 2 bytes long.
 	// (total count if signatures serializes as uint16)
-	if len(binary) != types.Uint16ByteSize {
+	if len(binary) != common.Uint16ByteSize {
 		t.Fatal()
 	}
 
@@ -131,7 +120,7 @@ func TestSignatures_MarshallBinary_MaxElementsCount(t *testing.T) {
 	_ = restoredSignatures.UnmarshalBinary(binary)
 
 	// Checks
-	if restoredSignatures.Count() != SignaturesMaxCount {
+	if int(restoredSignatures.Count()) != SignaturesMaxCount {
 		t.Fatal()
 	}
 	if restoredSignatures.Count() != signatures.Count() {
@@ -148,3 +137,78 @@ func TestSignatures_MarshallBinary_MaxElementsCount(t *testing.T) {
 		}
 	}
 }
+
+// Truncated data must be rejected instead of causing out of range panic.
+func TestSignatures_UnmarshalBinary_TruncatedData(t *testing.T) {
+	signatures := &Signatures{}
+	_ = signatures.Add(Signature{R: big.NewInt(1), S: big.NewInt(1)})
+
+	binary, err := signatures.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, size := range []int{0, 1, common.Uint16ByteSize, common.Uint16ByteSize * 2, len(binary) - 1} {
+		restored := &Signatures{}
+		if restored.UnmarshalBinary(binary[:size]) != errors.InvalidDataFormat {
+			t.Fatal("truncated data must be rejected: ", size)
+		}
+	}
+}
+
+// Regression: sizes fields were overwriting the total count field instead of being appended to it,
+// so the list of several signatures could not be read back.
+func TestSignatures_MarshalBinary_Layout(t *testing.T) {
+	signatures := &Signatures{}
+	for i := int64(1); i <= 3; i++ {
+		_ = signatures.Add(Signature{R: big.NewInt(i), S: big.NewInt(i)})
+	}
+
+	binary, err := signatures.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(binary) != common.Uint16ByteSize*4+SignatureBinarySize*3 {
+		t.Fatal("invalid binary size")
+	}
+
+	if bytes.Compare(binary[:common.Uint16ByteSize], utils.MarshalUint16(3)) != 0 {
+		t.Fatal("invalid total count field")
+	}
+
+	restored := &Signatures{}
+	err = restored.UnmarshalBinary(binary)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, sig := range signatures.At {
+		if sig.R.Cmp(restored.At[i].R) != 0 || sig.S.Cmp(restored.At[i].S) != 0 {
+			t.Fatal("restored signature differs from the original one")
+		}
+	}
+}
+
+// Header, that declares too many signatures or empty signature, must be rejected.
+func TestSignatures_UnmarshalBinary_InvalidHeader(t *testing.T) {
+	signatures := &Signatures{}
+	_ = signatures.Add(Signature{R: big.NewInt(1), S: big.NewInt(1)})
+
+	binary, err := signatures.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tooMany := append([]byte{}, binary...)
+	tooMany[0], tooMany[1] = 0xFF, 0xFF
+	if (&Signatures{}).UnmarshalBinary(tooMany) != errors.InvalidDataFormat {
+		t.Fatal("count over SignaturesMaxCount must be rejected")
+	}
+
+	zeroSize := append([]byte{}, binary...)
+	zeroSize[2], zeroSize[3] = 0, 0
+	if (&Signatures{}).UnmarshalBinary(zeroSize) != errors.InvalidDataFormat {
+		t.Fatal("empty signature must be rejected")
+	}
+}
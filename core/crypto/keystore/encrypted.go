@@ -0,0 +1,223 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	e "crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"geo-observers-blockchain/core/common/errors"
+	"golang.org/x/crypto/pbkdf2"
+	"hash"
+)
+
+// Password encrypted private keys are stored in PKCS#8 format (RFC 5958, "ENCRYPTED PRIVATE KEY" PEM block).
+// Encryption scheme is PBES2 (RFC 8018): key is derived via PBKDF2 and the data is encrypted with AES-CBC.
+// Keys are encrypted with PBKDF2-HMAC-SHA256 and AES-256-CBC (the same as "openssl pkcs8 -topk8 -v2 aes256" does),
+// HMAC-SHA1 and AES-128/192 are accepted on decryption as well.
+
+const (
+	pemTypePrivateKey          = "PRIVATE KEY"
+	pemTypeEncryptedPrivateKey = "ENCRYPTED PRIVATE KEY"
+
+	// Amount of PBKDF2 iterations for the newly encrypted keys.
+	EncryptedKeyIterationsCount = 100000
+
+	encryptedKeySaltSize = 16
+)
+
+var (
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES128CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+func (k *KeyStore) encodeEncryptedPKeyToPem(passphrase string) (pemEncoded string, err error) {
+	if passphrase == "" {
+		err = ErrKeyPassphraseRequired
+		return
+	}
+
+	plain, err := x509.MarshalPKCS8PrivateKey(k.privateKey())
+	if err != nil {
+		return
+	}
+
+	salt := make([]byte, encryptedKeySaltSize)
+	iv := make([]byte, aes.BlockSize)
+	_, err = rand.Read(salt)
+	if err != nil {
+		return
+	}
+
+	_, err = rand.Read(iv)
+	if err != nil {
+		return
+	}
+
+	key := pbkdf2.Key([]byte(passphrase), salt, EncryptedKeyIterationsCount, 32, sha256.New)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return
+	}
+
+	padding := aes.BlockSize - len(plain)%aes.BlockSize
+	data := append(plain, bytes.Repeat([]byte{byte(padding)}, padding)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, data)
+
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:           salt,
+		IterationCount: EncryptedKeyIterationsCount,
+		PRF:            pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue},
+	})
+	if err != nil {
+		return
+	}
+
+	ivParam, err := asn1.Marshal(iv)
+	if err != nil {
+		return
+	}
+
+	schemeParams, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParam}},
+	})
+	if err != nil {
+		return
+	}
+
+	der, err := asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: schemeParams}},
+		EncryptedData: data,
+	})
+	if err != nil {
+		return
+	}
+
+	pemEncoded = string(pem.EncodeToMemory(&pem.Block{Type: pemTypeEncryptedPrivateKey, Bytes: der}))
+	return
+}
+
+// decryptPKCS8 returns PKCS#8 encoded private key, decrypted from the "ENCRYPTED PRIVATE KEY" block data.
+// Returns ErrKeyDecryptionFailed in case if passphrase is wrong.
+func decryptPKCS8(der []byte, passphrase string) (plain []byte, err error) {
+	info := encryptedPrivateKeyInfo{}
+	rest, err := asn1.Unmarshal(der, &info)
+	if err != nil || len(rest) != 0 || !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, errors.InvalidDataFormat
+	}
+
+	params := pbes2Params{}
+	_, err = asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params)
+	if err != nil || !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, errors.InvalidDataFormat
+	}
+
+	kdfParams := pbkdf2Params{}
+	_, err = asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdfParams)
+	if err != nil || kdfParams.IterationCount <= 0 {
+		return nil, errors.InvalidDataFormat
+	}
+
+	var prf func() hash.Hash
+	switch {
+	case kdfParams.PRF.Algorithm == nil || kdfParams.PRF.Algorithm.Equal(oidHMACWithSHA1):
+		// HMAC-SHA1 is the default PRF of PBKDF2.
+		prf = sha1.New
+
+	case kdfParams.PRF.Algorithm.Equal(oidHMACWithSHA256):
+		prf = sha256.New
+
+	default:
+		return nil, errors.InvalidDataFormat
+	}
+
+	var keyLength int
+	switch {
+	case params.EncryptionScheme.Algorithm.Equal(oidAES128CBC):
+		keyLength = 16
+	case params.EncryptionScheme.Algorithm.Equal(oidAES192CBC):
+		keyLength = 24
+	case params.EncryptionScheme.Algorithm.Equal(oidAES256CBC):
+		keyLength = 32
+	default:
+		return nil, errors.InvalidDataFormat
+	}
+
+	var iv []byte
+	_, err = asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv)
+	if err != nil || len(iv) != aes.BlockSize {
+		return nil, errors.InvalidDataFormat
+	}
+
+	data := info.EncryptedData
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, errors.InvalidDataFormat
+	}
+
+	key := pbkdf2.Key([]byte(passphrase), kdfParams.Salt, kdfParams.IterationCount, keyLength, prf)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return
+	}
+
+	plain = make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data)
+
+	// Padding is checked, but it might be valid by chance even for the wrong passphrase,
+	// so the key parsing failure is treated as decryption failure as well (see decodePKeyFromPem()).
+	padding := int(plain[len(plain)-1])
+	if padding == 0 || padding > aes.BlockSize {
+		return nil, ErrKeyDecryptionFailed
+	}
+
+	for _, b := range plain[len(plain)-padding:] {
+		if int(b) != padding {
+			return nil, ErrKeyDecryptionFailed
+		}
+	}
+
+	return plain[:len(plain)-padding], nil
+}
+
+func parsePKCS8ECPrivateKey(der []byte) (pkey *e.PrivateKey, err error) {
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return
+	}
+
+	pkey, isECDSA := key.(*e.PrivateKey)
+	if !isECDSA {
+		return nil, errors.InvalidDataFormat
+	}
+
+	return
+}
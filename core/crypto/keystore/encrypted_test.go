@@ -0,0 +1,110 @@
+package keystore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Encrypts the key and decrypts it back with the right, wrong and absent passphrases.
+func TestKeyStore_EncryptedPem(t *testing.T) {
+	k, err := NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pemEncoded, err := k.encodeEncryptedPKeyToPem("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := &KeyStore{}
+	err = reloaded.decodePKeyFromPem(pemEncoded, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reloaded.Fingerprint() != k.Fingerprint() {
+		t.Fatal("other key decrypted")
+	}
+
+	err = (&KeyStore{}).decodePKeyFromPem(pemEncoded, "wrong")
+	if err != ErrKeyDecryptionFailed {
+		t.Fatal()
+	}
+
+	err = (&KeyStore{}).decodePKeyFromPem(pemEncoded, "")
+	if err != ErrKeyPassphraseRequired {
+		t.Fatal()
+	}
+
+	_, err = k.encodeEncryptedPKeyToPem("")
+	if err != ErrKeyPassphraseRequired {
+		t.Fatal()
+	}
+}
+
+// Loads encrypted key with the passphrase, read from the environment variable,
+// and checks that unencrypted keys are still loaded even if the passphrase is present.
+func TestNew_EncryptedPassphraseFromEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keystore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	k, err := NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted, err := k.encodeEncryptedPKeyToPem("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plain, err := k.encodePKeyToPem()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encryptedPath := filepath.Join(dir, "encrypted.key")
+	plainPath := filepath.Join(dir, "plain.key")
+	if ioutil.WriteFile(encryptedPath, []byte(encrypted), 0600) != nil ||
+		ioutil.WriteFile(plainPath, []byte(plain), 0600) != nil {
+		t.Fatal()
+	}
+
+	previous, wasSet := os.LookupEnv(PassphraseEnvVar)
+	defer func() {
+		if wasSet {
+			os.Setenv(PassphraseEnvVar, previous)
+		} else {
+			os.Unsetenv(PassphraseEnvVar)
+		}
+	}()
+
+	os.Unsetenv(PassphraseEnvVar)
+	_, err = New(encryptedPath, "")
+	if err != ErrKeyPassphraseRequired {
+		t.Fatal()
+	}
+
+	os.Setenv(PassphraseEnvVar, "secret")
+	for _, path := range []string{encryptedPath, plainPath} {
+		loaded, err := New(path, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if loaded.Fingerprint() != k.Fingerprint() {
+			t.Fatal("other key loaded")
+		}
+	}
+
+	_, err = New(encryptedPath, "wrong")
+	if err != ErrKeyDecryptionFailed {
+		t.Fatal("explicit passphrase must take precedence")
+	}
+}
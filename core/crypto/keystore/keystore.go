@@ -2,72 +2,246 @@ package keystore
 
 import (
 	e "crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/pem"
+	"geo-observers-blockchain/core/common/errors"
 	"geo-observers-blockchain/core/common/types/hash"
 	"geo-observers-blockchain/core/crypto/ecdsa"
+	"geo-observers-blockchain/core/utils"
 	log "github.com/sirupsen/logrus"
 	"io/ioutil"
 	"os"
+	"sync"
+)
+
+const (
+	// Path of the private key, that is used by default (relative to the working directory).
+	DefaultKeyPath = "p521.key"
+
+	// Environment variable, from which the passphrase of the encrypted private key is read,
+	// in case if it is not specified explicitly (so it is not needed to pass it via command line).
+	PassphraseEnvVar = "GEO_OBSERVER_KEY_PASSPHRASE"
+
+	// Amount of bytes of the public key hash, that are included into the fingerprint.
+	FingerprintBytesSize = 8
+)
+
+var (
+	// No key has been provisioned: there is no file with the key.
+	ErrKeyNotFound = utils.Error("keystore", "private key file not found")
+
+	// Key file is present, but it's content can't be parsed as PEM encoded private key.
+	ErrKeyInvalid = utils.Error("keystore", "private key is corrupted or has unsupported format")
+
+	// Key file is already present and must not be overwritten.
+	ErrKeyExists = utils.Error("keystore", "private key file already exists")
+
+	// Key is encrypted, but no passphrase has been specified.
+	ErrKeyPassphraseRequired = utils.Error("keystore", "private key is encrypted, passphrase required")
+
+	// Key is encrypted, and it can't be decrypted with the passphrase specified.
+	ErrKeyDecryptionFailed = utils.Error("keystore", "private key can't be decrypted, passphrase is wrong")
 )
 
 type KeyStore struct {
 	pkey *e.PrivateKey
+
+	// Public keys, that has been used before the key rotation (see Rotate()).
+	previous []previousKey
+
+	// Protects the key and the previous keys from the concurrent rotation.
+	mutex sync.RWMutex
 }
 
-func New() (keystore *KeyStore, err error) {
-	keyFile, err := os.Open("p521.key")
+// New loads PEM encoded private key from the file specified.
+// Key might be encrypted (see encrypted.go), in this case it is decrypted with the passphrase.
+// If passphrase is empty - it is read from the PassphraseEnvVar.
+// Unencrypted keys are loaded as is, passphrase is ignored.
+//
+// Returns ErrKeyNotFound in case if there is no such file,
+// and ErrKeyInvalid in case if file is present, but the key can't be parsed.
+// Returns ErrKeyPassphraseRequired or ErrKeyDecryptionFailed in case if encrypted key can't be decrypted.
+// Other errors (for example, permissions errors) are returned wrapped.
+func New(path, passphrase string) (keystore *KeyStore, err error) {
+	pemEncoded, err := ioutil.ReadFile(path)
 	if err != nil {
-		return
+		if os.IsNotExist(err) {
+			log.WithFields(log.Fields{"prefix": "keystore", "Path": path}).Error("No private key provisioned")
+			return nil, ErrKeyNotFound
+		}
+
+		return nil, utils.Wrap(err, "can't read private key "+path)
+	}
+
+	if passphrase == "" {
+		passphrase = os.Getenv(PassphraseEnvVar)
+	}
+
+	keystore = &KeyStore{}
+	err = keystore.decodePKeyFromPem(string(pemEncoded), passphrase)
+	if err == ErrKeyPassphraseRequired || err == ErrKeyDecryptionFailed {
+		return nil, err
 	}
 
-	pemEncoded, err := ioutil.ReadAll(keyFile)
+	if err != nil {
+		log.WithFields(log.Fields{"prefix": "keystore", "Path": path}).Error("Can't parse private key: ", err)
+		return nil, ErrKeyInvalid
+	}
+
+	keystore.log().WithFields(log.Fields{"Fingerprint": keystore.Fingerprint(), "Path": path}).Info("Key loaded")
+	return
+}
+
+// NewDefault loads the private key from the DefaultKeyPath.
+// Passphrase of the encrypted key is read from the PassphraseEnvVar.
+func NewDefault() (keystore *KeyStore, err error) {
+	return New(DefaultKeyPath, "")
+}
+
+// NewFromPrivateKey returns keystore, that uses the private key specified.
+// Key is never persisted (see GenerateAndPersist()).
+func NewFromPrivateKey(pkey *e.PrivateKey) *KeyStore {
+	return &KeyStore{pkey: pkey}
+}
+
+// NewInMemory generates new ephemeral P-521 private key and returns keystore, that uses it.
+// Key is never persisted, so it is lost as soon as the keystore is released
+// (intended for the tests and for the ephemeral nodes).
+func NewInMemory() (keystore *KeyStore, err error) {
+	pkey, err := e.GenerateKey(elliptic.P521(), rand.Reader)
 	if err != nil {
 		return
 	}
 
-	keystore = &KeyStore{}
-	err = keystore.decodePKeyFromPem(string(pemEncoded))
+	return NewFromPrivateKey(pkey), nil
+}
+
+// GenerateAndPersist generates new P-521 private key and writes it (PEM encoded) to the file specified.
+// The file is readable only by the owner.
+// Returns ErrKeyExists in case if file is already present, and "overwrite" is false.
+func GenerateAndPersist(path string, overwrite bool) (keystore *KeyStore, err error) {
+	keystore, err = NewInMemory()
 	if err != nil {
 		return
 	}
 
+	pemEncoded, err := keystore.encodePKeyToPem()
+	if err != nil {
+		return nil, err
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if overwrite {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+
+	keyFile, err := os.OpenFile(path, flags, 0600)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, ErrKeyExists
+		}
+
+		return nil, utils.Wrap(err, "can't create private key "+path)
+	}
+	defer keyFile.Close()
+
+	// Overwritten file keeps it's previous permissions.
+	err = keyFile.Chmod(0600)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = keyFile.WriteString(pemEncoded)
+	if err != nil {
+		return nil, err
+	}
+
+	err = keyFile.Sync()
+	if err != nil {
+		return nil, err
+	}
+
+	keystore.log().WithFields(log.Fields{"Fingerprint": keystore.Fingerprint(), "Path": path}).Info("Key generated")
 	return
 }
 
+// Fingerprint returns short identifier of the public key (hex encoded truncated SHA-256 of it's PKIX encoding).
+// It is intended to be used in logs and dashboards to check which key is used by the observer,
+// without exposing the key itself.
+// Returns empty string in case if public key can't be encoded.
+func (k *KeyStore) Fingerprint() string {
+	return fingerprint(k.PublicKey())
+}
+
+// PublicKey returns public key of the observer.
+func (k *KeyStore) PublicKey() *e.PublicKey {
+	return &k.privateKey().PublicKey
+}
+
+// PublicKeyPEM returns PEM encoded (PKIX, "PUBLIC KEY" block) public key of the observer.
+// Is intended to be published, for example, on the observers configuration contract.
+func (k *KeyStore) PublicKeyPEM() (pemEncoded string, err error) {
+	return k.encodePubKeyToPem()
+}
+
 func (k *KeyStore) IsEqualPubKey(key *e.PublicKey) bool {
-	return k.pkey.PublicKey.X.Cmp(key.X) == 0 &&
-		k.pkey.PublicKey.Y.Cmp(key.Y) == 0
+	pubKey := k.PublicKey()
+	return pubKey.X.Cmp(key.X) == 0 &&
+		pubKey.Y.Cmp(key.Y) == 0
 }
 
+// SignHash signs the hash with the current key.
+// Signature is always returned in the canonical low-S form (see ecdsa.Signature.IsCanonical()).
 func (k *KeyStore) SignHash(h hash.SHA256Container) (signature *ecdsa.Signature, err error) {
 	signature = &ecdsa.Signature{}
-	signature.R, signature.S, err = e.Sign(rand.Reader, k.pkey, h.Bytes[:])
+	signature.R, signature.S, err = e.Sign(rand.Reader, k.privateKey(), h.Bytes[:])
+	if err != nil {
+		return nil, err
+	}
+
+	signature.Canonicalize()
 	return
 }
 
+// CheckOwnSignature verifies the signature with the current key,
+// or with one of the previous keys, that has not expired yet (see Rotate()).
 func (k *KeyStore) CheckOwnSignature(h hash.SHA256Container, sig ecdsa.Signature) bool {
-	return e.Verify(&k.pkey.PublicKey, h.Bytes[:], sig.R, sig.S)
+	return k.CheckExternalSignatureAnyValid(h, sig, k.AcceptedPubKeys())
 }
 
+// CheckExternalSignature verifies the signature with the public key specified.
+// Signatures, that are not in the canonical low-S form, are rejected (see ecdsa.Signature.IsCanonical()).
 func (k *KeyStore) CheckExternalSignature(h hash.SHA256Container, sig ecdsa.Signature, pubKey *e.PublicKey) bool {
-	return e.Verify(pubKey, h.Bytes[:], sig.R, sig.S)
+	return sig.Verify(pubKey, h.Bytes[:])
+}
+
+func fingerprint(pubKey *e.PublicKey) string {
+	x509Encoded, err := x509.MarshalPKIXPublicKey(pubKey)
+	if err != nil {
+		return ""
+	}
+
+	digest := sha256.Sum256(x509Encoded)
+	return hex.EncodeToString(digest[:FingerprintBytesSize])
 }
 
 func (k *KeyStore) encodePKeyToPem() (pemEncoded string, err error) {
-	x509Encoded, err := x509.MarshalECPrivateKey(k.pkey)
+	x509Encoded, err := x509.MarshalECPrivateKey(k.privateKey())
 	if err != nil {
 		return
 	}
 
-	pemEncodedBytes := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: x509Encoded})
+	pemEncodedBytes := pem.EncodeToMemory(&pem.Block{Type: pemTypePrivateKey, Bytes: x509Encoded})
 	pemEncoded = string(pemEncodedBytes)
 	return
 }
 
 func (k *KeyStore) encodePubKeyToPem() (pemEncoded string, err error) {
-	x509Encoded, err := x509.MarshalPKIXPublicKey(&k.pkey.PublicKey)
+	x509Encoded, err := x509.MarshalPKIXPublicKey(k.PublicKey())
 	if err != nil {
 		return
 	}
@@ -77,9 +251,39 @@ func (k *KeyStore) encodePubKeyToPem() (pemEncoded string, err error) {
 	return
 }
 
-func (k *KeyStore) decodePKeyFromPem(pemEncodedPKey string) (err error) {
+// decodePKeyFromPem parses the private key.
+// Encrypted keys ("ENCRYPTED PRIVATE KEY" block) are decrypted with the passphrase.
+// Unencrypted keys might be in SEC 1 or in PKCS#8 form.
+func (k *KeyStore) decodePKeyFromPem(pemEncodedPKey, passphrase string) (err error) {
 	block, _ := pem.Decode([]byte(pemEncodedPKey))
+	if block == nil {
+		return errors.InvalidDataFormat
+	}
+
+	if block.Type == pemTypeEncryptedPrivateKey {
+		if passphrase == "" {
+			return ErrKeyPassphraseRequired
+		}
+
+		plain, err := decryptPKCS8(block.Bytes, passphrase)
+		if err != nil {
+			return err
+		}
+
+		k.pkey, err = parsePKCS8ECPrivateKey(plain)
+		if err != nil {
+			// See decryptPKCS8() for the details.
+			return ErrKeyDecryptionFailed
+		}
+
+		return nil
+	}
+
 	k.pkey, err = x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		k.pkey, err = parsePKCS8ECPrivateKey(block.Bytes)
+	}
+
 	return
 }
 
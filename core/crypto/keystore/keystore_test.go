@@ -0,0 +1,235 @@
+package keystore
+
+import (
+	e "crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"geo-observers-blockchain/core/common/types/hash"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Reloads the same key from it's PEM representation
+// and checks that the fingerprint is not changed.
+func TestKeyStore_Fingerprint_StableAcrossReloads(t *testing.T) {
+	k, err := NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pemEncoded, err := k.encodePKeyToPem()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := &KeyStore{}
+	err = reloaded.decodePKeyFromPem(pemEncoded, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fingerprint := k.Fingerprint()
+	if len(fingerprint) != FingerprintBytesSize*2 {
+		t.Fatal()
+	}
+
+	if reloaded.Fingerprint() != fingerprint {
+		t.Fatal("fingerprint of the same key must be stable")
+	}
+}
+
+func TestKeyStore_Fingerprint_DifferentKeys(t *testing.T) {
+	k, err := NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if k.Fingerprint() == other.Fingerprint() {
+		t.Fatal("fingerprints of different keys must differ")
+	}
+}
+
+// Checks that absent and corrupted keys are reported with different errors,
+// and that the valid key is loaded from the path specified.
+func TestNew_Path(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keystore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	_, err = New(filepath.Join(dir, "absent.key"), "")
+	if err != ErrKeyNotFound {
+		t.Fatal()
+	}
+
+	corruptedPath := filepath.Join(dir, "corrupted.key")
+	err = ioutil.WriteFile(corruptedPath, []byte("not a key"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = New(corruptedPath, "")
+	if err != ErrKeyInvalid {
+		t.Fatal()
+	}
+
+	k, err := NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pemEncoded, err := k.encodePKeyToPem()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validPath := filepath.Join(dir, "valid.key")
+	err = ioutil.WriteFile(validPath, []byte(pemEncoded), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := New(validPath, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.Fingerprint() != k.Fingerprint() {
+		t.Fatal("other key loaded")
+	}
+}
+
+// Generates the key, checks that it is persisted with restricted permissions and is loadable,
+// and that it is not overwritten without explicit permission.
+func TestGenerateAndPersist(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keystore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "p521.key")
+	generated, err := GenerateAndPersist(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm() != 0600 {
+		t.Fatal("invalid permissions")
+	}
+
+	loaded, err := New(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.Fingerprint() != generated.Fingerprint() {
+		t.Fatal("other key loaded")
+	}
+
+	_, err = GenerateAndPersist(path, false)
+	if err != ErrKeyExists {
+		t.Fatal("existing key must not be overwritten")
+	}
+
+	regenerated, err := GenerateAndPersist(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err = New(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.Fingerprint() != regenerated.Fingerprint() || loaded.Fingerprint() == generated.Fingerprint() {
+		t.Fatal("key is not overwritten")
+	}
+}
+
+// Parses exported PEM back and checks that it is the same public key.
+func TestKeyStore_PublicKeyPEM_RoundTrip(t *testing.T) {
+	k, err := NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pemEncoded, err := k.PublicKeyPEM()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, _ := pem.Decode([]byte(pemEncoded))
+	if block == nil || block.Type != "PUBLIC KEY" {
+		t.Fatal("invalid PEM block")
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pubKey, isECDSA := parsed.(*e.PublicKey)
+	if !isECDSA || pubKey.Curve != elliptic.P521() || !k.IsEqualPubKey(pubKey) {
+		t.Fatal("public key mismatch")
+	}
+
+	if k.PublicKey().X.Cmp(pubKey.X) != 0 || k.PublicKey().Y.Cmp(pubKey.Y) != 0 {
+		t.Fatal("public key mismatch")
+	}
+}
+
+// Keystore must use exactly the private key it has been created with.
+func TestNewFromPrivateKey(t *testing.T) {
+	pkey, err := e.GenerateKey(elliptic.P521(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k := NewFromPrivateKey(pkey)
+	if !k.IsEqualPubKey(&pkey.PublicKey) {
+		t.Fatal()
+	}
+
+	h := hash.NewSHA256Container([]byte("data"))
+	signature, err := k.SignHash(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !e.Verify(&pkey.PublicKey, h.Bytes[:], signature.R, signature.S) {
+		t.Fatal("signature must be valid for the original key")
+	}
+}
+
+// Each in memory keystore must have it's own key.
+func TestNewInMemory(t *testing.T) {
+	first, err := NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.IsEqualPubKey(second.PublicKey()) {
+		t.Fatal("keys must be different")
+	}
+}
@@ -0,0 +1,162 @@
+package keystore
+
+import (
+	e "crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/common/types/hash"
+	"geo-observers-blockchain/core/crypto/ecdsa"
+	"math/big"
+)
+
+const (
+	// P-521 scalars and coordinates are 521 bits long, so 66 bytes are needed to store them.
+	RecoverableSignatureScalarBytesSize = 66
+
+	// Format:
+	// 1B  - recovery id.
+	// 66B - R (big endian, zero padded).
+	// 66B - S (big endian, zero padded).
+	RecoverableSignatureBytesSize = 1 + RecoverableSignatureScalarBytesSize*2
+)
+
+// SignHashRecoverable signs the hash and returns compact signature,
+// from which the public key of the signer might be recovered (similar to ethereum's v, r, s).
+// It makes possible to omit the public key from the signed messages.
+func (k *KeyStore) SignHashRecoverable(h hash.SHA256Container) (data []byte, err error) {
+	pkey := k.privateKey()
+	r, s, err := e.Sign(rand.Reader, pkey, h.Bytes[:])
+	if err != nil {
+		return
+	}
+
+	// Signature is converted to the canonical low-S form,
+	// recovery id is found for the converted signature.
+	signature := &ecdsa.Signature{R: r, S: s}
+	signature.Canonicalize()
+	s = signature.S
+
+	// Recovery id is not returned by the standard library,
+	// so it is found by checking all possible candidates.
+	for recoveryID := byte(0); recoveryID < 4; recoveryID++ {
+		pubKey, err := recoverPubKey(pkey.Curve, h, r, s, recoveryID)
+		if err != nil {
+			continue
+		}
+
+		if pubKey.X.Cmp(pkey.X) == 0 && pubKey.Y.Cmp(pkey.Y) == 0 {
+			data = make([]byte, RecoverableSignatureBytesSize)
+			data[0] = recoveryID
+			r.FillBytes(data[1 : 1+RecoverableSignatureScalarBytesSize])
+			s.FillBytes(data[1+RecoverableSignatureScalarBytesSize:])
+			return data, nil
+		}
+	}
+
+	return nil, errors.ExpectationFailed
+}
+
+// RecoverPubKey returns public key of the observer, that has generated the recoverable signature.
+func RecoverPubKey(h hash.SHA256Container, data []byte) (pubKey *e.PublicKey, err error) {
+	if len(data) != RecoverableSignatureBytesSize {
+		return nil, errors.InvalidDataFormat
+	}
+
+	var (
+		recoveryID = data[0]
+		r          = new(big.Int).SetBytes(data[1 : 1+RecoverableSignatureScalarBytesSize])
+		s          = new(big.Int).SetBytes(data[1+RecoverableSignatureScalarBytesSize:])
+	)
+
+	return recoverPubKey(elliptic.P521(), h, r, s, recoveryID)
+}
+
+// CheckRecoverableSignature returns true if recoverable signature
+// was generated by the owner of the public key.
+func (k *KeyStore) CheckRecoverableSignature(h hash.SHA256Container, data []byte, pubKey *e.PublicKey) bool {
+	recoveredPubKey, err := RecoverPubKey(h, data)
+	if err != nil {
+		return false
+	}
+
+	return recoveredPubKey.X.Cmp(pubKey.X) == 0 &&
+		recoveredPubKey.Y.Cmp(pubKey.Y) == 0
+}
+
+// recoverPubKey calculates public key as Q = r^-1 * (s*R - e*G),
+// where R is the curve point restored from the "r" and recovery id.
+func recoverPubKey(
+	curve elliptic.Curve, h hash.SHA256Container, r, s *big.Int, recoveryID byte) (pubKey *e.PublicKey, err error) {
+
+	params := curve.Params()
+	if recoveryID > 3 ||
+		r.Sign() <= 0 || r.Cmp(params.N) >= 0 ||
+		s.Sign() <= 0 || s.Cmp(params.N) >= 0 {
+		return nil, errors.InvalidDataFormat
+	}
+
+	// X coordinate of the R point.
+	x := new(big.Int).Set(r)
+	if recoveryID&2 != 0 {
+		x.Add(x, params.N)
+	}
+	if x.Cmp(params.P) >= 0 {
+		return nil, errors.InvalidDataFormat
+	}
+
+	// Y coordinate of the R point: y^2 = x^3 - 3x + b.
+	y := new(big.Int).Mul(x, x)
+	y.Mul(y, x)
+	threeX := new(big.Int).Lsh(x, 1)
+	threeX.Add(threeX, x)
+	y.Sub(y, threeX)
+	y.Add(y, params.B)
+	y.Mod(y, params.P)
+	if y.ModSqrt(y, params.P) == nil {
+		return nil, errors.InvalidDataFormat
+	}
+	if y.Bit(0) != uint(recoveryID&1) {
+		y.Sub(params.P, y)
+	}
+	if !curve.IsOnCurve(x, y) {
+		return nil, errors.InvalidDataFormat
+	}
+
+	// s*R
+	sRx, sRy := curve.ScalarMult(x, y, s.Bytes())
+
+	// -e*G
+	eGx, eGy := curve.ScalarBaseMult(hashToInt(h, curve).Bytes())
+	eGy.Sub(params.P, eGy)
+
+	Qx, Qy := curve.Add(sRx, sRy, eGx, eGy)
+
+	rInverse := new(big.Int).ModInverse(r, params.N)
+	Qx, Qy = curve.ScalarMult(Qx, Qy, rInverse.Bytes())
+
+	if Qx.Sign() == 0 && Qy.Sign() == 0 {
+		return nil, errors.InvalidDataFormat
+	}
+
+	return &e.PublicKey{Curve: curve, X: Qx, Y: Qy}, nil
+}
+
+// hashToInt converts hash to the integer in the same way as crypto/ecdsa does.
+func hashToInt(h hash.SHA256Container, curve elliptic.Curve) *big.Int {
+	orderBits := curve.Params().N.BitLen()
+	orderBytes := (orderBits + 7) / 8
+
+	data := h.Bytes[:]
+	if len(data) > orderBytes {
+		data = data[:orderBytes]
+	}
+
+	result := new(big.Int).SetBytes(data)
+	excess := len(data)*8 - orderBits
+	if excess > 0 {
+		result.Rsh(result, uint(excess))
+	}
+
+	return result
+}
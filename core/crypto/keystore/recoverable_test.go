@@ -0,0 +1,82 @@
+package keystore
+
+import (
+	"geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/common/types/hash"
+	"testing"
+)
+
+// Signs several hashes and checks that the public key of the signer
+// might be restored from each one signature.
+func TestKeyStore_SignHashRecoverable_RecoversPubKey(t *testing.T) {
+	k, err := NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 16; i++ {
+		h := hash.NewSHA256Container([]byte{byte(i)})
+		data, err := k.SignHashRecoverable(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(data) != RecoverableSignatureBytesSize {
+			t.Fatal()
+		}
+
+		pubKey, err := RecoverPubKey(h, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !k.IsEqualPubKey(pubKey) {
+			t.Fatal("recovered public key differs from the signer's one")
+		}
+
+		if !k.CheckRecoverableSignature(h, data, &k.pkey.PublicKey) {
+			t.Fatal()
+		}
+	}
+}
+
+func TestKeyStore_CheckRecoverableSignature_OtherHashOrKey(t *testing.T) {
+	k, err := NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := hash.NewSHA256Container([]byte("block"))
+	data, err := k.SignHashRecoverable(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if k.CheckRecoverableSignature(hash.NewSHA256Container([]byte("other block")), data, &k.pkey.PublicKey) {
+		t.Fatal("signature must not be valid for other hash")
+	}
+
+	if k.CheckRecoverableSignature(h, data, &other.pkey.PublicKey) {
+		t.Fatal("signature must not be valid for other public key")
+	}
+}
+
+func TestRecoverPubKey_InvalidData(t *testing.T) {
+	h := hash.NewSHA256Container([]byte("block"))
+
+	_, err := RecoverPubKey(h, []byte{0, 1, 2})
+	if err != errors.InvalidDataFormat {
+		t.Fatal()
+	}
+
+	// Zero R and S.
+	_, err = RecoverPubKey(h, make([]byte, RecoverableSignatureBytesSize))
+	if err != errors.InvalidDataFormat {
+		t.Fatal()
+	}
+}
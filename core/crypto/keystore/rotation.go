@@ -0,0 +1,102 @@
+package keystore
+
+import (
+	e "crypto/ecdsa"
+	"geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/common/types/hash"
+	"geo-observers-blockchain/core/crypto/ecdsa"
+	"geo-observers-blockchain/core/settings"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// previousKey is the public key, that has been used before the key rotation.
+// Signatures of this key are accepted until the expiration time.
+type previousKey struct {
+	pubKey  *e.PublicKey
+	expires time.Time
+}
+
+// Rotate replaces the signing key by the new one.
+// Remote observers might still have the old public key for some time,
+// so the old public key is kept in the set of accepted keys during settings.KeyRotationGracePeriod
+// (see AcceptedPubKeys()).
+// Expired keys are dropped on each rotation.
+func (k *KeyStore) Rotate(newKey *e.PrivateKey) error {
+	if newKey == nil {
+		return errors.NilParameter
+	}
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	now := time.Now()
+	previous := make([]previousKey, 0, len(k.previous)+1)
+	for _, key := range k.previous {
+		if now.Before(key.expires) {
+			previous = append(previous, key)
+		}
+	}
+
+	if k.pkey != nil {
+		previous = append(previous, previousKey{
+			pubKey:  &k.pkey.PublicKey,
+			expires: now.Add(settings.KeyRotationGracePeriod),
+		})
+	}
+
+	k.previous = previous
+	k.pkey = newKey
+
+	log.WithFields(log.Fields{"prefix": "keystore", "Fingerprint": fingerprint(&newKey.PublicKey)}).Info("Key rotated")
+	return nil
+}
+
+// AcceptedPubKeys returns the current public key (first) and all previous public keys, that has not expired yet.
+func (k *KeyStore) AcceptedPubKeys() (pubKeys []*e.PublicKey) {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	pubKeys = make([]*e.PublicKey, 0, len(k.previous)+1)
+	pubKeys = append(pubKeys, &k.pkey.PublicKey)
+
+	now := time.Now()
+	for _, key := range k.previous {
+		if now.Before(key.expires) {
+			pubKeys = append(pubKeys, key.pubKey)
+		}
+	}
+
+	return
+}
+
+// CheckExternalSignatureAnyValid returns true if the signature is valid for at least one of the public keys.
+// Is intended to be used during the key rotation, when the signer might use any of the keys
+// (see AcceptedPubKeys()). Nil public keys are skipped.
+func (k *KeyStore) CheckExternalSignatureAnyValid(
+	h hash.SHA256Container, sig ecdsa.Signature, pubKeys []*e.PublicKey) bool {
+
+	if sig.R == nil || sig.S == nil {
+		return false
+	}
+
+	for _, pubKey := range pubKeys {
+		if pubKey == nil {
+			continue
+		}
+
+		if k.CheckExternalSignature(h, sig, pubKey) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// privateKey returns current signing key.
+func (k *KeyStore) privateKey() *e.PrivateKey {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	return k.pkey
+}
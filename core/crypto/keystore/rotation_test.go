@@ -0,0 +1,107 @@
+package keystore
+
+import (
+	e "crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/common/types/hash"
+	"testing"
+	"time"
+)
+
+func newTestPrivateKey(t *testing.T) *e.PrivateKey {
+	pkey, err := e.GenerateKey(elliptic.P521(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pkey
+}
+
+// Signature of the pre-rotation key must be accepted until the previous key expires.
+func TestKeyStore_Rotate_PreviousKeyAcceptedUntilExpiry(t *testing.T) {
+	k, err := NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldPubKey := k.PublicKey()
+
+	h := hash.NewSHA256Container([]byte("block"))
+	signature, err := k.SignHash(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = k.Rotate(newTestPrivateKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if k.IsEqualPubKey(oldPubKey) {
+		t.Fatal("signing key must be replaced")
+	}
+
+	accepted := k.AcceptedPubKeys()
+	if len(accepted) != 2 || !k.IsEqualPubKey(accepted[0]) {
+		t.Fatal("current key must be followed by the previous one")
+	}
+
+	if !k.CheckExternalSignatureAnyValid(h, *signature, accepted) || !k.CheckOwnSignature(h, *signature) {
+		t.Fatal("signature of the previous key must be accepted before expiry")
+	}
+
+	if k.CheckExternalSignature(h, *signature, k.PublicKey()) {
+		t.Fatal("signature of the previous key must not be valid for the current key")
+	}
+
+	// Previous key expires.
+	k.previous[0].expires = time.Now().Add(-time.Second)
+
+	accepted = k.AcceptedPubKeys()
+	if len(accepted) != 1 {
+		t.Fatal("expired key must not be accepted")
+	}
+
+	if k.CheckExternalSignatureAnyValid(h, *signature, accepted) || k.CheckOwnSignature(h, *signature) {
+		t.Fatal("signature of the expired key must be rejected")
+	}
+
+	// Expired keys are dropped on the next rotation.
+	err = k.Rotate(newTestPrivateKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(k.previous) != 1 {
+		t.Fatal("expired key must be dropped")
+	}
+}
+
+// Signatures of the current key must be accepted after rotation.
+func TestKeyStore_Rotate_NewKeySignatures(t *testing.T) {
+	k, err := NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = k.Rotate(newTestPrivateKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := hash.NewSHA256Container([]byte("block"))
+	signature, err := k.SignHash(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !k.CheckOwnSignature(h, *signature) {
+		t.Fatal()
+	}
+
+	if k.Rotate(nil) != errors.NilParameter {
+		t.Fatal()
+	}
+}
@@ -0,0 +1,103 @@
+package keystore
+
+import (
+	"context"
+	e "crypto/ecdsa"
+	"geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/common/types/hash"
+	"geo-observers-blockchain/core/crypto/ecdsa"
+	"geo-observers-blockchain/core/settings"
+	"runtime"
+	"sync"
+)
+
+// ExternalSignature is a signature of the remote observer with it's public key.
+type ExternalSignature struct {
+	Signature *ecdsa.Signature
+	PubKey    *e.PublicKey
+}
+
+// CheckExternalSignatures verifies signatures of the hash one by one.
+// Verification is stopped as soon as ctx is done,
+// or settings.SignaturesVerificationBudget signatures has been verified.
+//
+// "results" has the same length as "signatures": signatures, that are invalid or has not been verified, are false.
+// "isComplete" is false in case if verification has been stopped before all signatures were verified.
+// Signatures without data or public key are considered invalid and are not counted in the budget.
+func (k *KeyStore) CheckExternalSignatures(
+	ctx context.Context, h hash.SHA256Container, signatures []ExternalSignature) (results []bool, isComplete bool) {
+
+	results = make([]bool, len(signatures))
+	verified := 0
+
+	for i, signature := range signatures {
+		if signature.Signature == nil || signature.Signature.R == nil || signature.Signature.S == nil ||
+			signature.PubKey == nil {
+			continue
+		}
+
+		if verified >= settings.SignaturesVerificationBudget {
+			return results, false
+		}
+
+		select {
+		case <-ctx.Done():
+			return results, false
+		default:
+		}
+
+		results[i] = k.CheckExternalSignature(h, *signature.Signature, signature.PubKey)
+		verified++
+	}
+
+	return results, true
+}
+
+// CheckExternalSignaturesBatch verifies signatures of the hash in parallel.
+// Signature with index N is verified with the public key with index N,
+// so the "signatures" and "pubKeys" must have the same length (errors.InvalidParameter is returned otherwise).
+// Amount of goroutines is bounded by the amount of CPUs, since the verification is CPU bound.
+//
+// "results" has the same length as "signatures".
+// Signatures without data or public key are considered invalid.
+func (k *KeyStore) CheckExternalSignaturesBatch(
+	h hash.SHA256Container, signatures []ecdsa.Signature, pubKeys []*e.PublicKey) (results []bool, err error) {
+
+	if len(signatures) != len(pubKeys) {
+		err = errors.InvalidParameter
+		return
+	}
+
+	results = make([]bool, len(signatures))
+	workersCount := runtime.NumCPU()
+	if workersCount > len(signatures) {
+		workersCount = len(signatures)
+	}
+
+	indexes := make(chan int, len(signatures))
+	for i := range signatures {
+		indexes <- i
+	}
+	close(indexes)
+
+	// Each one result is written by exactly one worker, so no synchronisation of the results is needed.
+	wg := sync.WaitGroup{}
+	wg.Add(workersCount)
+	for w := 0; w < workersCount; w++ {
+		go func() {
+			defer wg.Done()
+
+			for i := range indexes {
+				signature := signatures[i]
+				if signature.R == nil || signature.S == nil || pubKeys[i] == nil {
+					continue
+				}
+
+				results[i] = k.CheckExternalSignature(h, signature, pubKeys[i])
+			}
+		}()
+	}
+
+	wg.Wait()
+	return
+}
@@ -0,0 +1,230 @@
+package keystore
+
+import (
+	"context"
+	e "crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/common/types/hash"
+	"geo-observers-blockchain/core/crypto/ecdsa"
+	"geo-observers-blockchain/core/settings"
+	"math/big"
+	"testing"
+)
+
+func newTestExternalSignatures(t *testing.T, h hash.SHA256Container, count int) []ExternalSignature {
+	signer, err := NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signature, err := signer.SignHash(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signatures := make([]ExternalSignature, 0, count)
+	for i := 0; i < count; i++ {
+		signatures = append(signatures, ExternalSignature{Signature: signature, PubKey: &signer.pkey.PublicKey})
+	}
+
+	return signatures
+}
+
+// Verifies the batch, that is larger than the budget,
+// and checks that only the budgeted amount of signatures is verified.
+func TestKeyStore_CheckExternalSignatures_Budget(t *testing.T) {
+	defaultBudget := settings.SignaturesVerificationBudget
+	settings.SignaturesVerificationBudget = 4
+	defer func() { settings.SignaturesVerificationBudget = defaultBudget }()
+
+	h := hash.NewSHA256Container([]byte("block"))
+	signatures := newTestExternalSignatures(t, h, 20)
+
+	// Signatures without data are not counted in the budget.
+	signatures[1] = ExternalSignature{}
+
+	k, err := NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, isComplete := k.CheckExternalSignatures(context.Background(), h, signatures)
+	if isComplete || len(results) != len(signatures) {
+		t.Fatal("verification must be stopped when budget is exceeded")
+	}
+
+	for i, isValid := range results {
+		expected := i < 5 && i != 1
+		if isValid != expected {
+			t.Fatal("invalid verification result at position ", i)
+		}
+	}
+
+	results, isComplete = k.CheckExternalSignatures(context.Background(), h, signatures[:5])
+	if !isComplete || results[1] || !results[4] {
+		t.Fatal()
+	}
+}
+
+// Checks that no signatures are verified after the context is done.
+func TestKeyStore_CheckExternalSignatures_ContextDone(t *testing.T) {
+	h := hash.NewSHA256Container([]byte("block"))
+	signatures := newTestExternalSignatures(t, h, 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	k, err := NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, isComplete := k.CheckExternalSignatures(ctx, h, signatures)
+	if isComplete {
+		t.Fatal()
+	}
+
+	for _, isValid := range results {
+		if isValid {
+			t.Fatal("signatures must not be verified after the context is done")
+		}
+	}
+}
+
+func newTestSignaturesBatch(
+	t testing.TB, h hash.SHA256Container, count int) (signatures []ecdsa.Signature, pubKeys []*e.PublicKey) {
+
+	for i := 0; i < count; i++ {
+		pkey, err := e.GenerateKey(elliptic.P521(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		signature, err := (&KeyStore{pkey: pkey}).SignHash(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		signatures = append(signatures, *signature)
+		pubKeys = append(pubKeys, &pkey.PublicKey)
+	}
+
+	return
+}
+
+// Verifies the batch with valid, forged and empty signatures
+// and checks that each one result corresponds to it's signature.
+func TestKeyStore_CheckExternalSignaturesBatch(t *testing.T) {
+	h := hash.NewSHA256Container([]byte("block"))
+	signatures, pubKeys := newTestSignaturesBatch(t, h, 8)
+
+	// Signature of other observer.
+	pubKeys[2], pubKeys[3] = pubKeys[3], pubKeys[2]
+	signatures[5] = ecdsa.Signature{}
+	pubKeys[6] = nil
+
+	k, err := NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := k.CheckExternalSignaturesBatch(h, signatures, pubKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []bool{true, true, false, false, true, false, false, true}
+	if len(results) != len(expected) {
+		t.Fatal()
+	}
+
+	for i := range expected {
+		if results[i] != expected[i] {
+			t.Fatal("invalid result of signature ", i)
+		}
+	}
+}
+
+func TestKeyStore_CheckExternalSignaturesBatch_LengthMismatch(t *testing.T) {
+	h := hash.NewSHA256Container([]byte("block"))
+	signatures, pubKeys := newTestSignaturesBatch(t, h, 2)
+
+	k, err := NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = k.CheckExternalSignaturesBatch(h, signatures, pubKeys[:1])
+	if err != errors.InvalidParameter {
+		t.Fatal()
+	}
+}
+
+// Mutates valid signature into (R, N-S), which is valid for the curve as well,
+// and checks that it is rejected, and that signing always produces low-S signatures.
+func TestKeyStore_CheckExternalSignature_Malleability(t *testing.T) {
+	k, err := NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	curveOrder := elliptic.P521().Params().N
+
+	for i := 0; i < 16; i++ {
+		h := hash.NewSHA256Container([]byte{byte(i)})
+		signature, err := k.SignHash(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !signature.IsCanonical() {
+			t.Fatal("signature must be produced in low-S form")
+		}
+
+		if !k.CheckExternalSignature(h, *signature, k.PublicKey()) {
+			t.Fatal("canonical signature must be accepted")
+		}
+
+		mutated := ecdsa.Signature{R: signature.R, S: new(big.Int).Sub(curveOrder, signature.S)}
+		if !e.Verify(k.PublicKey(), h.Bytes[:], mutated.R, mutated.S) {
+			t.Fatal("mutated signature is expected to be valid for the curve")
+		}
+
+		if k.CheckExternalSignature(h, mutated, k.PublicKey()) || k.CheckOwnSignature(h, mutated) {
+			t.Fatal("high-S signature must be rejected")
+		}
+	}
+}
+
+// Amount of signatures in the benchmarks batch.
+// Speedup of the parallel verification is proportional to the amount of CPUs available.
+const benchmarkSignaturesCount = 64
+
+func BenchmarkKeyStore_CheckExternalSignature_Serial(b *testing.B) {
+	h := hash.NewSHA256Container([]byte("block"))
+	signatures, pubKeys := newTestSignaturesBatch(b, h, benchmarkSignaturesCount)
+	k := &KeyStore{}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for i := range signatures {
+			k.CheckExternalSignature(h, signatures[i], pubKeys[i])
+		}
+	}
+}
+
+func BenchmarkKeyStore_CheckExternalSignaturesBatch(b *testing.B) {
+	h := hash.NewSHA256Container([]byte("block"))
+	signatures, pubKeys := newTestSignaturesBatch(b, h, benchmarkSignaturesCount)
+	k := &KeyStore{}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		_, err := k.CheckExternalSignaturesBatch(h, signatures, pubKeys)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
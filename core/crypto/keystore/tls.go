@@ -0,0 +1,56 @@
+package keystore
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"time"
+)
+
+const (
+	// Validity period of the observer's TLS certificate.
+	TLSCertificateValidity = time.Hour * 24 * 365 * 10
+)
+
+// TLSCertificate returns self-signed TLS certificate of the observer's key.
+// Observers are authenticated by their public keys (see external.Observer), not by the certificates chain,
+// so no certificate authority is needed.
+func (k *KeyStore) TLSCertificate() (certificate tls.Certificate, err error) {
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject:      pkix.Name{CommonName: "geo-observer-" + k.Fingerprint()},
+
+		// Clocks of the observers might be slightly out of sync.
+		NotBefore: now.Add(-time.Hour),
+		NotAfter:  now.Add(TLSCertificateValidity),
+
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	pkey := k.privateKey()
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &pkey.PublicKey, pkey)
+	if err != nil {
+		return
+	}
+
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return
+	}
+
+	certificate = tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  pkey,
+		Leaf:        leaf,
+	}
+	return
+}
@@ -3,7 +3,7 @@ package lamport
 import (
 	"bytes"
 	"geo-observers-blockchain/core/common"
-	"geo-observers-blockchain/core/common/types"
+	"geo-observers-blockchain/core/common/errors"
 	"math/rand"
 	"testing"
 )
@@ -11,7 +11,7 @@ import (
 func TestPubKey_UnmarshalBinary_TooShortData(t *testing.T) {
 	key := &PubKey{}
 	err := key.UnmarshalBinary([]byte{0, 0, 0, 0, 0, 0})
-	if err != common.ErrInvalidDataFormat {
+	if err != errors.InvalidDataFormat {
 		t.Fatal()
 	}
 }
@@ -24,7 +24,7 @@ func TestPubKey_UnmarshalBinary_TooShortData(t *testing.T) {
 func TestPubKeys_Add_NilParameter(t *testing.T) {
 	keys := &PubKeys{}
 	err := keys.Add(nil)
-	if err != common.ErrNilParameter {
+	if err != errors.NilParameter {
 		t.Fatal()
 	}
 }
@@ -39,7 +39,7 @@ func TestPubKeys_Add_Max(t *testing.T) {
 	}
 
 	err := keys.Add(&PubKey{})
-	if err != common.ErrMaxCountReached {
+	if err != errors.MaxCountReached {
 		t.Fatal("keys list must restrict max count of elements")
 	}
 }
@@ -69,7 +69,7 @@ func TestPubKeys_MarshallBinary_NoData(t *testing.T) {
 
 	// Empty serialised keys must be 2 bytes long.
 	// (total count if keys serializes as uint16)
-	if len(binary) != types.Uint16ByteSize {
+	if len(binary) != common.Uint16ByteSize {
 		t.Fatal()
 	}
 
@@ -141,7 +141,7 @@ func TestPubKeys_MarshallBinary_MaxElementsCount(t *testing.T) {
 	_ = restoredKeys.UnmarshalBinary(binary)
 
 	// Checks
-	if restoredKeys.Count() != PubKeysMaxCount {
+	if int(restoredKeys.Count()) != PubKeysMaxCount {
 		t.Fatal()
 	}
 	if restoredKeys.Count() != keys.Count() {
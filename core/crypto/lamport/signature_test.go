@@ -3,7 +3,7 @@ package lamport
 import (
 	"bytes"
 	"geo-observers-blockchain/core/common"
-	"geo-observers-blockchain/core/common/types"
+	"geo-observers-blockchain/core/common/errors"
 	"math/rand"
 	"testing"
 )
@@ -11,7 +11,7 @@ import (
 func TestSignature_UnmarshalBinary_TooShortData(t *testing.T) {
 	sig := &Signature{}
 	err := sig.UnmarshalBinary([]byte{0, 0, 0, 0, 0, 0})
-	if err != common.ErrInvalidDataFormat {
+	if err != errors.InvalidDataFormat {
 		t.Fatal()
 	}
 }
@@ -24,7 +24,7 @@ func TestSignature_UnmarshalBinary_TooShortData(t *testing.T) {
 func TestSignatures_Add_NilParameter(t *testing.T) {
 	signatures := &Signatures{}
 	err := signatures.Add(nil)
-	if err != common.ErrNilParameter {
+	if err != errors.NilParameter {
 		t.Fatal()
 	}
 }
@@ -39,7 +39,7 @@ func TestSignatures_Add_Max(t *testing.T) {
 	}
 
 	err := signatures.Add(&Signature{})
-	if err != common.ErrMaxCountReached {
+	if err != errors.MaxCountReached {
 		t.Fatal("signatures list must restrict max count of elements")
 	}
 }
@@ -69,7 +69,7 @@ func TestSignatures_MarshallBinary_NoData(t *testing.T) {
 
 	// Empty serialised signatures must be 2 bytes long.
 	// (total count if signatures serializes as uint16)
-	if len(binary) != types.Uint16ByteSize {
+	if len(binary) != common.Uint16ByteSize {
 		t.Fatal()
 	}
 
@@ -140,7 +140,7 @@ func TestSignatures_MarshallBinary_MaxElementsCount(t *testing.T) {
 	_ = restoredSignatures.UnmarshalBinary(binary)
 
 	// Checks
-	if restoredSignatures.Count() != SignaturesMaxCount {
+	if int(restoredSignatures.Count()) != SignaturesMaxCount {
 		t.Fatal()
 	}
 	if restoredSignatures.Count() != signatures.Count() {
@@ -2,20 +2,35 @@ package geo
 
 import (
 	"bytes"
+	e "crypto/ecdsa"
 	"geo-observers-blockchain/core/common"
 	"geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/common/types/hash"
 	"geo-observers-blockchain/core/common/types/transactions"
+	"geo-observers-blockchain/core/crypto/ecdsa"
+	"geo-observers-blockchain/core/crypto/keystore"
+	"geo-observers-blockchain/core/settings"
 	"geo-observers-blockchain/core/utils"
+	log "github.com/sirupsen/logrus"
 	"sort"
 )
 
+const (
+	// Max size of the claim's signature binary representation.
+	ClaimSignatureMaxBinarySize = ecdsa.SignatureBinarySize
+)
+
 var (
-	ClaimMinBinarySize = transactions.TxIDBinarySize + ClaimMembersMinBinarySize
+	ClaimMinBinarySize = transactions.TxIDBinarySize + common.Uint16ByteSize + ClaimMembersMinBinarySize
 )
 
 type Claim struct {
 	TxUUID  *transactions.TxID
 	Members *ClaimMembers
+
+	// Signature of the claim's originator (see Sign()).
+	// Claim might be unsigned, in this case signature is nil.
+	Signature *ecdsa.Signature
 }
 
 func NewClaim() *Claim {
@@ -35,12 +50,18 @@ func (claim *Claim) MarshalBinary() (data []byte, err error) {
 		return
 	}
 
+	signatureBinary, err := claim.signatureBinary()
+	if err != nil {
+		return
+	}
+
 	membersBinary, err := claim.Members.MarshalBinary()
 	if err != nil {
 		return
 	}
 
-	data = utils.ChainByteSlices(txIDBinary, membersBinary)
+	data = utils.ChainByteSlices(
+		txIDBinary, utils.MarshalUint16(uint16(len(signatureBinary))), signatureBinary, membersBinary)
 	return
 }
 
@@ -50,8 +71,9 @@ func (claim *Claim) UnmarshalBinary(data []byte) (err error) {
 	}
 
 	const (
-		offsetUUIDData    = 0
-		offsetMembersData = offsetUUIDData + transactions.TxIDBinarySize
+		offsetUUIDData          = 0
+		offsetSignatureSizeData = offsetUUIDData + transactions.TxIDBinarySize
+		offsetSignatureData     = offsetSignatureSizeData + common.Uint16ByteSize
 	)
 
 	claim.TxUUID = transactions.NewEmptyTxID()
@@ -60,12 +82,43 @@ func (claim *Claim) UnmarshalBinary(data []byte) (err error) {
 		return
 	}
 
+	signatureSize, err := utils.UnmarshalUint16(data[offsetSignatureSizeData:offsetSignatureData])
+	if err != nil {
+		return
+	}
+
+	// Signature has fixed size, so the claim is either unsigned, or signature has exactly this size.
+	if (signatureSize != 0 && signatureSize != ecdsa.SignatureBinarySize) ||
+		len(data) < ClaimMinBinarySize+int(signatureSize) {
+		return errors.InvalidDataFormat
+	}
+
+	offsetMembersData := offsetSignatureData + int(signatureSize)
+
+	claim.Signature = nil
+	if signatureSize != 0 {
+		claim.Signature = &ecdsa.Signature{}
+		err = claim.Signature.UnmarshalBinary(data[offsetSignatureData:offsetMembersData])
+		if err != nil {
+			return
+		}
+	}
+
 	claim.Members = &ClaimMembers{}
 	err = claim.Members.UnmarshalBinary(data[offsetMembersData:])
 	if err != nil {
 		return
 	}
 
+	// Members are parsed up to the end of the data,
+	// so in strict mode declared members count must correspond to the data size.
+	if settings.StrictUnmarshalling {
+		declaredCount, _ := utils.UnmarshalUint16(data[offsetMembersData:])
+		if declaredCount != claim.Members.Count() {
+			return errors.InvalidDataFormat
+		}
+	}
+
 	return
 }
 
@@ -73,6 +126,117 @@ func (claim *Claim) TxID() *transactions.TxID {
 	return claim.TxUUID
 }
 
+// Hash returns SHA256 hash of the claim's binary representation (see MarshalBinary()).
+// Signature is included into the binary representation, so it affects the hash as well.
+func (claim *Claim) Hash() (h hash.SHA256Container, err error) {
+	data, err := claim.MarshalBinary()
+	if err != nil {
+		return
+	}
+
+	return hash.NewSHA256Container(data), nil
+}
+
+// Sign signs the claim's transaction ID and members with the key specified.
+// Previous signature (if any) is replaced.
+func (claim *Claim) Sign(ks *keystore.KeyStore) (err error) {
+	if ks == nil {
+		return errors.NilParameter
+	}
+
+	h, err := claim.signedHash()
+	if err != nil {
+		return
+	}
+
+	signature, err := ks.SignHash(h)
+	if err != nil {
+		return
+	}
+
+	claim.Signature = signature
+	return
+}
+
+// VerifySignature returns true if the claim is signed with the key, that corresponds to the public key specified,
+// and neither transaction ID nor members has been changed after signing.
+// Unsigned claim is never verified.
+// Signature must be in the canonical low-S form (see ecdsa.Signature.Verify()):
+// the signature is covered by the claim's hash (see Hash()),
+// so otherwise the same claim might be presented under several hashes.
+func (claim *Claim) VerifySignature(pubKey *e.PublicKey) bool {
+	if claim.Signature == nil {
+		return false
+	}
+
+	h, err := claim.signedHash()
+	if err != nil {
+		return false
+	}
+
+	return claim.Signature.Verify(pubKey, h.Bytes[:])
+}
+
+// signedHash returns hash of the claim's data, that is covered by the signature:
+// binary representation of the transaction ID and of the members.
+func (claim *Claim) signedHash() (h hash.SHA256Container, err error) {
+	if claim.TxUUID == nil || claim.Members == nil {
+		return h, errors.NilInternalDataStructure
+	}
+
+	txIDBinary, err := claim.TxUUID.MarshalBinary()
+	if err != nil {
+		return
+	}
+
+	membersBinary, err := claim.Members.MarshalBinary()
+	if err != nil {
+		return
+	}
+
+	return hash.NewSHA256Container(utils.ChainByteSlices(txIDBinary, membersBinary)), nil
+}
+
+// signatureBinary returns binary representation of the signature, or nil if the claim is unsigned.
+func (claim *Claim) signatureBinary() (data []byte, err error) {
+	if claim.Signature == nil {
+		return nil, nil
+	}
+
+	return claim.Signature.MarshalBinary()
+}
+
+// Validate checks that the claim is semantically correct:
+// transaction ID is set and is not zero, members count is in range [1, ClaimMembersMaxCount],
+// and all members are set.
+// Unmarshalling checks only the binary format, so claims received from the remote side must be validated.
+func (claim *Claim) Validate() error {
+	if claim.TxUUID == nil || claim.Members == nil {
+		return errors.NilInternalDataStructure
+	}
+
+	if claim.TxUUID.Bytes == [transactions.TxIDBinarySize]byte{} {
+		return ErrEmptyTxID
+	}
+
+	// Binary representation of the members must contain at least one member (see ClaimMembersMinBinarySize).
+	if len(claim.Members.At) == 0 {
+		return ErrNoClaimMembers
+	}
+
+	if len(claim.Members.At) > ClaimMembersMaxCount {
+		return ErrTooManyClaimMembers
+	}
+
+	for _, member := range claim.Members.At {
+		if member == nil || member.PubKey == nil {
+			return errors.NilInternalDataStructure
+		}
+	}
+
+	return nil
+}
+
 // --------------------------------------------------------------------------------------------------------------------
 
 const (
@@ -81,26 +245,136 @@ const (
 
 type Claims struct {
 	At []*Claim
+
+	// Positions of the claims by their transaction IDs (see txIDs()).
+	index *claimsIndex
+}
+
+// claimsIndex maps transaction IDs of the claims to their positions in the list.
+// Claims might be assigned to At directly, so the index tracks amount of claims it has been built for,
+// and is rebuilt in case if it differs from the current claims count
+// (or if the claim at the indexed position has another transaction ID).
+// In case if several claims of the same transaction are present - position of the first one is stored.
+type claimsIndex struct {
+	positions map[[transactions.TxIDBinarySize]byte]int
+	indexed   int
 }
 
+// Add appends the claim to the end of the list.
+// Returns errors.Collision in case if claim with the same transaction ID is already present
+// (even if members of the claims are different: only one claim per transaction is allowed).
 func (c *Claims) Add(claim *Claim) error {
 	if claim == nil {
 		return errors.NilParameter
 	}
 
-	if c.Count() < ClaimsMaxCount {
-		c.At = append(c.At, claim)
-		return nil
+	if c.Count() >= ClaimsMaxCount {
+		return errors.MaxCountReached
+	}
+
+	if claim.TxUUID == nil {
+		return errors.NilParameter
+	}
+
+	if c.Contains(claim.TxUUID) {
+		return errors.Collision
+	}
+
+	c.At = append(c.At, claim)
+	index := c.txIDs()
+	index.positions[claim.TxUUID.Bytes] = len(c.At) - 1
+	index.indexed = len(c.At)
+	return nil
+}
+
+// Contains returns true if claim with the transaction ID specified is present.
+func (c *Claims) Contains(txID *transactions.TxID) bool {
+	_, err := c.ByTxID(txID)
+	return err == nil
+}
+
+// ByTxID returns claim with the transaction ID specified.
+// Returns errors.NotFound in case if there is no such claim.
+func (c *Claims) ByTxID(txID *transactions.TxID) (claim *Claim, err error) {
+	if txID == nil {
+		return nil, errors.NilParameter
+	}
+
+	position, isPresent := c.txIDs().positions[txID.Bytes]
+	if isPresent && !c.isAtPosition(txID, position) {
+		// Claims has been reordered bypassing the index.
+		c.index = nil
+		position, isPresent = c.txIDs().positions[txID.Bytes]
+	}
+
+	if !isPresent {
+		return nil, errors.NotFound
+	}
+
+	return c.At[position], nil
+}
+
+func (c *Claims) isAtPosition(txID *transactions.TxID, position int) bool {
+	return position < len(c.At) && c.At[position] != nil &&
+		c.At[position].TxUUID != nil && c.At[position].TxUUID.Compare(txID)
+}
+
+// txIDs returns index of the transaction IDs of the claims.
+// Index is rebuilt in case if claims has been added or removed bypassing Add().
+func (c *Claims) txIDs() *claimsIndex {
+	if c.index != nil && c.index.indexed == len(c.At) {
+		return c.index
+	}
+
+	c.index = &claimsIndex{
+		positions: make(map[[transactions.TxIDBinarySize]byte]int, len(c.At)),
+		indexed:   len(c.At),
+	}
+
+	for position, claim := range c.At {
+		if claim == nil || claim.TxUUID == nil {
+			continue
+		}
+
+		if _, isPresent := c.index.positions[claim.TxUUID.Bytes]; !isPresent {
+			c.index.positions[claim.TxUUID.Bytes] = position
+		}
+	}
+
+	return c.index
+}
+
+// AddSorted inserts the claim at it's position in the canonical order (see Sort()),
+// so the claims, that are already sorted, are kept sorted without full resorting.
+// Returns errors.Collision in case if claim with the same transaction ID is already present.
+func (c *Claims) AddSorted(claim *Claim) error {
+	if claim == nil || claim.TxUUID == nil {
+		return errors.NilParameter
+	}
+
+	position := c.searchSorted(claim.TxID())
+	if position < len(c.At) && c.At[position].TxID().Compare(claim.TxID()) {
+		return errors.Collision
+	}
+
+	if c.Count() >= ClaimsMaxCount {
+		return errors.MaxCountReached
 	}
 
-	return errors.MaxCountReached
+	c.At = append(c.At, nil)
+	copy(c.At[position+1:], c.At[position:])
+	c.At[position] = claim
+	c.index = nil
+	return nil
 }
 
 func (c *Claims) Count() uint16 {
 	return uint16(len(c.At))
 }
 
-// todo: tests needed
+// Sort orders the claims by their binary representation (canonical order).
+// Each claim is marshalled only once: binary representations are precomputed before sorting.
+// In case of error claims are left untouched.
 func (c *Claims) Sort() (err error) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -109,103 +383,203 @@ func (c *Claims) Sort() (err error) {
 		}
 	}()
 
-	sort.Slice(c.At, func(i, j int) bool {
-		aBinaryData, err := c.At[i].MarshalBinary()
-		if err != nil {
-			panic(err)
-		}
+	type sortingKey struct {
+		data  []byte
+		claim *Claim
+	}
 
-		bBinaryData, err := c.At[j].MarshalBinary()
+	keys := make([]sortingKey, len(c.At))
+	for i, claim := range c.At {
+		keys[i].claim = claim
+		keys[i].data, err = claim.MarshalBinary()
 		if err != nil {
-			panic(err)
+			return
 		}
+	}
 
-		return bytes.Compare(aBinaryData, bBinaryData) == -1
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i].data, keys[j].data) == -1
 	})
 
+	for i := range keys {
+		c.At[i] = keys[i].claim
+	}
+
+	c.index = nil
 	return
 }
 
-// Format:
-// 2B - Total claims count.
-// [4B, 4B, ... 4B] - ClaimsHashes sizes.
-// [NB, NB, ... NB] - ClaimsHashes bodies.
-func (c *Claims) MarshalBinary() (data []byte, err error) {
-	var (
-		initialDataSize = common.Uint16ByteSize + // Total claims count.
-			common.Uint16ByteSize*c.Count() // ClaimsHashes sizes fields.
-	)
-
-	data = make([]byte, 0, initialDataSize)
-	data = append(data, utils.MarshalUint16(c.Count())...)
-	claims := make([][]byte, 0, c.Count())
+// RemoveDuplicates sorts the claims and drops the claims with the same transaction ID,
+// so only one claim per transaction is left. Returns count of removed claims.
+// Sets received from the remote observers might contain duplicates,
+// so this cleanup is expected to be done before the claims would be included into the block.
+func (c *Claims) RemoveDuplicates() (removed int, err error) {
+	if len(c.At) < 2 {
+		return
+	}
 
-	for _, claim := range c.At {
-		claimBinary, err := claim.MarshalBinary()
-		if err != nil {
-			return nil, err
-		}
+	err = c.Sort()
+	if err != nil {
+		return
+	}
 
-		// Skip empty claim, if any.
-		if len(claimBinary) == 0 {
+	// Sorting is done by the binary representation of the claims,
+	// that starts with the transaction ID, so claims with the same tx ID are adjacent.
+	unique := c.At[:1]
+	for _, claim := range c.At[1:] {
+		if claim.TxID().Compare(unique[len(unique)-1].TxID()) {
 			continue
 		}
 
-		// Append claim size directly to the data stream.
-		data = append(data, utils.MarshalUint32(uint32(len(claimBinary)))...)
+		unique = append(unique, claim)
+	}
+
+	removed = len(c.At) - len(unique)
 
-		// ClaimsHashes would be attached to the data after all claims size fields would be written.
-		claims = append(claims, claimBinary)
+	// Removed claims must not be referenced from the rest of the underlying array.
+	for i := len(unique); i < len(c.At); i++ {
+		c.At[i] = nil
 	}
 
-	data = append(data, utils.ChainByteSlices(claims...)...)
+	c.At = unique
+	c.index = nil
 	return
 }
 
+// IndexOf returns position of the claim with the transaction ID specified
+// in the canonically sorted claims set (see Sort()).
+// Original claims order is left untouched.
+// Returns errors.NotFound in case if there is no such claim.
+func (c *Claims) IndexOf(txID *transactions.TxID) (index int, err error) {
+	if txID == nil {
+		return -1, errors.NilParameter
+	}
+
+	sorted, err := c.sortedCopy()
+	if err != nil {
+		return -1, err
+	}
+
+	return sorted.indexOfSorted(txID)
+}
+
+// indexOfSorted performs binary search of the claim with the transaction ID specified.
+// Claims must be sorted.
+func (c *Claims) indexOfSorted(txID *transactions.TxID) (index int, err error) {
+	index = c.searchSorted(txID)
+	if index == len(c.At) || !c.At[index].TxID().Compare(txID) {
+		return -1, errors.NotFound
+	}
+
+	return
+}
+
+// searchSorted returns position of the first claim with transaction ID >= txID.
+// Claims must be sorted. Sorting is done by the binary representation of the claims,
+// that starts with the transaction ID, so transaction ID is used as a search key.
+func (c *Claims) searchSorted(txID *transactions.TxID) int {
+	return sort.Search(len(c.At), func(i int) bool {
+		return bytes.Compare(c.At[i].TxID().Bytes[:], txID.Bytes[:]) >= 0
+	})
+}
+
+// Format:
+// 2B - Total claims count.
+// [4B, 4B, ... 4B] - ClaimsHashes sizes.
+// [NB, NB, ... NB] - ClaimsHashes bodies.
+// See WriteTo() for the streaming version.
+func (c *Claims) MarshalBinary() (data []byte, err error) {
+	size, err := c.binarySize()
+	if err != nil {
+		return
+	}
+
+	buffer := bytes.NewBuffer(make([]byte, 0, size))
+	_, err = c.WriteTo(buffer)
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// In lenient mode (see settings.StrictUnmarshalling) malformed claims are skipped.
+// Sizes of the claims must be valid in both modes, otherwise it is impossible to reach the next claims.
+// Data is received from the remote side, so each offset is checked against the data size before reading.
+// In case of error claims are left untouched.
 func (c *Claims) UnmarshalBinary(data []byte) (err error) {
+	dataSize := uint64(len(data))
+	if dataSize < common.Uint16ByteSize {
+		return errors.InvalidDataFormat
+	}
+
 	count, err := utils.UnmarshalUint16(data[:common.Uint16ByteSize])
 	if err != nil {
 		return
 	}
 
-	c.At = make([]*Claim, count, count)
-	if count == 0 {
-		return
+	// Count is received from the remote side and must be checked
+	// before any memory would be allocated for the claims.
+	if count > ClaimsMaxCount {
+		return errors.InvalidDataFormat
 	}
 
-	var i uint16
-	for i = 0; i < count; i++ {
-		c.At[i] = NewClaim()
+	var offset uint64 = common.Uint16ByteSize
+	if dataSize < offset+common.Uint32ByteSize*uint64(count) {
+		return errors.InvalidDataFormat
 	}
 
-	claimsSizes := make([]uint32, 0, count)
+	var (
+		claimsSizes     = make([]uint64, 0, count)
+		totalClaimsSize uint64
+	)
 
-	var offset uint32 = common.Uint16ByteSize
-	for i = 0; i < count; i++ {
+	for i := uint16(0); i < count; i++ {
 		claimSize, err := utils.UnmarshalUint32(data[offset : offset+common.Uint32ByteSize])
 		if err != nil {
 			return err
 		}
-		if claimSize == 0 {
-			err = errors.InvalidDataFormat
-		}
 
-		claimsSizes = append(claimsSizes, claimSize)
+		claimsSizes = append(claimsSizes, uint64(claimSize))
+		totalClaimsSize += uint64(claimSize)
 		offset += common.Uint32ByteSize
 	}
 
-	for i = 0; i < count; i++ {
+	// Sum of the declared sizes must fit into the data,
+	// so no claim might be read beyond it.
+	if dataSize < offset+totalClaimsSize {
+		return errors.InvalidDataFormat
+	}
+
+	if settings.StrictUnmarshalling && dataSize != offset+totalClaimsSize {
+		return errors.InvalidDataFormat
+	}
+
+	claims := make([]*Claim, 0, count)
+	for i, claimSize := range claimsSizes {
 		claim := NewClaim()
-		claimSize := claimsSizes[i]
+		if claimSize == 0 {
+			err = errors.InvalidDataFormat
+		} else {
+			err = claim.UnmarshalBinary(data[offset : offset+claimSize])
+		}
 
-		err = claim.UnmarshalBinary(data[offset : offset+claimSize])
+		offset += claimSize
 		if err != nil {
-			return err
+			if settings.StrictUnmarshalling {
+				return err
+			}
+
+			log.WithFields(log.Fields{"prefix": "Claims", "Position": i}).Warn(
+				"Malformed claim skipped: ", err)
+			err = nil
+			continue
 		}
 
-		offset += claimSize
-		c.At[i] = claim
+		claims = append(claims, claim)
 	}
 
+	c.At = claims
+	c.index = nil
 	return
 }
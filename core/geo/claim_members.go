@@ -79,6 +79,12 @@ func (members *ClaimMembers) UnmarshalBinary(data []byte) (err error) {
 			return errors.InvalidDataFormat
 		}
 
+		// Members are parsed up to the end of the data,
+		// so the data must not contain more members than allowed.
+		if len(members.At) >= ClaimMembersMaxCount {
+			return errors.InvalidDataFormat
+		}
+
 		member := &ClaimMember{}
 		membersData := data[offset : offset+ClaimMemberBinarySize]
 		err = member.UnmarshalBinary(membersData)
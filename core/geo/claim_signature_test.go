@@ -0,0 +1,156 @@
+package geo
+
+import (
+	"crypto/elliptic"
+	"geo-observers-blockchain/core/crypto/keystore"
+	"math/big"
+	"testing"
+)
+
+// Signs the claim, marshals and restores it,
+// and checks that the signature is restored and is still valid.
+func TestClaim_Sign_RoundTrip(t *testing.T) {
+	ks, err := keystore.NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claim := newTestClaim(2)
+	err = claim.Sign(ks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !claim.VerifySignature(ks.PublicKey()) {
+		t.Fatal("signature must be valid")
+	}
+
+	data, err := claim.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	size, err := claim.binarySize()
+	if err != nil || size != len(data) {
+		t.Fatal("binary size must include the signature")
+	}
+
+	restored := NewClaim()
+	err = restored.UnmarshalBinary(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if restored.Signature == nil ||
+		restored.Signature.R.Cmp(claim.Signature.R) != 0 ||
+		restored.Signature.S.Cmp(claim.Signature.S) != 0 {
+		t.Fatal("signature must be restored")
+	}
+
+	if !restored.VerifySignature(ks.PublicKey()) {
+		t.Fatal("restored signature must be valid")
+	}
+
+	if restored.Members.Count() != claim.Members.Count() {
+		t.Fatal("members must be restored")
+	}
+}
+
+// Checks that the signature is invalidated by the change of the transaction ID or members,
+// and that it is not valid for other key.
+func TestClaim_VerifySignature_Tampered(t *testing.T) {
+	ks, err := keystore.NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := keystore.NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claim := newTestClaim(2)
+	err = claim.Sign(ks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if claim.VerifySignature(other.PublicKey()) {
+		t.Fatal("signature must not be valid for other key")
+	}
+
+	claim.TxUUID.Bytes[0] ^= 0xFF
+	if claim.VerifySignature(ks.PublicKey()) {
+		t.Fatal("changed transaction ID must invalidate the signature")
+	}
+	claim.TxUUID.Bytes[0] ^= 0xFF
+
+	claim.Members.At[0].ID++
+	if claim.VerifySignature(ks.PublicKey()) {
+		t.Fatal("changed members must invalidate the signature")
+	}
+	claim.Members.At[0].ID--
+
+	if !claim.VerifySignature(ks.PublicKey()) {
+		t.Fatal("signature of the original data must be valid")
+	}
+}
+
+// High-S form of the valid signature (R, N-S) is mathematically valid too,
+// but it changes the claim's hash, so it must be rejected.
+func TestClaim_VerifySignature_HighS(t *testing.T) {
+	ks, err := keystore.NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claim := newTestClaim(2)
+	err = claim.Sign(ks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	originalHash, err := claim.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claim.Signature.S = new(big.Int).Sub(elliptic.P521().Params().N, claim.Signature.S)
+	malleatedHash, err := claim.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if originalHash.Compare(&malleatedHash) {
+		t.Fatal("signature must be covered by the claim's hash")
+	}
+
+	if claim.VerifySignature(ks.PublicKey()) {
+		t.Fatal("high-S signature must be rejected")
+	}
+}
+
+// Unsigned claims must be marshalled with empty signature and must never be verified.
+func TestClaim_VerifySignature_Unsigned(t *testing.T) {
+	ks, err := keystore.NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claim := newTestClaim(1)
+
+	data, err := claim.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewClaim()
+	err = restored.UnmarshalBinary(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if restored.Signature != nil || restored.VerifySignature(ks.PublicKey()) {
+		t.Fatal("unsigned claim must not be verified")
+	}
+}
@@ -0,0 +1,172 @@
+package geo
+
+import (
+	"geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/common/types/hash"
+	"geo-observers-blockchain/core/common/types/transactions"
+	"geo-observers-blockchain/core/utils"
+)
+
+// Merkle tree over the claims set.
+// It allows light clients to verify inclusion of the claim into the set
+// without downloading the whole set (only the root and the proof of the claim are needed).
+//
+// Tree is built over the hashes of the claims (see Claim.Hash()) in canonical (sorted) order,
+// so it does not depend on the order of adding.
+// Leaves and inner nodes are hashed with different prefixes,
+// so the inner node could never be presented as a leaf (and vice versa).
+// Node without a pair on it's level is moved to the next level as is.
+
+const (
+	merkleLeafPrefix byte = 0
+	merkleNodePrefix byte = 1
+)
+
+type MerkleProofStep struct {
+	Hash hash.SHA256Container
+
+	// True if the hash is a left sibling of the current node.
+	IsLeft bool
+}
+
+// MerkleProof contains hashes of the siblings on the path from the claim's leaf to the root.
+type MerkleProof struct {
+	Steps []MerkleProofStep
+}
+
+// MerkleRoot returns the root of the Merkle tree over the claims (the one, that is included into the block header).
+// Returns errors.EmptySequence in case if there are no claims.
+func (c *Claims) MerkleRoot() (root hash.SHA256Container, err error) {
+	levels, err := c.merkleTree()
+	if err != nil {
+		return
+	}
+
+	root = levels[len(levels)-1][0]
+	return
+}
+
+// ProofFor returns the Merkle proof of inclusion of the claim with the transaction ID specified.
+// Returns errors.NotFound in case if there is no such claim.
+func (c *Claims) ProofFor(txID *transactions.TxID) (proof MerkleProof, err error) {
+	if txID == nil {
+		err = errors.NilParameter
+		return
+	}
+
+	sorted, err := c.sortedCopy()
+	if err != nil {
+		return
+	}
+
+	position, err := sorted.indexOfSorted(txID)
+	if err != nil {
+		return
+	}
+
+	levels, err := sorted.merkleTree()
+	if err != nil {
+		return
+	}
+
+	for _, level := range levels[:len(levels)-1] {
+		if position%2 == 1 {
+			proof.Steps = append(proof.Steps, MerkleProofStep{Hash: level[position-1], IsLeft: true})
+
+		} else if position+1 < len(level) {
+			proof.Steps = append(proof.Steps, MerkleProofStep{Hash: level[position+1], IsLeft: false})
+		}
+
+		position /= 2
+	}
+
+	return
+}
+
+// VerifyProof checks that the claim is included into the claims set with the Merkle root specified.
+func VerifyProof(root hash.SHA256Container, claim *Claim, proof MerkleProof) bool {
+	if claim == nil {
+		return false
+	}
+
+	current, err := merkleLeaf(claim)
+	if err != nil {
+		return false
+	}
+
+	for _, step := range proof.Steps {
+		if step.IsLeft {
+			current = merkleNode(step.Hash, current)
+
+		} else {
+			current = merkleNode(current, step.Hash)
+		}
+	}
+
+	return current.Compare(&root)
+}
+
+// merkleTree returns all levels of the tree: from the leaves (first) up to the root (last).
+func (c *Claims) merkleTree() (levels [][]hash.SHA256Container, err error) {
+	if c.Count() == 0 {
+		err = errors.EmptySequence
+		return
+	}
+
+	sorted, err := c.sortedCopy()
+	if err != nil {
+		return
+	}
+
+	level := make([]hash.SHA256Container, 0, len(sorted.At))
+	for _, claim := range sorted.At {
+		leaf, err := merkleLeaf(claim)
+		if err != nil {
+			return nil, err
+		}
+
+		level = append(level, leaf)
+	}
+
+	levels = append(levels, level)
+	for len(level) > 1 {
+		next := make([]hash.SHA256Container, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+
+			next = append(next, merkleNode(level[i], level[i+1]))
+		}
+
+		levels = append(levels, next)
+		level = next
+	}
+
+	return
+}
+
+// sortedCopy returns claims in canonical order.
+// Original claims order is left untouched.
+func (c *Claims) sortedCopy() (sorted *Claims, err error) {
+	sorted = &Claims{At: make([]*Claim, len(c.At))}
+	copy(sorted.At, c.At)
+
+	err = sorted.Sort()
+	return
+}
+
+func merkleLeaf(claim *Claim) (leaf hash.SHA256Container, err error) {
+	claimHash, err := claim.Hash()
+	if err != nil {
+		return
+	}
+
+	return hash.NewSHA256Container(utils.ChainByteSlices([]byte{merkleLeafPrefix}, claimHash.Bytes[:])), nil
+}
+
+func merkleNode(left, right hash.SHA256Container) hash.SHA256Container {
+	return hash.NewSHA256Container(
+		utils.ChainByteSlices([]byte{merkleNodePrefix}, left.Bytes[:], right.Bytes[:]))
+}
@@ -0,0 +1,171 @@
+package geo
+
+import (
+	"geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/common/types/hash"
+	"geo-observers-blockchain/core/common/types/transactions"
+	"testing"
+)
+
+func newTestMerkleClaims(t *testing.T, count int) (claims *Claims) {
+	claims = &Claims{}
+	for i := 0; i < count; i++ {
+		txID, err := transactions.NewRandomTxID(uint64(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		claim := &Claim{TxUUID: txID, Members: &ClaimMembers{}}
+		_ = claim.Members.Add(NewClaimMember(uint16(i)))
+		_ = claims.Add(claim)
+	}
+
+	return
+}
+
+// Checks proofs of all claims of the sets of various sizes (including odd ones),
+// and that the root does not depend on the order of the claims.
+func TestClaims_MerkleProof_Valid(t *testing.T) {
+	for count := 1; count <= 9; count++ {
+		claims := newTestMerkleClaims(t, count)
+		root, err := claims.MerkleRoot()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		reversed := &Claims{}
+		for i := len(claims.At) - 1; i >= 0; i-- {
+			_ = reversed.Add(claims.At[i])
+		}
+
+		reversedRoot, err := reversed.MerkleRoot()
+		if err != nil || !reversedRoot.Compare(&root) {
+			t.Fatal("root must not depend on the claims order")
+		}
+
+		for _, claim := range claims.At {
+			proof, err := claims.ProofFor(claim.TxID())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !VerifyProof(root, claim, proof) {
+				t.Fatal("valid proof rejected")
+			}
+		}
+	}
+}
+
+// Checks that the proof is rejected for the tampered claim.
+func TestClaims_MerkleProof_TamperedClaim(t *testing.T) {
+	claims := newTestMerkleClaims(t, 5)
+	root, err := claims.MerkleRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claim := claims.At[2]
+	proof, err := claims.ProofFor(claim.TxID())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := &Claim{TxUUID: claim.TxUUID, Members: &ClaimMembers{}}
+	_ = tampered.Members.Add(NewClaimMember(1000))
+	if VerifyProof(root, tampered, proof) {
+		t.Fatal("proof for tampered claim must be rejected")
+	}
+
+	// Proof of one claim must not be valid for another one.
+	if VerifyProof(root, claims.At[3], proof) {
+		t.Fatal()
+	}
+}
+
+func TestClaims_MerkleProof_Errors(t *testing.T) {
+	_, err := (&Claims{}).MerkleRoot()
+	if err != errors.EmptySequence {
+		t.Fatal()
+	}
+
+	claims := newTestMerkleClaims(t, 3)
+	txID, err := transactions.NewRandomTxID(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = claims.ProofFor(txID)
+	if err != errors.NotFound {
+		t.Fatal()
+	}
+}
+
+// Hash must correspond to the hash of the binary representation of the claim.
+func TestClaim_Hash(t *testing.T) {
+	claim := newTestClaim(1)
+	data, err := claim.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := claim.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := hash.NewSHA256Container(data)
+	if !h.Compare(&expected) {
+		t.Fatal()
+	}
+
+	_, err = (&Claim{}).Hash()
+	if err != errors.NilInternalDataStructure {
+		t.Fatal()
+	}
+}
+
+// Checks the root of the sets with one claim and with odd amount of claims against manually built tree.
+func TestClaims_MerkleRoot_SingleAndOdd(t *testing.T) {
+	leaf := func(claim *Claim) hash.SHA256Container {
+		h, err := merkleLeaf(claim)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return h
+	}
+
+	single := &Claims{}
+	_ = single.Add(newTestClaim(1))
+	root, err := single.MerkleRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := leaf(single.At[0])
+	if !root.Compare(&expected) {
+		t.Fatal("root of the single claim must be it's leaf")
+	}
+
+	// Claims are added in reverse order, so the canonical order differs from the order of adding.
+	odd := &Claims{}
+	for i := 3; i > 0; i-- {
+		_ = odd.Add(newTestClaim(i))
+	}
+
+	root, err = odd.MerkleRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sorted, err := odd.sortedCopy()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Node without a pair is moved to the next level as is.
+	expected = merkleNode(merkleNode(leaf(sorted.At[0]), leaf(sorted.At[1])), leaf(sorted.At[2]))
+	if !root.Compare(&expected) {
+		t.Fatal("root of the odd claims set differs from the expected one")
+	}
+}
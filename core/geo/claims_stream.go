@@ -0,0 +1,242 @@
+package geo
+
+import (
+	"geo-observers-blockchain/core/common"
+	"geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/common/types/transactions"
+	"geo-observers-blockchain/core/settings"
+	"geo-observers-blockchain/core/utils"
+	log "github.com/sirupsen/logrus"
+	"io"
+)
+
+var (
+	// Max size of one claim's binary representation (claim with max amount of members).
+	ClaimMaxBinarySize = transactions.TxIDBinarySize + common.Uint16ByteSize + ClaimSignatureMaxBinarySize +
+		common.Uint16ByteSize + ClaimMembersMaxCount*ClaimMemberBinarySize
+)
+
+// binarySize returns size of the binary representation of the claim (see MarshalBinary())
+// without the claim marshalling.
+func (claim *Claim) binarySize() (size int, err error) {
+	if claim.TxUUID == nil || claim.Members == nil {
+		return 0, errors.NilInternalDataStructure
+	}
+
+	if len(claim.Members.At) > ClaimMembersMaxCount {
+		return 0, errors.MaxCountReached
+	}
+
+	signatureBinary, err := claim.signatureBinary()
+	if err != nil {
+		return
+	}
+
+	return transactions.TxIDBinarySize + common.Uint16ByteSize + len(signatureBinary) +
+		common.Uint16ByteSize + len(claim.Members.At)*ClaimMemberBinarySize, nil
+}
+
+// binarySize returns size of the binary representation of the claims (see MarshalBinary()).
+func (c *Claims) binarySize() (size int, err error) {
+	size = common.Uint16ByteSize + common.Uint32ByteSize*len(c.At)
+	for _, claim := range c.At {
+		claimSize, err := claim.binarySize()
+		if err != nil {
+			return 0, err
+		}
+
+		size += claimSize
+	}
+
+	return
+}
+
+// WriteTo writes binary representation of the claims (see MarshalBinary()) to the writer.
+// Claims are marshalled and written one by one,
+// so the whole binary representation is never present in memory.
+// Implements io.WriterTo.
+func (c *Claims) WriteTo(w io.Writer) (n int64, err error) {
+	header := make([]byte, 0, common.Uint16ByteSize+common.Uint32ByteSize*len(c.At))
+	header = append(header, utils.MarshalUint16(c.Count())...)
+	for _, claim := range c.At {
+		claimSize, err := claim.binarySize()
+		if err != nil {
+			return 0, err
+		}
+
+		header = append(header, utils.MarshalUint32(uint32(claimSize))...)
+	}
+
+	written, err := w.Write(header)
+	n += int64(written)
+	if err != nil {
+		return
+	}
+
+	for _, claim := range c.At {
+		written, err := claim.writeTo(w)
+		n += written
+		if err != nil {
+			return n, err
+		}
+	}
+
+	return
+}
+
+// writeTo writes binary representation of the claim (see Claim.MarshalBinary()) to the writer.
+// Fields are written directly, so public keys of the members are never copied.
+func (claim *Claim) writeTo(w io.Writer) (n int64, err error) {
+	write := func(data []byte) error {
+		written, err := w.Write(data)
+		n += int64(written)
+		return err
+	}
+
+	if claim.TxUUID == nil || claim.Members == nil {
+		return 0, errors.NilInternalDataStructure
+	}
+
+	if len(claim.Members.At) > ClaimMembersMaxCount {
+		return 0, errors.MaxCountReached
+	}
+
+	signatureBinary, err := claim.signatureBinary()
+	if err != nil {
+		return
+	}
+
+	err = write(claim.TxUUID.Bytes[:])
+	if err != nil {
+		return
+	}
+
+	err = write(utils.MarshalUint16(uint16(len(signatureBinary))))
+	if err != nil {
+		return
+	}
+
+	err = write(signatureBinary)
+	if err != nil {
+		return
+	}
+
+	err = write(utils.MarshalUint16(claim.Members.Count()))
+	if err != nil {
+		return
+	}
+
+	for _, member := range claim.Members.At {
+		if member == nil || member.PubKey == nil {
+			return n, errors.NilInternalDataStructure
+		}
+
+		err = write(utils.MarshalUint16(member.ID))
+		if err != nil {
+			return
+		}
+
+		err = write(member.PubKey.Bytes[:])
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// ReadFrom reads binary representation of the claims (see MarshalBinary()) from the reader.
+// Exactly one claims set is read, the rest of the stream is left untouched.
+// Claims are read one by one, so the memory is never allocated for more than one claim at once,
+// and claims larger than ClaimMaxBinarySize are rejected before reading.
+// Malformed claims are processed in the same way as in UnmarshalBinary() (see settings.StrictUnmarshalling).
+// Truncated stream is reported as errors.InvalidDataFormat. In case of error claims are left untouched.
+// Implements io.ReaderFrom.
+func (c *Claims) ReadFrom(r io.Reader) (n int64, err error) {
+	countData := make([]byte, common.Uint16ByteSize)
+	read, err := readClaimsData(r, countData)
+	n += int64(read)
+	if err != nil {
+		return
+	}
+
+	count, err := utils.UnmarshalUint16(countData)
+	if err != nil {
+		return
+	}
+
+	if count > ClaimsMaxCount {
+		return n, errors.InvalidDataFormat
+	}
+
+	sizesData := make([]byte, common.Uint32ByteSize*int(count))
+	read, err = readClaimsData(r, sizesData)
+	n += int64(read)
+	if err != nil {
+		return
+	}
+
+	var (
+		claims = make([]*Claim, 0, count)
+		body   []byte
+	)
+
+	for i := 0; i < int(count); i++ {
+		offset := i * common.Uint32ByteSize
+		claimSize, err := utils.UnmarshalUint32(sizesData[offset : offset+common.Uint32ByteSize])
+		if err != nil {
+			return n, err
+		}
+
+		// Claim can't be skipped without reading, so too large claim is rejected in both modes.
+		if claimSize > uint32(ClaimMaxBinarySize) {
+			return n, errors.InvalidDataFormat
+		}
+
+		// Claims are copied on unmarshalling, so the buffer is reused.
+		if cap(body) < int(claimSize) {
+			body = make([]byte, claimSize)
+		}
+		body = body[:claimSize]
+
+		read, err = readClaimsData(r, body)
+		n += int64(read)
+		if err != nil {
+			return n, err
+		}
+
+		claim := NewClaim()
+		if claimSize == 0 {
+			err = errors.InvalidDataFormat
+		} else {
+			err = claim.UnmarshalBinary(body)
+		}
+
+		if err != nil {
+			if settings.StrictUnmarshalling {
+				return n, err
+			}
+
+			log.WithFields(log.Fields{"prefix": "Claims", "Position": i}).Warn(
+				"Malformed claim skipped: ", err)
+			continue
+		}
+
+		claims = append(claims, claim)
+	}
+
+	c.At = claims
+	c.index = nil
+	return n, nil
+}
+
+// readClaimsData fills the buffer from the reader.
+// Reports end of the stream as errors.InvalidDataFormat.
+func readClaimsData(r io.Reader, buffer []byte) (read int, err error) {
+	read, err = io.ReadFull(r, buffer)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = errors.InvalidDataFormat
+	}
+
+	return
+}
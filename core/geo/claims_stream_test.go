@@ -0,0 +1,121 @@
+package geo
+
+import (
+	"bytes"
+	"geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/utils"
+	"io/ioutil"
+	"testing"
+)
+
+func newTestClaims(count int) *Claims {
+	claims := &Claims{}
+	for i := 0; i < count; i++ {
+		_ = claims.Add(newTestClaim(i))
+	}
+
+	return claims
+}
+
+// Writes claims to the stream, reads them back, and checks that the stream contains
+// exactly the binary representation of the claims, and the rest of the stream is left untouched.
+func TestClaims_WriteTo_ReadFrom(t *testing.T) {
+	claims := newTestClaims(5)
+	data, err := claims.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := utils.MarshalUint16(claims.Count())
+	bodies := make([][]byte, 0, claims.Count())
+	for _, claim := range claims.At {
+		claimBinary, err := claim.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected = append(expected, utils.MarshalUint32(uint32(len(claimBinary)))...)
+		bodies = append(bodies, claimBinary)
+	}
+
+	if !bytes.Equal(data, utils.ChainByteSlices(expected, utils.ChainByteSlices(bodies...))) {
+		t.Fatal("binary format must not be changed")
+	}
+
+	stream := &bytes.Buffer{}
+	written, err := claims.WriteTo(stream)
+	if err != nil || written != int64(len(data)) || !bytes.Equal(stream.Bytes(), data) {
+		t.Fatal("stream must contain the binary representation of the claims")
+	}
+
+	stream.Write([]byte{1, 2, 3})
+
+	restored := &Claims{}
+	read, err := restored.ReadFrom(stream)
+	if err != nil || read != written {
+		t.Fatal(err)
+	}
+
+	if restored.Count() != 5 || stream.Len() != 3 {
+		t.Fatal("exactly one claims set must be read")
+	}
+
+	for i, claim := range claims.At {
+		if !restored.At[i].TxUUID.Compare(claim.TxUUID) || restored.At[i].Members.At[0].ID != claim.Members.At[0].ID {
+			t.Fatal()
+		}
+	}
+}
+
+func TestClaims_ReadFrom_Truncated(t *testing.T) {
+	data, err := newTestClaims(2).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, size := range []int{0, 1, 5, len(data) - 1} {
+		restored := &Claims{}
+		_, err = restored.ReadFrom(bytes.NewReader(data[:size]))
+		if err != errors.InvalidDataFormat || restored.At != nil {
+			t.Fatal("truncated stream must be rejected")
+		}
+	}
+}
+
+// Declares claim larger than any valid claim and checks that it is rejected before reading.
+func TestClaims_ReadFrom_ClaimAboveMaxSize(t *testing.T) {
+	data := utils.ChainByteSlices(utils.MarshalUint16(1), utils.MarshalUint32(uint32(ClaimMaxBinarySize+1)))
+
+	_, err := (&Claims{}).ReadFrom(bytes.NewReader(data))
+	if err != errors.InvalidDataFormat {
+		t.Fatal()
+	}
+}
+
+func BenchmarkClaims_MarshalBinary(b *testing.B) {
+	claims := newTestClaims(64)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		data, err := claims.MarshalBinary()
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		_, _ = ioutil.Discard.Write(data)
+	}
+}
+
+func BenchmarkClaims_WriteTo(b *testing.B) {
+	claims := newTestClaims(64)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, err := claims.WriteTo(ioutil.Discard)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
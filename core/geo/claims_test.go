@@ -4,15 +4,18 @@ import (
 	"bytes"
 	"crypto/rand"
 	"geo-observers-blockchain/core/common"
-	"geo-observers-blockchain/core/common/types"
-	"geo-observers-blockchain/core/crypto/lamport"
+	"geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/common/types/transactions"
+	"geo-observers-blockchain/core/settings"
+	"geo-observers-blockchain/core/utils"
+	"math"
 	"testing"
 )
 
 func TestClaim_MarshalBinary_EmptyInternal(t *testing.T) {
 	claim := &Claim{}
 	_, err := claim.MarshalBinary()
-	if err != common.ErrNilInternalDataStructure {
+	if err != errors.NilInternalDataStructure {
 		t.Fatal()
 	}
 }
@@ -20,7 +23,7 @@ func TestClaim_MarshalBinary_EmptyInternal(t *testing.T) {
 func TestClaim_UnmarshalBinary_EmptyData(t *testing.T) {
 	claim := NewClaim()
 	err := claim.UnmarshalBinary([]byte{})
-	if err != common.ErrInvalidDataFormat {
+	if err != errors.InvalidDataFormat {
 		t.Fatal()
 	}
 }
@@ -33,7 +36,7 @@ func TestClaim_UnmarshalBinary_EmptyData(t *testing.T) {
 func TestClaims_Add_NilParameter(t *testing.T) {
 	claims := &Claims{}
 	err := claims.Add(nil)
-	if err != common.ErrNilParameter {
+	if err != errors.NilParameter {
 		t.Fatal()
 	}
 }
@@ -41,14 +44,14 @@ func TestClaims_Add_NilParameter(t *testing.T) {
 func TestClaims_Add_Max(t *testing.T) {
 	claims := &Claims{}
 	for i := 0; i < ClaimsMaxCount; i++ {
-		err := claims.Add(NewClaim())
+		err := claims.Add(newTestClaim(i))
 		if err != nil {
 			t.Fatal()
 		}
 	}
 
-	err := claims.Add(&Claim{})
-	if err != common.ErrMaxCountReached {
+	err := claims.Add(newTestClaim(ClaimsMaxCount))
+	if err != errors.MaxCountReached {
 		t.Fatal("claims list must restrict max count of elements")
 	}
 }
@@ -60,7 +63,7 @@ func TestClaims_Count(t *testing.T) {
 	}
 
 	for i := 0; i < ClaimsMaxCount; i++ {
-		_ = claims.Add(NewClaim())
+		_ = claims.Add(newTestClaim(i))
 		if claims.Count() != uint16(i+1) {
 			t.Fatal()
 		}
@@ -78,7 +81,7 @@ func TestClaims_MarshallBinary_NoData(t *testing.T) {
 
 	// Empty serialised claims must be 2 bytes long.
 	// (total count if claims serializes as uint16)
-	if len(binary) != types.Uint16ByteSize {
+	if len(binary) != common.Uint16ByteSize {
 		t.Fatal()
 	}
 
@@ -98,15 +101,15 @@ func TestClaims_MarshallBinary_OneClaim_1024PubKeys(t *testing.T) {
 
 	// Reference data initialisation.
 	claim := NewClaim()
-	claim.TxUUID.Bytes = [16]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 1, 2, 3, 4, 5, 6}
+	claim.TxUUID.Bytes = [transactions.TxIDBinarySize]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 1, 2, 3, 4, 5, 6}
 	for i := 0; i < 1024; i++ {
-		pubKey := &lamport.PubKey{}
-		_, err := rand.Read(pubKey.Bytes[:])
+		member := NewClaimMember(uint16(i))
+		_, err := rand.Read(member.PubKey.Bytes[:])
 		if err != nil {
 			t.Fatal()
 		}
 
-		_ = claim.Members.Add(pubKey)
+		_ = claim.Members.Add(member)
 	}
 
 	claims := &Claims{}
@@ -139,9 +142,9 @@ func TestClaims_MarshallBinary_OneClaim_1024PubKeys(t *testing.T) {
 	}
 
 	// Members data
-	for i, pubKey := range claims.At[0].Members.At {
-		restoredPubKey := restoredClaims.At[0].Members.At[i]
-		pubKeyNIsEqual := bytes.Compare(pubKey.Bytes[:], restoredPubKey.Bytes[:]) == 0
+	for i, member := range claims.At[0].Members.At {
+		restoredMember := restoredClaims.At[0].Members.At[i]
+		pubKeyNIsEqual := bytes.Compare(member.PubKey.Bytes[:], restoredMember.PubKey.Bytes[:]) == 0
 		if !pubKeyNIsEqual {
 			t.Fatal()
 		}
@@ -163,12 +166,12 @@ func TestClaims_MarshallBinary_MaxElementsCount(t *testing.T) {
 			t.Fatal()
 		}
 
-		pubKey := &lamport.PubKey{}
-		_, err = rand.Read(pubKey.Bytes[:])
+		member := NewClaimMember(0)
+		_, err = rand.Read(member.PubKey.Bytes[:])
 		if err != nil {
 			t.Fatal()
 		}
-		_ = claim.Members.Add(pubKey)
+		_ = claim.Members.Add(member)
 
 		err = claims.Add(claim)
 		if err != nil {
@@ -199,11 +202,554 @@ func TestClaims_MarshallBinary_MaxElementsCount(t *testing.T) {
 			t.Fatal()
 		}
 
-		for j, key := range restoredClaim.Members.At {
-			pubKeyNIsEqual := bytes.Compare(key.Bytes[:], claims.At[i].Members.At[j].Bytes[:]) == 0
+		for j, member := range restoredClaim.Members.At {
+			pubKeyNIsEqual := bytes.Compare(member.PubKey.Bytes[:], claims.At[i].Members.At[j].PubKey.Bytes[:]) == 0
 			if !pubKeyNIsEqual {
 				t.Fatal()
 			}
 		}
 	}
 }
+
+// Creates serialized claims list with declared elements count greater than allowed.
+// Deserialization must be rejected before any memory for the claims would be allocated.
+func TestClaims_UnmarshalBinary_CountAboveMax(t *testing.T) {
+	binary := utils.MarshalUint16(ClaimsMaxCount + 1)
+
+	claims := &Claims{}
+	err := claims.UnmarshalBinary(binary)
+	if err != errors.InvalidDataFormat {
+		t.Fatal()
+	}
+
+	if claims.At != nil {
+		t.Fatal("claims must not be allocated for the invalid count")
+	}
+
+	claims = &Claims{}
+	err = claims.UnmarshalBinary(utils.MarshalUint16(math.MaxUint16))
+	if err != errors.InvalidDataFormat {
+		t.Fatal()
+	}
+
+	if claims.At != nil {
+		t.Fatal("claims must not be allocated for the invalid count")
+	}
+}
+
+// Creates claims list with several duplicates of each claim (in shuffled order),
+// and checks that duplicates are collapsed to one claim and removed count is reported correctly.
+func TestClaims_RemoveDuplicates(t *testing.T) {
+	const (
+		uniqueCount     = 4
+		duplicatesCount = 3
+	)
+
+	unique := make([]*Claim, 0, uniqueCount)
+	for i := 0; i < uniqueCount; i++ {
+		txID, err := transactions.NewRandomTxID(uint64(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		unique = append(unique, &Claim{TxUUID: txID, Members: &ClaimMembers{}})
+	}
+
+	// Duplicates are rejected by Add(), so they are assigned directly
+	// (as they might be received from the remote observers).
+	claims := &Claims{}
+	for i := 0; i < duplicatesCount; i++ {
+		for j := uniqueCount - 1; j >= 0; j-- {
+			claims.At = append(claims.At, unique[j])
+		}
+	}
+
+	removed, err := claims.RemoveDuplicates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if removed != uniqueCount*(duplicatesCount-1) {
+		t.Fatal("invalid removed claims count")
+	}
+
+	if claims.Count() != uniqueCount {
+		t.Fatal()
+	}
+
+	for i := 1; i < int(claims.Count()); i++ {
+		if claims.At[i].TxID().Compare(claims.At[i-1].TxID()) {
+			t.Fatal("duplicates must be removed")
+		}
+	}
+
+	// Nothing to remove on the second pass.
+	removed, err = claims.RemoveDuplicates()
+	if err != nil || removed != 0 {
+		t.Fatal()
+	}
+}
+
+func newTestClaimsWithCorruptedClaim(t *testing.T) (claims *Claims, data []byte) {
+	claims = &Claims{}
+	for i := 0; i < 3; i++ {
+		txID, err := transactions.NewRandomTxID(uint64(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		claim := &Claim{TxUUID: txID, Members: &ClaimMembers{}}
+		_ = claim.Members.Add(NewClaimMember(uint16(i)))
+		_ = claims.Add(claim)
+	}
+
+	data, err := claims.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Members count of the second (unsigned) claim is set out of the allowed range.
+	firstClaimBinary, _ := claims.At[0].MarshalBinary()
+	offset := common.Uint16ByteSize + common.Uint32ByteSize*3 + len(firstClaimBinary) +
+		transactions.TxIDBinarySize + common.Uint16ByteSize
+	copy(data[offset:], utils.MarshalUint16(math.MaxUint16))
+	return
+}
+
+// Strict mode must reject the whole sequence, if one of the claims is malformed.
+func TestClaims_UnmarshalBinary_StrictCorruptedClaim(t *testing.T) {
+	_, data := newTestClaimsWithCorruptedClaim(t)
+
+	restored := &Claims{}
+	err := restored.UnmarshalBinary(data)
+	if err != errors.InvalidDataFormat {
+		t.Fatal()
+	}
+}
+
+// Lenient mode must skip malformed claim and restore the rest of them.
+func TestClaims_UnmarshalBinary_LenientCorruptedClaim(t *testing.T) {
+	settings.StrictUnmarshalling = false
+	defer func() { settings.StrictUnmarshalling = true }()
+
+	claims, data := newTestClaimsWithCorruptedClaim(t)
+
+	restored := &Claims{}
+	err := restored.UnmarshalBinary(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if restored.Count() != 2 ||
+		!restored.At[0].TxID().Compare(claims.At[0].TxID()) ||
+		!restored.At[1].TxID().Compare(claims.At[2].TxID()) {
+		t.Fatal("only corrupted claim must be skipped")
+	}
+}
+
+// Declared members count must correspond to the data size only in strict mode.
+func TestClaim_UnmarshalBinary_MembersCountMismatch(t *testing.T) {
+	claim := NewClaim()
+	_ = claim.Members.Add(NewClaimMember(0))
+	data, err := claim.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Claim is unsigned, so members follow the empty signature.
+	copy(data[transactions.TxIDBinarySize+common.Uint16ByteSize:], utils.MarshalUint16(2))
+
+	err = NewClaim().UnmarshalBinary(data)
+	if err != errors.InvalidDataFormat {
+		t.Fatal()
+	}
+
+	settings.StrictUnmarshalling = false
+	defer func() { settings.StrictUnmarshalling = true }()
+
+	restored := NewClaim()
+	err = restored.UnmarshalBinary(data)
+	if err != nil || restored.Members.Count() != 1 {
+		t.Fatal()
+	}
+}
+
+// Checks that each claim is located at it's position in the sorted set,
+// original order is not changed, and absent claims are reported as not found.
+func TestClaims_IndexOf(t *testing.T) {
+	claims := &Claims{}
+	for i := 0; i < 7; i++ {
+		txID, err := transactions.NewRandomTxID(uint64(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_ = claims.Add(&Claim{TxUUID: txID, Members: &ClaimMembers{}})
+	}
+
+	original := make([]*Claim, len(claims.At))
+	copy(original, claims.At)
+
+	sorted := &Claims{At: make([]*Claim, len(claims.At))}
+	copy(sorted.At, claims.At)
+	err := sorted.Sort()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, claim := range sorted.At {
+		index, err := claims.IndexOf(claim.TxID())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if index != i {
+			t.Fatal("invalid claim position")
+		}
+	}
+
+	for i := range original {
+		if claims.At[i] != original[i] {
+			t.Fatal("original order must be preserved")
+		}
+	}
+
+	absent, err := transactions.NewRandomTxID(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = claims.IndexOf(absent)
+	if err != errors.NotFound {
+		t.Fatal()
+	}
+
+	_, err = (&Claims{}).IndexOf(absent)
+	if err != errors.NotFound {
+		t.Fatal()
+	}
+}
+
+// Inserts claims in shuffled order (with duplicates) via AddSorted,
+// and checks that the set is kept sorted and duplicates are rejected.
+func TestClaims_AddSorted(t *testing.T) {
+	unique := make([]*Claim, 0, 8)
+	for i := 0; i < 8; i++ {
+		txID, err := transactions.NewRandomTxID(uint64(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		unique = append(unique, &Claim{TxUUID: txID, Members: &ClaimMembers{}})
+	}
+
+	claims := &Claims{}
+	for _, i := range []int{5, 2, 7, 0, 3, 6, 1, 4} {
+		err := claims.AddSorted(unique[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, claim := range unique {
+		duplicate := &Claim{TxUUID: claim.TxUUID, Members: &ClaimMembers{}}
+		_ = duplicate.Members.Add(NewClaimMember(1))
+		if claims.AddSorted(duplicate) != errors.Collision {
+			t.Fatal("duplicate must be rejected")
+		}
+	}
+
+	if claims.Count() != 8 {
+		t.Fatal()
+	}
+
+	sorted := &Claims{At: make([]*Claim, len(unique))}
+	copy(sorted.At, unique)
+	err := sorted.Sort()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range sorted.At {
+		if claims.At[i] != sorted.At[i] {
+			t.Fatal("claims must be kept sorted")
+		}
+	}
+}
+
+// newTestClaim returns claim with one member, which transaction ID is derived from the number specified.
+func newTestClaim(number int) *Claim {
+	claim := NewClaim()
+	copy(claim.TxUUID.Bytes[:], utils.MarshalUint64(uint64(number)))
+	_ = claim.Members.Add(NewClaimMember(uint16(number)))
+	return claim
+}
+
+// Adds claims with the same transaction ID (with equal and with different members),
+// and checks that only the first one is accepted.
+func TestClaims_Add_Duplicate(t *testing.T) {
+	claims := &Claims{}
+	claim := newTestClaim(1)
+	if claims.Add(claim) != nil {
+		t.Fatal()
+	}
+
+	if claims.Add(claim) != errors.Collision {
+		t.Fatal("the same claim must be rejected")
+	}
+
+	sameTx := &Claim{TxUUID: claim.TxUUID, Members: &ClaimMembers{}}
+	_ = sameTx.Members.Add(NewClaimMember(1))
+	if claims.Add(sameTx) != errors.Collision {
+		t.Fatal("claim of the same transaction with other members must be rejected")
+	}
+
+	if claims.Add(newTestClaim(2)) != nil || claims.Count() != 2 {
+		t.Fatal()
+	}
+}
+
+// Assigns claims directly, bypassing Add(), and checks that duplicates are still detected.
+func TestClaims_Add_DuplicateOfAssigned(t *testing.T) {
+	claims := &Claims{}
+	_ = claims.Add(newTestClaim(1))
+
+	claims.At = []*Claim{newTestClaim(2), newTestClaim(3)}
+	if claims.Add(newTestClaim(1)) != nil {
+		t.Fatal("index must follow assigned claims")
+	}
+
+	if claims.Add(newTestClaim(3)) != errors.Collision {
+		t.Fatal()
+	}
+
+	if claims.Add(&Claim{}) != errors.NilParameter {
+		t.Fatal()
+	}
+}
+
+// Checks lookup of the claims by their transaction IDs, including the claims reordered by sorting
+// and the claims received via UnmarshalBinary().
+func TestClaims_ByTxID(t *testing.T) {
+	claims := &Claims{}
+	for i := 5; i > 0; i-- {
+		_ = claims.Add(newTestClaim(i))
+	}
+
+	claim, err := claims.ByTxID(newTestClaim(3).TxUUID)
+	if err != nil || claim != claims.At[2] {
+		t.Fatal()
+	}
+
+	err = claims.Sort()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claim, err = claims.ByTxID(newTestClaim(3).TxUUID)
+	if err != nil || !claim.TxUUID.Compare(newTestClaim(3).TxUUID) {
+		t.Fatal("sorted claims must be found")
+	}
+
+	if claims.Contains(newTestClaim(6).TxUUID) {
+		t.Fatal()
+	}
+
+	_, err = claims.ByTxID(newTestClaim(6).TxUUID)
+	if err != errors.NotFound {
+		t.Fatal()
+	}
+
+	data, err := claims.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := &Claims{}
+	_ = restored.Add(newTestClaim(6))
+	err = restored.UnmarshalBinary(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if restored.Contains(newTestClaim(6).TxUUID) || !restored.Contains(newTestClaim(1).TxUUID) {
+		t.Fatal("index must be rebuilt on unmarshalling")
+	}
+
+	_, err = restored.ByTxID(nil)
+	if err != errors.NilParameter {
+		t.Fatal()
+	}
+}
+
+// Feeds claims with truncated data and with sizes, that point beyond the data,
+// and checks that the data is rejected without panic and the claims are left untouched.
+func TestClaims_UnmarshalBinary_MalformedSizes(t *testing.T) {
+	claims := &Claims{}
+	for i := 0; i < 3; i++ {
+		_ = claims.Add(newTestClaim(i))
+	}
+
+	data, err := claims.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, strict := range []bool{true, false} {
+		settings.StrictUnmarshalling = strict
+
+		for size := 0; size < len(data); size++ {
+			restored := &Claims{}
+			_ = restored.Add(newTestClaim(100))
+			if restored.UnmarshalBinary(data[:size]) == nil && strict {
+				t.Fatal("truncated data must be rejected")
+			}
+		}
+
+		oversized := make([]byte, len(data))
+		copy(oversized, data)
+		copy(oversized[common.Uint16ByteSize:], utils.MarshalUint32(math.MaxUint32))
+
+		restored := &Claims{}
+		_ = restored.Add(newTestClaim(100))
+		if restored.UnmarshalBinary(oversized) != errors.InvalidDataFormat {
+			t.Fatal("claim size beyond the data must be rejected")
+		}
+
+		if restored.Count() != 1 || !restored.Contains(newTestClaim(100).TxUUID) {
+			t.Fatal("claims must be left untouched")
+		}
+	}
+
+	settings.StrictUnmarshalling = true
+}
+
+// Checks that members data can't contain more members than allowed, even if the declared count is valid.
+func TestClaimMembers_UnmarshalBinary_TooManyMembers(t *testing.T) {
+	data := utils.MarshalUint16(1)
+	data = append(data, make([]byte, ClaimMemberBinarySize*(ClaimMembersMaxCount+1))...)
+
+	members := &ClaimMembers{}
+	if members.UnmarshalBinary(data) != errors.InvalidDataFormat {
+		t.Fatal()
+	}
+}
+
+// Checks that arbitrary data never leads to panic on unmarshalling.
+func FuzzClaims_UnmarshalBinary(f *testing.F) {
+	claims := &Claims{}
+	for i := 0; i < 2; i++ {
+		_ = claims.Add(newTestClaim(i))
+	}
+
+	data, err := claims.MarshalBinary()
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	f.Add(data)
+	f.Add(data[:len(data)/2])
+	f.Add([]byte{0, 1, 255, 255, 255, 255})
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, strict := range []bool{true, false} {
+			settings.StrictUnmarshalling = strict
+			_ = (&Claims{}).UnmarshalBinary(data)
+		}
+
+		settings.StrictUnmarshalling = true
+	})
+}
+
+func TestClaim_Validate(t *testing.T) {
+	if newTestClaim(1).Validate() != nil {
+		t.Fatal()
+	}
+
+	if NewClaim().Validate() != ErrEmptyTxID {
+		t.Fatal("zero transaction ID must be rejected")
+	}
+
+	claim := newTestClaim(1)
+	claim.Members.At = nil
+	if claim.Validate() != ErrNoClaimMembers {
+		t.Fatal("claim without members must be rejected")
+	}
+
+	for i := 0; i < ClaimMembersMaxCount; i++ {
+		claim.Members.At = append(claim.Members.At, NewClaimMember(uint16(i)))
+	}
+	if claim.Validate() != nil {
+		t.Fatal()
+	}
+
+	claim.Members.At = append(claim.Members.At, NewClaimMember(0))
+	if claim.Validate() != ErrTooManyClaimMembers {
+		t.Fatal("claim with too many members must be rejected")
+	}
+
+	claim.Members.At = []*ClaimMember{{ID: 1}}
+	if claim.Validate() != errors.NilInternalDataStructure {
+		t.Fatal()
+	}
+
+	if (&Claim{}).Validate() != errors.NilInternalDataStructure {
+		t.Fatal()
+	}
+}
+
+// Sorts claims in reversed order and checks that they are ordered by their binary representation.
+func TestClaims_Sort(t *testing.T) {
+	claims := &Claims{}
+	for i := 10; i > 0; i-- {
+		_ = claims.Add(newTestClaim(i))
+	}
+
+	err := claims.Sort()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i < len(claims.At); i++ {
+		previous, _ := claims.At[i-1].MarshalBinary()
+		current, _ := claims.At[i].MarshalBinary()
+		if bytes.Compare(previous, current) != -1 {
+			t.Fatal("claims must be sorted")
+		}
+	}
+
+	if !claims.At[0].TxUUID.Compare(newTestClaim(1).TxUUID) || !claims.Contains(newTestClaim(10).TxUUID) {
+		t.Fatal()
+	}
+}
+
+// Sorts claims, one of which can't be marshalled,
+// and checks that the error is returned and the order of claims is not changed.
+func TestClaims_Sort_MarshallingError(t *testing.T) {
+	claims := &Claims{At: []*Claim{newTestClaim(2), {}, newTestClaim(1)}}
+	err := claims.Sort()
+	if err != errors.NilInternalDataStructure {
+		t.Fatal()
+	}
+
+	if !claims.At[0].TxUUID.Compare(newTestClaim(2).TxUUID) || claims.At[1].TxUUID != nil {
+		t.Fatal("claims must be left untouched")
+	}
+}
+
+func BenchmarkClaims_Sort(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		claims := &Claims{}
+		for j := 64; j > 0; j-- {
+			_ = claims.Add(newTestClaim(j))
+		}
+		b.StartTimer()
+
+		err := claims.Sort()
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package geo
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// Golden vectors lock the binary format of the claims sets.
+// In case if the format is changed intentionally - vectors must be regenerated
+// by running this test with the "-update-golden" flag.
+var updateGolden = flag.Bool("update-golden", false, "regenerate golden test vectors")
+
+// newVectorClaim returns claim, which content depends only on the seed.
+func newVectorClaim(seed byte, membersCount int) *Claim {
+	claim := NewClaim()
+	for i := range claim.TxUUID.Bytes {
+		claim.TxUUID.Bytes[i] = seed + byte(i)
+	}
+
+	for m := 0; m < membersCount; m++ {
+		member := NewClaimMember(uint16(seed)<<8 | uint16(m))
+		for i := range member.PubKey.Bytes {
+			member.PubKey.Bytes[i] = seed ^ byte(m) ^ byte(i)
+		}
+
+		_ = claim.Members.Add(member)
+	}
+
+	return claim
+}
+
+var claimsVectors = []struct {
+	name   string
+	claims func() *Claims
+}{
+	{
+		name:   "claims_empty",
+		claims: func() *Claims { return &Claims{} },
+	},
+	{
+		name: "claims_multi",
+		claims: func() *Claims {
+			claims := &Claims{}
+			_ = claims.Add(newVectorClaim(0x01, 1))
+			_ = claims.Add(newVectorClaim(0x20, 2))
+			_ = claims.Add(newVectorClaim(0x7f, 1))
+			return claims
+		},
+	},
+}
+
+func TestClaims_GoldenVectors(t *testing.T) {
+	for _, vector := range claimsVectors {
+		path := filepath.Join("testdata", vector.name+".golden")
+
+		claims := vector.claims()
+		data, err := claims.MarshalBinary()
+		if err != nil {
+			t.Fatal(vector.name, err)
+		}
+
+		if *updateGolden {
+			err = ioutil.WriteFile(path, data, 0644)
+			if err != nil {
+				t.Fatal(vector.name, err)
+			}
+		}
+
+		golden, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(vector.name, err)
+		}
+
+		if !bytes.Equal(data, golden) {
+			t.Fatal(vector.name, ": marshalled data differs from the golden vector")
+		}
+
+		restored := &Claims{}
+		err = restored.UnmarshalBinary(golden)
+		if err != nil {
+			t.Fatal(vector.name, err)
+		}
+
+		if restored.Count() != claims.Count() {
+			t.Fatal(vector.name, ": invalid claims count")
+		}
+
+		for i, claim := range claims.At {
+			expected, _ := claim.MarshalBinary()
+			received, err := restored.At[i].MarshalBinary()
+			if err != nil || !bytes.Equal(expected, received) {
+				t.Fatal(vector.name, ": restored claim differs from the original one")
+			}
+		}
+	}
+}
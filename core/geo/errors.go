@@ -0,0 +1,9 @@
+package geo
+
+import "geo-observers-blockchain/core/utils"
+
+var (
+	ErrEmptyTxID           = utils.Error("claim", "transaction ID is empty")
+	ErrNoClaimMembers      = utils.Error("claim", "claim has no members")
+	ErrTooManyClaimMembers = utils.Error("claim", "claim members count exceeds max allowed")
+)
@@ -74,13 +74,13 @@ func (members *TSLMembers) UnmarshalBinary(data []byte) (err error) {
 	}
 
 	members.At = make([]*TSLMember, 0, int(totalMembersCount))
-	for offset := common.Uint16ByteSize; offset < len(data); offset += ClaimMemberBinarySize {
-		if len(data)-offset < ClaimMemberBinarySize {
+	for offset := common.Uint16ByteSize; offset < len(data); offset += TSLMemberBinarySize {
+		if len(data)-offset < TSLMemberBinarySize {
 			return errors.InvalidDataFormat
 		}
 
 		member := &TSLMember{}
-		membersData := data[offset : offset+ClaimMemberBinarySize]
+		membersData := data[offset : offset+TSLMemberBinarySize]
 		err = member.UnmarshalBinary(membersData)
 		if err != nil {
 			return
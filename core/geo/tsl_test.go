@@ -3,8 +3,8 @@ package geo
 import (
 	"bytes"
 	"geo-observers-blockchain/core/common"
-	"geo-observers-blockchain/core/common/types"
-	"geo-observers-blockchain/core/crypto/lamport"
+	"geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/common/types/transactions"
 	"math/rand"
 	"testing"
 )
@@ -12,7 +12,7 @@ import (
 func TestTransactionSignaturesList_MarshalBinaryEmptyInternal(t *testing.T) {
 	tsl := &TSL{}
 	_, err := tsl.MarshalBinary()
-	if err != common.ErrNilInternalDataStructure {
+	if err != errors.NilInternalDataStructure {
 		t.Fatal()
 	}
 }
@@ -20,7 +20,7 @@ func TestTransactionSignaturesList_MarshalBinaryEmptyInternal(t *testing.T) {
 func TestTransactionSignaturesList_UnmarshalBinary_EmptyData(t *testing.T) {
 	tsl := NewTSL()
 	err := tsl.UnmarshalBinary([]byte{})
-	if err != common.ErrInvalidDataFormat {
+	if err != errors.InvalidDataFormat {
 		t.Fatal()
 	}
 }
@@ -28,7 +28,7 @@ func TestTransactionSignaturesList_UnmarshalBinary_EmptyData(t *testing.T) {
 func TestTransactionSignaturesList_UnmarshalBinary_TooShortData(t *testing.T) {
 	tsl := NewTSL()
 	err := tsl.UnmarshalBinary([]byte{0, 0, 0, 0, 0, 0})
-	if err != common.ErrInvalidDataFormat {
+	if err != errors.InvalidDataFormat {
 		t.Fatal()
 	}
 }
@@ -41,7 +41,7 @@ func TestTransactionSignaturesList_UnmarshalBinary_TooShortData(t *testing.T) {
 func TestTransactionSignaturesLists_Add_NilParameter(t *testing.T) {
 	tsls := &TSLs{}
 	err := tsls.Add(nil)
-	if err != common.ErrNilParameter {
+	if err != errors.NilParameter {
 		t.Fatal()
 	}
 }
@@ -56,7 +56,7 @@ func TestTransactionSignaturesLists_Add_Max(t *testing.T) {
 	}
 
 	err := tsls.Add(NewTSL())
-	if err != common.ErrMaxCountReached {
+	if err != errors.MaxCountReached {
 		t.Fatal("tsls list must restrict max count of elements")
 	}
 }
@@ -86,7 +86,7 @@ func TestTransactionSignaturesLists_MarshallBinary_NoData(t *testing.T) {
 
 	// Empty serialised tsls must be 2 bytes long.
 	// (total count if tsls serializes as uint16)
-	if len(binary) != types.Uint16ByteSize {
+	if len(binary) != common.Uint16ByteSize {
 		t.Fatal()
 	}
 
@@ -106,15 +106,15 @@ func TestTransactionSignaturesLists_MarshallBinary_OneTSL_1024Signatures(t *test
 
 	// Reference data initialisation.
 	tsl := NewTSL()
-	tsl.TxUUID.Bytes = [16]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 1, 2, 3, 4, 5, 6}
+	tsl.TxUUID.Bytes = [transactions.TxIDBinarySize]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 1, 2, 3, 4, 5, 6}
 	for i := 0; i < 1024; i++ {
-		sig := &lamport.Signature{}
-		_, err := rand.Read(sig.Bytes[:])
+		member := NewTSLMember(uint16(i))
+		_, err := rand.Read(member.Signature.Bytes[:])
 		if err != nil {
 			t.Fatal()
 		}
 
-		_ = tsl.Members.Add(sig)
+		_ = tsl.Members.Add(member)
 	}
 
 	tsls := &TSLs{}
@@ -147,9 +147,9 @@ func TestTransactionSignaturesLists_MarshallBinary_OneTSL_1024Signatures(t *test
 	}
 
 	// At data
-	for i, sig := range tsls.At[0].Members.At {
-		restoredSig := restoredTSLs.At[0].Members.At[i]
-		sigNIsEqual := bytes.Compare(sig.Bytes[:], restoredSig.Bytes[:]) == 0
+	for i, member := range tsls.At[0].Members.At {
+		restoredMember := restoredTSLs.At[0].Members.At[i]
+		sigNIsEqual := bytes.Compare(member.Signature.Bytes[:], restoredMember.Signature.Bytes[:]) == 0
 		if !sigNIsEqual {
 			t.Fatal()
 		}
@@ -163,55 +163,99 @@ func TestTransactionSignaturesLists_MarshallBinary_OneTSL_1024Signatures(t *test
 func TestTransactionSignaturesLists_MarshallBinary_MaxElementsCount(t *testing.T) {
 
 	// Reference data initialisation.
-	TSLs := &TSLs{}
+	tsls := &TSLs{}
 	for j := 0; j < TSLsMaxCount; j++ {
-		TSL := NewTSL()
-		_, err := rand.Read(TSL.TxUUID.Bytes[:])
+		tsl := NewTSL()
+		_, err := rand.Read(tsl.TxUUID.Bytes[:])
 		if err != nil {
 			t.Fatal()
 		}
 
-		sig := &lamport.Signature{}
-		_, err = rand.Read(sig.Bytes[:])
+		member := NewTSLMember(0)
+		_, err = rand.Read(member.Signature.Bytes[:])
 		if err != nil {
 			t.Fatal()
 		}
-		_ = TSL.Members.Add(sig)
+		_ = tsl.Members.Add(member)
 
-		err = TSLs.Add(TSL)
+		err = tsls.Add(tsl)
 		if err != nil {
 			t.Fatal()
 		}
 	}
 
 	// Marshalling.
-	binary, _ := TSLs.MarshalBinary()
+	binary, _ := tsls.MarshalBinary()
 
 	restoredTSLs := &TSLs{}
 	_ = restoredTSLs.UnmarshalBinary(binary)
 
 	// Checks
-	if restoredTSLs.Count() != TSLsMaxCount {
+	if int(restoredTSLs.Count()) != TSLsMaxCount {
 		t.Fatal()
 	}
-	if restoredTSLs.Count() != TSLs.Count() {
+	if restoredTSLs.Count() != tsls.Count() {
 		t.Fatal()
 	}
 
 	for i, restoredTSL := range restoredTSLs.At {
 		transactionUUIDsAreEqual := bytes.Compare(
 			restoredTSL.TxUUID.Bytes[:],
-			TSLs.At[i].TxUUID.Bytes[:]) == 0
+			tsls.At[i].TxUUID.Bytes[:]) == 0
 
 		if !transactionUUIDsAreEqual {
 			t.Fatal()
 		}
 
-		for j, sig := range restoredTSL.Members.At {
-			sigNIsEqual := bytes.Compare(sig.Bytes[:], TSLs.At[i].Members.At[j].Bytes[:]) == 0
+		for j, member := range restoredTSL.Members.At {
+			sigNIsEqual := bytes.Compare(member.Signature.Bytes[:], tsls.At[i].Members.At[j].Signature.Bytes[:]) == 0
 			if !sigNIsEqual {
 				t.Fatal()
 			}
 		}
 	}
 }
+
+// Regression: TSL members were unmarshalled with the size of the claim member,
+// so serialized TSL members could not be read back.
+func TestTSLMembers_MarshalBinary_RoundTrip(t *testing.T) {
+	members := &TSLMembers{}
+	for i := 0; i < 3; i++ {
+		member := NewTSLMember(uint16(i))
+		_, err := rand.Read(member.Signature.Bytes[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = members.Add(member)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	binary, err := members.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(binary) != common.Uint16ByteSize+TSLMemberBinarySize*3 {
+		t.Fatal("invalid binary size")
+	}
+
+	restored := &TSLMembers{}
+	err = restored.UnmarshalBinary(binary)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if restored.Count() != members.Count() {
+		t.Fatal()
+	}
+
+	for i, member := range members.At {
+		if restored.At[i].ID != member.ID ||
+			bytes.Compare(restored.At[i].Signature.Bytes[:], member.Signature.Bytes[:]) != 0 {
+			t.Fatal("restored member differs from the original one")
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package core
+
+import (
+	"geo-observers-blockchain/core/chain/pool"
+	"geo-observers-blockchain/core/crypto/keystore"
+	observersNet "geo-observers-blockchain/core/network/communicator/observers"
+	"geo-observers-blockchain/core/network/external"
+	"geo-observers-blockchain/core/ticker"
+	"net"
+	"strconv"
+	"time"
+)
+
+// HealthSnapshot summarizes the state of the observer's subsystems.
+// It is serializable to JSON, so it might be returned as is by the health endpoint.
+type HealthSnapshot struct {
+	Timestamp time.Time `json:"timestamp"`
+
+	Ticker ticker.Status `json:"ticker"`
+
+	PoolClaims pool.Stats `json:"pool_claims"`
+	PoolTSLs   pool.Stats `json:"pool_tsls"`
+
+	ObserversCount int `json:"observers"`
+
+	// Amount of observers of the current configuration, to which connections are open
+	// (the current observer itself is not counted).
+	ConnectedObserversCount int `json:"connected_observers"`
+
+	// Addresses of the observers of the current configuration, to which there are no open connections
+	// (the current observer itself is never reported).
+	MissingObservers []string `json:"missing_observers"`
+
+	// Fingerprint of the observer's key (see keystore.Fingerprint()).
+	KeyFingerprint string `json:"key_fingerprint"`
+}
+
+// HealthSnapshot collects current state of the subsystems.
+// It is safe to call this method from any goroutine.
+func (c *Core) HealthSnapshot() HealthSnapshot {
+	conf, err := c.observersConfReporter.GetCurrentConfiguration()
+	if err != nil {
+		conf = nil
+	}
+
+	return collectHealthSnapshot(c.ticker, c.poolClaims, c.poolTSLs, c.senderObservers, c.keystore, conf)
+}
+
+// collectHealthSnapshot pulls snapshots from the subsystems.
+// Absent subsystems (nil) leave their fields empty.
+func collectHealthSnapshot(
+	t *ticker.Ticker, poolClaims, poolTSLs *pool.Handler, sender *observersNet.Sender,
+	k *keystore.KeyStore, conf *external.Configuration) (snapshot HealthSnapshot) {
+
+	snapshot.Timestamp = time.Now()
+	snapshot.MissingObservers = []string{}
+
+	if t != nil {
+		snapshot.Ticker = t.Status()
+	}
+
+	if poolClaims != nil {
+		snapshot.PoolClaims = poolClaims.Stats()
+	}
+
+	if poolTSLs != nil {
+		snapshot.PoolTSLs = poolTSLs.Stats()
+	}
+
+	if conf != nil && sender != nil {
+		missing := sender.MissingObservers(conf)
+		snapshot.ObserversCount = len(conf.Observers)
+		if len(conf.Observers) > 0 {
+			snapshot.ConnectedObserversCount = len(conf.Observers) - 1 - len(missing)
+		}
+
+		for _, observer := range missing {
+			snapshot.MissingObservers = append(
+				snapshot.MissingObservers, net.JoinHostPort(observer.Host, strconv.Itoa(int(observer.Port))))
+		}
+	}
+
+	if k != nil {
+		snapshot.KeyFingerprint = k.Fingerprint()
+	}
+
+	return
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"encoding/json"
+	"geo-observers-blockchain/core/chain/pool"
+	"geo-observers-blockchain/core/crypto/keystore"
+	observersNet "geo-observers-blockchain/core/network/communicator/observers"
+	"geo-observers-blockchain/core/network/external"
+	"geo-observers-blockchain/core/settings"
+	"geo-observers-blockchain/core/ticker"
+	"math"
+	"testing"
+)
+
+// Constructs the subsystems and checks that the snapshot is populated from all of them
+// and is serializable.
+func TestCollectHealthSnapshot(t *testing.T) {
+	conf := external.NewConfiguration(0, []*external.Observer{
+		external.NewObserver("127.0.0.1", 3000, nil),
+		external.NewObserver("127.0.0.1", 3001, nil),
+		external.NewObserver("127.0.0.1", 3002, nil),
+	})
+	conf.CurrentObserverIndex = 1
+
+	k, err := keystore.NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot := collectHealthSnapshot(
+		ticker.New(nil), pool.NewHandler(nil), pool.NewHandler(nil),
+		observersNet.NewSender(nil, nil), k, conf)
+
+	if snapshot.Timestamp.IsZero() {
+		t.Fatal()
+	}
+
+	if snapshot.Ticker.IsRunning || snapshot.Ticker.FrameIndex != math.MaxUint16 {
+		t.Fatal("invalid ticker status")
+	}
+
+	if len(snapshot.PoolClaims.ApprovalHistogram) != settings.ObserversConsensusCount+1 ||
+		len(snapshot.PoolTSLs.ApprovalHistogram) != settings.ObserversConsensusCount+1 {
+		t.Fatal("invalid pools stats")
+	}
+
+	if snapshot.ObserversCount != 3 || snapshot.ConnectedObserversCount != 0 ||
+		len(snapshot.MissingObservers) != 2 {
+		t.Fatal("invalid connections stats")
+	}
+
+	// Current observer is never connected to itself, so it must not be reported as missing.
+	if snapshot.MissingObservers[0] != "127.0.0.1:3000" || snapshot.MissingObservers[1] != "127.0.0.1:3002" {
+		t.Fatal("current observer must not be reported as missing")
+	}
+
+	if snapshot.KeyFingerprint == "" || snapshot.KeyFingerprint != k.Fingerprint() {
+		t.Fatal("invalid key fingerprint")
+	}
+
+	_, err = json.Marshal(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,122 @@
+package common
+
+import (
+	"encoding"
+	common2 "geo-observers-blockchain/core/common"
+	"geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/utils"
+	"io"
+	"net"
+	"time"
+)
+
+var (
+	// Response has not been received before the deadline (observer might be dead or overloaded).
+	ErrResponseTimeout = utils.Error("geo api", "response timeout")
+)
+
+// IdentifiedRequest is the request, that is sent by the client together with it's ID
+// (see MarshalRequestWithID()).
+type IdentifiedRequest interface {
+	encoding.BinaryMarshaler
+	ID() uint32
+}
+
+// ReceiveResponse reads the response to the request from the connection, and unmarshals it into "response".
+// Response format: [response size: uint32] [response].
+// Read is stopped as soon as the deadline is reached (zero deadline means no deadline),
+// in this case ErrResponseTimeout is returned.
+func ReceiveResponse(conn net.Conn, response encoding.BinaryUnmarshaler, deadline time.Time) (err error) {
+	data, err := receiveMessage(conn, deadline)
+	if err != nil {
+		return
+	}
+
+	return response.UnmarshalBinary(data)
+}
+
+// MarshalRequestWithID returns binary representation of the request, prefixed by the protocol header
+// and the ID of the request (see ProtocolVersionWithRequestID).
+// Message size is not included.
+func MarshalRequestWithID(request IdentifiedRequest) (data []byte, err error) {
+	requestBinary, err := request.MarshalBinary()
+	if err != nil {
+		return
+	}
+
+	return utils.ChainByteSlices(
+		[]byte{ProtocolVersionWithRequestID}, utils.MarshalUint32(request.ID()), requestBinary), nil
+}
+
+// ReceiveResponseWithID reads the next response from the connection, that is used for the requests with IDs
+// (see ProtocolVersionWithRequestID), and returns the ID of the request, the response is related to,
+// and the response itself (not parsed, response type is defined by the request).
+// Response format: [response size: uint32] [request ID: uint32] [response].
+// Read is stopped as soon as the deadline is reached (zero deadline means no deadline),
+// in this case ErrResponseTimeout is returned.
+func ReceiveResponseWithID(conn net.Conn, deadline time.Time) (requestID uint32, response []byte, err error) {
+	data, err := receiveMessage(conn, deadline)
+	if err != nil {
+		return
+	}
+
+	if len(data) < common2.Uint32ByteSize {
+		err = errors.InvalidDataFormat
+		return
+	}
+
+	requestID, err = utils.UnmarshalUint32(data[:common2.Uint32ByteSize])
+	if err != nil {
+		return
+	}
+
+	response = data[common2.Uint32ByteSize:]
+	return
+}
+
+// receiveMessage reads one message [size: uint32] [data] from the connection.
+// Connection is read directly (without buffering),
+// so the next messages are left in the connection untouched.
+func receiveMessage(conn net.Conn, deadline time.Time) (data []byte, err error) {
+	err = conn.SetReadDeadline(deadline)
+	if err != nil {
+		return
+	}
+	defer conn.SetReadDeadline(time.Time{})
+
+	sizeBinary := make([]byte, common2.Uint32ByteSize)
+	_, err = io.ReadFull(conn, sizeBinary)
+	if err != nil {
+		return nil, responseReadError(err)
+	}
+
+	size, err := utils.UnmarshalUint32(sizeBinary)
+	if err != nil {
+		return
+	}
+
+	if size > MaxResponseSize {
+		return nil, errors.InvalidDataFormat
+	}
+
+	data = make([]byte, size)
+	_, err = io.ReadFull(conn, data)
+	if err != nil {
+		return nil, responseReadError(err)
+	}
+
+	return
+}
+
+func responseReadError(err error) error {
+	netErr, isNetErr := err.(net.Error)
+	if isNetErr && netErr.Timeout() {
+		return ErrResponseTimeout
+	}
+
+	if err == io.ErrUnexpectedEOF {
+		return errors.InvalidDataFormat
+	}
+
+	return err
+}
@@ -0,0 +1,87 @@
+package common
+
+import (
+	"geo-observers-blockchain/core/utils"
+	"net"
+	"testing"
+	"time"
+)
+
+type testResponse struct {
+	data []byte
+}
+
+func (r *testResponse) UnmarshalBinary(data []byte) error {
+	r.data = data
+	return nil
+}
+
+// startTestServer accepts connections and passes them to the handler.
+func startTestServer(t *testing.T, handler func(conn net.Conn)) (address string, stop func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go handler(conn)
+		}
+	}()
+
+	return listener.Addr().String(), func() { _ = listener.Close() }
+}
+
+// Server accepts the connection, but never replies:
+// client must stop waiting at the deadline.
+func TestReceiveResponse_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	address, stop := startTestServer(t, func(conn net.Conn) {
+		defer conn.Close()
+		<-release
+	})
+	defer stop()
+
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	started := time.Now()
+	err = ReceiveResponse(conn, &testResponse{}, time.Now().Add(time.Millisecond*100))
+	if err != ErrResponseTimeout {
+		t.Fatal(err)
+	}
+
+	if time.Since(started) > time.Second {
+		t.Fatal("response must not be awaited after the deadline")
+	}
+}
+
+func TestReceiveResponse(t *testing.T) {
+	address, stop := startTestServer(t, func(conn net.Conn) {
+		defer conn.Close()
+		_, _ = conn.Write(utils.ChainByteSlices(utils.MarshalUint32(3), []byte{1, 2, 3}))
+	})
+	defer stop()
+
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	response := &testResponse{}
+	err = ReceiveResponse(conn, response, time.Now().Add(time.Second))
+	if err != nil || len(response.data) != 3 || response.data[2] != 3 {
+		t.Fatal(err)
+	}
+}
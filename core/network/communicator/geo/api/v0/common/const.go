@@ -2,6 +2,16 @@ package common
 
 const (
 	ProtocolVersion = 0
+
+	// Same as ProtocolVersion, but the request type is preceded by the request ID:
+	// [protocol version] [request ID: uint32] [request type] [request],
+	// and the request ID is echoed in the response: [response size: uint32] [request ID: uint32] [response].
+	// Connection is not closed after the response, so the client is able to send several requests
+	// through one connection and to match the responses (that might arrive in any order) by the request ID.
+	ProtocolVersionWithRequestID = 1
+
+	// Max size of the response, that is accepted by the client (see ReceiveResponse()).
+	MaxResponseSize = 1024 * 1024 * 32 // 32 MB
 )
 
 const (
@@ -12,9 +22,10 @@ const (
 	ReqChainLastBlockNumber = 32
 
 	// TSLs.
-	ReqTSLAppend    = 64
-	ReqTSLIsPresent = 66
-	ReqTSLGet       = 68
+	ReqTSLAppend      = 64
+	ReqTSLIsPresent   = 66
+	ReqTSLGet         = 68
+	ReqTSLsArePresent = 70
 
 	// Claims
 	ReqClaimAppend    = 128
@@ -1,6 +1,15 @@
 package common
 
-import "encoding"
+import (
+	"encoding"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ID of the last request created (see nextRequestID()).
+	lastRequestID uint32
+)
 
 type Request interface {
 	// Returns channel from which the response should be fetched,
@@ -9,6 +18,11 @@ type Request interface {
 	ResponseChannel() chan encoding.BinaryMarshaler
 	ErrorsChannel() chan error
 	UnmarshalBinary(data []byte) (err error)
+
+	// Returns ID of the request, that is echoed in the response (see ProtocolVersionWithRequestID).
+	// Requests without response has no ID (0 is returned).
+	ID() uint32
+	SetID(id uint32)
 }
 
 // --------------------------------------------------------------------------------------------------------------------
@@ -16,14 +30,42 @@ type Request interface {
 type RequestWithResponse struct {
 	response chan encoding.BinaryMarshaler
 	errors   chan error
+
+	// Time, until which the client waits for the response (see ReceiveResponse()).
+	// Is not transferred to the observer. Zero deadline means no deadline.
+	deadline time.Time
+
+	// See Request.ID().
+	id uint32
 }
 
 func NewRequestWithResponse() *RequestWithResponse {
 	return &RequestWithResponse{
 		response: make(chan encoding.BinaryMarshaler, 1),
+		id:       nextRequestID(),
 	}
 }
 
+func NewRequestWithDeadline(deadline time.Time) *RequestWithResponse {
+	r := NewRequestWithResponse()
+	r.deadline = deadline
+	return r
+}
+
+// Deadline returns time, until which the client waits for the response.
+func (r *RequestWithResponse) Deadline() time.Time {
+	return r.deadline
+}
+
+func (r *RequestWithResponse) ID() uint32 {
+	return r.id
+}
+
+// SetID replaces the generated ID by the ID, received from the client.
+func (r *RequestWithResponse) SetID(id uint32) {
+	r.id = id
+}
+
 func (r *RequestWithResponse) ResponseChannel() chan encoding.BinaryMarshaler {
 	return r.response
 }
@@ -43,3 +85,18 @@ func (r *RequestWithoutResponse) ResponseChannel() chan encoding.BinaryMarshaler
 func (r *RequestWithoutResponse) ErrorsChannel() chan error {
 	return nil
 }
+
+func (r *RequestWithoutResponse) ID() uint32 {
+	return 0
+}
+
+// SetID does nothing: there is no response, that the ID might be echoed in.
+func (r *RequestWithoutResponse) SetID(id uint32) {}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+// nextRequestID returns monotonically increasing (until overflow) request ID.
+// IDs are unique in scope of the process, so the requests of several clients might share one connection.
+func nextRequestID() uint32 {
+	return atomic.AddUint32(&lastRequestID, 1)
+}
@@ -1,22 +1,47 @@
 package v0
 
 import (
+	common2 "geo-observers-blockchain/core/common"
 	"geo-observers-blockchain/core/common/errors"
 	"geo-observers-blockchain/core/network/communicator/geo/api/v0/common"
 	"geo-observers-blockchain/core/network/communicator/geo/api/v0/requests"
+	"geo-observers-blockchain/core/utils"
 )
 
 const (
 	protocolHeaderBytesSize = 2
 )
 
+// ParseRequest parses the request of any of supported protocol versions
+// (see common.ProtocolVersion and common.ProtocolVersionWithRequestID).
+// In case if the request ID is present - it is set to the parsed request.
 func ParseRequest(data []byte) (request common.Request, e errors.E) {
 	e = validateProtocolHeader(data)
 	if e != nil {
 		return
 	}
 
-	return dispatchRequest(data[1:])
+	if data[0] == common.ProtocolVersion {
+		return dispatchRequest(data[1:])
+	}
+
+	// Request ID must be followed by the request type.
+	if len(data) < protocolHeaderBytesSize+common2.Uint32ByteSize {
+		return nil, errors.AppendStackTrace(errors.InvalidDataFormat)
+	}
+
+	requestID, err := utils.UnmarshalUint32(data[1 : 1+common2.Uint32ByteSize])
+	if err != nil {
+		return nil, errors.AppendStackTrace(errors.InvalidDataFormat)
+	}
+
+	request, e = dispatchRequest(data[1+common2.Uint32ByteSize:])
+	if e != nil {
+		return
+	}
+
+	request.SetID(requestID)
+	return
 }
 
 func validateProtocolHeader(data []byte) (e errors.E) {
@@ -25,7 +50,7 @@ func validateProtocolHeader(data []byte) (e errors.E) {
 		return errors.AppendStackTrace(errors.InvalidDataFormat)
 	}
 
-	if data[0] != common.ProtocolVersion {
+	if data[0] != common.ProtocolVersion && data[0] != common.ProtocolVersionWithRequestID {
 		return errors.AppendStackTrace(errors.InvalidDataFormat)
 	}
 
@@ -49,6 +74,9 @@ func dispatchRequest(data []byte) (request common.Request, e errors.E) {
 	case common.ReqTSLIsPresent:
 		return parseRequest(&requests.TSLIsPresent{}, requestData)
 
+	case common.ReqTSLsArePresent:
+		return parseRequest(&requests.TSLsArePresent{}, requestData)
+
 	case common.ReqClaimAppend:
 		return parseRequest(&requests.ClaimAppend{}, requestData)
 
@@ -1,10 +1,13 @@
 package requests
 
 import (
+	common2 "geo-observers-blockchain/core/common"
+	"geo-observers-blockchain/core/common/errors"
 	"geo-observers-blockchain/core/common/types/transactions"
 	"geo-observers-blockchain/core/geo"
 	"geo-observers-blockchain/core/network/communicator/geo/api/v0/common"
 	"geo-observers-blockchain/core/utils"
+	"time"
 )
 
 type TSLAppend struct {
@@ -63,6 +66,15 @@ func NewTSLIsPresent(TxID *transactions.TxID) *TSLIsPresent {
 	}
 }
 
+// NewTSLIsPresentWithDeadline returns the request, response to which is awaited by the client
+// until the deadline (see common.ReceiveResponse()).
+func NewTSLIsPresentWithDeadline(TxID *transactions.TxID, deadline time.Time) *TSLIsPresent {
+	return &TSLIsPresent{
+		RequestWithResponse: common.NewRequestWithDeadline(deadline),
+		TxID:                TxID,
+	}
+}
+
 func (request *TSLIsPresent) MarshalBinary() (data []byte, err error) {
 	typeID := []byte{common.ReqTSLIsPresent}
 	txIDBinary, err := request.TxID.MarshalBinary()
@@ -74,3 +86,58 @@ func (request *TSLIsPresent) UnmarshalBinary(data []byte) (err error) {
 	request.TxID = &transactions.TxID{}
 	return request.TxID.UnmarshalBinary(data)
 }
+
+// --------------------------------------------------------------------------------------------------------------------
+
+const (
+	// Max amount of transactions IDs, that might be checked by one TSLsArePresent request.
+	TSLsArePresentMaxCount = 1024
+)
+
+// TSLsArePresent is the batch version of the TSLIsPresent request.
+type TSLsArePresent struct {
+	*common.RequestWithResponse
+	TxIDs *transactions.TransactionIDs
+}
+
+func NewTSLsArePresent(TxIDs []*transactions.TxID) *TSLsArePresent {
+	return &TSLsArePresent{
+		RequestWithResponse: common.NewRequestWithResponse(),
+		TxIDs:               transactions.NewTransactionIDs(TxIDs),
+	}
+}
+
+func (request *TSLsArePresent) MarshalBinary() (data []byte, err error) {
+	if len(request.TxIDs.At) > TSLsArePresentMaxCount {
+		return nil, errors.MaxCountReached
+	}
+
+	typeID := []byte{common.ReqTSLsArePresent}
+	txIDsBinary, err := request.TxIDs.MarshalBinary()
+	if err != nil {
+		return
+	}
+
+	return utils.ChainByteSlices(typeID, txIDsBinary), nil
+}
+
+// UnmarshalBinary returns errors.MaxCountReached
+// in case if more than TSLsArePresentMaxCount transactions IDs are requested.
+func (request *TSLsArePresent) UnmarshalBinary(data []byte) (err error) {
+	count, err := utils.UnmarshalUint16(data)
+	if err != nil {
+		return errors.InvalidDataFormat
+	}
+
+	if count > TSLsArePresentMaxCount {
+		return errors.MaxCountReached
+	}
+
+	if count == 0 || len(data) != common2.Uint16ByteSize+int(count)*transactions.TxIDBinarySize {
+		return errors.InvalidDataFormat
+	}
+
+	request.RequestWithResponse = common.NewRequestWithResponse()
+	request.TxIDs = &transactions.TransactionIDs{}
+	return request.TxIDs.UnmarshalBinary(data)
+}
@@ -5,14 +5,59 @@ import (
 	"geo-observers-blockchain/core/common/errors"
 	"geo-observers-blockchain/core/geo"
 	"geo-observers-blockchain/core/utils"
+	"math"
 )
 
+// TSLPresenceStatus describes the result of the TSL presence check.
+// Is transferred in addition to the presence flags, so the GEO node is able
+// to distinguish absent TSL from the TSL, that can't be checked at the moment.
+type TSLPresenceStatus uint8
+
+const (
+	TSLStatusAbsent TSLPresenceStatus = iota
+	TSLStatusPresent
+
+	// Observer has not synchronised it's chain yet, so the TSL might be present in the chain of other observers.
+	TSLStatusNotSynced
+
+	// Presence check has failed on the observer's side.
+	TSLStatusInternalError
+)
+
+// TSLIsPresent format: [presentInPool: 1B] [presentInBlock: uint64] [status: 1B].
+// Status is appended to the end of the response,
+// so the clients, that are not aware of it, still read the presence flags as before.
+// Responses without status are treated as TSLStatusPresent / TSLStatusAbsent in accordance to the presence flags.
 type TSLIsPresent struct {
 	PresentInPool  bool
 	PresentInBlock uint64
+	Status         TSLPresenceStatus
+}
+
+// NewTSLIsPresent returns response with the status corresponding to the presence flags.
+func NewTSLIsPresent(presentInPool bool, presentInBlock uint64) *TSLIsPresent {
+	response := &TSLIsPresent{
+		PresentInPool:  presentInPool,
+		PresentInBlock: presentInBlock,
+	}
+
+	response.Status = response.presenceStatus()
+	return response
+}
+
+// NewTSLIsPresentWithStatus returns response, that carries only the status (e.g. TSLStatusNotSynced).
+// Presence flags are left unset.
+func NewTSLIsPresentWithStatus(status TSLPresenceStatus) *TSLIsPresent {
+	return &TSLIsPresent{
+		Status: status,
+	}
 }
 
 func (response *TSLIsPresent) MarshalBinary() (data []byte, err error) {
+	if response.Status > TSLStatusInternalError {
+		return nil, errors.InvalidDataFormat
+	}
+
 	data = make([]byte, 1)
 	if response.PresentInPool {
 		data[0] = 1
@@ -21,7 +66,7 @@ func (response *TSLIsPresent) MarshalBinary() (data []byte, err error) {
 	}
 
 	presentInBlockBinary := utils.MarshalUint64(response.PresentInBlock)
-	return utils.ChainByteSlices(data, presentInBlockBinary), nil
+	return utils.ChainByteSlices(data, presentInBlockBinary, []byte{byte(response.Status)}), nil
 }
 
 func (response *TSLIsPresent) UnmarshalBinary(data []byte) (err error) {
@@ -34,7 +79,85 @@ func (response *TSLIsPresent) UnmarshalBinary(data []byte) (err error) {
 		response.PresentInPool = false
 	}
 
-	response.PresentInBlock, err = utils.UnmarshalUint64(data[1:])
+	response.PresentInBlock, err = utils.UnmarshalUint64(data[1 : 1+common.Uint64ByteSize])
+	if err != nil {
+		return
+	}
+
+	if len(data) == common.Uint64ByteSize+1 {
+		// Response of the previous format.
+		response.Status = response.presenceStatus()
+		return
+	}
+
+	response.Status = TSLPresenceStatus(data[1+common.Uint64ByteSize])
+	if response.Status > TSLStatusInternalError {
+		return errors.InvalidDataFormat
+	}
+
+	return
+}
+
+func (response *TSLIsPresent) presenceStatus() TSLPresenceStatus {
+	if response.PresentInPool || response.PresentInBlock != 0 {
+		return TSLStatusPresent
+	}
+
+	return TSLStatusAbsent
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+var (
+	tslIsPresentBinarySize = 1 + common.Uint64ByteSize + 1
+)
+
+// TSLsArePresent contains presence info of each TSL of the TSLsArePresent request,
+// in the order of the requested transactions IDs.
+type TSLsArePresent struct {
+	At []*TSLIsPresent
+}
+
+func (response *TSLsArePresent) MarshalBinary() (data []byte, err error) {
+	if len(response.At) > math.MaxUint16 {
+		return nil, errors.MaxCountReached
+	}
+
+	data = make([]byte, 0, common.Uint16ByteSize+len(response.At)*tslIsPresentBinarySize)
+	data = append(data, utils.MarshalUint16(uint16(len(response.At)))...)
+
+	for _, presence := range response.At {
+		presenceBinary, err := presence.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+
+		data = append(data, presenceBinary...)
+	}
+
+	return
+}
+
+func (response *TSLsArePresent) UnmarshalBinary(data []byte) (err error) {
+	count, err := utils.UnmarshalUint16(data)
+	if err != nil {
+		return errors.InvalidDataFormat
+	}
+
+	if len(data) != common.Uint16ByteSize+int(count)*tslIsPresentBinarySize {
+		return errors.InvalidDataFormat
+	}
+
+	response.At = make([]*TSLIsPresent, count)
+	for i := range response.At {
+		offset := common.Uint16ByteSize + i*tslIsPresentBinarySize
+		response.At[i] = &TSLIsPresent{}
+		err = response.At[i].UnmarshalBinary(data[offset : offset+tslIsPresentBinarySize])
+		if err != nil {
+			return
+		}
+	}
+
 	return
 }
 
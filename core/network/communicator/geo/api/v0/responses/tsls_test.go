@@ -0,0 +1,114 @@
+package responses
+
+import (
+	"geo-observers-blockchain/core/common/errors"
+	"testing"
+)
+
+func TestTSLIsPresent_Statuses(t *testing.T) {
+	cases := []struct {
+		response *TSLIsPresent
+		status   TSLPresenceStatus
+	}{
+		{NewTSLIsPresent(false, 0), TSLStatusAbsent},
+		{NewTSLIsPresent(true, 0), TSLStatusPresent},
+		{NewTSLIsPresent(false, 42), TSLStatusPresent},
+		{NewTSLIsPresentWithStatus(TSLStatusNotSynced), TSLStatusNotSynced},
+		{NewTSLIsPresentWithStatus(TSLStatusInternalError), TSLStatusInternalError},
+	}
+
+	for i, c := range cases {
+		data, err := c.response.MarshalBinary()
+		if err != nil {
+			t.Fatal(i, err)
+		}
+
+		if len(data) != tslIsPresentBinarySize {
+			t.Fatal(i, "unexpected binary size")
+		}
+
+		restored := &TSLIsPresent{}
+		err = restored.UnmarshalBinary(data)
+		if err != nil {
+			t.Fatal(i, err)
+		}
+
+		if restored.Status != c.status ||
+			restored.PresentInPool != c.response.PresentInPool ||
+			restored.PresentInBlock != c.response.PresentInBlock {
+			t.Fatal(i, "response must be restored as it was")
+		}
+	}
+}
+
+// Responses of the previous format (without status) must still be accepted,
+// status must be derived from the presence flags.
+func TestTSLIsPresent_PreviousFormat(t *testing.T) {
+	data, err := NewTSLIsPresent(false, 7).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := &TSLIsPresent{}
+	err = restored.UnmarshalBinary(data[:len(data)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if restored.Status != TSLStatusPresent || restored.PresentInBlock != 7 {
+		t.Fatal()
+	}
+
+	err = restored.UnmarshalBinary(make([]byte, len(data)-1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if restored.Status != TSLStatusAbsent {
+		t.Fatal()
+	}
+}
+
+func TestTSLIsPresent_UnknownStatus(t *testing.T) {
+	_, err := NewTSLIsPresentWithStatus(TSLStatusInternalError + 1).MarshalBinary()
+	if err != errors.InvalidDataFormat {
+		t.Fatal(err)
+	}
+
+	data, err := NewTSLIsPresent(true, 0).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data[len(data)-1] = byte(TSLStatusInternalError + 1)
+	err = (&TSLIsPresent{}).UnmarshalBinary(data)
+	if err != errors.InvalidDataFormat {
+		t.Fatal(err)
+	}
+}
+
+func TestTSLsArePresent_Statuses(t *testing.T) {
+	response := &TSLsArePresent{
+		At: []*TSLIsPresent{
+			NewTSLIsPresent(true, 3),
+			NewTSLIsPresentWithStatus(TSLStatusNotSynced),
+		},
+	}
+
+	data, err := response.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := &TSLsArePresent{}
+	err = restored.UnmarshalBinary(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(restored.At) != 2 ||
+		restored.At[0].Status != TSLStatusPresent ||
+		restored.At[1].Status != TSLStatusNotSynced {
+		t.Fatal()
+	}
+}
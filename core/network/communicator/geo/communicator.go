@@ -2,6 +2,7 @@ package geo
 
 import (
 	"bufio"
+	"context"
 	"encoding"
 	"fmt"
 	"geo-observers-blockchain/core/common"
@@ -10,8 +11,10 @@ import (
 	geoRequests "geo-observers-blockchain/core/network/communicator/geo/api/v0/common"
 	"geo-observers-blockchain/core/utils"
 	log "github.com/sirupsen/logrus"
+	"io"
 	"net"
 	"reflect"
+	"sync"
 	"time"
 )
 
@@ -22,6 +25,12 @@ const (
 
 type Communicator struct {
 	Requests chan geoRequests.Request
+
+	// Accepted connections, that are closed on stop (see Stop()).
+	connections common.ConnectionsTracker
+
+	// Controls stopping of the accepting loop (see Stop()).
+	lifecycle common.Lifecycle
 }
 
 func New() *Communicator {
@@ -32,6 +41,9 @@ func New() *Communicator {
 
 // todo: replace `globalErrorsFlow chan<- error` by `globalErrorsFlow chan<- errors.E`
 func (r *Communicator) Run(host string, port uint16, globalErrorsFlow chan<- error) {
+	stop := r.lifecycle.Start()
+	defer r.lifecycle.Done()
+
 	listener, err := net.Listen("tcp", fmt.Sprint(host, ":", port))
 	if err != nil {
 		globalErrorsFlow <- err
@@ -40,6 +52,18 @@ func (r *Communicator) Run(host string, port uint16, globalErrorsFlow chan<- err
 
 	//noinspection GoUnhandledErrorResult
 	defer listener.Close()
+	defer r.connections.CloseAll()
+
+	// Listener is closed on stop, so the accepting loop is interrupted.
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-stop:
+			_ = listener.Close()
+		case <-finished:
+		}
+	}()
 
 	// Inform outer scope that initialisation was performed well
 	// and no errors has been occurred.
@@ -53,41 +77,99 @@ func (r *Communicator) Run(host string, port uint16, globalErrorsFlow chan<- err
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
+			select {
+			case <-stop:
+				r.log().Info("Stopped")
+				return
+			default:
+			}
+
 			// todo: throw fatal error
 			globalErrorsFlow <- err
 			return
 		}
 
-		go r.handleConnection(conn, globalErrorsFlow)
+		if !r.connections.Add(conn) {
+			continue
+		}
+
+		go func() {
+			defer r.connections.Done(conn)
+			r.handleConnection(conn, globalErrorsFlow)
+		}()
 	}
 }
 
+// Stop closes the listener and all accepted connections, and waits until they are handled.
+// Implements common.Subsystem.
+func (r *Communicator) Stop(ctx context.Context) error {
+	return r.lifecycle.Stop(ctx)
+}
+
 func (r *Communicator) handleConnection(conn net.Conn, globalErrorsFlow chan<- error) {
 	processError := func(err errors.E) {
 		conn.Close()
 	}
 
-	message, err := r.receiveData(conn)
-	if err != nil {
-		processError(err)
-		return
-	}
+	// Reader is shared between the messages of the connection,
+	// otherwise data of the next message, that is already buffered, would be lost.
+	reader := bufio.NewReader(conn)
 
-	request, e := v0.ParseRequest(message)
-	if e != nil {
-		processError(e)
-		return
-	}
+	// Responses to the requests of one connection are sent concurrently.
+	writeMutex := &sync.Mutex{}
+
+	for {
+		message, err := r.receiveData(conn, reader)
+		if err != nil {
+			processError(err)
+			return
+		}
 
-	go r.handleRequest(conn, request, globalErrorsFlow)
+		request, e := v0.ParseRequest(message)
+		if e != nil {
+			processError(e)
+			return
+		}
+
+		if message[0] != geoRequests.ProtocolVersionWithRequestID {
+			// Only one request is expected through the connection.
+			go r.handleRequest(conn, request, globalErrorsFlow)
+			return
+		}
+
+		// Client might send several requests through the connection,
+		// connection is closed by the client (or on error).
+		go r.handleRequestWithID(conn, writeMutex, request, globalErrorsFlow)
+	}
 }
 
 func (r *Communicator) handleRequest(conn net.Conn, request geoRequests.Request, globalErrorsFlow chan<- error) {
 	defer conn.Close()
 
+	r.transferRequest(request, globalErrorsFlow, func(data []byte) errors.E {
+		return r.sendData(conn, data)
+	})
+}
+
+// handleRequestWithID transfers the request to the core and sends the response prefixed by the request ID
+// (see geoRequests.ProtocolVersionWithRequestID).
+func (r *Communicator) handleRequestWithID(
+	conn net.Conn, writeMutex *sync.Mutex, request geoRequests.Request, globalErrorsFlow chan<- error) {
+
+	r.transferRequest(request, globalErrorsFlow, func(data []byte) errors.E {
+		writeMutex.Lock()
+		defer writeMutex.Unlock()
+
+		return r.sendData(conn, utils.ChainByteSlices(utils.MarshalUint32(request.ID()), data))
+	})
+}
+
+func (r *Communicator) transferRequest(
+	request geoRequests.Request, globalErrorsFlow chan<- error, send func(data []byte) errors.E) {
+
 	select {
 	case r.Requests <- request:
-		r.handleResponseIfAny(conn, request, globalErrorsFlow)
+		r.handleResponseIfAny(request, globalErrorsFlow, send)
 		r.log().WithFields(log.Fields{
 			"Type": reflect.TypeOf(request).String(),
 		}).Debug("Transferred to core")
@@ -97,7 +179,9 @@ func (r *Communicator) handleRequest(conn net.Conn, request geoRequests.Request,
 	}
 }
 
-func (r *Communicator) handleResponseIfAny(conn net.Conn, request geoRequests.Request, globalErrorsFlow chan<- error) {
+func (r *Communicator) handleResponseIfAny(
+	request geoRequests.Request, globalErrorsFlow chan<- error, send func(data []byte) errors.E) {
+
 	processResponseSending := func(response encoding.BinaryMarshaler) {
 		binaryData, err := response.MarshalBinary()
 		if err != nil {
@@ -105,7 +189,7 @@ func (r *Communicator) handleResponseIfAny(conn net.Conn, request geoRequests.Re
 			return
 		}
 
-		e := r.sendData(conn, binaryData)
+		e := send(binaryData)
 		if e != nil {
 			globalErrorsFlow <- e.Error()
 			return
@@ -133,19 +217,15 @@ func (r *Communicator) handleResponseIfAny(conn net.Conn, request geoRequests.Re
 	}
 }
 
-func (r *Communicator) receiveData(conn net.Conn) (data []byte, e errors.E) {
-	reader := bufio.NewReader(conn)
-
-	messageSizeBinary := []byte{0, 0, 0, 0}
-	bytesRead, err := reader.Read(messageSizeBinary)
+func (r *Communicator) receiveData(conn net.Conn, reader *bufio.Reader) (data []byte, e errors.E) {
+	// Several messages might be sent through one connection (see geoRequests.ProtocolVersionWithRequestID),
+	// so the header of the next message might be received partially.
+	messageSizeBinary := make([]byte, common.Uint32ByteSize)
+	_, err := io.ReadFull(reader, messageSizeBinary)
 	if err != nil {
 		e = errors.AppendStackTrace(err)
 		return
 	}
-	if bytesRead != common.Uint32ByteSize {
-		e = errors.AppendStackTrace(errors.InvalidDataFormat)
-		return
-	}
 
 	messageSize, err := utils.UnmarshalUint32(messageSizeBinary)
 	if err != nil {
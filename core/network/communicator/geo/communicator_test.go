@@ -0,0 +1,157 @@
+package geo
+
+import (
+	"geo-observers-blockchain/core/common/types/transactions"
+	geoRequests "geo-observers-blockchain/core/network/communicator/geo/api/v0/common"
+	"geo-observers-blockchain/core/network/communicator/geo/api/v0/requests"
+	"geo-observers-blockchain/core/network/communicator/geo/api/v0/responses"
+	"geo-observers-blockchain/core/utils"
+	"net"
+	"testing"
+	"time"
+)
+
+func startTestCommunicator(t *testing.T) (communicator *Communicator, address string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	_ = listener.Close()
+
+	communicator = New()
+	errorsFlow := make(chan error, 16)
+	go communicator.Run("127.0.0.1", uint16(port), errorsFlow)
+
+	err = <-errorsFlow
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return communicator, listener.Addr().String()
+}
+
+func sendTestRequestWithID(t *testing.T, conn net.Conn, request geoRequests.IdentifiedRequest) {
+	data, err := geoRequests.MarshalRequestWithID(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = conn.Write(utils.ChainByteSlices(utils.MarshalUint32(uint32(len(data))), data))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Two requests are sent through one connection, and the responses are sent in the reversed order:
+// client must be able to match each response to it's request by the request ID.
+func TestCommunicator_InterleavedRequests(t *testing.T) {
+	communicator, address := startTestCommunicator(t)
+
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	firstTxID, _ := transactions.NewRandomTxID(1)
+	secondTxID, _ := transactions.NewRandomTxID(1)
+	first := requests.NewTSLIsPresent(firstTxID)
+	second := requests.NewTSLIsPresent(secondTxID)
+	if second.ID() <= first.ID() {
+		t.Fatal("requests IDs must increase")
+	}
+
+	sendTestRequestWithID(t, conn, first)
+	sendTestRequestWithID(t, conn, second)
+
+	// Requests might be transferred to the core in any order.
+	received := make(map[uint32]*requests.TSLIsPresent)
+	for i := 0; i < 2; i++ {
+		select {
+		case r := <-communicator.Requests:
+			request := r.(*requests.TSLIsPresent)
+			received[request.ID()] = request
+
+		case <-time.After(time.Second):
+			t.Fatal("request has not been received")
+		}
+	}
+
+	if received[first.ID()] == nil || !received[first.ID()].TxID.Compare(firstTxID) ||
+		received[second.ID()] == nil || !received[second.ID()].TxID.Compare(secondTxID) {
+		t.Fatal("requests IDs must be received as they were sent")
+	}
+
+	received[second.ID()].ResponseChannel() <- responses.NewTSLIsPresent(false, 2)
+	requestID, data, err := geoRequests.ReceiveResponseWithID(conn, time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received[first.ID()].ResponseChannel() <- responses.NewTSLIsPresent(false, 1)
+	otherRequestID, otherData, err := geoRequests.ReceiveResponseWithID(conn, time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if requestID != second.ID() || otherRequestID != first.ID() {
+		t.Fatal("responses must be correlated with the requests")
+	}
+
+	expectedBlocks := map[uint32]uint64{first.ID(): 1, second.ID(): 2}
+	for id, binary := range map[uint32][]byte{requestID: data, otherRequestID: otherData} {
+		response := &responses.TSLIsPresent{}
+		err = response.UnmarshalBinary(binary)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if response.PresentInBlock != expectedBlocks[id] {
+			t.Fatal("response content must correspond to the request")
+		}
+	}
+}
+
+// Requests of the previous protocol version has no ID:
+// response must be sent without ID, and connection must be closed after it.
+func TestCommunicator_RequestWithoutID(t *testing.T) {
+	communicator, address := startTestCommunicator(t)
+
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	TxID, _ := transactions.NewRandomTxID(1)
+	requestBinary, err := requests.NewTSLIsPresent(TxID).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := utils.ChainByteSlices([]byte{geoRequests.ProtocolVersion}, requestBinary)
+	_, err = conn.Write(utils.ChainByteSlices(utils.MarshalUint32(uint32(len(data))), data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case r := <-communicator.Requests:
+		r.ResponseChannel() <- responses.NewTSLIsPresent(true, 0)
+
+	case <-time.After(time.Second):
+		t.Fatal("request has not been received")
+	}
+
+	response := &responses.TSLIsPresent{}
+	err = geoRequests.ReceiveResponse(conn, response, time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !response.PresentInPool {
+		t.Fatal()
+	}
+}
@@ -0,0 +1,114 @@
+package observers
+
+import (
+	"bytes"
+	"geo-observers-blockchain/core/network/external"
+	"geo-observers-blockchain/core/utils"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Observers addresses normalization.
+//
+// The same observer might be referenced by the addresses in different forms
+// (IP or DNS name, IPv4 or IPv4-mapped IPv6, upper or lower case, etc).
+// To prevent duplicated or orphaned connections, addresses are always compared in normalized form:
+//   * host is lowercased, trailing dot of the fully qualified domain name is dropped;
+//   * IP addresses are written in canonical form:
+//     IPv4-mapped IPv6 addresses are converted to IPv4, IPv6 addresses are written in compressed form;
+//   * DNS names are resolved: the lowest IPv4 address is used (or the lowest IPv6, if there are no IPv4 addresses);
+//     in case if the name can't be resolved - it is used as is (lowercased);
+//   * port must be numeric and must be in range 1..65535.
+//
+// Normalized address has form "host:port" ("[host]:port" for IPv6).
+
+var (
+	ErrInvalidAddress = utils.Error("connections", "invalid observer address")
+
+	// Is replaced in tests to not depend on the DNS.
+	lookupIP = net.LookupIP
+)
+
+func normalizeHost(host string) (normalized string, err error) {
+	host = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if host == "" {
+		return "", ErrInvalidAddress
+	}
+
+	ip := net.ParseIP(host)
+	if ip != nil {
+		return canonicalIP(ip), nil
+	}
+
+	ips, err := lookupIP(host)
+	if err != nil || len(ips) == 0 {
+		// Name can't be resolved at the moment,
+		// but it still might be used for the comparison.
+		return host, nil
+	}
+
+	return canonicalIP(lowestIP(ips)), nil
+}
+
+func normalizeAddress(address string) (normalized string, err error) {
+	host, port, err := net.SplitHostPort(strings.TrimSpace(address))
+	if err != nil {
+		return "", ErrInvalidAddress
+	}
+
+	portNumber, err := strconv.ParseUint(port, 10, 16)
+	if err != nil || portNumber == 0 {
+		return "", ErrInvalidAddress
+	}
+
+	host, err = normalizeHost(host)
+	if err != nil {
+		return
+	}
+
+	return net.JoinHostPort(host, strconv.FormatUint(portNumber, 10)), nil
+}
+
+// observerAddress returns normalized address of the observer.
+// In case if address is invalid - it is returned as is, so it would never match any valid address.
+// Might perform DNS lookup: connections map caches the result (see ConnectionsMap.cachedAddress()).
+func observerAddress(observer *external.Observer) string {
+	address := configuredAddress(observer)
+	normalized, err := normalizeAddress(address)
+	if err != nil {
+		return address
+	}
+
+	return normalized
+}
+
+// configuredAddress returns address of the observer as is (without normalization).
+func configuredAddress(observer *external.Observer) string {
+	return net.JoinHostPort(observer.Host, strconv.Itoa(int(observer.Port)))
+}
+
+func canonicalIP(ip net.IP) string {
+	if ipv4 := ip.To4(); ipv4 != nil {
+		return ipv4.String()
+	}
+
+	return ip.String()
+}
+
+// lowestIP returns the lowest IPv4 address, or the lowest IPv6 address if there are no IPv4 addresses.
+// Resolver might return addresses in any order, so the choice must not depend on it.
+func lowestIP(ips []net.IP) net.IP {
+	sort.Slice(ips, func(i, j int) bool {
+		iIsV4, jIsV4 := ips[i].To4() != nil, ips[j].To4() != nil
+		if iIsV4 != jIsV4 {
+			return iIsV4
+		}
+
+		return bytes.Compare(ips[i].To16(), ips[j].To16()) < 0
+	})
+
+	return ips[0]
+}
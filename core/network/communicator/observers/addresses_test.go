@@ -0,0 +1,175 @@
+package observers
+
+import (
+	"errors"
+	"geo-observers-blockchain/core/network/external"
+	"net"
+	"testing"
+	"time"
+)
+
+func withTestResolver() (restore func()) {
+	defaultLookupIP := lookupIP
+	lookupIP = func(host string) ([]net.IP, error) {
+		switch host {
+		case "observer.example":
+			return []net.IP{net.ParseIP("2001:db8::1"), net.ParseIP("10.0.0.2"), net.ParseIP("10.0.0.1")}, nil
+		default:
+			return nil, errors.New("no such host")
+		}
+	}
+
+	return func() { lookupIP = defaultLookupIP }
+}
+
+// Equivalent addresses in different forms must be normalized to the same address.
+func TestNormalizeAddress_EquivalentForms(t *testing.T) {
+	defer withTestResolver()()
+
+	for _, address := range []string{
+		"10.0.0.1:3000",
+		" 10.0.0.1:3000 ",
+		"[::ffff:10.0.0.1]:3000",
+		"observer.example:3000",
+		"OBSERVER.Example.:3000",
+		"10.0.0.1:03000",
+	} {
+		normalized, err := normalizeAddress(address)
+		if err != nil {
+			t.Fatal(address, err)
+		}
+
+		if normalized != "10.0.0.1:3000" {
+			t.Fatal("invalid normalization of ", address, ": ", normalized)
+		}
+	}
+
+	normalized, err := normalizeAddress("[2001:DB8:0:0::1]:3000")
+	if err != nil || normalized != "[2001:db8::1]:3000" {
+		t.Fatal()
+	}
+
+	// Unresolvable names are used as is.
+	normalized, err = normalizeAddress("Unknown.example:3000")
+	if err != nil || normalized != "unknown.example:3000" {
+		t.Fatal()
+	}
+}
+
+func TestNormalizeAddress_Invalid(t *testing.T) {
+	defer withTestResolver()()
+
+	for _, address := range []string{"", "10.0.0.1", "10.0.0.1:0", "10.0.0.1:70000", "10.0.0.1:http", ":3000"} {
+		_, err := normalizeAddress(address)
+		if err != ErrInvalidAddress {
+			t.Fatal(address)
+		}
+	}
+}
+
+// Observers with equivalent addresses must map to the same connection:
+// setting connection for one of them replaces connection of the other,
+// and deleting by any form of the host removes it.
+func TestConnectionsMap_Set_EquivalentAddresses(t *testing.T) {
+	defer withTestResolver()()
+
+	cm := NewConnectionsMap(time.Minute)
+	byName := external.NewObserver("observer.example", 3000, nil)
+	byIP := external.NewObserver("10.0.0.1", 3000, nil)
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+	cm.Set(byName, local)
+
+	previous, err := cm.Get(byName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	local, remote = net.Pipe()
+	defer remote.Close()
+	cm.Set(byIP, local)
+
+	if !previous.IsClosed() || len(cm.Connections) != 1 {
+		t.Fatal("connection to the same observer must be replaced")
+	}
+
+	// Observer on other port is another observer.
+	local, remote = net.Pipe()
+	defer remote.Close()
+	cm.Set(external.NewObserver("10.0.0.1", 3001, nil), local)
+	if len(cm.Connections) != 2 {
+		t.Fatal()
+	}
+
+	cm.DeleteByRemoteHost("::ffff:10.0.0.1")
+	if len(cm.Connections) != 0 {
+		t.Fatal("connections must be deleted by equivalent host")
+	}
+}
+
+// Active set might reference observers by the equivalent addresses in other form.
+func TestConnectionsMap_ReconcileWithConfiguration_EquivalentAddresses(t *testing.T) {
+	defer withTestResolver()()
+
+	cm := NewConnectionsMap(time.Minute)
+	observer := external.NewObserver("10.0.0.1", 3000, nil)
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+	cm.Set(observer, local)
+
+	cm.ReconcileWithConfiguration([]*external.Observer{external.NewObserver("Observer.Example", 3000, nil)})
+
+	conn, err := cm.Get(observer)
+	if err != nil || conn.IsClosed() {
+		t.Fatal("connection of the active observer must be preserved")
+	}
+}
+
+// Address of the observer is resolved once (on the configuration change, or on the first use),
+// so the map is not blocked by the DNS lookups, and the key of the observer is stable even if DNS records change.
+func TestConnectionsMap_AddressIsResolvedOnce(t *testing.T) {
+	var (
+		lookups  = 0
+		resolved = net.ParseIP("10.0.0.1")
+	)
+
+	defaultLookupIP := lookupIP
+	lookupIP = func(host string) ([]net.IP, error) {
+		lookups++
+		return []net.IP{resolved}, nil
+	}
+	defer func() { lookupIP = defaultLookupIP }()
+
+	cm := NewConnectionsMap(time.Minute)
+	configured := external.NewObserver("observer.example", 3000, nil)
+	cm.ReconcileWithConfiguration([]*external.Observer{configured})
+	if lookups != 1 {
+		t.Fatal("address of the configured observer must be resolved on the configuration change")
+	}
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+	cm.Set(configured, local)
+
+	resolved = net.ParseIP("10.0.0.2")
+	conn, err := cm.Get(external.NewObserver("observer.example", 3000, nil))
+	if err != nil || conn.Connection != local {
+		t.Fatal("key of the observer must not depend on the current DNS records")
+	}
+
+	if len(cm.MissingObservers([]*external.Observer{configured})) != 0 || lookups != 1 {
+		t.Fatal("cached address must be used")
+	}
+
+	// Observer out of the configuration is resolved on the first use.
+	other := external.NewObserver("other.example", 3000, nil)
+	for i := 0; i < 3; i++ {
+		cm.DeleteByObserver(other)
+	}
+
+	if lookups != 2 {
+		t.Fatal("address must be resolved once: ", lookups)
+	}
+}
@@ -0,0 +1,157 @@
+package observers
+
+import (
+	"crypto/rand"
+	"geo-observers-blockchain/core/common"
+	"geo-observers-blockchain/core/common/types/hash"
+	"geo-observers-blockchain/core/crypto/ecdsa"
+	"geo-observers-blockchain/core/crypto/keystore"
+	"geo-observers-blockchain/core/network/external"
+	"geo-observers-blockchain/core/utils"
+	"io"
+	"net"
+	"time"
+)
+
+// Inbound connection is known only by it's remote address,
+// so the remote observer must prove it's identity before the connection would be bound to it.
+//
+// Accepting side (verifier) sends random challenge [challenge: 32B],
+// and the connecting side (prover) responds with
+// [observer index: uint16] [nonce: 32B] [signature: ecdsa.SignatureBinarySize].
+// Signature is checked with the public key of the observer with the declared index
+// in the current observers configuration.
+// Accepting side closes the connection in case if the response is not received in time, or is invalid.
+//
+// Signed hash covers the identity of the verifier (the observer, the prover has connected to)
+// and both nonces (see authenticationHash()), so the proof, issued to one observer,
+// can't be relayed by it to other observers.
+
+const (
+	kAuthenticationChallengeSize = 32
+	kAuthenticationNonceSize     = 32
+
+	// Prevents using the challenge signature as a signature of any other data.
+	kAuthenticationDomain = "geo-observers-authentication"
+)
+
+var (
+	ErrAuthenticationFailed = utils.Error("authentication", "remote observer authentication failed")
+)
+
+// ProveIdentity responds to the challenge of the remote observer ("verifier", the observer connected to)
+// with the signature of the observer with the index specified.
+// Non positive timeout means no timeout.
+func ProveIdentity(
+	conn net.Conn, reader io.Reader, ks *keystore.KeyStore, observerIndex uint16,
+	verifier *external.Observer, timeout time.Duration) (err error) {
+
+	verifierIdentity := verifier.Identity()
+	if verifierIdentity == "" {
+		return ErrAuthenticationFailed
+	}
+
+	err = setHandshakeDeadline(conn, timeout)
+	if err != nil {
+		return
+	}
+	defer conn.SetDeadline(time.Time{})
+
+	challenge := make([]byte, kAuthenticationChallengeSize)
+	_, err = io.ReadFull(reader, challenge)
+	if err != nil {
+		return
+	}
+
+	nonce := make([]byte, kAuthenticationNonceSize)
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return
+	}
+
+	signature, err := ks.SignHash(authenticationHash(verifierIdentity, challenge, nonce, observerIndex))
+	if err != nil {
+		return
+	}
+
+	signatureBinary, err := signature.MarshalBinary()
+	if err != nil {
+		return
+	}
+
+	_, err = conn.Write(utils.ChainByteSlices(utils.MarshalUint16(observerIndex), nonce, signatureBinary))
+	return
+}
+
+// AuthenticatePeer challenges the remote observer and returns the observer of the configuration,
+// which key the response is signed with.
+// Response must be issued to this observer (to the owner of the "ks").
+// Returns ErrAuthenticationFailed in case if the response is invalid,
+// or if it is not received during the timeout (non positive timeout means no timeout).
+func AuthenticatePeer(
+	conn net.Conn, reader io.Reader, ks *keystore.KeyStore,
+	registry *external.ObserverRegistry, timeout time.Duration) (observer *external.Observer, err error) {
+
+	err = setHandshakeDeadline(conn, timeout)
+	if err != nil {
+		return
+	}
+	defer conn.SetDeadline(time.Time{})
+
+	challenge := make([]byte, kAuthenticationChallengeSize)
+	_, err = rand.Read(challenge)
+	if err != nil {
+		return
+	}
+
+	_, err = conn.Write(challenge)
+	if err != nil {
+		return
+	}
+
+	response := make([]byte, common.Uint16ByteSize+kAuthenticationNonceSize+ecdsa.SignatureBinarySize)
+	_, err = io.ReadFull(reader, response)
+	if err != nil {
+		return nil, ErrAuthenticationFailed
+	}
+
+	observerIndex, err := utils.UnmarshalUint16(response[:common.Uint16ByteSize])
+	if err != nil {
+		return nil, ErrAuthenticationFailed
+	}
+
+	nonce := response[common.Uint16ByteSize : common.Uint16ByteSize+kAuthenticationNonceSize]
+
+	signature := &ecdsa.Signature{}
+	err = signature.UnmarshalBinary(response[common.Uint16ByteSize+kAuthenticationNonceSize:])
+	if err != nil {
+		return nil, ErrAuthenticationFailed
+	}
+
+	observer, err = registry.ObserverByIndex(observerIndex)
+	if err != nil || observer.PubKey == nil {
+		return nil, ErrAuthenticationFailed
+	}
+
+	h := authenticationHash(external.PubKeyIdentity(ks.PublicKey()), challenge, nonce, observerIndex)
+	if !ks.CheckExternalSignature(h, *signature, observer.PubKey) {
+		return nil, ErrAuthenticationFailed
+	}
+
+	return observer, nil
+}
+
+// authenticationHash returns hash of the authentication transcript:
+// identity of the verifier, it's challenge, nonce of the prover, and the index, declared by the prover.
+// Identity is prefixed with it's length to prevent ambiguity of the variable length value.
+func authenticationHash(
+	verifier external.ObserverIdentity, challenge, nonce []byte, observerIndex uint16) hash.SHA256Container {
+
+	return hash.NewSHA256Container(utils.ChainByteSlices(
+		[]byte(kAuthenticationDomain),
+		utils.MarshalUint16(uint16(len(verifier))),
+		[]byte(verifier),
+		challenge,
+		nonce,
+		utils.MarshalUint16(observerIndex)))
+}
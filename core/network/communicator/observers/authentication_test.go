@@ -0,0 +1,342 @@
+package observers
+
+import (
+	"geo-observers-blockchain/core/crypto/ecdsa"
+	"geo-observers-blockchain/core/crypto/keystore"
+	"geo-observers-blockchain/core/network/external"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// newTestVerifier returns observer with the public key of the keystore specified
+// (the observer, the prover connects to).
+func newTestVerifier(ks *keystore.KeyStore) *external.Observer {
+	return external.NewObserver("127.0.0.1", 5000, ks.PublicKey())
+}
+
+// Remote observer responds to the challenge with the key of the observer with declared index:
+// connection must be bound to this observer.
+func TestConnectionsMap_SetAuthenticated_Valid(t *testing.T) {
+	prover, err := keystore.NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := keystore.NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	observer := external.NewObserver("127.0.0.1", 4000, prover.PublicKey())
+	registry := external.NewObserverRegistry([]*external.Observer{
+		external.NewObserver("127.0.0.1", 4001, other.PublicKey()),
+		observer,
+	})
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	verifier, err := keystore.NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		_ = ProveIdentity(remote, remote, prover, 1, newTestVerifier(verifier), time.Second)
+	}()
+
+	cm := NewConnectionsMap(0)
+	defer cm.Close()
+
+	authenticated, err := cm.SetAuthenticated(local, local, verifier, registry, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if authenticated != observer {
+		t.Fatal("connection must be bound to the observer, that has proven it's identity")
+	}
+
+	w, err := cm.Get(observer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !w.IsAuthenticated() || w.Observer() != observer {
+		t.Fatal()
+	}
+}
+
+// Remote observer declares index of other observer, but signs the challenge with it's own key:
+// connection must be rejected and closed.
+func TestConnectionsMap_SetAuthenticated_Forged(t *testing.T) {
+	ks, err := keystore.NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forger, err := keystore.NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	observer := external.NewObserver("127.0.0.1", 4000, ks.PublicKey())
+	registry := external.NewObserverRegistry([]*external.Observer{observer})
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	verifier, err := keystore.NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		_ = ProveIdentity(remote, remote, forger, 0, newTestVerifier(verifier), time.Second)
+	}()
+
+	cm := NewConnectionsMap(0)
+	defer cm.Close()
+
+	_, err = cm.SetAuthenticated(local, local, verifier, registry, time.Second)
+	if err != ErrAuthenticationFailed {
+		t.Fatal(err)
+	}
+
+	if len(cm.Connections) != 0 {
+		t.Fatal("forged connection must not be added")
+	}
+
+	_, err = local.Write([]byte{0})
+	if err == nil {
+		t.Fatal("forged connection must be closed")
+	}
+}
+
+// Remote observer receives the challenge, but does not respond:
+// connection must be rejected after the timeout.
+func TestConnectionsMap_SetAuthenticated_Timeout(t *testing.T) {
+	ks, err := keystore.NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier, err := keystore.NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	observer := external.NewObserver("127.0.0.1", 4000, ks.PublicKey())
+	registry := external.NewObserverRegistry([]*external.Observer{observer})
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	go func() {
+		_, _ = io.ReadFull(remote, make([]byte, kAuthenticationChallengeSize))
+	}()
+
+	cm := NewConnectionsMap(0)
+	defer cm.Close()
+
+	started := time.Now()
+	_, err = cm.SetAuthenticated(local, local, verifier, registry, time.Millisecond*100)
+	if err != ErrAuthenticationFailed {
+		t.Fatal(err)
+	}
+
+	if time.Since(started) > time.Second {
+		t.Fatal("authentication must be stopped after the timeout")
+	}
+}
+
+// Malicious observer relays the challenge of the honest verifier to the honest prover,
+// and relays the proof back: proof is issued to the malicious observer, so it must be rejected by the verifier.
+func TestAuthenticatePeer_Relayed(t *testing.T) {
+	prover, err := keystore.NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	malicious, err := keystore.NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier, err := keystore.NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry := external.NewObserverRegistry([]*external.Observer{
+		external.NewObserver("127.0.0.1", 4000, prover.PublicKey()),
+	})
+
+	// Verifier <-> malicious observer.
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	// Malicious observer <-> prover.
+	relayLocal, relayRemote := net.Pipe()
+	defer relayLocal.Close()
+	defer relayRemote.Close()
+
+	go func() {
+		// Prover has connected to the malicious observer, so the proof is issued to it.
+		_ = ProveIdentity(relayRemote, relayRemote, prover, 0, newTestVerifier(malicious), time.Second)
+	}()
+
+	go func() {
+		challenge := make([]byte, kAuthenticationChallengeSize)
+		_, err := io.ReadFull(remote, challenge)
+		if err != nil {
+			return
+		}
+
+		_, err = relayLocal.Write(challenge)
+		if err != nil {
+			return
+		}
+
+		_, _ = io.CopyN(remote, relayLocal, int64(2+kAuthenticationNonceSize+ecdsa.SignatureBinarySize))
+	}()
+
+	_, err = AuthenticatePeer(local, local, verifier, registry, time.Second)
+	if err != ErrAuthenticationFailed {
+		t.Fatal("relayed proof must be rejected")
+	}
+}
+
+// Receiver with enabled authentication binds inbound connection to the observer, that has proven it's identity,
+// and rejects connections of the observers, that fails to do it.
+func TestReceiver_Authentication(t *testing.T) {
+	prover, err := keystore.NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier, err := keystore.NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forger, err := keystore.NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	observer := external.NewObserver("127.0.0.1", 4000, prover.PublicKey())
+	registry := external.NewObserverRegistry([]*external.Observer{observer})
+
+	r := NewReceiver(NewBlacklist(3, time.Minute, time.Minute))
+	r.EnableAuthentication(verifier, func() (*external.ObserverRegistry, error) {
+		return registry, nil
+	})
+
+	connect := func(ks *keystore.KeyStore) (local net.Conn, finished chan struct{}) {
+		local, remote := net.Pipe()
+		finished = make(chan struct{})
+		go func() {
+			r.handleConnection(remote, make(chan error, 16))
+			close(finished)
+		}()
+
+		_, err := clientHandshake(local, supportedProtocolVersions, time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_ = ProveIdentity(local, local, ks, 0, newTestVerifier(verifier), time.Second)
+		return
+	}
+
+	local, finished := connect(prover)
+	deadline := time.Now().Add(time.Second)
+	for {
+		_, err := r.InboundConnections().Get(observer)
+		if err == nil {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("authenticated connection must be bound to the observer")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	local.Close()
+	<-finished
+	if r.InboundConnections().Len() != 0 {
+		t.Fatal("closed connection must be removed")
+	}
+
+	forged, finished := connect(forger)
+	defer forged.Close()
+
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("connection of the forged observer must be closed")
+	}
+
+	if r.InboundConnections().Len() != 0 {
+		t.Fatal("forged connection must not be added")
+	}
+}
+
+// Checks that the observer, that has failed to prove it's identity, is reported to the blacklist.
+func TestReceiver_Authentication_ForgedReported(t *testing.T) {
+	defer withTestResolver()()
+
+	verifier, err := keystore.NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ks, err := keystore.NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	observer := external.NewObserver("127.0.0.1", 4000, ks.PublicKey())
+	registry := external.NewObserverRegistry([]*external.Observer{observer})
+
+	blacklist := NewBlacklist(1, time.Minute, time.Minute)
+	r := NewReceiver(blacklist)
+	r.EnableAuthentication(verifier, func() (*external.ObserverRegistry, error) {
+		return registry, nil
+	})
+
+	local, remote := net.Pipe()
+	defer local.Close()
+
+	finished := make(chan struct{})
+	go func() {
+		r.handleConnection(remote, make(chan error, 16))
+		close(finished)
+	}()
+
+	_, err = clientHandshake(local, supportedProtocolVersions, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forged, err := keystore.NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = ProveIdentity(local, local, forged, 0, newTestVerifier(verifier), time.Second)
+
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("connection of the forged observer must be closed")
+	}
+
+	if !blacklist.IsBlacklisted(remoteHost(remote)) {
+		t.Fatal("forged observer must be blacklisted")
+	}
+}
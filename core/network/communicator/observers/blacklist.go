@@ -0,0 +1,131 @@
+package observers
+
+import (
+	"geo-observers-blockchain/core/settings"
+	"geo-observers-blockchain/core/utils"
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+var (
+	ErrObserverBlacklisted = utils.Error("blacklist", "observer is blacklisted")
+)
+
+// Blacklist tracks misbehaviour of the remote observers (malformed messages, invalid signatures, etc).
+// Observer, that has been reported at least "threshold" times during the "window",
+// is blacklisted for the "ttl": messages from it are dropped and no connections to it are established.
+// Entries expire automatically.
+//
+// Observers are identified by their hosts (normalized, see normalizeHost()),
+// so the reports might be made from inbound as well as from outbound connections.
+// Nil blacklist never blocks anything, so it might be omitted in tests and tools.
+type Blacklist struct {
+	threshold int
+	window    time.Duration
+	ttl       time.Duration
+
+	mutex   sync.Mutex
+	entries map[string]*blacklistEntry
+}
+
+type blacklistEntry struct {
+	reportsCount int
+	windowStart  time.Time
+	bannedUntil  time.Time
+}
+
+func NewBlacklist(threshold int, window, ttl time.Duration) *Blacklist {
+	return &Blacklist{
+		threshold: threshold,
+		window:    window,
+		ttl:       ttl,
+		entries:   make(map[string]*blacklistEntry),
+	}
+}
+
+// NewDefaultBlacklist returns blacklist configured via settings.
+func NewDefaultBlacklist() *Blacklist {
+	return NewBlacklist(
+		settings.ObserversBlacklistThreshold,
+		settings.ObserversBlacklistWindow,
+		settings.ObserversBlacklistTTL)
+}
+
+// Report registers misbehaviour of the observer with the host specified.
+// Returns true if the observer is blacklisted (as a result of this report or earlier).
+func (b *Blacklist) Report(host string) (isBlacklisted bool) {
+	if b == nil {
+		return false
+	}
+
+	key := blacklistKey(host)
+	now := time.Now()
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.dropExpired(now)
+
+	entry, isPresent := b.entries[key]
+	if !isPresent {
+		entry = &blacklistEntry{windowStart: now}
+		b.entries[key] = entry
+	}
+
+	if now.Before(entry.bannedUntil) {
+		return true
+	}
+
+	if now.Sub(entry.windowStart) > b.window {
+		entry.reportsCount = 0
+		entry.windowStart = now
+	}
+
+	entry.reportsCount++
+	if entry.reportsCount >= b.threshold {
+		entry.bannedUntil = now.Add(b.ttl)
+		entry.reportsCount = 0
+
+		log.WithFields(log.Fields{"prefix": "Blacklist", "Host": key}).Warn("Observer blacklisted")
+		return true
+	}
+
+	return false
+}
+
+// IsBlacklisted returns true if the observer with the host specified is blacklisted at the moment.
+func (b *Blacklist) IsBlacklisted(host string) bool {
+	if b == nil {
+		return false
+	}
+
+	key := blacklistKey(host)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	entry, isPresent := b.entries[key]
+	return isPresent && time.Now().Before(entry.bannedUntil)
+}
+
+// dropExpired removes entries, that are not banned and which reports window is over.
+// Must be called under the mutex.
+func (b *Blacklist) dropExpired(now time.Time) {
+	for key, entry := range b.entries {
+		if now.Before(entry.bannedUntil) || now.Sub(entry.windowStart) <= b.window {
+			continue
+		}
+
+		delete(b.entries, key)
+	}
+}
+
+func blacklistKey(host string) string {
+	normalized, err := normalizeHost(host)
+	if err != nil {
+		return host
+	}
+
+	return normalized
+}
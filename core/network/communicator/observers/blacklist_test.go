@@ -0,0 +1,83 @@
+package observers
+
+import (
+	"geo-observers-blockchain/core/network/communicator/observers/constants"
+	"geo-observers-blockchain/core/network/external"
+	"geo-observers-blockchain/core/settings"
+	"testing"
+	"time"
+)
+
+// Sends repeated malformed messages from one observer and checks that it is blacklisted:
+// further (even valid) messages from it are dropped until the entry expires,
+// while messages from other observers are still accepted.
+func TestReceiver_Blacklist(t *testing.T) {
+	const ttl = time.Millisecond * 200
+
+	receiver := NewReceiver(NewBlacklist(3, time.Minute, ttl))
+	valid := markAs([]byte{0, 0}, constants.StreamTypeRequestTimeFrameCollision)
+	malformed := []byte{250, 0, 0}
+
+	for i := 0; i < 3; i++ {
+		err := receiver.processDataPackage("10.0.0.1", malformed)
+		if err == nil || err == ErrObserverBlacklisted {
+			t.Fatal()
+		}
+	}
+
+	err := receiver.processDataPackage("10.0.0.1", valid)
+	if err != ErrObserverBlacklisted {
+		t.Fatal("messages from blacklisted observer must be dropped")
+	}
+
+	err = receiver.processDataPackage("10.0.0.2", valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-receiver.Requests
+
+	time.Sleep(ttl)
+	err = receiver.processDataPackage("10.0.0.1", valid)
+	if err != nil {
+		t.Fatal("messages must be accepted after the blacklist entry expiration")
+	}
+}
+
+// Checks that reports, spread wider than the window, does not lead to blacklisting,
+// and that the host forms are normalized.
+func TestBlacklist_Window(t *testing.T) {
+	blacklist := NewBlacklist(2, time.Millisecond*50, time.Minute)
+	if blacklist.Report("10.0.0.1") {
+		t.Fatal()
+	}
+
+	time.Sleep(time.Millisecond * 100)
+	if blacklist.Report("10.0.0.1") || blacklist.IsBlacklisted("10.0.0.1") {
+		t.Fatal("reports out of the window must not be accumulated")
+	}
+
+	if !blacklist.Report("::ffff:10.0.0.1") || !blacklist.IsBlacklisted("10.0.0.1") {
+		t.Fatal()
+	}
+
+	var nilBlacklist *Blacklist
+	if nilBlacklist.Report("10.0.0.1") || nilBlacklist.IsBlacklisted("10.0.0.1") {
+		t.Fatal()
+	}
+}
+
+// Checks that no connections are established to the blacklisted observer.
+func TestSender_Blacklist(t *testing.T) {
+	blacklist := NewBlacklist(1, time.Minute, time.Minute)
+	blacklist.Report("127.0.0.1")
+
+	if settings.Conf == nil {
+		settings.Conf = &settings.Settings{}
+	}
+
+	sender := &Sender{connections: NewConnectionsMap(time.Minute), blacklist: blacklist}
+	err := sender.sendDataToObserver(external.NewObserver("127.0.0.1", 3000, nil), []byte{0})
+	if err != ErrObserverBlacklisted {
+		t.Fatal()
+	}
+}
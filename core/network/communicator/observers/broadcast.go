@@ -0,0 +1,116 @@
+package observers
+
+import (
+	"context"
+	"geo-observers-blockchain/core/settings"
+	"geo-observers-blockchain/core/utils"
+	"sync"
+)
+
+var (
+	ErrQuorumUnreachable = utils.Error("broadcast", "quorum can't be reached")
+)
+
+// Broadcast sends the message of the data type specified to all observers, connections to which are present.
+// Message is written and flushed to each one connection concurrently
+// (with settings.ObserversConnectionWriteTimeout), so the slow observer does not delay the others.
+// Write failures are accounted in the same way as for the queued writes (see ConnectionWrapper.accountWriteResult()):
+// connections are closed and removed from the map only after several consecutive failures or on timeout
+// (the next sending would establish new ones). Already closed connections are removed at once.
+//
+// Returns amount of connections, the message has been written to, and errors of the failed connections.
+func (cm *ConnectionsMap) Broadcast(dataType uint8, body []byte) (sent int, errs []error) {
+	data := utils.ChainByteSlices([]byte{dataType}, body)
+
+	connections := cm.connectionsList()
+	results := make([]error, len(connections))
+
+	wg := sync.WaitGroup{}
+	wg.Add(len(connections))
+	for i, w := range connections {
+		go func(i int, w *ConnectionWrapper) {
+			defer wg.Done()
+
+			results[i] = w.WriteWithTimeout(data, settings.ObserversConnectionWriteTimeout)
+			if results[i] == ErrConnectionIsClosed {
+				w.drop()
+			}
+		}(i, w)
+	}
+	wg.Wait()
+
+	for _, err := range results {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		sent++
+	}
+
+	return
+}
+
+// BroadcastUntilQuorum broadcasts the message (see Broadcast()) and waits for the acknowledgements
+// of the remote observers. Returns as soon as acknowledgements of "quorum" distinct observers has been received,
+// so the caller does not wait for the slowest observers.
+// Acknowledgements are indexes of the observers, that has acknowledged the message.
+// Non positive quorum means settings.ObserversConsensusCount.
+//
+// Returns ErrQuorumUnreachable in case if the message has been sent to less than "quorum" observers,
+// or if the acknowledgements channel has been closed before the quorum,
+// and ctx.Err() in case if the context is done before the quorum.
+func (cm *ConnectionsMap) BroadcastUntilQuorum(
+	ctx context.Context, dataType uint8, body []byte, quorum int, acks <-chan uint16) error {
+
+	if quorum <= 0 {
+		quorum = settings.ObserversConsensusCount
+	}
+
+	// Acknowledgements might arrive before the message is written to all connections.
+	broadcasted := make(chan int, 1)
+	go func() {
+		sent, _ := cm.Broadcast(dataType, body)
+		broadcasted <- sent
+	}()
+
+	acknowledged := make(map[uint16]bool, quorum)
+	for {
+		select {
+		case index, isOpen := <-acks:
+			if !isOpen {
+				return ErrQuorumUnreachable
+			}
+
+			acknowledged[index] = true
+			if len(acknowledged) >= quorum {
+				return nil
+			}
+
+		case sent := <-broadcasted:
+			if sent < quorum {
+				return ErrQuorumUnreachable
+			}
+
+			// Receiving from nil channel blocks forever, so the case is disabled.
+			broadcasted = nil
+
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// connectionsList returns snapshot of the connections of the map
+// (the map itself might be changed while the connections are used).
+func (cm *ConnectionsMap) connectionsList() (connections []*ConnectionWrapper) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	connections = make([]*ConnectionWrapper, 0, len(cm.Connections))
+	for _, w := range cm.Connections {
+		connections = append(connections, w)
+	}
+
+	return
+}
@@ -0,0 +1,205 @@
+package observers
+
+import (
+	"bytes"
+	"context"
+	"geo-observers-blockchain/core/network/external"
+	"geo-observers-blockchain/core/settings"
+	"geo-observers-blockchain/core/utils"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+// Broadcasts the message to two live connections and one closed connection,
+// and checks that the live ones receive the framed message, and the failed one is dropped.
+func TestConnectionsMap_Broadcast(t *testing.T) {
+	cm := NewConnectionsMap(0)
+	defer cm.Stop(context.Background())
+
+	body := []byte{1, 2, 3}
+	expected := utils.ChainByteSlices(
+		[]byte{ProtocolVersion}, utils.MarshalUint32(uint32(len(body)+1)), []byte{42}, body)
+
+	received := make(chan []byte, 2)
+	for port := uint16(3000); port < 3002; port++ {
+		local, remote := net.Pipe()
+		defer remote.Close()
+
+		go func(remote net.Conn) {
+			frame := make([]byte, len(expected))
+			_, err := io.ReadFull(remote, frame)
+			if err != nil {
+				frame = nil
+			}
+
+			received <- frame
+		}(remote)
+
+		cm.Set(external.NewObserver("127.0.0.1", port, nil), local)
+	}
+
+	failed := external.NewObserver("127.0.0.1", 3002, nil)
+	local, remote := net.Pipe()
+	defer remote.Close()
+	cm.Set(failed, local)
+
+	failedConn, err := cm.Get(failed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = failedConn.Close()
+
+	sent, errs := cm.Broadcast(42, body)
+	if sent != 2 || len(errs) != 1 || errs[0] != ErrConnectionIsClosed {
+		t.Fatal(sent, errs)
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case frame := <-received:
+			if !bytes.Equal(frame, expected) {
+				t.Fatal("received frame differs from the sent one")
+			}
+
+		case <-time.After(time.Second):
+			t.Fatal("message has not been received")
+		}
+	}
+
+	_, err = cm.Get(failed)
+	if err != ErrNoObserver || cm.Len() != 2 {
+		t.Fatal("failed connection must be dropped")
+	}
+}
+
+// Broadcasting to the empty map is not an error.
+func TestConnectionsMap_Broadcast_NoConnections(t *testing.T) {
+	cm := NewConnectionsMap(0)
+	defer cm.Stop(context.Background())
+
+	sent, errs := cm.Broadcast(42, []byte{1})
+	if sent != 0 || len(errs) != 0 {
+		t.Fatal()
+	}
+}
+
+// startTestQuorumPeers adds connections to "count" fake observers to the map.
+// Each observer with index less than "acking" acknowledges the received message.
+func startTestQuorumPeers(t *testing.T, cm *ConnectionsMap, count, acking int) (acks chan uint16, stop func()) {
+	acks = make(chan uint16, count)
+	remotes := make([]net.Conn, 0, count)
+
+	for i := 0; i < count; i++ {
+		local, remote := net.Pipe()
+		remotes = append(remotes, remote)
+
+		go func(index uint16, remote net.Conn) {
+			_, err := io.Copy(ioutil.Discard, io.LimitReader(remote, 1))
+			if err != nil || int(index) >= acking {
+				_, _ = io.Copy(ioutil.Discard, remote)
+				return
+			}
+
+			acks <- index
+			_, _ = io.Copy(ioutil.Discard, remote)
+		}(uint16(i), remote)
+
+		cm.Set(external.NewObserver("127.0.0.1", uint16(3000+i), nil), local)
+	}
+
+	stop = func() {
+		for _, remote := range remotes {
+			_ = remote.Close()
+		}
+	}
+	return
+}
+
+// Some observers never acknowledge the message: broadcasting must be finished as soon as quorum is collected.
+func TestConnectionsMap_BroadcastUntilQuorum(t *testing.T) {
+	cm := NewConnectionsMap(0)
+	defer cm.Stop(context.Background())
+
+	acks, stop := startTestQuorumPeers(t, cm, 5, 3)
+	defer stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	started := time.Now()
+	err := cm.BroadcastUntilQuorum(ctx, 42, []byte{1, 2, 3}, 3, acks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if time.Since(started) > time.Second {
+		t.Fatal("broadcasting must be finished as soon as quorum is collected")
+	}
+}
+
+// Quorum is never collected: broadcasting must be finished when the context is done.
+func TestConnectionsMap_BroadcastUntilQuorum_ContextDone(t *testing.T) {
+	cm := NewConnectionsMap(0)
+	defer cm.Stop(context.Background())
+
+	acks, stop := startTestQuorumPeers(t, cm, 5, 3)
+	defer stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+
+	err := cm.BroadcastUntilQuorum(ctx, 42, []byte{1, 2, 3}, 4, acks)
+	if err != context.DeadlineExceeded {
+		t.Fatal(err)
+	}
+}
+
+// Message is sent to less observers, than the quorum: broadcasting must be finished without waiting.
+func TestConnectionsMap_BroadcastUntilQuorum_Unreachable(t *testing.T) {
+	cm := NewConnectionsMap(0)
+	defer cm.Stop(context.Background())
+
+	acks, stop := startTestQuorumPeers(t, cm, 2, 2)
+	defer stop()
+
+	err := cm.BroadcastUntilQuorum(context.Background(), 42, []byte{1, 2, 3}, 3, acks)
+	if err != ErrQuorumUnreachable {
+		t.Fatal(err)
+	}
+}
+
+// Broadcast write failures must be accounted by the same threshold, as the queued writes:
+// connection is dropped only after several consecutive failures, and not on the first one.
+func TestConnectionsMap_Broadcast_WriteFailuresThreshold(t *testing.T) {
+	threshold := settings.ObserversConnectionWriteFailuresThreshold
+	failures := make([]bool, 0)
+	for i := 0; i <= threshold; i++ {
+		failures = append(failures, true)
+	}
+
+	cm := NewConnectionsMap(0)
+	defer cm.Stop(context.Background())
+
+	observer := external.NewObserver("127.0.0.1", 3000, nil)
+	conn := &failingConn{failures: failures, writes: make(chan bool, len(failures))}
+	cm.Set(observer, conn)
+
+	for i := 0; i < threshold; i++ {
+		sent, errs := cm.Broadcast(42, []byte{byte(i)})
+		if sent != 0 || len(errs) != 1 {
+			t.Fatal(sent, errs)
+		}
+
+		if cm.Len() != 1 {
+			t.Fatal("connection must not be dropped before the threshold is exceeded")
+		}
+	}
+
+	_, _ = cm.Broadcast(42, []byte{})
+	if cm.Len() != 0 {
+		t.Fatal("connection must be dropped after the threshold is exceeded")
+	}
+}
@@ -2,69 +2,582 @@ package observers
 
 import (
 	"bufio"
+	"context"
+	"crypto/tls"
 	"errors"
+	"geo-observers-blockchain/core/crypto/keystore"
 	"geo-observers-blockchain/core/network/external"
+	"geo-observers-blockchain/core/settings"
+	"geo-observers-blockchain/core/utils"
+	log "github.com/sirupsen/logrus"
+	"io"
 	"net"
-	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 var (
-	ErrNoObserver = errors.New("no such index")
+	ErrNoObserver         = errors.New("no such index")
+	ErrSendQueueFull      = utils.Error("connections", "send queue is full")
+	ErrConnectionIsClosed = utils.Error("connections", "connection is closed")
+	ErrConnectionsClosed  = utils.Error("connections", "connections map is closed")
+	ErrWriteTimeout       = utils.Error("connections", "write timeout")
 )
 
 type ConnectionWrapper struct {
 	Connection net.Conn
 	Writer     *bufio.Writer
 	LastUsed   time.Time
+
+	// Protects the Writer: it is used by the writer goroutine as well as by the explicit flushes.
+	writerMutex sync.Mutex
+
+	// Serializes pings: only one pong might be awaited on the connection at once (see ping.go).
+	pingMutex sync.Mutex
+
+	// Normalized address of the remote observer (see normalizeAddress()).
+	address string
+
+	// Outgoing messages queue.
+	// All writes to the connection are performed by the one writer goroutine,
+	// so the messages, enqueued concurrently, are never interleaved in the stream.
+	queue chan []byte
+
+	// Closed on connection closing. Stops the writer goroutine.
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// Count of consecutive failed writes (see accountWriteResult()).
+	// Writes are done by the writer goroutine as well as directly (see WriteWithTimeout()),
+	// so it must be accessed only under the writer mutex.
+	writeFailures int
+
+	established time.Time
+
+	// True if the connection is protected with TLS (see tls.go).
+	isTLS bool
+
+	// Version of the wire protocol, negotiated with the remote observer (see protocol.go).
+	// Each frame is prefixed with it.
+	protocolVersion uint8
+
+	// Observer, the connection is bound to.
+	observer *external.Observer
+
+	// True if the remote observer has proven it's identity (see authentication.go).
+	isAuthenticated bool
+
+	// Amount of bytes successfully written to the connection (atomic).
+	bytesWritten uint64
+
+	// Smoothed round trip time of the connection in nanoseconds (atomic, see RoundTripTime()).
+	// Zero means that no round trip has been measured yet.
+	roundTripTime int64
+
+	// Map, that contains the connection (might be nil).
+	// Amount of bytes written is accounted in it as well,
+	// and the connection is removed from it in case of write timeout.
+	owner *ConnectionsMap
+}
+
+func newConnectionWrapper(conn net.Conn) *ConnectionWrapper {
+	return newOwnedConnectionWrapper(conn, nil)
+}
+
+func newOwnedConnectionWrapper(conn net.Conn, owner *ConnectionsMap) *ConnectionWrapper {
+	_, isTLS := conn.(*tls.Conn)
+
+	w := &ConnectionWrapper{
+		Connection:      conn,
+		Writer:          bufio.NewWriter(conn),
+		LastUsed:        time.Now(),
+		queue:           make(chan []byte, settings.ObserversConnectionSendQueueSize),
+		done:            make(chan struct{}),
+		established:     time.Now(),
+		isTLS:           isTLS,
+		protocolVersion: ProtocolVersion,
+		owner:           owner,
+	}
+
+	go w.processQueue()
+	return w
+}
+
+// Enqueue frames the data (prefixes it with the protocol version and the data size, see frames.go)
+// and schedules it for sending to the remote observer.
+// Returns ErrSendQueueFull in case if there is no free slot in the queue,
+// and ErrFrameTooLarge in case if the data exceeds the frame size limit.
+// Never blocks.
+func (w *ConnectionWrapper) Enqueue(data []byte) error {
+	select {
+	case <-w.done:
+		return ErrConnectionIsClosed
+	default:
+	}
+
+	frame, err := w.frame(data)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case w.queue <- frame:
+		return nil
+
+	default:
+		return ErrSendQueueFull
+	}
+}
+
+// ProtocolVersion returns version of the wire protocol, negotiated with the remote observer.
+func (w *ConnectionWrapper) ProtocolVersion() uint8 {
+	return w.protocolVersion
+}
+
+// Observer returns the remote observer, the connection is bound to.
+func (w *ConnectionWrapper) Observer() *external.Observer {
+	return w.observer
+}
+
+// IsAuthenticated returns true if the remote observer has proven it's identity
+// (see ConnectionsMap.SetAuthenticated()).
+func (w *ConnectionWrapper) IsAuthenticated() bool {
+	return w.isAuthenticated
+}
+
+// RoundTripTime returns smoothed round trip time of the connection (measured by the pings, see ping.go).
+// Returns false in case if no round trip has been measured yet.
+func (w *ConnectionWrapper) RoundTripTime() (rtt time.Duration, isKnown bool) {
+	rtt = time.Duration(atomic.LoadInt64(&w.roundTripTime))
+	return rtt, rtt > 0
+}
+
+// recordRoundTripTime accounts the measured round trip time in the smoothed one.
+// Smoothing is the same as TCP uses: one measurement moves the smoothed value by 1/8 of the difference,
+// so one delayed pong (for example, GC pause on the remote side) does not distort the value.
+func (w *ConnectionWrapper) recordRoundTripTime(sample time.Duration) {
+	if sample <= 0 {
+		sample = 1
+	}
+
+	for {
+		current := atomic.LoadInt64(&w.roundTripTime)
+		smoothed := int64(sample)
+		if current > 0 {
+			smoothed = current + (int64(sample)-current)/8
+			if smoothed <= 0 {
+				smoothed = 1
+			}
+		}
+
+		if atomic.CompareAndSwapInt64(&w.roundTripTime, current, smoothed) {
+			return
+		}
+	}
+}
+
+// IsTLS returns true if the connection is protected with TLS.
+func (w *ConnectionWrapper) IsTLS() bool {
+	return w.isTLS
+}
+
+// IsClosed returns true if connection was closed, or if the writer goroutine has failed to write the data.
+func (w *ConnectionWrapper) IsClosed() bool {
+	select {
+	case <-w.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (w *ConnectionWrapper) Close() error {
+	err := w.Connection.Close()
+	w.closeOnce.Do(func() {
+		close(w.done)
+	})
+
+	return err
+}
+
+// Flush writes buffered data (if any) to the connection.
+func (w *ConnectionWrapper) Flush() error {
+	if w.IsClosed() {
+		return ErrConnectionIsClosed
+	}
+
+	w.writerMutex.Lock()
+	defer w.writerMutex.Unlock()
+
+	if w.Writer.Buffered() == 0 {
+		return nil
+	}
+
+	err := w.Writer.Flush()
+	if err != nil {
+		// See processQueue() for the details.
+		w.Writer.Reset(w.Connection)
+	}
+
+	return err
+}
+
+func (w *ConnectionWrapper) countWritten(bytesCount int) {
+	atomic.AddUint64(&w.bytesWritten, uint64(bytesCount))
+	if w.owner != nil {
+		atomic.AddUint64(&w.owner.bytesWritten, uint64(bytesCount))
+	}
+}
+
+// WriteWithTimeout frames the data (see Enqueue()) and writes it to the connection immediately,
+// bypassing the queue. In case if the data can't be written during the "timeout"
+// (for example, remote observer does not read the data) - ErrWriteTimeout is returned,
+// the connection is closed and removed from the map, so the next sending would establish new one.
+func (w *ConnectionWrapper) WriteWithTimeout(data []byte, timeout time.Duration) (err error) {
+	if w.IsClosed() {
+		return ErrConnectionIsClosed
+	}
+
+	frame, err := w.frame(data)
+	if err != nil {
+		return
+	}
+
+	w.writerMutex.Lock()
+	err = w.writeFrame(frame, timeout)
+	mustBeDropped := w.accountWriteResult(err)
+	w.writerMutex.Unlock()
+
+	if mustBeDropped {
+		w.drop()
+	}
+
+	if isTimeout(err) {
+		return ErrWriteTimeout
+	}
+
+	if err != nil {
+		return
+	}
+
+	w.countWritten(len(frame))
+	return
+}
+
+func (w *ConnectionWrapper) frame(data []byte) ([]byte, error) {
+	return marshalFrame(w.protocolVersion, data)
+}
+
+// writeFrame writes and flushes the frame with the write deadline (if timeout is positive).
+// On error the writer is reset (buffered writer keeps the error and rejects all further writes).
+// Must be called under the writer mutex.
+func (w *ConnectionWrapper) writeFrame(frame []byte, timeout time.Duration) (err error) {
+	if timeout > 0 {
+		err = w.Connection.SetWriteDeadline(time.Now().Add(timeout))
+		if err != nil {
+			return
+		}
+	}
+
+	_, err = w.Writer.Write(frame)
+	if err == nil {
+		err = w.Writer.Flush()
+	}
+
+	if err != nil {
+		w.Writer.Reset(w.Connection)
+	}
+
+	return
+}
+
+// accountWriteResult counts consecutive write failures of the connection.
+// Short network hiccup should not lead to the reconnection,
+// so the connection must be dropped only after several consecutive failures
+// (see settings.ObserversConnectionWriteFailuresThreshold).
+// On timeout the connection must be dropped at once: the remote observer does not read the data,
+// there is no reason to wait for it on each next write.
+// Returns true if the connection must be dropped (see drop()).
+// Must be called under the writer mutex.
+func (w *ConnectionWrapper) accountWriteResult(err error) (mustBeDropped bool) {
+	if err == nil {
+		w.writeFailures = 0
+		return false
+	}
+
+	if isTimeout(err) {
+		return true
+	}
+
+	w.writeFailures++
+	return w.writeFailures > settings.ObserversConnectionWriteFailuresThreshold
+}
+
+// drop closes the connection and removes it from the owner map.
+// Must not be called under the map's mutex.
+func (w *ConnectionWrapper) drop() {
+	_ = w.Close()
+	if w.owner != nil {
+		w.owner.remove(w)
+	}
+}
+
+func (w *ConnectionWrapper) processQueue() {
+	for {
+		select {
+		case frame := <-w.queue:
+			w.writerMutex.Lock()
+			err := w.writeFrame(frame, settings.ObserversConnectionWriteTimeout)
+			mustBeDropped := w.accountWriteResult(err)
+			w.writerMutex.Unlock()
+
+			if mustBeDropped {
+				// Dropped connection is removed from the map, so the next sending would establish new one.
+				w.drop()
+				return
+			}
+
+			if err != nil {
+				// The frame itself is lost in any case.
+				continue
+			}
+
+			w.countWritten(len(frame))
+
+		case <-w.done:
+			return
+		}
+	}
 }
 
+// ObserverKey identifies the remote observer in the connections map.
+// Observer instances might be reallocated on each configuration change,
+// so the key is derived from the observer's identity (public key),
+// or from the normalized network address in case if observer has no public key (see observerKey()).
+type ObserverKey string
+
 type ConnectionsMap struct {
-	Connections map[*external.Observer]*ConnectionWrapper
+	Connections map[ObserverKey]*ConnectionWrapper
 	mutex       sync.Mutex
+
+	// Current observers configuration (see ReconcileWithConfiguration()).
+	// Is used for the lookup of the connections by the observers indexes (see GetByIndex()).
+	registry *external.ObserverRegistry
+
+	// Normalized addresses of the observers, keyed by their addresses as configured (see cachedAddress()).
+	// Addresses are resolved once (normalization might require DNS lookup),
+	// so the keys of the observers are stable and no lookup is performed on each map access.
+	// Is refreshed on each configuration change (see ReconcileWithConfiguration()).
+	addresses      map[string]string
+	addressesMutex sync.RWMutex
+
+	// Amount of bytes written by all connections of the map, including already closed ones (atomic).
+	bytesWritten uint64
+
+	// Closed on map stopping. Stops the background goroutines (auto-cleaner, auto-flush, auto-ping).
+	done     chan struct{}
+	stopOnce sync.Once
+
+	// Negotiates the wire protocol version with the remote observer on each connection, established by GetOrDial()
+	// (see EnableHandshake()). In case if nil - current protocol version is assumed.
+	handshake func(net.Conn) (version uint8, err error)
+
+	// Proves identity of this observer to the remote one on each connection, established by GetOrDial()
+	// (see EnableAuthentication()). In case if nil - no authentication is performed.
+	authenticate func(net.Conn, *external.Observer) error
 }
 
+// NewConnectionsMap returns connections map, that closes and removes connections,
+// that has not been used for "maxDelay" (see Close() to stop the cleaner).
+// Non positive "maxDelay" disables cleaning.
 func NewConnectionsMap(maxDelay time.Duration) *ConnectionsMap {
 	m := &ConnectionsMap{
-		Connections: make(map[*external.Observer]*ConnectionWrapper),
-	}
-
-	// todo: move into separate code block
-	// Auto-cleaner.
-	//go func() {
-	//	clean := func() {
-	//		indexesForRemoving := make([]*external.Observer, 0, len(m.Connections))
-	//		for observer, wrapper := range m.Connections {
-	//			maxDelayTimeout := time.Now().Add(maxDelay * -1)
-	//
-	//			if wrapper.LastUsed.Before(maxDelayTimeout) {
-	//				indexesForRemoving = append(indexesForRemoving, observer)
-	//			}
-	//		}
-	//
-	//		for _, observer := range indexesForRemoving {
-	//			delete(m.Connections, observer)
-	//		}
-	//	}
-	//
-	//	for {
-	//		time.Sleep(maxDelay)
-	//
-	//		m.mutex.Lock()
-	//		clean()
-	//		m.mutex.Unlock()
-	//	}
-	//}()
+		Connections: make(map[ObserverKey]*ConnectionWrapper),
+		addresses:   make(map[string]string),
+		done:        make(chan struct{}),
+	}
+
+	if maxDelay > 0 {
+		go m.cleanPeriodically(maxDelay)
+	}
 
 	return m
 }
 
+// EnableHandshake makes the map to negotiate the wire protocol version (see protocol.go)
+// on each connection, established by GetOrDial(). Must be called before the first GetOrDial().
+func (cm *ConnectionsMap) EnableHandshake(timeout time.Duration) {
+	cm.handshake = func(conn net.Conn) (version uint8, err error) {
+		return clientHandshake(conn, supportedProtocolVersions, timeout)
+	}
+}
+
+// EnableAuthentication makes the map to prove identity of this observer (see ProveIdentity())
+// on each connection, established by GetOrDial(), right after the protocol handshake.
+// "observerIndex" must return index of this observer in the current observers configuration.
+// Must be called before the first GetOrDial().
+func (cm *ConnectionsMap) EnableAuthentication(
+	ks *keystore.KeyStore, observerIndex func() (uint16, error), timeout time.Duration) {
+
+	cm.authenticate = func(conn net.Conn, observer *external.Observer) (err error) {
+		index, err := observerIndex()
+		if err != nil {
+			return
+		}
+
+		return ProveIdentity(conn, conn, ks, index, observer, timeout)
+	}
+}
+
 func (cm *ConnectionsMap) Get(observer *external.Observer) (*ConnectionWrapper, error) {
+	key := cm.observerKey(observer)
+
 	cm.mutex.Lock()
 	defer cm.mutex.Unlock()
 
-	w, isPresent := cm.Connections[observer]
+	return cm.getLocked(key)
+}
+
+// GetByIndex returns connection to the observer with the index specified in the current configuration
+// (see ReconcileWithConfiguration()).
+// Returns ErrNoObserver in case if there is no such observer, or there is no connection to it.
+func (cm *ConnectionsMap) GetByIndex(index uint16) (*ConnectionWrapper, error) {
+	cm.mutex.Lock()
+	registry := cm.registry
+	cm.mutex.Unlock()
+
+	if registry == nil {
+		return nil, ErrNoObserver
+	}
+
+	observer, err := registry.ObserverByIndex(index)
+	if err != nil {
+		return nil, ErrNoObserver
+	}
+
+	return cm.Get(observer)
+}
+
+// RoundTripTimeByIndex returns smoothed round trip time of the connection to the observer
+// with the index specified in the current configuration (see ConnectionWrapper.RoundTripTime()).
+// Unlike GetByIndex(), the connection is not marked as used.
+// Returns false in case if there is no such connection, or no round trip has been measured yet.
+func (cm *ConnectionsMap) RoundTripTimeByIndex(index uint16) (rtt time.Duration, isKnown bool) {
+	cm.mutex.Lock()
+	registry := cm.registry
+	cm.mutex.Unlock()
+
+	if registry == nil {
+		return 0, false
+	}
+
+	observer, err := registry.ObserverByIndex(index)
+	if err != nil {
+		return 0, false
+	}
+
+	key := cm.observerKey(observer)
+
+	cm.mutex.Lock()
+	w, isPresent := cm.Connections[key]
+	cm.mutex.Unlock()
+
+	if !isPresent {
+		return 0, false
+	}
+
+	return w.RoundTripTime()
+}
+
+// GetOrDial returns live connection to the observer.
+// In case if there is no connection, or it has been closed - new one is established via the "dialer".
+// Failed dials are retried with exponential backoff
+// (see settings.ObserversConnectionDialAttempts and settings.ObserversConnectionDialBackoff),
+// the error of the last attempt is returned. Map is not changed in case of failure.
+// In case if the handshake is enabled (see EnableHandshake()) - it is performed on the established connection;
+// failed handshake is retried as well, except the case of unsupported protocol version.
+// The same is true for the authentication (see EnableAuthentication()).
+// Dialing is done without the lock, so other connections are available meanwhile.
+func (cm *ConnectionsMap) GetOrDial(
+	observer *external.Observer, dialer func(*external.Observer) (net.Conn, error)) (w *ConnectionWrapper, err error) {
+
+	w, err = cm.Get(observer)
+	if err == nil && !w.IsClosed() {
+		return
+	}
+
+	backoff := settings.ObserversConnectionDialBackoff
+	for attempt := 1; ; attempt++ {
+		var conn net.Conn
+		conn, err = dialer(observer)
+		if err == nil {
+			version := ProtocolVersion
+			if cm.handshake != nil {
+				version, err = cm.handshake(conn)
+			}
+
+			if err == nil && cm.authenticate != nil {
+				err = cm.authenticate(conn, observer)
+			}
+
+			if err == nil {
+				cm.set(observer, conn, version, false)
+				return cm.Get(observer)
+			}
+
+			_ = conn.Close()
+			if err == ErrUnsupportedProtocolVersion {
+				return nil, err
+			}
+		}
+
+		if attempt >= settings.ObserversConnectionDialAttempts {
+			return nil, err
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-cm.done:
+			return nil, ErrConnectionsClosed
+		}
+
+		backoff *= 2
+		if backoff > settings.ObserversConnectionDialMaxBackoff {
+			backoff = settings.ObserversConnectionDialMaxBackoff
+		}
+	}
+}
+
+// removeConnection removes the wrapper of the connection specified from the map (in case if it is still present).
+// Connection itself is left open.
+func (cm *ConnectionsMap) removeConnection(conn net.Conn) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	for key, w := range cm.Connections {
+		if w.Connection == conn {
+			delete(cm.Connections, key)
+		}
+	}
+}
+
+// remove removes the connection from the map (in case if it is still present).
+func (cm *ConnectionsMap) remove(w *ConnectionWrapper) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	for key, conn := range cm.Connections {
+		if conn == w {
+			delete(cm.Connections, key)
+		}
+	}
+}
+
+// getLocked returns connection by the observer's key and marks it as used.
+// Must be called under the mutex (mutex is not reentrant).
+func (cm *ConnectionsMap) getLocked(key ObserverKey) (*ConnectionWrapper, error) {
+	w, isPresent := cm.Connections[key]
 	if !isPresent {
 		return nil, ErrNoObserver
 	}
@@ -73,51 +586,399 @@ func (cm *ConnectionsMap) Get(observer *external.Observer) (*ConnectionWrapper,
 	return w, nil
 }
 
+// Set adds the connection to the observer to the map.
+// Connection is assumed to use the current protocol version (no handshake is performed).
 func (cm *ConnectionsMap) Set(observer *external.Observer, conn net.Conn) {
+	cm.set(observer, conn, ProtocolVersion, false)
+}
+
+// SetAuthenticated challenges the remote side of the inbound connection (see AuthenticatePeer())
+// and adds the connection to the map, bound to the observer, that has proven it's identity.
+// "reader" is the reader of the connection (it might be buffered).
+// Connection is closed in case if the remote observer fails to authenticate during the timeout.
+func (cm *ConnectionsMap) SetAuthenticated(
+	conn net.Conn, reader io.Reader, ks *keystore.KeyStore, registry *external.ObserverRegistry,
+	timeout time.Duration) (observer *external.Observer, err error) {
+
+	observer, err = AuthenticatePeer(conn, reader, ks, registry, timeout)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	cm.set(observer, conn, ProtocolVersion, true)
+	return
+}
+
+func (cm *ConnectionsMap) set(observer *external.Observer, conn net.Conn, protocolVersion uint8, isAuthenticated bool) {
+
+	// Address might be resolved via DNS, so it is normalized before the lock.
+	address := cm.cachedAddress(observer)
+	key := cm.observerKey(observer)
+
+	err := configureConnection(conn)
+	if err != nil {
+		// Connection is still usable with the OS defaults.
+		log.WithFields(log.Fields{"prefix": "Connections", "Address": address}).Warn(
+			"Can't configure connection: ", err)
+	}
+
+	wrapper := newOwnedConnectionWrapper(conn, cm)
+	wrapper.address = address
+	wrapper.protocolVersion = protocolVersion
+	wrapper.observer = observer
+	wrapper.isAuthenticated = isAuthenticated
+
+	replaced := make([]*ConnectionWrapper, 0, 1)
+
 	cm.mutex.Lock()
-	defer cm.mutex.Unlock()
+	for k, previous := range cm.Connections {
+		// The same observer might be referenced by other observer instance with equivalent address,
+		// and even under the other key (for example, observer's public key has been changed).
+		// Only one connection per address is kept.
+		if k == key || previous.address == address {
+			replaced = append(replaced, previous)
+			delete(cm.Connections, k)
+		}
+	}
+	cm.Connections[key] = wrapper
+	cm.mutex.Unlock()
 
-	cm.Connections[observer] = &ConnectionWrapper{
-		Connection: conn,
-		Writer:     bufio.NewWriter(conn),
-		LastUsed:   time.Now(),
+	// Writer goroutines of the replaced connections must be stopped.
+	// Closing might block (for example, TLS connection), so it is done outside of the lock.
+	for _, previous := range replaced {
+		log.WithFields(log.Fields{"prefix": "Connections", "Address": address}).Debug(
+			"Connection replaced")
+		_ = previous.Close()
 	}
 }
 
 func (cm *ConnectionsMap) DeleteByObserver(observer *external.Observer) {
+	key := cm.observerKey(observer)
+
 	cm.mutex.Lock()
-	defer cm.mutex.Unlock()
+	conn, isPresent := cm.Connections[key]
+	if isPresent {
+		delete(cm.Connections, key)
+	}
+	cm.mutex.Unlock()
+
+	if isPresent {
+		closeConnections([]*ConnectionWrapper{conn})
+	}
+}
 
-	conn, err := cm.Get(observer)
+// DeleteByRemoteHost closes and removes all connections to the host specified.
+// Host is compared in normalized form (see normalizeHost()).
+func (cm *ConnectionsMap) DeleteByRemoteHost(host string) {
+	host, err := normalizeHost(host)
 	if err != nil {
 		return
 	}
 
-	conn.Connection.Close()
-	delete(cm.Connections, observer)
+	var victims []*ConnectionWrapper
+	cm.mutex.Lock()
+	for key, conn := range cm.Connections {
+		if connectionHost(conn) == host {
+			victims = append(victims, conn)
+			delete(cm.Connections, key)
+		}
+	}
+	cm.mutex.Unlock()
+
+	closeConnections(victims)
+}
+
+// FlushAll writes buffered data of all connections.
+// Connections are flushed outside of the map's lock, so the slow connection does not block the map.
+// Returns errors of the connections, that have failed to flush (nil if there are no such connections).
+//
+// Frames, written by the connections themselves, are flushed right after writing (see writeFrame()),
+// so this is needed only for the data, that has been buffered in some other way.
+func (cm *ConnectionsMap) FlushAll() (errs map[*external.Observer]error) {
+	for _, conn := range cm.connectionsList() {
+		err := conn.Flush()
+		if err == nil {
+			continue
+		}
+
+		if errs == nil {
+			errs = make(map[*external.Observer]error)
+		}
+		errs[conn.observer] = err
+	}
+
+	return
+}
+
+// StartAutoFlush periodically flushes all connections (see FlushAll()) until the map is stopped.
+// Non positive interval is ignored.
+func (cm *ConnectionsMap) StartAutoFlush(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				for observer, err := range cm.FlushAll() {
+					log.WithFields(log.Fields{"prefix": "Connections", "Address": cm.cachedAddress(observer)}).Debug(
+						"Can't flush connection: ", err)
+				}
+
+			case <-cm.done:
+				return
+			}
+		}
+	}()
 }
 
-func (cm *ConnectionsMap) DeleteByRemoteHost(host string) {
+// Close stops background goroutines of the map (auto-cleaner, auto-flush, auto-ping).
+// Connections are left open (see Stop()).
+// It is safe to call Close several times.
+func (cm *ConnectionsMap) Close() {
+	cm.stopOnce.Do(func() {
+		close(cm.done)
+	})
+}
+
+// Stop closes and removes all connections, and stops background goroutines.
+// Connections are removed under the lock, but are closed without it (closing might block on the network).
+// Returns ctx.Err() in case if ctx is done before all connections are closed
+// (the rest of them are closed in background anyway).
+// Implements common.Subsystem.
+func (cm *ConnectionsMap) Stop(ctx context.Context) error {
+	cm.Close()
+
 	cm.mutex.Lock()
-	defer cm.mutex.Unlock()
+	victims := make([]*ConnectionWrapper, 0, len(cm.Connections))
+	for key, conn := range cm.Connections {
+		victims = append(victims, conn)
+		delete(cm.Connections, key)
+	}
+	cm.mutex.Unlock()
+
+	closed := make(chan struct{})
+	go func() {
+		closeConnections(victims)
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+		return nil
+
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// closeConnections closes the connections, that has been already removed from the map.
+// Closing might block on the network (for example, TLS connections are notifying the remote side),
+// so it must be called without the lock, otherwise all other operations of the map would be blocked too.
+func closeConnections(connections []*ConnectionWrapper) {
+	for _, conn := range connections {
+		_ = conn.Close()
+	}
+}
+
+// ReconcileWithConfiguration closes and removes connections to the observers,
+// that are not present in the "active" observers set (for example, left the configuration).
+// Observers are matched by their normalized network address.
+// "active" is considered to be the current observers configuration:
+// positions of the observers in it are used as their indexes (see GetByIndex()).
+// Addresses of the active observers are resolved here, once per configuration,
+// and are reused by all other methods of the map (see cachedAddress()).
+func (cm *ConnectionsMap) ReconcileWithConfiguration(active []*external.Observer) {
+	// Addresses might be resolved via DNS, so they are normalized before the lock.
+	addresses := make(map[string]string, len(active))
+	activeAddresses := make(map[string]bool, len(active))
+	for _, observer := range active {
+		if observer == nil {
+			continue
+		}
+
+		normalized := observerAddress(observer)
+		addresses[configuredAddress(observer)] = normalized
+		activeAddresses[normalized] = true
+	}
+
+	cm.addressesMutex.Lock()
+	cm.addresses = addresses
+	cm.addressesMutex.Unlock()
+
+	registry := external.NewObserverRegistry(active)
+
+	var victims []*ConnectionWrapper
+	cm.mutex.Lock()
+	cm.registry = registry
+	for key, conn := range cm.Connections {
+		if activeAddresses[conn.address] {
+			continue
+		}
+
+		victims = append(victims, conn)
+		delete(cm.Connections, key)
+	}
+	cm.mutex.Unlock()
+
+	closeConnections(victims)
+}
+
+// cleanPeriodically closes and removes connections, that has not been used for "maxDelay",
+// until the map is closed.
+func (cm *ConnectionsMap) cleanPeriodically(maxDelay time.Duration) {
+	ticker := time.NewTicker(maxDelay)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			cm.removeUnused(now.Add(-maxDelay))
+
+		case <-cm.done:
+			return
+		}
+	}
+}
+
+// removeUnused closes and removes connections, that has been used last time before the "deadline".
+func (cm *ConnectionsMap) removeUnused(deadline time.Time) {
+	var victims []*ConnectionWrapper
+	cm.mutex.Lock()
+	for key, conn := range cm.Connections {
+		if conn.LastUsed.Before(deadline) {
+			victims = append(victims, conn)
+			delete(cm.Connections, key)
+		}
+	}
+	cm.mutex.Unlock()
+
+	closeConnections(victims)
+}
 
-	obsoleteRecords := make([]struct {
-		*external.Observer
-		net.Conn
-	}, 0)
+// MissingObservers returns observers, to which there are no open connections.
+// Observers are matched by their normalized network address.
+func (cm *ConnectionsMap) MissingObservers(observers []*external.Observer) (missing []*external.Observer) {
+	addresses := make([]string, len(observers))
+	for i, observer := range observers {
+		if observer != nil {
+			addresses[i] = cm.cachedAddress(observer)
+		}
+	}
 
-	for k, v := range cm.Connections {
-		currentHost := strings.Split(v.Connection.RemoteAddr().String(), ":")[0]
-		if currentHost == host {
-			obsoleteRecords = append(obsoleteRecords, struct {
-				*external.Observer
-				net.Conn
-			}{k, v.Connection})
+	cm.mutex.Lock()
+	connected := make(map[string]bool, len(cm.Connections))
+	for _, conn := range cm.Connections {
+		if !conn.IsClosed() {
+			connected[conn.address] = true
 		}
 	}
+	cm.mutex.Unlock()
 
-	for _, record := range obsoleteRecords {
-		record.Conn.Close()
-		delete(cm.Connections, record.Observer)
+	for i, observer := range observers {
+		if observer != nil && !connected[addresses[i]] {
+			missing = append(missing, observer)
+		}
 	}
+
+	return
+}
+
+// observerKey returns stable key of the observer in the connections map (see ObserverKey).
+func (cm *ConnectionsMap) observerKey(observer *external.Observer) ObserverKey {
+	identity := observer.Identity()
+	if identity != "" {
+		return ObserverKey("identity:" + identity)
+	}
+
+	return ObserverKey("address:" + cm.cachedAddress(observer))
+}
+
+// cachedAddress returns normalized address of the observer (see observerAddress()).
+// Address of the observer, that is present in the current configuration, is resolved on the configuration change;
+// address of any other observer is resolved on the first use.
+// In both cases the result is reused until the next configuration change,
+// so the key of the observer does not change between the calls, even if DNS records do.
+// Must not be called under the map's mutex: address might be resolved via DNS.
+func (cm *ConnectionsMap) cachedAddress(observer *external.Observer) string {
+	configured := configuredAddress(observer)
+
+	cm.addressesMutex.RLock()
+	normalized, isCached := cm.addresses[configured]
+	cm.addressesMutex.RUnlock()
+	if isCached {
+		return normalized
+	}
+
+	normalized = observerAddress(observer)
+
+	cm.addressesMutex.Lock()
+	defer cm.addressesMutex.Unlock()
+
+	// Other goroutine might have resolved the same address meanwhile: the first result wins.
+	cached, isCached := cm.addresses[configured]
+	if isCached {
+		return cached
+	}
+
+	cm.addresses[configured] = normalized
+	return normalized
+}
+
+func isTimeout(err error) bool {
+	netErr, isNetErr := err.(net.Error)
+	return isNetErr && netErr.Timeout()
+}
+
+// configureConnection applies TCP options (see settings) to the connection
+// (to the underlying one in case of TLS connection).
+// Connections of other types are left as is.
+func configureConnection(conn net.Conn) (err error) {
+	tlsConn, isTLS := conn.(*tls.Conn)
+	if isTLS {
+		conn = tlsConn.NetConn()
+	}
+
+	tcpConn, isTCP := conn.(*net.TCPConn)
+	if !isTCP {
+		return
+	}
+
+	err = tcpConn.SetNoDelay(settings.ObserversConnectionNoDelay)
+	if err != nil {
+		return
+	}
+
+	if settings.ObserversConnectionKeepAlivePeriod <= 0 {
+		return tcpConn.SetKeepAlive(false)
+	}
+
+	err = tcpConn.SetKeepAlive(true)
+	if err != nil {
+		return
+	}
+
+	return tcpConn.SetKeepAlivePeriod(settings.ObserversConnectionKeepAlivePeriod)
+}
+
+// connectionHost returns normalized host of the remote observer.
+// Host of the observer's address is used, if present (it is already normalized).
+// Otherwise - host is fetched from the connection itself.
+func connectionHost(w *ConnectionWrapper) (host string) {
+	if w.address != "" {
+		host, _, _ = net.SplitHostPort(w.address)
+		return
+	}
+
+	host, _, err := net.SplitHostPort(w.Connection.RemoteAddr().String())
+	if err != nil || net.ParseIP(host) == nil {
+		return ""
+	}
+
+	return canonicalIP(net.ParseIP(host))
 }
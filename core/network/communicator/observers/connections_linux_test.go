@@ -0,0 +1,78 @@
+package observers
+
+import (
+	"geo-observers-blockchain/core/network/external"
+	"geo-observers-blockchain/core/settings"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func socketOption(t *testing.T, conn *net.TCPConn, level, option int) (value int) {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sockErr error
+	err = rawConn.Control(func(fd uintptr) {
+		value, sockErr = syscall.GetsockoptInt(int(fd), level, option)
+	})
+	if err != nil || sockErr != nil {
+		t.Fatal(err, sockErr)
+	}
+
+	return
+}
+
+// Checks that keep-alive (with the period from settings) and no-delay
+// are applied to the TCP connection on it's setting into the connections map.
+func TestConnectionsMap_Set_TCPOptions(t *testing.T) {
+	defaultPeriod := settings.ObserversConnectionKeepAlivePeriod
+	settings.ObserversConnectionKeepAlivePeriod = time.Second * 42
+	defer func() { settings.ObserversConnectionKeepAlivePeriod = defaultPeriod }()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(time.Second)
+		}
+	}()
+
+	// Keep-alive is disabled explicitly, so it's enabling by the map is observable.
+	dialer := net.Dialer{KeepAlive: -1}
+	conn, err := dialer.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tcpConn := conn.(*net.TCPConn)
+	_ = tcpConn.SetNoDelay(false)
+
+	address := listener.Addr().(*net.TCPAddr)
+	cm := NewConnectionsMap(time.Minute)
+	cm.Set(external.NewObserver(address.IP.String(), uint16(address.Port), nil), conn)
+	defer cm.DeleteByRemoteHost(address.IP.String())
+
+	if socketOption(t, tcpConn, syscall.IPPROTO_TCP, syscall.TCP_NODELAY) == 0 {
+		t.Fatal("no-delay must be enabled")
+	}
+
+	if socketOption(t, tcpConn, syscall.SOL_SOCKET, syscall.SO_KEEPALIVE) == 0 {
+		t.Fatal("keep-alive must be enabled")
+	}
+
+	// Keep-alive period is the idle time before the first probe.
+	period := socketOption(t, tcpConn, syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE)
+	if period != 42 {
+		t.Fatal("invalid keep-alive period: ", period)
+	}
+}
@@ -0,0 +1,142 @@
+package observers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Upper bounds of the connections age histogram buckets (see ConnectionsCollector).
+var ConnectionsAgeBuckets = []time.Duration{
+	time.Minute,
+	time.Minute * 10,
+	time.Hour,
+	time.Hour * 24,
+}
+
+// ConnectionStats is a snapshot of the state of one connection.
+type ConnectionStats struct {
+	// Normalized address of the remote observer (see normalizeAddress()).
+	Address string `json:"address"`
+
+	// Remote address of the connection itself.
+	RemoteAddress string `json:"remote_address"`
+
+	Established  time.Time `json:"established"`
+	LastUsed     time.Time `json:"last_used"`
+	BytesWritten uint64    `json:"bytes_written"`
+}
+
+// ConnectionsStats is a snapshot of the state of the connections map.
+type ConnectionsStats struct {
+	// Amount of bytes written by all connections of the map, including already closed ones.
+	BytesWritten uint64 `json:"bytes_written"`
+
+	Connections []ConnectionStats `json:"connections"`
+}
+
+// Len returns amount of connections in the map.
+func (cm *ConnectionsMap) Len() int {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	return len(cm.Connections)
+}
+
+// Stats returns state of the connections of the map.
+func (cm *ConnectionsMap) Stats() (stats ConnectionsStats) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	stats.BytesWritten = atomic.LoadUint64(&cm.bytesWritten)
+	stats.Connections = make([]ConnectionStats, 0, len(cm.Connections))
+	for _, conn := range cm.Connections {
+		remoteAddress := ""
+		if conn.Connection.RemoteAddr() != nil {
+			remoteAddress = conn.Connection.RemoteAddr().String()
+		}
+
+		stats.Connections = append(stats.Connections, ConnectionStats{
+			Address:       conn.address,
+			RemoteAddress: remoteAddress,
+			Established:   conn.established,
+			LastUsed:      conn.LastUsed,
+			BytesWritten:  atomic.LoadUint64(&conn.bytesWritten),
+		})
+	}
+
+	return
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+// ConnectionsCollector exposes connections map metrics in Prometheus text format:
+// amount of active connections, total amount of bytes written and connections age distribution.
+// It is optional: it might be mounted to the monitoring endpoint (it implements http.Handler),
+// or it's output might be merged into some other metrics output (see Collect()).
+type ConnectionsCollector struct {
+	connections *ConnectionsMap
+	prefix      string
+}
+
+// NewConnectionsCollector returns collector of the map's metrics.
+// All metrics names are prefixed with the "prefix" (for example, "observers_sender").
+func NewConnectionsCollector(connections *ConnectionsMap, prefix string) *ConnectionsCollector {
+	return &ConnectionsCollector{
+		connections: connections,
+		prefix:      prefix,
+	}
+}
+
+// Collect writes current metrics to the "w".
+func (c *ConnectionsCollector) Collect(w io.Writer) (err error) {
+	stats := c.connections.Stats()
+	now := time.Now()
+
+	activeName := c.prefix + "_connections_active"
+	bytesName := c.prefix + "_connections_bytes_written_total"
+	ageName := c.prefix + "_connections_age_seconds"
+
+	_, err = fmt.Fprintf(w,
+		"# TYPE %s gauge\n%s %d\n# TYPE %s counter\n%s %d\n# TYPE %s histogram\n",
+		activeName, activeName, len(stats.Connections),
+		bytesName, bytesName, stats.BytesWritten,
+		ageName)
+	if err != nil {
+		return
+	}
+
+	// Buckets are cumulative.
+	ageSum := 0.0
+	bucketsCounts := make([]int, len(ConnectionsAgeBuckets))
+	for _, conn := range stats.Connections {
+		age := now.Sub(conn.Established)
+		ageSum += age.Seconds()
+
+		for i, bound := range ConnectionsAgeBuckets {
+			if age <= bound {
+				bucketsCounts[i]++
+			}
+		}
+	}
+
+	for i, bound := range ConnectionsAgeBuckets {
+		_, err = fmt.Fprintf(w, "%s_bucket{le=\"%g\"} %d\n", ageName, bound.Seconds(), bucketsCounts[i])
+		if err != nil {
+			return
+		}
+	}
+
+	_, err = fmt.Fprintf(w,
+		"%s_bucket{le=\"+Inf\"} %d\n%s_sum %g\n%s_count %d\n",
+		ageName, len(stats.Connections), ageName, ageSum, ageName, len(stats.Connections))
+	return
+}
+
+// ServeHTTP implements http.Handler.
+func (c *ConnectionsCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_ = c.Collect(w)
+}
@@ -0,0 +1,100 @@
+package observers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"geo-observers-blockchain/core/network/external"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Sends data via two connections and checks the stats and exported metrics.
+func TestConnectionsMap_Stats(t *testing.T) {
+	cm := NewConnectionsMap(0)
+	defer cm.Stop(context.Background())
+
+	observers := []*external.Observer{
+		external.NewObserver("127.0.0.1", 3000, nil),
+		external.NewObserver("127.0.0.1", 3001, nil),
+	}
+
+	for _, observer := range observers {
+		local, remote := net.Pipe()
+		defer remote.Close()
+		go io.Copy(ioutil.Discard, remote)
+
+		cm.Set(observer, local)
+	}
+
+	if cm.Len() != 2 {
+		t.Fatal()
+	}
+
+	w, err := cm.Get(observers[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Frame is prefixed with the protocol version and the size (see protocol.go).
+	data := []byte{1, 2, 3, 4}
+	frameSize := uint64(kFrameHeaderSize + len(data))
+
+	err = w.Enqueue(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for cm.Stats().BytesWritten != frameSize {
+		if time.Now().After(deadline) {
+			t.Fatal("bytes written are not counted")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	stats := cm.Stats()
+	if len(stats.Connections) != 2 {
+		t.Fatal()
+	}
+
+	for _, conn := range stats.Connections {
+		expectedBytes := uint64(0)
+		if conn.Address == w.address {
+			expectedBytes = frameSize
+		}
+
+		if conn.BytesWritten != expectedBytes || conn.LastUsed.IsZero() || conn.Established.IsZero() {
+			t.Fatal("invalid connection stats")
+		}
+	}
+
+	// Total amount of bytes includes closed connections as well.
+	cm.DeleteByObserver(observers[0])
+	if cm.Len() != 1 || cm.Stats().BytesWritten != frameSize {
+		t.Fatal()
+	}
+
+	output := bytes.Buffer{}
+	err = NewConnectionsCollector(cm, "observers").Collect(&output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{
+		"observers_connections_active 1\n",
+		fmt.Sprintf("observers_connections_bytes_written_total %d\n", frameSize),
+		"observers_connections_age_seconds_bucket{le=\"60\"} 1\n",
+		"observers_connections_age_seconds_bucket{le=\"+Inf\"} 1\n",
+		"observers_connections_age_seconds_count 1\n",
+	} {
+		if !strings.Contains(output.String(), line) {
+			t.Fatal("metric is absent: ", line)
+		}
+	}
+}
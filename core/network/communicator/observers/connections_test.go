@@ -0,0 +1,965 @@
+package observers
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"geo-observers-blockchain/core/network/external"
+	"geo-observers-blockchain/core/settings"
+	"geo-observers-blockchain/core/utils"
+	"io"
+	"io/ioutil"
+	"net"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Enqueues messages from many goroutines concurrently
+// and checks that each one frame arrives intact,
+// and frames of each one goroutine arrive in the order of enqueueing.
+func TestConnectionWrapper_Enqueue_ConcurrentFramesAreIntact(t *testing.T) {
+	const (
+		sendersCount      = 16
+		messagesPerSender = 32
+		messageSize       = 512
+	)
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	w := newConnectionWrapper(local)
+	defer w.Close()
+
+	// Message format: 1B sender index, 1B message sequence number, payload filled by sender index.
+	message := func(sender, seq int) []byte {
+		data := make([]byte, messageSize)
+		for i := range data {
+			data[i] = byte(sender)
+		}
+		data[1] = byte(seq)
+		return data
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(sendersCount)
+	for sender := 0; sender < sendersCount; sender++ {
+		go func(sender int) {
+			defer wg.Done()
+
+			for seq := 0; seq < messagesPerSender; seq++ {
+				for {
+					err := w.Enqueue(message(sender, seq))
+					if err == nil {
+						break
+					}
+
+					if err != ErrSendQueueFull {
+						t.Error(err)
+						return
+					}
+
+					// Writer has not processed the queue yet.
+					runtime.Gosched()
+				}
+			}
+		}(sender)
+	}
+
+	reader := bufio.NewReader(remote)
+	nextSeq := make([]int, sendersCount)
+	for i := 0; i < sendersCount*messagesPerSender; i++ {
+		header := make([]byte, 5)
+		_, err := io.ReadFull(reader, header)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if header[0] != ProtocolVersion {
+			t.Fatal("frame must be prefixed with the protocol version")
+		}
+
+		size, _ := utils.UnmarshalUint32(header[1:])
+		if size != messageSize {
+			t.Fatal("frame size mismatch")
+		}
+
+		data := make([]byte, size)
+		_, err = io.ReadFull(reader, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sender := int(data[0])
+		if sender >= sendersCount {
+			t.Fatal("invalid sender index")
+		}
+
+		if int(data[1]) != nextSeq[sender] {
+			t.Fatal("frames of the sender arrived out of order")
+		}
+		nextSeq[sender]++
+
+		expected := message(sender, int(data[1]))
+		for j := range data {
+			if data[j] != expected[j] {
+				t.Fatal("frame is corrupted")
+			}
+		}
+	}
+
+	wg.Wait()
+}
+
+func TestConnectionWrapper_Enqueue_QueueFull(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	w := newConnectionWrapper(local)
+	defer w.Close()
+
+	// Nobody reads from the remote side, so the writer goroutine is blocked on the first frame,
+	// and the queue would be filled up.
+	for {
+		err := w.Enqueue([]byte{1})
+		if err == ErrSendQueueFull {
+			break
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestConnectionWrapper_Enqueue_Closed(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	w := newConnectionWrapper(local)
+	w.Close()
+
+	if w.Enqueue([]byte{1}) != ErrConnectionIsClosed {
+		t.Fatal()
+	}
+
+	if !w.IsClosed() {
+		t.Fatal()
+	}
+}
+
+// failingConn reports write results in order, specified by the "failures" sequence.
+// Writes beyond the sequence are successful.
+type failingConn struct {
+	net.Conn
+
+	mutex    sync.Mutex
+	failures []bool
+	writes   chan bool
+}
+
+func (c *failingConn) Write(b []byte) (n int, err error) {
+	c.mutex.Lock()
+	fail := false
+	if len(c.failures) > 0 {
+		fail = c.failures[0]
+		c.failures = c.failures[1:]
+	}
+	c.mutex.Unlock()
+
+	c.writes <- !fail
+	if fail {
+		return 0, io.ErrClosedPipe
+	}
+
+	return len(b), nil
+}
+
+func (c *failingConn) Close() error {
+	return nil
+}
+
+func (c *failingConn) SetWriteDeadline(time.Time) error {
+	return nil
+}
+
+// sendFrames enqueues frames one by one, each time waiting for the write attempt.
+func sendFrames(t *testing.T, w *ConnectionWrapper, conn *failingConn, count int) {
+	for i := 0; i < count; i++ {
+		err := w.Enqueue([]byte{byte(i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		<-conn.writes
+	}
+}
+
+// waitClosed waits for the writer goroutine to close the connection.
+func waitClosed(w *ConnectionWrapper) bool {
+	select {
+	case <-w.done:
+		return true
+	case <-time.After(time.Second):
+		return false
+	}
+}
+
+func TestConnectionWrapper_WriteFailures_BelowThreshold(t *testing.T) {
+	threshold := settings.ObserversConnectionWriteFailuresThreshold
+	failures := make([]bool, 0)
+	for round := 0; round < 3; round++ {
+		for i := 0; i < threshold; i++ {
+			failures = append(failures, true)
+		}
+
+		// Successful write resets the counter.
+		failures = append(failures, false)
+	}
+
+	conn := &failingConn{failures: failures, writes: make(chan bool, 1)}
+	w := newConnectionWrapper(conn)
+	defer w.Close()
+
+	sendFrames(t, w, conn, len(failures))
+	if w.IsClosed() {
+		t.Fatal("connection must not be dropped on intermittent failures")
+	}
+}
+
+func TestConnectionWrapper_WriteFailures_AboveThreshold(t *testing.T) {
+	threshold := settings.ObserversConnectionWriteFailuresThreshold
+	failures := make([]bool, 0)
+	for i := 0; i <= threshold; i++ {
+		failures = append(failures, true)
+	}
+
+	conn := &failingConn{failures: failures, writes: make(chan bool, 1)}
+	w := newConnectionWrapper(conn)
+
+	sendFrames(t, w, conn, threshold)
+	if w.IsClosed() {
+		t.Fatal("connection must not be dropped before the threshold is exceeded")
+	}
+
+	sendFrames(t, w, conn, 1)
+	if !waitClosed(w) {
+		t.Fatal("connection must be dropped after the threshold is exceeded")
+	}
+}
+
+// Connection, that has exceeded the failures threshold, must be evicted from the map.
+func TestConnectionsMap_WriteFailures_Eviction(t *testing.T) {
+	threshold := settings.ObserversConnectionWriteFailuresThreshold
+	failures := make([]bool, 0)
+	for i := 0; i <= threshold; i++ {
+		failures = append(failures, true)
+	}
+
+	cm := NewConnectionsMap(0)
+	defer cm.Stop(context.Background())
+
+	observer := external.NewObserver("127.0.0.1", 3000, nil)
+	conn := &failingConn{failures: failures, writes: make(chan bool, 1)}
+	cm.Set(observer, conn)
+
+	w, err := cm.Get(observer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sendFrames(t, w, conn, threshold+1)
+	if !waitClosed(w) {
+		t.Fatal("connection must be dropped after the threshold is exceeded")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		_, err = cm.Get(observer)
+		if err == ErrNoObserver {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("connection must be removed from the map")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Removes one observer from the active set and checks that only it's connection is closed and dropped.
+func TestConnectionsMap_ReconcileWithConfiguration(t *testing.T) {
+	cm := NewConnectionsMap(time.Minute)
+
+	observers := []*external.Observer{
+		external.NewObserver("127.0.0.1", 3000, nil),
+		external.NewObserver("127.0.0.1", 3001, nil),
+		external.NewObserver("127.0.0.1", 3002, nil),
+	}
+
+	for _, observer := range observers {
+		local, remote := net.Pipe()
+		defer remote.Close()
+
+		cm.Set(observer, local)
+	}
+
+	removed, err := cm.Get(observers[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Active set might be built from other configuration instance,
+	// so observers are expected to be matched by their addresses.
+	cm.ReconcileWithConfiguration([]*external.Observer{
+		external.NewObserver("127.0.0.1", 3000, nil),
+		external.NewObserver("127.0.0.1", 3002, nil),
+	})
+
+	if !removed.IsClosed() {
+		t.Fatal("connection of the removed observer must be closed")
+	}
+
+	_, err = cm.Get(observers[1])
+	if err != ErrNoObserver {
+		t.Fatal("connection of the removed observer must be dropped")
+	}
+
+	for _, i := range []int{0, 2} {
+		conn, err := cm.Get(observers[i])
+		if err != nil || conn.IsClosed() {
+			t.Fatal("connections of the active observers must be preserved")
+		}
+	}
+}
+
+// Configuration is reallocated on change, so the same observer is represented by the new Observer instance:
+// it must be resolved to the existing connection (by the instance and by the index).
+func TestConnectionsMap_GetByIndex_ReallocatedObserver(t *testing.T) {
+	cm := NewConnectionsMap(0)
+	defer cm.Stop(context.Background())
+
+	pubKeys := make([]*ecdsa.PublicKey, 2)
+	for i := range pubKeys {
+		pkey, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pubKeys[i] = &pkey.PublicKey
+	}
+
+	configuration := func() []*external.Observer {
+		return []*external.Observer{
+			external.NewObserver("127.0.0.1", 3000, pubKeys[0]),
+			external.NewObserver("127.0.0.1", 3001, pubKeys[1]),
+		}
+	}
+
+	observers := configuration()
+	cm.ReconcileWithConfiguration(observers)
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+	cm.Set(observers[1], local)
+
+	original, err := cm.GetByIndex(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Configuration change: the same observers, but other instances.
+	reallocated := configuration()
+	cm.ReconcileWithConfiguration(reallocated)
+
+	w, err := cm.Get(reallocated[1])
+	if err != nil || w != original || w.IsClosed() {
+		t.Fatal("reallocated observer must be resolved to the existing connection")
+	}
+
+	w, err = cm.GetByIndex(1)
+	if err != nil || w != original {
+		t.Fatal("connection must be resolved by the observer's index")
+	}
+
+	_, err = cm.GetByIndex(0)
+	if err != ErrNoObserver {
+		t.Fatal("there is no connection to the observer")
+	}
+
+	_, err = cm.GetByIndex(2)
+	if err != ErrNoObserver {
+		t.Fatal("there is no such observer")
+	}
+}
+
+// Observer with the other key (other public key), but with the same address, replaces the previous one:
+// the previous connection must be closed and removed, not orphaned.
+func TestConnectionsMap_Set_ReplacesConnectionWithOtherKey(t *testing.T) {
+	cm := NewConnectionsMap(0)
+	defer cm.Stop(context.Background())
+
+	observers := make([]*external.Observer, 2)
+	for i := range observers {
+		pkey, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		observers[i] = external.NewObserver("127.0.0.1", 3000, &pkey.PublicKey)
+	}
+
+	if cm.observerKey(observers[0]) == cm.observerKey(observers[1]) {
+		t.Fatal("observers must have different keys")
+	}
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+	cm.Set(observers[0], local)
+
+	previous, err := cm.Get(observers[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	local, remote = net.Pipe()
+	defer remote.Close()
+	cm.Set(observers[1], local)
+
+	if !previous.IsClosed() {
+		t.Fatal("replaced connection must be closed")
+	}
+
+	_, err = cm.Get(observers[0])
+	if err != ErrNoObserver || len(cm.Connections) != 1 {
+		t.Fatal("replaced connection must be removed")
+	}
+
+	w, err := cm.Get(observers[1])
+	if err != nil || w.Connection != local {
+		t.Fatal()
+	}
+}
+
+// Writes partial data into the connection's buffer without flushing
+// and checks that it reaches the remote side only after FlushAll.
+func TestConnectionsMap_FlushAll(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	cm := NewConnectionsMap(time.Minute)
+	defer cm.Stop(context.Background())
+
+	observer := external.NewObserver("127.0.0.1", 3000, nil)
+	cm.Set(observer, local)
+
+	w, err := cm.Get(observer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.writerMutex.Lock()
+	_, err = w.Writer.Write([]byte{1, 2, 3})
+	w.writerMutex.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan []byte, 1)
+	go func() {
+		data := make([]byte, 3)
+		_, err := io.ReadFull(remote, data)
+		if err != nil {
+			received <- nil
+			return
+		}
+		received <- data
+	}()
+
+	select {
+	case <-received:
+		t.Fatal("data must not be sent before flushing")
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	errs := cm.FlushAll()
+	if errs != nil {
+		t.Fatal(errs)
+	}
+
+	select {
+	case data := <-received:
+		if data == nil || data[0] != 1 || data[1] != 2 || data[2] != 3 {
+			t.Fatal("invalid data received")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("data has not been flushed")
+	}
+}
+
+// Connection, flushing of which is blocked, must not block the map.
+func TestConnectionsMap_FlushAll_SlowConnection(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	cm := NewConnectionsMap(0)
+	defer cm.Stop(context.Background())
+
+	observer := external.NewObserver("127.0.0.1", 3000, nil)
+	cm.Set(observer, local)
+
+	w, err := cm.Get(observer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulates the writer, that is blocked by the slow remote observer.
+	w.writerMutex.Lock()
+	flushed := make(chan struct{})
+	go func() {
+		cm.FlushAll()
+		close(flushed)
+	}()
+
+	got := make(chan error, 1)
+	go func() {
+		_, err := cm.Get(observer)
+		got <- err
+	}()
+
+	select {
+	case err = <-got:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("map must not be locked during flushing")
+	}
+
+	w.writerMutex.Unlock()
+	<-flushed
+}
+
+// Checks that closed connections are reported by FlushAll.
+func TestConnectionsMap_FlushAll_Closed(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	cm := NewConnectionsMap(time.Minute)
+	defer cm.Stop(context.Background())
+
+	observer := external.NewObserver("127.0.0.1", 3000, nil)
+	cm.Set(observer, local)
+	cm.Connections[cm.observerKey(observer)].Close()
+
+	errs := cm.FlushAll()
+	if len(errs) != 1 || errs[observer] != ErrConnectionIsClosed {
+		t.Fatal()
+	}
+}
+
+// Checks that buffered data is delivered by the periodic flushing.
+func TestConnectionsMap_StartAutoFlush(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	cm := NewConnectionsMap(time.Minute)
+	defer cm.Stop(context.Background())
+
+	observer := external.NewObserver("127.0.0.1", 3000, nil)
+	cm.Set(observer, local)
+
+	w := cm.Connections[cm.observerKey(observer)]
+	w.writerMutex.Lock()
+	_, err := w.Writer.Write([]byte{1})
+	w.writerMutex.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cm.StartAutoFlush(time.Millisecond * 10)
+
+	remote.SetReadDeadline(time.Now().Add(time.Second))
+	data := make([]byte, 1)
+	_, err = io.ReadFull(remote, data)
+	if err != nil || data[0] != 1 {
+		t.Fatal("data has not been flushed")
+	}
+}
+
+// Checks that connections, that has not been used for the max delay, are closed and removed,
+// and that the used ones are left as is.
+func TestConnectionsMap_RemoveUnused(t *testing.T) {
+	cm := NewConnectionsMap(0)
+	defer cm.Stop(context.Background())
+
+	unused := external.NewObserver("127.0.0.1", 3000, nil)
+	used := external.NewObserver("127.0.0.1", 3001, nil)
+	for _, observer := range []*external.Observer{unused, used} {
+		local, remote := net.Pipe()
+		defer remote.Close()
+
+		cm.Set(observer, local)
+	}
+
+	unusedConn := cm.Connections[cm.observerKey(unused)]
+	unusedConn.LastUsed = time.Now().Add(-time.Minute)
+
+	cm.removeUnused(time.Now().Add(-time.Second))
+	if !unusedConn.IsClosed() || len(cm.Connections) != 1 || cm.Connections[cm.observerKey(used)] == nil {
+		t.Fatal()
+	}
+}
+
+// Checks that the cleaner goroutine removes unused connections, and that it might be stopped.
+func TestConnectionsMap_AutoCleaner(t *testing.T) {
+	cm := NewConnectionsMap(time.Millisecond * 10)
+	defer cm.Stop(context.Background())
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	observer := external.NewObserver("127.0.0.1", 3000, nil)
+	cm.Set(observer, local)
+
+	w, err := cm.Get(observer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !waitClosed(w) {
+		t.Fatal("unused connection has not been removed")
+	}
+
+	_, err = cm.Get(observer)
+	if err != ErrNoObserver {
+		t.Fatal()
+	}
+
+	cm.Close()
+	cm.Close()
+}
+
+// Regression: DeleteByObserver used to lock the mutex twice and hang forever.
+func TestConnectionsMap_DeleteByObserver(t *testing.T) {
+	cm := NewConnectionsMap(0)
+	defer cm.Stop(context.Background())
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	observer := external.NewObserver("127.0.0.1", 3000, nil)
+	cm.Set(observer, local)
+
+	w, err := cm.Get(observer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deleted := make(chan struct{})
+	go func() {
+		cm.DeleteByObserver(observer)
+		close(deleted)
+	}()
+
+	select {
+	case <-deleted:
+	case <-time.After(time.Second):
+		t.Fatal("deletion is blocked")
+	}
+
+	if !w.IsClosed() {
+		t.Fatal("connection must be closed")
+	}
+
+	_, err = cm.Get(observer)
+	if err != ErrNoObserver {
+		t.Fatal()
+	}
+
+	// Absent observer is ignored.
+	cm.DeleteByObserver(observer)
+}
+
+// blockingCloseConn blocks on close until it is released.
+type blockingCloseConn struct {
+	net.Conn
+	release chan struct{}
+}
+
+func (c *blockingCloseConn) Close() error {
+	<-c.release
+	return c.Conn.Close()
+}
+
+// Connection, that is closed slowly, must not block the map,
+// and the stop must return in time, specified by the context.
+func TestConnectionsMap_Stop_SlowClose(t *testing.T) {
+	cm := NewConnectionsMap(0)
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	conn := &blockingCloseConn{Conn: local, release: make(chan struct{})}
+	defer close(conn.release)
+
+	observer := external.NewObserver("127.0.0.1", 3000, nil)
+	cm.Set(observer, conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	err := cm.Stop(ctx)
+	if err != context.DeadlineExceeded {
+		t.Fatal("stop must honour the context: ", err)
+	}
+
+	_, err = cm.Get(observer)
+	if err != ErrNoObserver {
+		t.Fatal("connection must be removed from the map")
+	}
+}
+
+// Connections, that are removed from the map, must be closed without the lock:
+// slow closing of one connection must not block the operations with the rest of them.
+func TestConnectionsMap_Delete_SlowCloseDoesNotBlock(t *testing.T) {
+	removers := map[string]func(cm *ConnectionsMap, observer *external.Observer){
+		"DeleteByObserver": func(cm *ConnectionsMap, observer *external.Observer) {
+			cm.DeleteByObserver(observer)
+		},
+		"DeleteByRemoteHost": func(cm *ConnectionsMap, observer *external.Observer) {
+			cm.DeleteByRemoteHost("127.0.0.1")
+		},
+		"ReconcileWithConfiguration": func(cm *ConnectionsMap, observer *external.Observer) {
+			cm.ReconcileWithConfiguration(nil)
+		},
+		"removeUnused": func(cm *ConnectionsMap, observer *external.Observer) {
+			cm.removeUnused(time.Now().Add(time.Hour))
+		},
+	}
+
+	for name, remove := range removers {
+		cm := NewConnectionsMap(0)
+
+		local, remote := net.Pipe()
+		conn := &blockingCloseConn{Conn: local, release: make(chan struct{})}
+
+		observer := external.NewObserver("127.0.0.1", 3000, nil)
+		cm.Set(observer, conn)
+
+		removed := make(chan struct{})
+		go func() {
+			remove(cm, observer)
+			close(removed)
+		}()
+
+		other := external.NewObserver("127.0.0.1", 3001, nil)
+		otherLocal, otherRemote := net.Pipe()
+		accessed := make(chan struct{})
+		go func() {
+			cm.Set(other, otherLocal)
+			_, _ = cm.Get(other)
+			close(accessed)
+		}()
+
+		select {
+		case <-accessed:
+		case <-time.After(time.Second):
+			t.Fatal(name, ": map is blocked by the closing connection")
+		}
+
+		close(conn.release)
+		<-removed
+
+		_, err := cm.Get(observer)
+		if err != ErrNoObserver {
+			t.Fatal(name, ": connection must be removed")
+		}
+
+		_ = cm.Stop(context.Background())
+		_ = remote.Close()
+		_ = otherRemote.Close()
+	}
+}
+
+// Dialer fails several times before the connection is established:
+// the dial must be retried with backoff, and failed attempts must not leave any record in the map.
+func TestConnectionsMap_GetOrDial_Backoff(t *testing.T) {
+	defaultAttempts := settings.ObserversConnectionDialAttempts
+	defaultBackoff := settings.ObserversConnectionDialBackoff
+	settings.ObserversConnectionDialAttempts = 3
+	settings.ObserversConnectionDialBackoff = time.Millisecond * 10
+	defer func() {
+		settings.ObserversConnectionDialAttempts = defaultAttempts
+		settings.ObserversConnectionDialBackoff = defaultBackoff
+	}()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go io.Copy(ioutil.Discard, conn)
+		}
+	}()
+
+	cm := NewConnectionsMap(0)
+	defer cm.Stop(context.Background())
+
+	observer := external.NewObserver("127.0.0.1", 3000, nil)
+	failuresLeft := 0
+	attempts := 0
+	dialer := func(*external.Observer) (net.Conn, error) {
+		attempts++
+		if failuresLeft > 0 {
+			failuresLeft--
+			return nil, ErrObserverConnectionRefused
+		}
+
+		return net.Dial("tcp", listener.Addr().String())
+	}
+
+	// All attempts fail.
+	failuresLeft = 3
+	_, err = cm.GetOrDial(observer, dialer)
+	if err != ErrObserverConnectionRefused || attempts != 3 || cm.Len() != 0 {
+		t.Fatal("failed dials must not change the map")
+	}
+
+	// Third attempt succeeds.
+	attempts = 0
+	failuresLeft = 2
+	started := time.Now()
+	w, err := cm.GetOrDial(observer, dialer)
+	if err != nil || attempts != 3 {
+		t.Fatal(err)
+	}
+
+	// 10ms + 20ms of backoff.
+	if time.Since(started) < time.Millisecond*30 {
+		t.Fatal("dials must be delayed")
+	}
+
+	// Live connection is reused.
+	reused, err := cm.GetOrDial(observer, dialer)
+	if err != nil || reused != w || attempts != 3 {
+		t.Fatal("live connection must be reused")
+	}
+
+	// Closed connection is replaced.
+	w.Close()
+	replaced, err := cm.GetOrDial(observer, dialer)
+	if err != nil || replaced == w || attempts != 4 || cm.Len() != 1 {
+		t.Fatal("closed connection must be replaced")
+	}
+}
+
+// Remote side never reads the data: write must fail with timeout instead of hanging,
+// and the connection must be closed and removed from the map.
+func TestConnectionWrapper_WriteWithTimeout(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	cm := NewConnectionsMap(0)
+	defer cm.Stop(context.Background())
+
+	observer := external.NewObserver("127.0.0.1", 3000, nil)
+	cm.Set(observer, local)
+
+	w, err := cm.Get(observer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- w.WriteWithTimeout([]byte{1, 2, 3}, time.Millisecond*50)
+	}()
+
+	select {
+	case err = <-result:
+		if err != ErrWriteTimeout {
+			t.Fatal(err)
+		}
+
+	case <-time.After(time.Second * 5):
+		t.Fatal("write is blocked")
+	}
+
+	if !w.IsClosed() || cm.Len() != 0 {
+		t.Fatal("connection must be dropped")
+	}
+}
+
+// Checks that the data is written in case if remote side reads it.
+func TestConnectionWrapper_WriteWithTimeout_Success(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	w := newConnectionWrapper(local)
+	defer w.Close()
+
+	go func() {
+		err := w.WriteWithTimeout([]byte{1, 2, 3}, time.Second)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	frame := make([]byte, 8)
+	_, err := io.ReadFull(remote, frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	size, _ := utils.UnmarshalUint32(frame[1:5])
+	if frame[0] != ProtocolVersion || size != 3 || frame[7] != 3 {
+		t.Fatal("invalid frame")
+	}
+}
+
+// Queued frames are written with the timeout as well.
+func TestConnectionWrapper_Enqueue_WriteTimeout(t *testing.T) {
+	defaultTimeout := settings.ObserversConnectionWriteTimeout
+	settings.ObserversConnectionWriteTimeout = time.Millisecond * 50
+	defer func() { settings.ObserversConnectionWriteTimeout = defaultTimeout }()
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	cm := NewConnectionsMap(0)
+	defer cm.Stop(context.Background())
+
+	observer := external.NewObserver("127.0.0.1", 3000, nil)
+	cm.Set(observer, local)
+
+	w, err := cm.Get(observer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = w.Enqueue([]byte{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !waitClosed(w) {
+		t.Fatal("connection must be dropped")
+	}
+
+	_, err = cm.Get(observer)
+	if err != ErrNoObserver {
+		t.Fatal("connection must be removed from the map")
+	}
+}
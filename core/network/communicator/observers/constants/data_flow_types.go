@@ -1,5 +1,9 @@
 package constants
 
+import (
+	"fmt"
+)
+
 var (
 	// Range 0..63 is reserved for the future needs.
 
@@ -24,6 +28,14 @@ var (
 	DataTypeRequestBlockHashBroadcast uint8 = 139
 
 	DataTypeRequestTimeFrameCollision uint8 = 140
+
+	DataTypeResponseDigestReject uint8 = 141
+
+	DataTypeRequestBlockProposalBroadcast uint8 = 142
+	DataTypeResponseBlockProposalReject   uint8 = 143
+
+	DataTypeRequestPing  uint8 = 144
+	DataTypeResponsePong uint8 = 145
 )
 
 var (
@@ -48,4 +60,88 @@ var (
 	StreamTypeRequestBlockHashBroadcast = []byte{DataTypeRequestBlockHashBroadcast}
 
 	StreamTypeRequestTimeFrameCollision = []byte{DataTypeRequestTimeFrameCollision}
+
+	StreamTypeResponseDigestReject = []byte{DataTypeResponseDigestReject}
+
+	StreamTypeRequestBlockProposalBroadcast = []byte{DataTypeRequestBlockProposalBroadcast}
+	StreamTypeResponseBlockProposalReject   = []byte{DataTypeResponseBlockProposalReject}
+
+	StreamTypeRequestPing  = []byte{DataTypeRequestPing}
+	StreamTypeResponsePong = []byte{DataTypeResponsePong}
 )
+
+func init() {
+	// Data types are assigned by hand, so the collision is checked on start
+	// to prevent silent mis-parsing of the messages.
+	dataType, isPresent := duplicatedDataType(AllDataTypes())
+	if isPresent {
+		panic(fmt.Sprint("Data type is assigned to several messages types: ", dataType))
+	}
+}
+
+// AllDataTypes returns all defined data types (requests types first, then responses types).
+func AllDataTypes() []uint8 {
+	return append(requestsDataTypes(), responsesDataTypes()...)
+}
+
+// IsRequest returns true if the data type specified is the type of one of the requests.
+func IsRequest(dataType uint8) bool {
+	return containsDataType(requestsDataTypes(), dataType)
+}
+
+// IsResponse returns true if the data type specified is the type of one of the responses.
+func IsResponse(dataType uint8) bool {
+	return containsDataType(responsesDataTypes(), dataType)
+}
+
+func requestsDataTypes() []uint8 {
+	return []uint8{
+		DataTypeRequestTimeFrames,
+		DataTypeRequestTSLBroadcast,
+		DataTypeRequestClaimBroadcast,
+		DataTypeRequestDigestBroadcast,
+		DataTypeRequestBlockSignaturesBroadcast,
+		DataTypeRequestChainTop,
+		DataTypeRequestBlockHashBroadcast,
+		DataTypeRequestTimeFrameCollision,
+		DataTypeRequestBlockProposalBroadcast,
+		DataTypeRequestPing,
+	}
+}
+
+func responsesDataTypes() []uint8 {
+	return []uint8{
+		DataTypeResponseTimeFrame,
+		DataTypeResponseTSLApprove,
+		DataTypeResponseClaimApprove,
+		DataTypeResponseDigestApprove,
+		DataTypeResponseChainTop,
+		DataTypeResponseDigestReject,
+		DataTypeResponseBlockProposalReject,
+		DataTypeResponsePong,
+	}
+}
+
+func containsDataType(dataTypes []uint8, dataType uint8) bool {
+	for _, t := range dataTypes {
+		if t == dataType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// duplicatedDataType returns the first data type, that is present several times in the "dataTypes".
+func duplicatedDataType(dataTypes []uint8) (dataType uint8, isPresent bool) {
+	seen := make(map[uint8]bool, len(dataTypes))
+	for _, t := range dataTypes {
+		if seen[t] {
+			return t, true
+		}
+
+		seen[t] = true
+	}
+
+	return 0, false
+}
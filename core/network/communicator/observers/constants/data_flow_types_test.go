@@ -0,0 +1,81 @@
+package constants
+
+import (
+	"testing"
+)
+
+// Checks that all data types are unique, are out of the reserved range,
+// and that each stream type consists of the corresponding data type.
+func TestDataTypes_Unique(t *testing.T) {
+	streams := map[uint8][]byte{
+		DataTypeRequestTimeFrames:               StreamTypeRequestTimeFrames,
+		DataTypeResponseTimeFrame:               StreamTypeResponseTimeFrame,
+		DataTypeRequestTSLBroadcast:             StreamTypeRequestTSLBroadcast,
+		DataTypeResponseTSLApprove:              StreamTypeResponseTSLApprove,
+		DataTypeRequestClaimBroadcast:           StreamTypeRequestClaimBroadcast,
+		DataTypeResponseClaimApprove:            StreamTypeResponseClaimApprove,
+		DataTypeRequestDigestBroadcast:          StreamTypeRequestDigestBroadcast,
+		DataTypeResponseDigestApprove:           StreamTypeResponseDigestApprove,
+		DataTypeRequestBlockSignaturesBroadcast: StreamTypeRequestBlockSignaturesBroadcast,
+		DataTypeRequestChainTop:                 StreamTypeRequestChainTop,
+		DataTypeResponseChainTop:                StreamTypeResponseChainTop,
+		DataTypeRequestBlockHashBroadcast:       StreamTypeRequestBlockHashBroadcast,
+		DataTypeRequestTimeFrameCollision:       StreamTypeRequestTimeFrameCollision,
+		DataTypeResponseDigestReject:            StreamTypeResponseDigestReject,
+		DataTypeRequestBlockProposalBroadcast:   StreamTypeRequestBlockProposalBroadcast,
+		DataTypeResponseBlockProposalReject:     StreamTypeResponseBlockProposalReject,
+		DataTypeRequestPing:                     StreamTypeRequestPing,
+		DataTypeResponsePong:                    StreamTypeResponsePong,
+	}
+
+	// Data types are variables, so duplicated keys of the map literal are silently overwritten.
+	const definedTypesCount = 18
+	if len(streams) != definedTypesCount {
+		t.Fatal("data types must be unique")
+	}
+
+	for dataType, stream := range streams {
+		if dataType < 64 {
+			t.Fatal("data type is in the reserved range: ", dataType)
+		}
+
+		if len(stream) != 1 || stream[0] != dataType {
+			t.Fatal("stream type does not correspond to the data type: ", dataType)
+		}
+	}
+}
+
+// Checks that each data type is either request or response type.
+func TestDataTypes_RequestsResponses(t *testing.T) {
+	all := AllDataTypes()
+	if len(all) != 18 {
+		t.Fatal()
+	}
+
+	for _, dataType := range all {
+		if IsRequest(dataType) == IsResponse(dataType) {
+			t.Fatal("data type must be either request or response type: ", dataType)
+		}
+	}
+
+	if !IsRequest(DataTypeRequestBlockProposalBroadcast) || !IsResponse(DataTypeResponseBlockProposalReject) ||
+		!IsRequest(DataTypeRequestPing) || !IsResponse(DataTypeResponsePong) {
+		t.Fatal()
+	}
+
+	if IsRequest(0) || IsResponse(0) {
+		t.Fatal("reserved data type must not be recognised")
+	}
+}
+
+func TestDataTypes_Duplicated(t *testing.T) {
+	_, isPresent := duplicatedDataType(AllDataTypes())
+	if isPresent {
+		t.Fatal("data types must be unique")
+	}
+
+	dataType, isPresent := duplicatedDataType([]uint8{128, 129, 130, 129})
+	if !isPresent || dataType != 129 {
+		t.Fatal("collision must be detected")
+	}
+}
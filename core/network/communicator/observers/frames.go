@@ -0,0 +1,105 @@
+package observers
+
+import (
+	"bufio"
+	"geo-observers-blockchain/core/common"
+	"geo-observers-blockchain/core/settings"
+	"geo-observers-blockchain/core/utils"
+	"io"
+)
+
+// Message frame format: [protocol version: uint8] [data size: uint32] [data] (see protocol.go).
+// Data of the message starts with it's type (see constants), followed by the message body.
+// Frames are built and parsed only by the functions of this file,
+// so the sending and the receiving sides can't diverge.
+
+const (
+	kFrameHeaderSize = 1 + common.Uint32ByteSize
+)
+
+var (
+	ErrFrameTooLarge  = utils.Error("frames", "frame data size exceeds the limit")
+	ErrFrameTruncated = utils.Error("frames", "frame is truncated")
+)
+
+// marshalFrame prefixes the data with the protocol version and the data size.
+// Returns ErrFrameTooLarge in case if the data exceeds settings.ObserversFrameBodyMaxSize
+// (such frame would be rejected by the remote observer anyway).
+func marshalFrame(protocolVersion uint8, data []byte) (frame []byte, err error) {
+	if len(data) > settings.ObserversFrameBodyMaxSize {
+		return nil, ErrFrameTooLarge
+	}
+
+	return utils.ChainByteSlices([]byte{protocolVersion}, utils.MarshalUint32(uint32(len(data))), data), nil
+}
+
+// readFrame reads one message frame from the reader and returns it's data.
+// Returns io.EOF in case if the stream has ended before the frame,
+// and ErrFrameTruncated in case if it has ended in the middle of the frame.
+// Frames of the protocol version, other than expected one, are rejected with ErrUnsupportedProtocolVersion
+// (they can't be parsed reliably).
+// Declared data size is checked before the data reading,
+// so frames larger than settings.ObserversFrameBodyMaxSize are rejected (ErrFrameTooLarge) without memory allocation.
+func readFrame(r io.Reader, protocolVersion uint8) (data []byte, err error) {
+	header := make([]byte, kFrameHeaderSize)
+	_, err = io.ReadFull(r, header)
+	if err == io.ErrUnexpectedEOF {
+		return nil, ErrFrameTruncated
+	}
+	if err != nil {
+		return
+	}
+
+	if header[0] != protocolVersion {
+		return nil, ErrUnsupportedProtocolVersion
+	}
+
+	size, err := utils.UnmarshalUint32(header[1:])
+	if err != nil {
+		return
+	}
+
+	if uint64(size) > uint64(settings.ObserversFrameBodyMaxSize) {
+		return nil, ErrFrameTooLarge
+	}
+
+	data = make([]byte, size)
+	_, err = io.ReadFull(r, data)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil, ErrFrameTruncated
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// WriteFrame writes the message of the type specified as one frame of the current protocol version.
+// Writer is not flushed.
+// Returns ErrFrameTooLarge in case if the message exceeds settings.ObserversFrameBodyMaxSize.
+func WriteFrame(w *bufio.Writer, dataType uint8, body []byte) (err error) {
+	frame, err := marshalFrame(ProtocolVersion, utils.ChainByteSlices([]byte{dataType}, body))
+	if err != nil {
+		return
+	}
+
+	_, err = w.Write(frame)
+	return
+}
+
+// ReadFrame reads one frame of the current protocol version (see readFrame() for the errors)
+// and returns type and body of the message it contains.
+// Frame without the message type is rejected with ErrFrameTruncated.
+func ReadFrame(r *bufio.Reader) (dataType uint8, body []byte, err error) {
+	data, err := readFrame(r, ProtocolVersion)
+	if err != nil {
+		return
+	}
+
+	if len(data) == 0 {
+		return 0, nil, ErrFrameTruncated
+	}
+
+	return data[0], data[1:], nil
+}
@@ -0,0 +1,162 @@
+package observers
+
+import (
+	"bufio"
+	"bytes"
+	"geo-observers-blockchain/core/settings"
+	"geo-observers-blockchain/core/utils"
+	"io"
+	"testing"
+)
+
+// Writes several frames and reads them back.
+func TestFrames_MarshalRead(t *testing.T) {
+	buffer := &bytes.Buffer{}
+
+	payloads := [][]byte{{1, 2, 3}, {}, bytes.Repeat([]byte{7}, 4096)}
+	for _, data := range payloads {
+		frame, err := marshalFrame(ProtocolVersion, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(frame) != kFrameHeaderSize+len(data) || frame[0] != ProtocolVersion {
+			t.Fatal("invalid frame")
+		}
+
+		buffer.Write(frame)
+	}
+
+	for _, expected := range payloads {
+		data, err := readFrame(buffer, ProtocolVersion)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(data, expected) {
+			t.Fatal("invalid frame")
+		}
+	}
+
+	_, err := readFrame(buffer, ProtocolVersion)
+	if err != io.EOF {
+		t.Fatal("end of the stream must be reported")
+	}
+}
+
+// Checks that the stream, ended in the middle of the frame, is reported as truncated.
+func TestFrames_ReadTruncated(t *testing.T) {
+	frame, err := marshalFrame(ProtocolVersion, []byte{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, size := range []int{1, 3, len(frame) - 1} {
+		_, err = readFrame(bytes.NewReader(frame[:size]), ProtocolVersion)
+		if err != ErrFrameTruncated {
+			t.Fatal("truncated frame must be rejected: ", size)
+		}
+	}
+}
+
+// Header might arrive in several TCP segments: it must be read completely.
+func TestFrames_ReadPartialHeader(t *testing.T) {
+	frame, err := marshalFrame(ProtocolVersion, []byte{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := readFrame(&oneByteReader{data: frame}, ProtocolVersion)
+	if err != nil || !bytes.Equal(data, []byte{1, 2, 3}) {
+		t.Fatal("frame must be read byte by byte")
+	}
+}
+
+type oneByteReader struct {
+	data []byte
+}
+
+func (r *oneByteReader) Read(p []byte) (n int, err error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	p[0], r.data = r.data[0], r.data[1:]
+	return 1, nil
+}
+
+// Checks that oversized frames are rejected on both sides.
+func TestFrames_Oversized(t *testing.T) {
+	defaultMaxSize := settings.ObserversFrameBodyMaxSize
+	settings.ObserversFrameBodyMaxSize = 16
+	defer func() { settings.ObserversFrameBodyMaxSize = defaultMaxSize }()
+
+	_, err := marshalFrame(ProtocolVersion, make([]byte, 17))
+	if err != ErrFrameTooLarge {
+		t.Fatal("oversized frame must not be written")
+	}
+
+	// Hostile length field: data itself is absent, it must not be waited for (nor allocated).
+	frame := utils.ChainByteSlices([]byte{ProtocolVersion}, utils.MarshalUint32(0xFFFFFFFF))
+	_, err = readFrame(bytes.NewReader(frame), ProtocolVersion)
+	if err != ErrFrameTooLarge {
+		t.Fatal("oversized frame must be rejected")
+	}
+}
+
+// Writes several messages via the public API and reads them back, including the truncated and oversized ones.
+func TestFrames_WriteReadFrame(t *testing.T) {
+	defaultMaxSize := settings.ObserversFrameBodyMaxSize
+	settings.ObserversFrameBodyMaxSize = 16
+	defer func() { settings.ObserversFrameBodyMaxSize = defaultMaxSize }()
+
+	buffer := &bytes.Buffer{}
+	w := bufio.NewWriter(buffer)
+	if WriteFrame(w, 130, []byte{1, 2, 3}) != nil || WriteFrame(w, 131, nil) != nil || w.Flush() != nil {
+		t.Fatal()
+	}
+
+	// Data type is a part of the frame data, so the body limit is one byte less.
+	if WriteFrame(w, 132, make([]byte, 16)) != ErrFrameTooLarge {
+		t.Fatal("oversized frame must not be written")
+	}
+
+	r := bufio.NewReader(buffer)
+	dataType, body, err := ReadFrame(r)
+	if err != nil || dataType != 130 || !bytes.Equal(body, []byte{1, 2, 3}) {
+		t.Fatal("invalid frame read")
+	}
+
+	dataType, body, err = ReadFrame(r)
+	if err != nil || dataType != 131 || len(body) != 0 {
+		t.Fatal("invalid frame read")
+	}
+
+	_, _, err = ReadFrame(r)
+	if err != io.EOF {
+		t.Fatal("end of the stream must be reported")
+	}
+
+	// Frame without the message type.
+	frame, _ := marshalFrame(ProtocolVersion, nil)
+	_, _, err = ReadFrame(bufio.NewReader(bytes.NewReader(frame)))
+	if err != ErrFrameTruncated {
+		t.Fatal("frame without the message type must be rejected")
+	}
+
+	frame, _ = marshalFrame(ProtocolVersion, []byte{130, 1, 2, 3})
+	_, _, err = ReadFrame(bufio.NewReader(bytes.NewReader(frame[:len(frame)-1])))
+	if err != ErrFrameTruncated {
+		t.Fatal("truncated frame must be rejected")
+	}
+
+	frame = utils.ChainByteSlices([]byte{ProtocolVersion}, utils.MarshalUint32(17))
+	_, _, err = ReadFrame(bufio.NewReader(bytes.NewReader(frame)))
+	if err != ErrFrameTooLarge {
+		t.Fatal("oversized frame must be rejected")
+	}
+}
@@ -0,0 +1,160 @@
+package observers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"geo-observers-blockchain/core/common"
+	"geo-observers-blockchain/core/network/communicator/observers/constants"
+	"geo-observers-blockchain/core/network/external"
+	"geo-observers-blockchain/core/settings"
+	"geo-observers-blockchain/core/utils"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"time"
+)
+
+// Ping is sent through the outgoing connection to the remote observer: [DataTypeRequestPing] [nonce: uint64],
+// and the remote observer's receiver responds through the same connection: [DataTypeResponsePong] [nonce: uint64].
+// Both messages are framed as usual (see protocol.go).
+// Nothing else is read from the outgoing connections, so the pong is read by the ping initiator itself.
+
+const (
+	kPingNonceSize = common.Uint64ByteSize
+	kPingDataSize  = 1 + kPingNonceSize
+)
+
+var (
+	ErrPingTimeout = utils.Error("ping", "pong has not been received in time")
+	ErrInvalidPong = utils.Error("ping", "invalid pong received")
+	ErrInvalidPing = utils.Error("ping", "invalid ping received")
+)
+
+// Ping checks that the connection to the observer is alive (TCP connection might be half-open),
+// and returns round trip time of the ping message.
+// Connection is marked as used on success (see LastUsed), and the round trip time is accounted
+// in the smoothed round trip time of the connection (see ConnectionWrapper.RoundTripTime()).
+// Connection is closed and removed from the map in case if the pong is not received
+// during settings.ObserversPingTimeout, or is invalid.
+// Returns ErrNoObserver in case if there is no connection to the observer.
+func (cm *ConnectionsMap) Ping(observer *external.Observer) (rtt time.Duration, err error) {
+	w, err := cm.Get(observer)
+	if err != nil {
+		return
+	}
+
+	return cm.pingConnection(w)
+}
+
+// StartAutoPing periodically pings all connections of the map (see Ping()) until the map is stopped,
+// so the dead connections are dropped, and the round trip times are kept up to date.
+// Non positive interval is ignored.
+func (cm *ConnectionsMap) StartAutoPing(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				for _, w := range cm.connectionsList() {
+					_, err := cm.pingConnection(w)
+					if err != nil {
+						log.WithFields(log.Fields{"prefix": "Connections", "Address": w.address}).Debug(
+							"Connection dropped, ping failed: ", err)
+					}
+				}
+
+			case <-cm.done:
+				return
+			}
+		}
+	}()
+}
+
+func (cm *ConnectionsMap) pingConnection(w *ConnectionWrapper) (rtt time.Duration, err error) {
+	rtt, err = w.ping(settings.ObserversPingTimeout)
+	if err != nil {
+		w.drop()
+		return
+	}
+
+	w.recordRoundTripTime(rtt)
+
+	cm.mutex.Lock()
+	w.LastUsed = time.Now()
+	cm.mutex.Unlock()
+
+	return
+}
+
+// ping sends the ping and waits for the pong until the timeout.
+// Pings are serialized: only one pong might be awaited on the connection at once.
+func (w *ConnectionWrapper) ping(timeout time.Duration) (rtt time.Duration, err error) {
+	w.pingMutex.Lock()
+	defer w.pingMutex.Unlock()
+
+	nonce := make([]byte, kPingNonceSize)
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return
+	}
+
+	started := time.Now()
+	err = w.WriteWithTimeout(utils.ChainByteSlices(constants.StreamTypeRequestPing, nonce), timeout)
+	if err != nil {
+		return
+	}
+
+	err = w.Connection.SetReadDeadline(started.Add(timeout))
+	if err != nil {
+		return
+	}
+	defer w.Connection.SetReadDeadline(time.Time{})
+
+	// [DataTypeResponsePong] [nonce]
+	pong, err := readFrame(w.Connection, w.protocolVersion)
+	if isTimeout(err) {
+		return 0, ErrPingTimeout
+	}
+	if err == ErrUnsupportedProtocolVersion {
+		return 0, ErrInvalidPong
+	}
+	if err != nil {
+		return
+	}
+
+	rtt = time.Since(started)
+
+	if !bytes.Equal(pong, utils.ChainByteSlices(constants.StreamTypeResponsePong, nonce)) {
+		return 0, ErrInvalidPong
+	}
+
+	return
+}
+
+// respondToPing sends the pong with the nonce of the ping through the connection, the ping has been received from.
+func respondToPing(conn net.Conn, protocolVersion uint8, ping []byte) (err error) {
+	if len(ping) != kPingDataSize || ping[0] != constants.DataTypeRequestPing {
+		return ErrInvalidPing
+	}
+
+	if settings.ObserversConnectionWriteTimeout > 0 {
+		err = conn.SetWriteDeadline(time.Now().Add(settings.ObserversConnectionWriteTimeout))
+		if err != nil {
+			return
+		}
+		defer conn.SetWriteDeadline(time.Time{})
+	}
+
+	pong, err := marshalFrame(protocolVersion, utils.ChainByteSlices(constants.StreamTypeResponsePong, ping[1:]))
+	if err != nil {
+		return
+	}
+
+	_, err = conn.Write(pong)
+	return
+}
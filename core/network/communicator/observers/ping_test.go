@@ -0,0 +1,216 @@
+package observers
+
+import (
+	"context"
+	"geo-observers-blockchain/core/common"
+	"geo-observers-blockchain/core/network/external"
+	"geo-observers-blockchain/core/settings"
+	"geo-observers-blockchain/core/utils"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// readTestPing reads one framed message from the remote side of the connection
+// and returns the protocol version and the data of it.
+func readTestPing(remote net.Conn) (version uint8, data []byte, err error) {
+	header := make([]byte, 1+common.Uint32ByteSize)
+	_, err = io.ReadFull(remote, header)
+	if err != nil {
+		return
+	}
+
+	size, err := utils.UnmarshalUint32(header[1:])
+	if err != nil {
+		return
+	}
+
+	data = make([]byte, size)
+	_, err = io.ReadFull(remote, data)
+	return header[0], data, err
+}
+
+// Remote observer responds to the ping: RTT must be measured, and the connection must be marked as used.
+func TestConnectionsMap_Ping(t *testing.T) {
+	cm := NewConnectionsMap(0)
+	defer cm.Stop(context.Background())
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	go func() {
+		version, data, err := readTestPing(remote)
+		if err != nil {
+			return
+		}
+
+		time.Sleep(time.Millisecond * 20)
+		_ = respondToPing(remote, version, data)
+	}()
+
+	observer := external.NewObserver("127.0.0.1", 3000, nil)
+	cm.Set(observer, local)
+
+	w, err := cm.Get(observer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lastUsed := time.Now().Add(-time.Hour)
+	w.LastUsed = lastUsed
+
+	rtt, err := cm.Ping(observer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rtt < time.Millisecond*20 || rtt > settings.ObserversPingTimeout {
+		t.Fatal("unexpected RTT: ", rtt)
+	}
+
+	if !w.LastUsed.After(lastUsed) || w.IsClosed() {
+		t.Fatal("connection must be marked as used")
+	}
+
+	smoothed, isKnown := w.RoundTripTime()
+	if !isKnown || smoothed != rtt {
+		t.Fatal("first measured round trip time must be used as is")
+	}
+}
+
+// One delayed pong must not distort the smoothed round trip time.
+func TestConnectionWrapper_RoundTripTime_Smoothing(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	w := newConnectionWrapper(local)
+	defer w.Close()
+
+	_, isKnown := w.RoundTripTime()
+	if isKnown {
+		t.Fatal("round trip time must be unknown before the first measurement")
+	}
+
+	w.recordRoundTripTime(time.Millisecond * 10)
+	w.recordRoundTripTime(time.Millisecond * 90)
+
+	rtt, isKnown := w.RoundTripTime()
+	if !isKnown || rtt != time.Millisecond*20 {
+		t.Fatal("unexpected smoothed round trip time: ", rtt)
+	}
+}
+
+func TestConnectionsMap_RoundTripTimeByIndex(t *testing.T) {
+	cm := NewConnectionsMap(0)
+	defer cm.Stop(context.Background())
+
+	observers := []*external.Observer{
+		external.NewObserver("127.0.0.1", 3000, nil),
+		external.NewObserver("127.0.0.1", 3001, nil),
+	}
+	cm.ReconcileWithConfiguration(observers)
+
+	for _, observer := range observers {
+		local, remote := net.Pipe()
+		defer remote.Close()
+		cm.Set(observer, local)
+	}
+
+	w, err := cm.GetByIndex(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.recordRoundTripTime(time.Millisecond * 15)
+
+	rtt, isKnown := cm.RoundTripTimeByIndex(1)
+	if !isKnown || rtt != time.Millisecond*15 {
+		t.Fatal()
+	}
+
+	_, isKnown = cm.RoundTripTimeByIndex(0)
+	if isKnown {
+		t.Fatal("round trip time has not been measured yet")
+	}
+
+	_, isKnown = cm.RoundTripTimeByIndex(2)
+	if isKnown {
+		t.Fatal("there is no such observer")
+	}
+}
+
+// Remote observer reads the ping, but does not respond (half-open connection, or overloaded observer):
+// connection must be dropped after the timeout.
+func TestConnectionsMap_Ping_Timeout(t *testing.T) {
+	defer func(timeout time.Duration) { settings.ObserversPingTimeout = timeout }(settings.ObserversPingTimeout)
+	settings.ObserversPingTimeout = time.Millisecond * 100
+
+	cm := NewConnectionsMap(0)
+	defer cm.Stop(context.Background())
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	go func() {
+		_, _, _ = readTestPing(remote)
+	}()
+
+	observer := external.NewObserver("127.0.0.1", 3000, nil)
+	cm.Set(observer, local)
+
+	started := time.Now()
+	_, err := cm.Ping(observer)
+	if err != ErrPingTimeout {
+		t.Fatal(err)
+	}
+
+	if time.Since(started) > time.Second {
+		t.Fatal("ping must be stopped after the timeout")
+	}
+
+	_, err = cm.Get(observer)
+	if err != ErrNoObserver {
+		t.Fatal("connection must be dropped")
+	}
+}
+
+// Pong with other nonce must be rejected.
+func TestConnectionsMap_Ping_InvalidPong(t *testing.T) {
+	cm := NewConnectionsMap(0)
+	defer cm.Stop(context.Background())
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	go func() {
+		version, data, err := readTestPing(remote)
+		if err != nil {
+			return
+		}
+
+		data[len(data)-1]++
+		_ = respondToPing(remote, version, data)
+	}()
+
+	observer := external.NewObserver("127.0.0.1", 3000, nil)
+	cm.Set(observer, local)
+
+	_, err := cm.Ping(observer)
+	if err != ErrInvalidPong {
+		t.Fatal(err)
+	}
+
+	if cm.Len() != 0 {
+		t.Fatal("connection must be dropped")
+	}
+}
+
+func TestConnectionsMap_Ping_NoConnection(t *testing.T) {
+	cm := NewConnectionsMap(0)
+	defer cm.Stop(context.Background())
+
+	_, err := cm.Ping(external.NewObserver("127.0.0.1", 3000, nil))
+	if err != ErrNoObserver {
+		t.Fatal(err)
+	}
+}
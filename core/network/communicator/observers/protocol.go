@@ -0,0 +1,133 @@
+package observers
+
+import (
+	"geo-observers-blockchain/core/utils"
+	"io"
+	"net"
+	"time"
+)
+
+// Each message, sent to the remote observer, is framed as
+// [protocol version: uint8] [message size: uint32] [message].
+//
+// Protocol version is negotiated on connect: the connecting side sends the list of the versions it supports
+// [versions count: uint8] [version]..., and the accepting side responds with the greatest version,
+// supported by both sides [version: uint8] (or with kProtocolVersionNone if there is no such version).
+// Messages of other versions are rejected by the receiver.
+
+const (
+	// Current version of the observers wire protocol.
+	ProtocolVersion uint8 = 1
+
+	// Is sent by the accepting side in case if none of the proposed versions is supported.
+	kProtocolVersionNone uint8 = 0
+)
+
+var (
+	ErrUnsupportedProtocolVersion = utils.Error("protocol", "unsupported protocol version")
+	ErrInvalidHandshake           = utils.Error("protocol", "invalid protocol handshake")
+)
+
+var (
+	// Versions of the wire protocol, that are supported by this observer.
+	supportedProtocolVersions = []uint8{ProtocolVersion}
+)
+
+// clientHandshake proposes the supported versions to the remote observer
+// and returns the version, selected by it.
+// Non positive timeout means no timeout.
+func clientHandshake(conn net.Conn, supported []uint8, timeout time.Duration) (version uint8, err error) {
+	if len(supported) == 0 || len(supported) > 255 {
+		return kProtocolVersionNone, ErrInvalidHandshake
+	}
+
+	err = setHandshakeDeadline(conn, timeout)
+	if err != nil {
+		return
+	}
+	defer conn.SetDeadline(time.Time{})
+
+	_, err = conn.Write(utils.ChainByteSlices([]byte{uint8(len(supported))}, supported))
+	if err != nil {
+		return
+	}
+
+	response := make([]byte, 1)
+	_, err = io.ReadFull(conn, response)
+	if err != nil {
+		return
+	}
+
+	version = response[0]
+	if version == kProtocolVersionNone || !isProtocolVersionSupported(supported, version) {
+		return kProtocolVersionNone, ErrUnsupportedProtocolVersion
+	}
+
+	return
+}
+
+// serverHandshake reads versions, proposed by the remote observer, and responds with the selected one.
+// In case if none of the proposed versions is supported - ErrUnsupportedProtocolVersion is returned
+// (remote side is informed about it as well).
+// Non positive timeout means no timeout.
+func serverHandshake(
+	conn net.Conn, reader io.Reader, supported []uint8, timeout time.Duration) (version uint8, err error) {
+
+	err = setHandshakeDeadline(conn, timeout)
+	if err != nil {
+		return
+	}
+	defer conn.SetDeadline(time.Time{})
+
+	count := make([]byte, 1)
+	_, err = io.ReadFull(reader, count)
+	if err != nil {
+		return
+	}
+
+	if count[0] == 0 {
+		return kProtocolVersionNone, ErrInvalidHandshake
+	}
+
+	proposed := make([]byte, count[0])
+	_, err = io.ReadFull(reader, proposed)
+	if err != nil {
+		return
+	}
+
+	version = kProtocolVersionNone
+	for _, v := range proposed {
+		if v > version && isProtocolVersionSupported(supported, v) {
+			version = v
+		}
+	}
+
+	_, err = conn.Write([]byte{version})
+	if err != nil {
+		return
+	}
+
+	if version == kProtocolVersionNone {
+		return kProtocolVersionNone, ErrUnsupportedProtocolVersion
+	}
+
+	return
+}
+
+func isProtocolVersionSupported(supported []uint8, version uint8) bool {
+	for _, v := range supported {
+		if v == version {
+			return true
+		}
+	}
+
+	return false
+}
+
+func setHandshakeDeadline(conn net.Conn, timeout time.Duration) error {
+	if timeout <= 0 {
+		return nil
+	}
+
+	return conn.SetDeadline(time.Now().Add(timeout))
+}
@@ -0,0 +1,79 @@
+package observers
+
+import (
+	"bufio"
+	"bytes"
+	"geo-observers-blockchain/core/utils"
+	"net"
+	"testing"
+	"time"
+)
+
+type testHandshakeResult struct {
+	version uint8
+	err     error
+}
+
+// runTestHandshake performs the handshake between the client and the server with the versions specified.
+func runTestHandshake(clientVersions, serverVersions []uint8) (client, server testHandshakeResult) {
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+
+	serverResult := make(chan testHandshakeResult, 1)
+	go func() {
+		version, err := serverHandshake(remote, bufio.NewReader(remote), serverVersions, time.Second)
+		serverResult <- testHandshakeResult{version, err}
+	}()
+
+	client.version, client.err = clientHandshake(local, clientVersions, time.Second)
+	server = <-serverResult
+	return
+}
+
+// Both sides support the same versions: the greatest common version must be selected.
+func TestHandshake_MatchingVersions(t *testing.T) {
+	client, server := runTestHandshake([]uint8{1, 2, 3}, []uint8{1, 2})
+	if client.err != nil || server.err != nil {
+		t.Fatal(client.err, server.err)
+	}
+
+	if client.version != 2 || server.version != 2 {
+		t.Fatal("the greatest common version must be selected")
+	}
+}
+
+// Sides has no common versions: both sides must report unsupported version.
+func TestHandshake_MismatchedVersions(t *testing.T) {
+	client, server := runTestHandshake([]uint8{2}, []uint8{ProtocolVersion})
+	if client.err != ErrUnsupportedProtocolVersion || server.err != ErrUnsupportedProtocolVersion {
+		t.Fatal(client.err, server.err)
+	}
+}
+
+// Frames of the protocol version, other than negotiated one, must be rejected.
+func TestReceiver_ReceiveDataPackage_Version(t *testing.T) {
+	r := NewReceiver(NewBlacklist(3, time.Minute, time.Minute))
+	frame := utils.ChainByteSlices([]byte{ProtocolVersion}, utils.MarshalUint32(3), []byte{1, 2, 3})
+
+	data, err := r.receiveDataPackage(bufio.NewReader(bytes.NewReader(frame)), ProtocolVersion)
+	if err != nil || !bytes.Equal(data, []byte{1, 2, 3}) {
+		t.Fatal("frame of the negotiated version must be accepted")
+	}
+
+	_, err = r.receiveDataPackage(bufio.NewReader(bytes.NewReader(frame)), ProtocolVersion+1)
+	if err != ErrUnsupportedProtocolVersion {
+		t.Fatal("frame of other version must be rejected")
+	}
+}
+
+// Frame with hostile size must be rejected before the data allocation.
+func TestReceiver_ReceiveDataPackage_Oversized(t *testing.T) {
+	r := NewReceiver(NewBlacklist(3, time.Minute, time.Minute))
+	frame := utils.ChainByteSlices([]byte{ProtocolVersion}, utils.MarshalUint32(0xFFFFFFFF))
+
+	_, err := r.receiveDataPackage(bufio.NewReader(bytes.NewReader(frame)), ProtocolVersion)
+	if err != ErrFrameTooLarge {
+		t.Fatal("oversized frame must be rejected")
+	}
+}
@@ -3,21 +3,24 @@ package observers
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"crypto/tls"
 	"fmt"
 	"geo-observers-blockchain/core/settings"
 
 	//"geo-observers-blockchain/core/chain"
+	"geo-observers-blockchain/core/common"
 	"geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/crypto/keystore"
 	"geo-observers-blockchain/core/geo"
 	"geo-observers-blockchain/core/network/communicator/observers/constants"
 	"geo-observers-blockchain/core/network/communicator/observers/requests"
 	"geo-observers-blockchain/core/network/communicator/observers/responses"
-	"geo-observers-blockchain/core/utils"
+	"geo-observers-blockchain/core/network/external"
 	log "github.com/sirupsen/logrus"
 	"io"
 	"net"
 	"reflect"
-	"strings"
 	"time"
 )
 
@@ -32,9 +35,31 @@ type Receiver struct {
 	//BlockCandidates chan *chain.BlockSigned
 	Requests  chan requests.Request
 	Responses chan responses.Response
+
+	// Messages received, grouped by the data type.
+	counters MessagesCounters
+
+	// Messages from the blacklisted observers are dropped.
+	// Observers, that sends malformed messages or fails authentication, are reported to it.
+	blacklist *Blacklist
+
+	// If present - only TLS connections are accepted (see EnableTLS()).
+	tlsConfig *tls.Config
+
+	// If present - only the connections of the observers, that has proven their identity, are accepted
+	// (see EnableAuthentication()). Authenticated connections are bound to the observers in this map.
+	inbound                *ConnectionsMap
+	authenticationKeyStore *keystore.KeyStore
+	registry               func() (*external.ObserverRegistry, error)
+
+	// Accepted connections, that are closed on stop (see Stop()).
+	connections common.ConnectionsTracker
+
+	// Controls stopping of the accepting loop (see Stop()).
+	lifecycle common.Lifecycle
 }
 
-func NewReceiver() *Receiver {
+func NewReceiver(blacklist *Blacklist) *Receiver {
 	const ChannelBufferSize = 1
 
 	return &Receiver{
@@ -48,18 +73,61 @@ func NewReceiver() *Receiver {
 		//BlockCandidates: make(chan *chain.BlockSigned, ChannelBufferSize),
 		Requests:  make(chan requests.Request, ChannelBufferSize),
 		Responses: make(chan responses.Response, ChannelBufferSize),
+		blacklist: blacklist,
 	}
 }
 
+// EnableTLS makes the receiver to accept only mutual TLS connections (see tls.go, ServerTLSConfig()).
+// Must be called before Run().
+func (r *Receiver) EnableTLS(config *tls.Config) {
+	r.tlsConfig = config
+}
+
+// EnableAuthentication makes the receiver to accept only connections of the observers,
+// that has proven their identity (see AuthenticatePeer()).
+// "registry" must return registry of the current observers configuration.
+// Must be called before Run().
+func (r *Receiver) EnableAuthentication(ks *keystore.KeyStore, registry func() (*external.ObserverRegistry, error)) {
+	r.inbound = NewConnectionsMap(0)
+	r.authenticationKeyStore = ks
+	r.registry = registry
+}
+
+// InboundConnections returns authenticated inbound connections (see EnableAuthentication()).
+// Returns nil in case if authentication is disabled.
+func (r *Receiver) InboundConnections() *ConnectionsMap {
+	return r.inbound
+}
+
 func (r *Receiver) Run(host string, port uint16, errors chan<- error) {
+	stop := r.lifecycle.Start()
+	defer r.lifecycle.Done()
+
 	listener, err := net.Listen("tcp", fmt.Sprint(host, ":", port))
 	if err != nil {
 		errors <- err
 		return
 	}
 
+	if r.tlsConfig != nil {
+		// Handshake is performed on the first read from the connection.
+		listener = tls.NewListener(listener, r.tlsConfig)
+	}
+
 	//noinspection GoUnhandledErrorResult
 	defer listener.Close()
+	defer r.connections.CloseAll()
+
+	// Listener is closed on stop, so the accepting loop is interrupted.
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-stop:
+			_ = listener.Close()
+		case <-finished:
+		}
+	}()
 
 	// Inform outer scope that initialisation was performed well
 	// and no errors has been occurred.
@@ -73,26 +141,82 @@ func (r *Receiver) Run(host string, port uint16, errors chan<- error) {
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
+			select {
+			case <-stop:
+				r.log().Info("Stopped")
+				return
+			default:
+			}
+
 			errors <- err
 			continue
 		}
 
-		go r.handleConnection(conn, errors)
+		if r.blacklist.IsBlacklisted(remoteHost(conn)) {
+			_ = conn.Close()
+			continue
+		}
+
+		if !r.connections.Add(conn) {
+			continue
+		}
+
+		go func() {
+			defer r.connections.Done(conn)
+			r.handleConnection(conn, errors)
+		}()
 	}
 }
 
+// Stop closes the listener and all accepted connections, and waits until they are handled.
+// Implements common.Subsystem.
+func (r *Receiver) Stop(ctx context.Context) error {
+	return r.lifecycle.Stop(ctx)
+}
+
 func (r *Receiver) handleConnection(conn net.Conn, errors chan<- error) {
 	defer conn.Close()
 
 	reader := bufio.NewReader(conn)
 
+	version, err := serverHandshake(conn, reader, supportedProtocolVersions, settings.ObserversProtocolHandshakeTimeout)
+	if err != nil {
+		r.log().WithFields(log.Fields{
+			"Addressee": conn.RemoteAddr(),
+		}).Error("Protocol handshake failed: ", err)
+
+		errors <- err
+		return
+	}
+
+	if r.inbound != nil {
+		err = r.authenticate(conn, reader)
+		if err != nil {
+			if err == ErrAuthenticationFailed {
+				// Signature of the remote observer does not match the identity it has declared.
+				r.blacklist.Report(remoteHost(conn))
+			}
+
+			r.log().WithFields(log.Fields{
+				"Addressee": conn.RemoteAddr(),
+			}).Error("Remote observer authentication failed: ", err)
+
+			errors <- err
+			return
+		}
+
+		defer r.inbound.removeConnection(conn)
+	}
+
 	for {
-		dataPackage, err := r.receiveDataPackage(reader)
+		dataPackage, err := r.receiveDataPackage(reader, version)
 		if err != nil {
 			if err == io.EOF {
+				// Address of non TCP connection might have no port.
+				host, port, _ := net.SplitHostPort(conn.RemoteAddr().String())
 				r.sendEvent(r.OutgoingEventsConnectionClosed, &EventConnectionClosed{
-					RemoteHost: strings.Split(conn.RemoteAddr().String(), ":")[0],
-					RemotePort: strings.Split(conn.RemoteAddr().String(), ":")[1],
+					RemoteHost: host,
+					RemotePort: port,
 				})
 				return
 			}
@@ -106,7 +230,20 @@ func (r *Receiver) handleConnection(conn net.Conn, errors chan<- error) {
 
 		r.logIngress(len(dataPackage), conn)
 
-		err = r.parseAndRouteData(dataPackage)
+		if len(dataPackage) > 0 && dataPackage[0] == constants.DataTypeRequestPing {
+			// Pong is sent through the same connection (see ping.go),
+			// so the ping is not routed to the core.
+			r.counters.countReceived(constants.DataTypeRequestPing)
+			err = respondToPing(conn, version, dataPackage)
+			if err != nil {
+				errors <- err
+				return
+			}
+
+			continue
+		}
+
+		err = r.processDataPackage(remoteHost(conn), dataPackage)
 		if err != nil {
 			errors <- err
 
@@ -120,35 +257,58 @@ func (r *Receiver) handleConnection(conn net.Conn, errors chan<- error) {
 	}
 }
 
-func (r *Receiver) receiveDataPackage(reader *bufio.Reader) (data []byte, err error) {
-	const kPackageSizeHeaderBytes = 4
-	packageSizeMarshaled := make([]byte, kPackageSizeHeaderBytes, kPackageSizeHeaderBytes)
-	bytesRead, err := reader.Read(packageSizeMarshaled)
+// authenticate challenges the remote observer (see ConnectionsMap.SetAuthenticated())
+// and binds the connection to it.
+func (r *Receiver) authenticate(conn net.Conn, reader io.Reader) (err error) {
+	registry, err := r.registry()
 	if err != nil {
 		return
 	}
-	if bytesRead != kPackageSizeHeaderBytes {
-		return nil, errors.BufferDiscarding
+
+	_, err = r.inbound.SetAuthenticated(
+		conn, reader, r.authenticationKeyStore, registry, settings.ObserversAuthenticationTimeout)
+	return
+}
+
+// processDataPackage drops data packages of the blacklisted observers,
+// and reports the observer to the blacklist in case if the package can't be parsed.
+func (r *Receiver) processDataPackage(host string, data []byte) (err error) {
+	if r.blacklist.IsBlacklisted(host) {
+		return ErrObserverBlacklisted
 	}
 
-	packageSize, err := utils.UnmarshalUint32(packageSizeMarshaled)
-	if err != nil {
-		return
+	err = r.parseAndRouteData(data)
+	if err != nil && err != errors.ChannelTransferringFailed {
+		r.blacklist.Report(host)
 	}
 
-	var offset uint32 = 0
-	data = make([]byte, packageSize, packageSize)
-	for {
-		bytesReceived, err := reader.Read(data[offset:])
-		if err != nil {
-			return nil, err
-		}
+	return
+}
 
-		offset += uint32(bytesReceived)
-		if offset == packageSize {
-			return data, nil
-		}
+// receiveDataPackage reads one frame (see frames.go) from the reader.
+// Frames of the protocol version, other than negotiated one, are rejected (they can't be parsed reliably),
+// as well as the frames, that exceeds settings.ObserversFrameBodyMaxSize.
+func (r *Receiver) receiveDataPackage(reader io.Reader, protocolVersion uint8) (data []byte, err error) {
+	data, err = readFrame(reader, protocolVersion)
+	switch err {
+	case ErrUnsupportedProtocolVersion:
+		r.log().WithFields(log.Fields{
+			"Expected": protocolVersion,
+		}).Error("Message of unsupported protocol version rejected")
+
+	case ErrFrameTooLarge:
+		r.log().WithFields(log.Fields{
+			"MaxSize": settings.ObserversFrameBodyMaxSize,
+		}).Error("Message of too large size rejected")
 	}
+
+	return
+}
+
+// MessagesStats returns amount of messages received from the observers, grouped by the data type.
+// Messages of unexpected types are counted too.
+func (r *Receiver) MessagesStats() MessagesStats {
+	return r.counters.Stats()
 }
 
 func (r *Receiver) parseAndRouteData(data []byte) (err error) {
@@ -211,6 +371,8 @@ func (r *Receiver) parseAndRouteData(data []byte) (err error) {
 		return
 	}
 
+	r.counters.countReceived(uint8(dataTypeHeader))
+
 	switch uint8(dataTypeHeader) {
 
 	// Timer
@@ -244,6 +406,9 @@ func (r *Receiver) parseAndRouteData(data []byte) (err error) {
 	case constants.DataTypeResponseDigestApprove:
 		return processResponse(&responses.CandidateDigestApprove{}, nil)
 
+	case constants.DataTypeResponseDigestReject:
+		return processResponse(&responses.CandidateDigestReject{}, nil)
+
 	case constants.DataTypeRequestBlockSignaturesBroadcast:
 		return processRequest(&requests.BlockSignaturesBroadcast{})
 
@@ -286,3 +451,12 @@ func (r *Receiver) logIngress(bytesReceived int, conn net.Conn) {
 func (r *Receiver) log() *log.Entry {
 	return log.WithFields(log.Fields{"prefix": "Network/Observers/Receiver"})
 }
+
+func remoteHost(conn net.Conn) string {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return conn.RemoteAddr().String()
+	}
+
+	return host
+}
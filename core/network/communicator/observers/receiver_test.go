@@ -0,0 +1,54 @@
+package observers
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// Stop must interrupt the accepting loop and close the connections, that are handled at the moment.
+func TestReceiver_Stop(t *testing.T) {
+	r := NewReceiver(nil)
+
+	errs := make(chan error, 16)
+	finished := make(chan struct{})
+	go func() {
+		r.Run("127.0.0.1", 0, errs)
+		close(finished)
+	}()
+
+	if <-errs != nil {
+		t.Fatal()
+	}
+
+	// Handler waits for the protocol handshake, that would never be sent.
+	local, remote := net.Pipe()
+	defer local.Close()
+
+	if !r.connections.Add(remote) {
+		t.Fatal()
+	}
+	go func() {
+		defer r.connections.Done(remote)
+		r.handleConnection(remote, errs)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	err := r.Stop(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("accepting loop must be stopped")
+	}
+
+	if r.connections.Add(local) {
+		t.Fatal("no connections must be accepted after the stop")
+	}
+}
@@ -1,16 +1,122 @@
 package requests
 
+import (
+	"crypto/rand"
+	"geo-observers-blockchain/core/common"
+	"geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/utils"
+	"time"
+)
+
+const (
+	// Current version of the binary format of the SynchronisationTimeFrames.
+	// Must be incremented on each incompatible change of the format.
+	SynchronisationTimeFramesVersion uint8 = 1
+
+	synchronisationTimeFramesBinarySize = common.Uint16ByteSize + // Observer index.
+		1 + // Version.
+		common.Uint64ByteSize + // Round nonce.
+		common.Uint64ByteSize // Time of sending.
+)
+
 // SynchronisationTimeFrames is used for time frame synchronisation purposes.
 // It is emitted by the observer as a request for information about
 // current state of the ticker on other observers.
 type SynchronisationTimeFrames struct {
 	request
+
+	// Version of the binary format, the request has been received in.
+	Version uint8
+
+	// Random number, that identifies the synchronisation round.
+	Nonce uint64
+
+	// Time of sending of the request (by the clock of the requesting observer).
+	// It is echoed back by the responding observers and is used for round trip time calculation.
+	// Zero time means that the time of sending is unknown.
+	Sent time.Time
 }
 
-func (r *SynchronisationTimeFrames) MarshalBinary() ([]byte, error) {
-	return r.request.MarshalBinary()
+func NewSynchronisationTimeFrames() *SynchronisationTimeFrames {
+	nonce := make([]byte, common.Uint64ByteSize)
+	_, _ = rand.Read(nonce)
+	nonceValue, _ := utils.UnmarshalUint64(nonce)
+
+	return &SynchronisationTimeFrames{
+		Version: SynchronisationTimeFramesVersion,
+		Nonce:   nonceValue,
+		Sent:    time.Now(),
+	}
+}
+
+// Format:
+// 2B - Observer index.
+// 1B - Version of the format.
+// 8B - Round nonce.
+// 8B - Time of sending (unix nanoseconds, 0 if unknown).
+func (r *SynchronisationTimeFrames) MarshalBinary() (data []byte, err error) {
+	requestBinary, err := r.request.MarshalBinary()
+	if err != nil {
+		return
+	}
+
+	sent := uint64(0)
+	if !r.Sent.IsZero() {
+		sent = uint64(r.Sent.UnixNano())
+	}
+
+	return utils.ChainByteSlices(
+		requestBinary,
+		[]byte{SynchronisationTimeFramesVersion},
+		utils.MarshalUint64(r.Nonce),
+		utils.MarshalUint64(sent)), nil
 }
 
-func (r *SynchronisationTimeFrames) UnmarshalBinary(data []byte) error {
-	return r.request.UnmarshalBinary(data)
+// UnmarshalBinary accepts requests without version (observers of previous versions send only observer index).
+// Requests of unknown versions are rejected with errors.UnsupportedVersion.
+func (r *SynchronisationTimeFrames) UnmarshalBinary(data []byte) (err error) {
+	if len(data) < common.Uint16ByteSize {
+		return errors.InvalidDataFormat
+	}
+
+	err = r.request.UnmarshalBinary(data)
+	if err != nil {
+		return
+	}
+
+	r.Version, r.Nonce, r.Sent = 0, 0, time.Time{}
+	if len(data) == common.Uint16ByteSize {
+		return
+	}
+
+	const (
+		offsetVersion = common.Uint16ByteSize
+		offsetNonce   = offsetVersion + 1
+		offsetSent    = offsetNonce + common.Uint64ByteSize
+	)
+
+	if data[offsetVersion] != SynchronisationTimeFramesVersion {
+		return errors.UnsupportedVersion
+	}
+
+	if len(data) != synchronisationTimeFramesBinarySize {
+		return errors.InvalidDataFormat
+	}
+
+	r.Version = data[offsetVersion]
+	r.Nonce, err = utils.UnmarshalUint64(data[offsetNonce:offsetSent])
+	if err != nil {
+		return
+	}
+
+	sent, err := utils.UnmarshalUint64(data[offsetSent:])
+	if err != nil {
+		return
+	}
+
+	if sent != 0 {
+		r.Sent = time.Unix(0, int64(sent))
+	}
+
+	return
 }
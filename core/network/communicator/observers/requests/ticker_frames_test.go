@@ -0,0 +1,64 @@
+package requests
+
+import (
+	"geo-observers-blockchain/core/common/errors"
+	"testing"
+)
+
+func TestSynchronisationTimeFrames_Binary_RoundTrip(t *testing.T) {
+	request := NewSynchronisationTimeFrames()
+	request.SetObserverIndex(7)
+
+	data, err := request.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := &SynchronisationTimeFrames{}
+	err = restored.UnmarshalBinary(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if restored.ObserverIndex() != 7 ||
+		restored.Version != SynchronisationTimeFramesVersion ||
+		restored.Nonce != request.Nonce ||
+		!restored.Sent.Equal(request.Sent) {
+		t.Fatal()
+	}
+
+	// Request without version (from the observers of previous versions).
+	legacy := &SynchronisationTimeFrames{}
+	err = legacy.UnmarshalBinary(data[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if legacy.ObserverIndex() != 7 || legacy.Version != 0 || !legacy.Sent.IsZero() {
+		t.Fatal()
+	}
+}
+
+func TestSynchronisationTimeFrames_Binary_Invalid(t *testing.T) {
+	data, err := NewSynchronisationTimeFrames().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data[2] = SynchronisationTimeFramesVersion + 1
+	err = (&SynchronisationTimeFrames{}).UnmarshalBinary(data)
+	if err != errors.UnsupportedVersion {
+		t.Fatal("unsupported version must be rejected")
+	}
+
+	data[2] = SynchronisationTimeFramesVersion
+	err = (&SynchronisationTimeFrames{}).UnmarshalBinary(data[:len(data)-1])
+	if err != errors.InvalidDataFormat {
+		t.Fatal()
+	}
+
+	err = (&SynchronisationTimeFrames{}).UnmarshalBinary(data[:1])
+	if err != errors.InvalidDataFormat {
+		t.Fatal()
+	}
+}
@@ -2,6 +2,8 @@ package responses
 
 import (
 	"geo-observers-blockchain/core/common"
+	"geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/common/types/hash"
 	"geo-observers-blockchain/core/crypto/ecdsa"
 	"geo-observers-blockchain/core/network/communicator/observers/requests"
 	"geo-observers-blockchain/core/utils"
@@ -52,3 +54,53 @@ func (r *CandidateDigestApprove) UnmarshalBinary(data []byte) (err error) {
 
 	return r.Signature.UnmarshalBinary(data[common.Uint16ByteSize:])
 }
+
+// CandidateDigestReject is emitted by the observer in case when it can't match the next block candidate digest,
+// because some claims, referenced by the digest, are absent in it's pool.
+// The proposer is expected to respond with the missing claims.
+// Claims are referenced by their hashes (the same as in digest),
+// because the approver has no other info about the claims it lacks.
+type CandidateDigestReject struct {
+	*response
+
+	MissingClaimsHashes hash.List
+}
+
+func NewCandidateDigestReject(
+	r requests.Request, observerNumber uint16,
+	missingClaimsHashes []hash.SHA256Container) *CandidateDigestReject {
+
+	return &CandidateDigestReject{
+		response:            newResponse(r, observerNumber),
+		MissingClaimsHashes: hash.List{At: missingClaimsHashes},
+	}
+}
+
+func (r *CandidateDigestReject) MarshalBinary() (data []byte, err error) {
+	responseBinary, err := r.response.MarshalBinary()
+	if err != nil {
+		return
+	}
+
+	hashesBinary, err := r.MissingClaimsHashes.MarshalBinary()
+	if err != nil {
+		return
+	}
+
+	data = utils.ChainByteSlices(responseBinary, hashesBinary)
+	return
+}
+
+func (r *CandidateDigestReject) UnmarshalBinary(data []byte) (err error) {
+	if len(data) < common.Uint16ByteSize*2 {
+		return errors.InvalidDataFormat
+	}
+
+	r.response = &response{}
+	err = r.response.UnmarshalBinary(data[:common.Uint16ByteSize])
+	if err != nil {
+		return
+	}
+
+	return r.MissingClaimsHashes.UnmarshalBinary(data[common.Uint16ByteSize:])
+}
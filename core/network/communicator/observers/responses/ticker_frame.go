@@ -1,7 +1,11 @@
 package responses
 
 import (
+	"encoding/json"
+	"fmt"
+	"geo-observers-blockchain/core/common/errors"
 	"geo-observers-blockchain/core/network/communicator/observers/requests"
+	"geo-observers-blockchain/core/settings"
 	"geo-observers-blockchain/core/utils"
 	"time"
 )
@@ -13,26 +17,156 @@ type TimeFrame struct {
 	NanosecondsLeft uint64
 	Received        time.Time
 
+	// Time of sending of the synchronisation request, echoed back from the request.
+	// It is set by the clock of the requesting observer, so together with Received
+	// it gives the round trip time of the request.
+	// Zero time means that the time of sending is unknown (e.g. response from the observer of previous version).
+	RequestSent time.Time
+
+	// Smoothed round trip time of the connection to the remote observer, that has sent the response
+	// (see observers.ConnectionWrapper.RoundTripTime()). Is not transferred: it is set by the ticker
+	// of the receiving observer before the frames consensus. Zero means that the round trip time is unknown.
+	ObserverRoundTripTime time.Duration
+
 	// todo: add observers configuration hash
 	// todo: add observer signature to prevent data obfuscation
 }
 
 func NewTimeFrame(r requests.Request, observerIndex, index uint16, nanosecondsLeft uint64) *TimeFrame {
-	return &TimeFrame{
+	frame := &TimeFrame{
 		response:        newResponse(r, observerIndex),
 		FrameIndex:      index,
 		NanosecondsLeft: nanosecondsLeft,
 	}
+
+	request, ok := r.(*requests.SynchronisationTimeFrames)
+	if ok && request != nil {
+		frame.RequestSent = request.Sent
+	}
+
+	return frame
+}
+
+// NewValidatedTimeFrame creates time frame response and checks that all it's fields are in legitimate ranges.
+// It is intended for building responses in tests and tools (replay, simulation, etc).
+func NewValidatedTimeFrame(
+	observerIndex, index uint16, nanosecondsLeft uint64, received time.Time) (r *TimeFrame, err error) {
+
+	r = NewTimeFrame(nil, observerIndex, index, nanosecondsLeft)
+	r.Received = received
+
+	err = r.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// Validate checks that observer index and frame index are in range of the observers count,
+// and that time left to the next frame does not exceed two block generation time ranges
+// (observer adds one extra range in case if frame is about to close).
+func (r *TimeFrame) Validate() error {
+	if r.response == nil ||
+		int(r.ObserverIndex()) >= settings.ObserversMaxCount ||
+		int(r.FrameIndex) >= settings.ObserversMaxCount {
+		return errors.InvalidParameter
+	}
+
+	if r.NanosecondsLeft > uint64(settings.AverageBlockGenerationTimeRange.Nanoseconds()*2) {
+		return errors.InvalidParameter
+	}
+
+	return nil
+}
+
+func (r *TimeFrame) String() string {
+	observerIndex := uint16(0)
+	if r.response != nil {
+		observerIndex = r.ObserverIndex()
+	}
+
+	return fmt.Sprintf(
+		"TimeFrame{Observer: %d, FrameIndex: %d, TimeLeft: %s, Received: %s}",
+		observerIndex, r.FrameIndex, time.Duration(r.NanosecondsLeft), r.Received.Format(time.RFC3339Nano))
+}
+
+type timeFrameJSON struct {
+	ObserverIndex   uint16    `json:"observer"`
+	FrameIndex      uint16    `json:"frame"`
+	NanosecondsLeft uint64    `json:"nanoseconds_left"`
+	Received        time.Time `json:"received"`
+}
+
+func (r *TimeFrame) MarshalJSON() ([]byte, error) {
+	observerIndex := uint16(0)
+	if r.response != nil {
+		observerIndex = r.ObserverIndex()
+	}
+
+	return json.Marshal(&timeFrameJSON{
+		ObserverIndex:   observerIndex,
+		FrameIndex:      r.FrameIndex,
+		NanosecondsLeft: r.NanosecondsLeft,
+		Received:        r.Received,
+	})
+}
+
+// UnmarshalJSON restores the response and validates it.
+// Original request is not a part of JSON representation.
+func (r *TimeFrame) UnmarshalJSON(data []byte) (err error) {
+	decoded := &timeFrameJSON{}
+	err = json.Unmarshal(data, decoded)
+	if err != nil {
+		return
+	}
+
+	restored, err := NewValidatedTimeFrame(
+		decoded.ObserverIndex, decoded.FrameIndex, decoded.NanosecondsLeft, decoded.Received)
+	if err != nil {
+		return
+	}
+
+	*r = *restored
+	return
 }
 
 func (r *TimeFrame) Request() requests.Request {
 	return r.request
 }
 
+// HasObserverIndex returns true if the index of the observer, that has sent the response, is known
+// (responses of the observers of previous versions does not contain it).
+func (r *TimeFrame) HasObserverIndex() bool {
+	return r.response != nil
+}
+
+// RoundTripTime returns the time between sending of the request and receiving of the response.
+// Returns false in case if the time of sending or the time of receiving is unknown.
+func (r *TimeFrame) RoundTripTime() (rtt time.Duration, isKnown bool) {
+	if r.RequestSent.IsZero() || r.Received.IsZero() || r.Received.Before(r.RequestSent) {
+		return 0, false
+	}
+
+	return r.Received.Sub(r.RequestSent), true
+}
+
 func (r *TimeFrame) MarshalBinary() ([]byte, error) {
+	requestSent := uint64(0)
+	if !r.RequestSent.IsZero() {
+		requestSent = uint64(r.RequestSent.UnixNano())
+	}
+
+	observerIndex := uint16(0)
+	if r.response != nil {
+		observerIndex = r.ObserverIndex()
+	}
+
 	return utils.ChainByteSlices(
 		utils.MarshalUint16(r.FrameIndex),
-		utils.MarshalUint64(r.NanosecondsLeft)), nil
+		utils.MarshalUint64(r.NanosecondsLeft),
+		utils.MarshalUint64(requestSent),
+		utils.MarshalUint16(observerIndex)), nil
 }
 
 func (r *TimeFrame) UnmarshalBinary(data []byte) (err error) {
@@ -46,5 +180,33 @@ func (r *TimeFrame) UnmarshalBinary(data []byte) (err error) {
 		return
 	}
 
+	// Observers of previous versions does not echo the time of sending of the request.
+	r.RequestSent = time.Time{}
+	if len(data) < 18 {
+		return nil
+	}
+
+	requestSent, err := utils.UnmarshalUint64(data[10:18])
+	if err != nil {
+		return
+	}
+
+	if requestSent != 0 {
+		r.RequestSent = time.Unix(0, int64(requestSent))
+	}
+
+	// Observers of previous versions does not report their index.
+	// Index is reported by the remote observer itself, so it is used only as a hint
+	// (see ObserverRoundTripTime), and never as an identity.
+	if len(data) < 20 {
+		return nil
+	}
+
+	observerIndex, err := utils.UnmarshalUint16(data[18:20])
+	if err != nil {
+		return
+	}
+
+	r.response = newResponse(nil, observerIndex)
 	return nil
 }
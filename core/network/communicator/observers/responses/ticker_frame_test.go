@@ -0,0 +1,119 @@
+package responses
+
+import (
+	"encoding/json"
+	"geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/network/communicator/observers/requests"
+	"geo-observers-blockchain/core/settings"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewValidatedTimeFrame(t *testing.T) {
+	received := time.Now()
+	frame, err := NewValidatedTimeFrame(1, 2, uint64(time.Second), received)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if frame.ObserverIndex() != 1 || frame.FrameIndex != 2 ||
+		frame.NanosecondsLeft != uint64(time.Second) || !frame.Received.Equal(received) {
+		t.Fatal()
+	}
+
+	if !strings.Contains(frame.String(), "FrameIndex: 2") {
+		t.Fatal()
+	}
+}
+
+func TestNewValidatedTimeFrame_Invalid(t *testing.T) {
+	_, err := NewValidatedTimeFrame(uint16(settings.ObserversMaxCount), 0, 0, time.Now())
+	if err != errors.InvalidParameter {
+		t.Fatal("observer index out of range must be rejected")
+	}
+
+	_, err = NewValidatedTimeFrame(0, math.MaxUint16, 0, time.Now())
+	if err != errors.InvalidParameter {
+		t.Fatal("frame index out of range must be rejected")
+	}
+
+	_, err = NewValidatedTimeFrame(0, 0, math.MaxUint64, time.Now())
+	if err != errors.InvalidParameter {
+		t.Fatal("time left out of range must be rejected")
+	}
+}
+
+func TestTimeFrame_JSON_RoundTrip(t *testing.T) {
+	frame, err := NewValidatedTimeFrame(3, 1, uint64(time.Millisecond*1500), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := &TimeFrame{}
+	err = json.Unmarshal(data, restored)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if restored.ObserverIndex() != frame.ObserverIndex() ||
+		restored.FrameIndex != frame.FrameIndex ||
+		restored.NanosecondsLeft != frame.NanosecondsLeft ||
+		!restored.Received.Equal(frame.Received) {
+		t.Fatal("restored response differs from the original one")
+	}
+}
+
+func TestTimeFrame_UnmarshalJSON_Invalid(t *testing.T) {
+	restored := &TimeFrame{}
+	err := json.Unmarshal([]byte(`{"observer": 0, "frame": 65535}`), restored)
+	if err != errors.InvalidParameter {
+		t.Fatal()
+	}
+}
+
+// Checks that the time of sending of the request is echoed in the response and survives binary round trip,
+// and that the responses without it (from observers of previous versions) are still accepted.
+func TestTimeFrame_Binary_RequestSent(t *testing.T) {
+	request := requests.NewSynchronisationTimeFrames()
+	response := NewTimeFrame(request, 1, 2, 3)
+	if !response.RequestSent.Equal(request.Sent) {
+		t.Fatal()
+	}
+
+	data, err := response.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := &TimeFrame{}
+	err = restored.UnmarshalBinary(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !restored.RequestSent.Equal(request.Sent) || restored.FrameIndex != 2 || restored.NanosecondsLeft != 3 {
+		t.Fatal()
+	}
+
+	if !restored.HasObserverIndex() || restored.ObserverIndex() != 1 {
+		t.Fatal("index of the observer must be transferred")
+	}
+
+	legacy := &TimeFrame{}
+	err = legacy.UnmarshalBinary(data[:10])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, isKnown := legacy.RoundTripTime()
+	if !legacy.RequestSent.IsZero() || isKnown || legacy.HasObserverIndex() {
+		t.Fatal()
+	}
+}
@@ -1,8 +1,12 @@
 package observers
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
+	"geo-observers-blockchain/core/common"
 	errors2 "geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/crypto/keystore"
 	"geo-observers-blockchain/core/geo"
 	"geo-observers-blockchain/core/network/communicator/observers/constants"
 	"geo-observers-blockchain/core/network/communicator/observers/requests"
@@ -33,32 +37,89 @@ type Sender struct {
 	OutgoingRequests  chan requests.Request
 	OutgoingResponses chan responses.Response
 	IncomingEvents    chan interface{}
-	reporter          *external.Reporter
+	reporter          configurationReporter
 	connections       *ConnectionsMap
+
+	// Controls stopping of the sending loop (see Stop()).
+	lifecycle common.Lifecycle
+
+	// Messages sent, grouped by the data type.
+	counters MessagesCounters
+
+	// No connections are established to the blacklisted observers.
+	blacklist *Blacklist
+
+	// Establishes connections to the remote observers (plain TCP by default, see EnableTLS()).
+	dialer func(*external.Observer) (net.Conn, error)
+}
+
+// configurationReporter is implemented by external.Reporter.
+type configurationReporter interface {
+	GetCurrentConfiguration() (*external.Configuration, error)
+	GetCurrentObserverIndex() (uint16, error)
 }
 
-func NewSender(observersConfReporter *external.Reporter) *Sender {
+func NewSender(observersConfReporter *external.Reporter, blacklist *Blacklist) *Sender {
+	connections := NewConnectionsMap(time.Minute * 10)
+	connections.EnableHandshake(settings.ObserversProtocolHandshakeTimeout)
+
+	// Nil reporter must not be wrapped into the (non nil) interface.
+	var reporter configurationReporter
+	if observersConfReporter != nil {
+		reporter = observersConfReporter
+	}
+
 	return &Sender{
 		OutgoingRequests:  make(chan requests.Request, 16),
 		OutgoingResponses: make(chan responses.Response, 16),
 		IncomingEvents:    make(chan interface{}, 1),
-		reporter:          observersConfReporter,
-		connections:       NewConnectionsMap(time.Minute * 10),
+		reporter:          reporter,
+		connections:       connections,
+		blacklist:         blacklist,
 	}
 }
 
+// EnableTLS makes the sender to establish mutual TLS connections to the remote observers
+// (see tls.go). Must be called before Run().
+func (s *Sender) EnableTLS(certificate tls.Certificate) {
+	s.dialer = TLSDialer(certificate, settings.ObserversConnectionDialTimeout)
+}
+
+// EnableAuthentication makes the sender to prove identity of this observer
+// on each connection to the remote observers (see ConnectionsMap.EnableAuthentication()).
+// Must be called before Run().
+func (s *Sender) EnableAuthentication(ks *keystore.KeyStore) {
+	s.connections.EnableAuthentication(ks, func() (index uint16, err error) {
+		conf, err := s.reporter.GetCurrentConfiguration()
+		if err != nil {
+			return
+		}
+
+		return conf.CurrentObserverIndex, nil
+	}, settings.ObserversAuthenticationTimeout)
+}
+
 func (s *Sender) Run(host string, port uint16, errors chan<- error) {
+	s.applyCurrentConfiguration()
+
 	// Report Ok
 	errors <- nil
 	s.log().Info("Started")
 
+	s.connections.StartAutoFlush(settings.ObserversConnectionAutoFlushInterval)
+	s.connections.StartAutoPing(settings.ObserversPingInterval)
 	s.waitAndSendInfo(errors)
 }
 
 func (s *Sender) waitAndSendInfo(errors chan<- error) {
+	stop := s.lifecycle.Start()
+	defer s.lifecycle.Done()
 
-	processSending := func() {
+	processSending := func() (stopped bool) {
 		select {
+		case <-stop:
+			return true
+
 		case request := <-s.OutgoingRequests:
 			s.processRequestSending(request, errors)
 
@@ -68,11 +129,83 @@ func (s *Sender) waitAndSendInfo(errors chan<- error) {
 		case event := <-s.IncomingEvents:
 			s.processIncomingEvent(event, errors)
 		}
+
+		return false
 	}
 
 	for {
-		processSending()
+		if processSending() {
+			s.log().Info("Stopped")
+			return
+		}
+	}
+}
+
+// Stop interrupts sending loop and closes all connections to the remote observers.
+// Connections are closed even if the sending loop has not been stopped in time.
+// Implements common.Subsystem.
+func (s *Sender) Stop(ctx context.Context) (err error) {
+	err = s.lifecycle.Stop(ctx)
+
+	e := s.connections.Stop(ctx)
+	if err == nil {
+		err = e
+	}
+
+	return
+}
+
+// MessagesStats returns amount of messages sent to the observers, grouped by the data type.
+// Message sent to several observers is counted once per each observer.
+func (s *Sender) MessagesStats() MessagesStats {
+	return s.counters.Stats()
+}
+
+// RoundTripTimeByIndex returns smoothed round trip time of the connection to the observer
+// with the index specified (see ConnectionsMap.RoundTripTimeByIndex()).
+// It is safe to call this method from any goroutine.
+func (s *Sender) RoundTripTimeByIndex(index uint16) (rtt time.Duration, isKnown bool) {
+	return s.connections.RoundTripTimeByIndex(index)
+}
+
+// MissingObservers returns observers of the configuration, to which there are no open connections.
+// The current observer is never connected to itself, so it is not reported.
+// It is safe to call this method from any goroutine.
+func (s *Sender) MissingObservers(conf *external.Configuration) []*external.Observer {
+	if conf == nil {
+		return nil
+	}
+
+	return s.connections.MissingObservers(remoteObservers(conf))
+}
+
+// remoteObservers returns observers of the configuration except the current one.
+func remoteObservers(conf *external.Configuration) []*external.Observer {
+	remote := make([]*external.Observer, 0, len(conf.Observers))
+	for i, observer := range conf.Observers {
+		if uint16(i) != conf.CurrentObserverIndex {
+			remote = append(remote, observer)
+		}
+	}
+
+	return remote
+}
+
+// applyCurrentConfiguration makes the connections map aware of the current observers configuration,
+// so the connections are available by the observers indexes (see ConnectionsMap.GetByIndex()
+// and RoundTripTimeByIndex()). Further changes are applied on EventConfigurationChanged.
+func (s *Sender) applyCurrentConfiguration() {
+	if s.reporter == nil {
+		return
 	}
+
+	conf, err := s.reporter.GetCurrentConfiguration()
+	if err != nil {
+		s.log().Warn("Can't receive current observers configuration: ", err)
+		return
+	}
+
+	s.connections.ReconcileWithConfiguration(conf.Observers)
 }
 
 // todo: remove global errors flow
@@ -206,6 +339,11 @@ func (s *Sender) processResponseSending(response responses.Response, errors chan
 			constants.StreamTypeResponseDigestApprove,
 			[]uint16{response.Request().ObserverIndex()})
 
+	case *responses.CandidateDigestReject:
+		send(
+			constants.StreamTypeResponseDigestReject,
+			[]uint16{response.Request().ObserverIndex()})
+
 	case *responses.ChainTop:
 		send(
 			constants.StreamTypeResponseChainTop,
@@ -228,6 +366,13 @@ func (s *Sender) processIncomingEvent(event interface{}, errors chan<- error) {
 		{
 			s.connections.DeleteByRemoteHost(event.(*EventConnectionClosed).RemoteHost)
 		}
+
+	case *external.EventConfigurationChanged:
+		{
+			// Connections to the observers, that has left the configuration, must be closed.
+			s.connections.ReconcileWithConfiguration(
+				event.(*external.EventConfigurationChanged).Configuration.Observers)
+		}
 	}
 }
 
@@ -286,39 +431,14 @@ func (s *Sender) sendDataToObservers(data []byte, observersIndexes []uint16, err
 
 func (s *Sender) sendDataToObserver(observer *external.Observer, data []byte) (err error) {
 	send := func(conn *ConnectionWrapper, data []byte) (err error) {
-		// Writing data total size.
-		dataSize := len(data)
-		dataSizeMarshaled := utils.MarshalUint32(uint32(dataSize))
-		bytesWritten, err := conn.Writer.Write(dataSizeMarshaled)
-		if err != nil {
-			return
-		}
-		if bytesWritten != len(dataSizeMarshaled) {
-			err = ErrPartialWriteOccurred
-			return
-		}
-
-		// todo: split the data to chunks of 2-4Kb in size
-		// todo: send them sequentially.
-
-		// Writing data.
-		//conn.Connection.(net.TCPConn).SetNoDelay(true)
-		bytesWritten, err = conn.Writer.Write(data)
-		if err != nil {
-			return
-		}
-
-		// todo: resend the rest (enhancement).
-		if bytesWritten != len(data) {
-			err = ErrPartialWriteOccurred
-			return
-		}
-
-		err = conn.Writer.Flush()
+		// Data is written to the connection by the connection's writer goroutine,
+		// so the messages, sent concurrently to the same observer, are never interleaved.
+		err = conn.Enqueue(data)
 		if err != nil {
 			return
 		}
 
+		s.counters.countSent(data)
 		s.logEgress(len(data), conn.Connection)
 		return nil
 	}
@@ -337,27 +457,21 @@ func (s *Sender) sendDataToObserver(observer *external.Observer, data []byte) (e
 		}
 	}
 
-	conn, err := s.connections.Get(observer)
-	if err != nil {
-
-		switch err {
-		case ErrNoObserver:
-			{
-				// In case if there is no connection to observer - it should be created.
-				conn, err = s.connectToObserver(observer)
-				if err != nil {
-					return
-				}
-			}
+	if s.blacklist.IsBlacklisted(observer.Host) {
+		return ErrObserverBlacklisted
+	}
 
-		default:
-			return
-		}
+	// In case if there is no connection to observer - it should be created.
+	conn, err := s.connections.GetOrDial(observer, s.dialObserver)
+	if err != nil {
+		return
 	}
 
 	err = send(conn, data)
-	if err != nil {
-		conn, err = s.connectToObserver(observer)
+	if err == ErrConnectionIsClosed {
+		// Previous write to the connection has failed.
+		// Connection should be established once more.
+		conn, err = s.connections.GetOrDial(observer, s.dialObserver)
 		if err != nil {
 			return
 		}
@@ -368,8 +482,13 @@ func (s *Sender) sendDataToObserver(observer *external.Observer, data []byte) (e
 	return
 }
 
-func (s *Sender) connectToObserver(o *external.Observer) (connection *ConnectionWrapper, err error) {
-	conn, err := net.Dial("tcp", fmt.Sprint(o.Host, ":", o.Port))
+func (s *Sender) dialObserver(o *external.Observer) (conn net.Conn, err error) {
+	if s.dialer != nil {
+		conn, err = s.dialer(o)
+	} else {
+		conn, err = net.DialTimeout("tcp", fmt.Sprint(o.Host, ":", o.Port), settings.ObserversConnectionDialTimeout)
+	}
+
 	if err != nil {
 		// No connection is possible to some of observers.
 		// Connection error would be reported, but it would not contain any connection details,
@@ -381,7 +500,7 @@ func (s *Sender) connectToObserver(o *external.Observer) (connection *Connection
 			s.log().WithFields(log.Fields{
 				"Host": o.Host,
 				"Port": o.Port,
-			}).Warn("Remote obs. connection refused")
+			}).Warn("Remote obs. connection refused: ", err)
 		}
 
 		return nil, ErrObserverConnectionRefused
@@ -394,8 +513,7 @@ func (s *Sender) connectToObserver(o *external.Observer) (connection *Connection
 		}).Info("Connected to remote observer.")
 	}
 
-	s.connections.Set(o, conn)
-	return s.connections.Get(o)
+	return
 }
 
 func (s *Sender) logEgress(bytesSent int, conn net.Conn) {
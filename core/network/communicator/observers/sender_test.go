@@ -0,0 +1,85 @@
+package observers
+
+import (
+	"context"
+	"geo-observers-blockchain/core/network/external"
+	"net"
+	"testing"
+	"time"
+)
+
+type testConfigurationReporter struct {
+	conf *external.Configuration
+}
+
+func (r *testConfigurationReporter) GetCurrentConfiguration() (*external.Configuration, error) {
+	return r.conf, nil
+}
+
+func (r *testConfigurationReporter) GetCurrentObserverIndex() (uint16, error) {
+	return r.conf.CurrentObserverIndex, nil
+}
+
+// Connections of the running sender must be available by the observers indexes of the current configuration
+// right after the start, without any configuration change event.
+func TestSender_Run_CurrentConfiguration(t *testing.T) {
+	observers := []*external.Observer{
+		external.NewObserver("127.0.0.1", 3000, nil),
+		external.NewObserver("127.0.0.1", 3001, nil),
+	}
+
+	sender := NewSender(nil, nil)
+	sender.reporter = &testConfigurationReporter{conf: external.NewConfiguration(0, observers)}
+
+	errs := make(chan error, 1)
+	go sender.Run("127.0.0.1", 3000, errs)
+	if <-errs != nil {
+		t.Fatal()
+	}
+	defer sender.Stop(context.Background())
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+	sender.connections.Set(observers[1], local)
+
+	w, err := sender.connections.GetByIndex(1)
+	if err != nil {
+		t.Fatal("connection must be available by the observer's index: ", err)
+	}
+
+	w.recordRoundTripTime(time.Millisecond * 20)
+	rtt, isKnown := sender.RoundTripTimeByIndex(1)
+	if !isKnown || rtt != time.Millisecond*20 {
+		t.Fatal("round trip time must be reported by the observer's index")
+	}
+}
+
+// Connections must be closed on stop even if the sending loop has not been stopped in time.
+func TestSender_Stop_ClosesConnectionsOnTimeout(t *testing.T) {
+	sender := NewSender(nil, nil)
+
+	// Sending loop is considered as started, but never finishes.
+	sender.lifecycle.Start()
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	observer := external.NewObserver("127.0.0.1", 3000, nil)
+	sender.connections.Set(observer, local)
+	w, err := sender.connections.Get(observer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	err = sender.Stop(ctx)
+	if err != context.DeadlineExceeded {
+		t.Fatal(err)
+	}
+
+	if !waitClosed(w) {
+		t.Fatal("connection must be closed")
+	}
+}
@@ -0,0 +1,66 @@
+package observers
+
+import (
+	"expvar"
+	"sync/atomic"
+)
+
+// MessagesCounters tracks amount of messages sent and received, grouped by the data type
+// (see constants.DataType*). Data type is the first byte of each message.
+// It is safe to use counters from several goroutines. Zero value is ready to use.
+type MessagesCounters struct {
+	sent     [256]uint64
+	received [256]uint64
+}
+
+// MessagesStats is a snapshot of the messages counters.
+// Only data types with at least one message are present.
+type MessagesStats struct {
+	Sent     map[uint8]uint64 `json:"sent"`
+	Received map[uint8]uint64 `json:"received"`
+}
+
+func (c *MessagesCounters) Stats() MessagesStats {
+	return MessagesStats{
+		Sent:     snapshotCounters(&c.sent),
+		Received: snapshotCounters(&c.received),
+	}
+}
+
+func (c *MessagesCounters) countSent(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+
+	atomic.AddUint64(&c.sent[data[0]], 1)
+	atomic.AddUint64(&totalMessagesCounters.sent[data[0]], 1)
+}
+
+func (c *MessagesCounters) countReceived(dataType uint8) {
+	atomic.AddUint64(&c.received[dataType], 1)
+	atomic.AddUint64(&totalMessagesCounters.received[dataType], 1)
+}
+
+func snapshotCounters(counters *[256]uint64) (snapshot map[uint8]uint64) {
+	snapshot = make(map[uint8]uint64)
+	for dataType := range counters {
+		count := atomic.LoadUint64(&counters[dataType])
+		if count > 0 {
+			snapshot[uint8(dataType)] = count
+		}
+	}
+
+	return
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+// Counters of all senders and receivers of the process.
+// They are exported as metrics via expvar ("/debug/vars", "observers_messages").
+var totalMessagesCounters = &MessagesCounters{}
+
+func init() {
+	expvar.Publish("observers_messages", expvar.Func(func() interface{} {
+		return totalMessagesCounters.Stats()
+	}))
+}
@@ -0,0 +1,109 @@
+package observers
+
+import (
+	"context"
+	"geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/network/communicator/observers/constants"
+	"geo-observers-blockchain/core/network/external"
+	"geo-observers-blockchain/core/settings"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Sends several messages of different types to the local listener
+// and checks that sender's counters reflect them (as well as the process wide ones).
+func TestSender_MessagesStats(t *testing.T) {
+	if settings.Conf == nil {
+		settings.Conf = &settings.Settings{}
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go io.Copy(ioutil.Discard, conn)
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	observer := external.NewObserver("127.0.0.1", uint16(portNumber), nil)
+
+	sender := &Sender{connections: NewConnectionsMap(time.Minute)}
+	defer sender.connections.Stop(context.Background())
+
+	totalBefore := totalMessagesCounters.Stats()
+	messages := [][]byte{
+		constants.StreamTypeRequestClaimBroadcast,
+		constants.StreamTypeRequestClaimBroadcast,
+		constants.StreamTypeRequestClaimBroadcast,
+		constants.StreamTypeRequestTimeFrames,
+		constants.StreamTypeRequestDigestBroadcast,
+	}
+
+	for _, streamType := range messages {
+		err = sender.sendDataToObserver(observer, markAs([]byte{0, 0}, streamType))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats := sender.MessagesStats()
+	if len(stats.Sent) != 3 || len(stats.Received) != 0 ||
+		stats.Sent[constants.DataTypeRequestClaimBroadcast] != 3 ||
+		stats.Sent[constants.DataTypeRequestTimeFrames] != 1 ||
+		stats.Sent[constants.DataTypeRequestDigestBroadcast] != 1 {
+		t.Fatal("invalid sent messages counters")
+	}
+
+	totalAfter := totalMessagesCounters.Stats()
+	claimsSent := totalAfter.Sent[constants.DataTypeRequestClaimBroadcast] -
+		totalBefore.Sent[constants.DataTypeRequestClaimBroadcast]
+	if claimsSent != 3 {
+		t.Fatal("process wide counters must be updated too")
+	}
+}
+
+// Routes several messages of different types (including unexpected one)
+// and checks that receiver's counters reflect them.
+func TestReceiver_MessagesStats(t *testing.T) {
+	receiver := NewReceiver(nil)
+	messages := [][]byte{
+		markAs([]byte{0, 0}, constants.StreamTypeRequestTimeFrames),
+		markAs([]byte{0, 0}, constants.StreamTypeRequestTimeFrames),
+		markAs([]byte{0, 0}, constants.StreamTypeRequestTimeFrameCollision),
+	}
+
+	for _, data := range messages {
+		err := receiver.parseAndRouteData(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := receiver.parseAndRouteData([]byte{250, 0, 0})
+	if err != errors.UnexpectedDataType {
+		t.Fatal()
+	}
+
+	stats := receiver.MessagesStats()
+	if len(stats.Sent) != 0 || len(stats.Received) != 3 ||
+		stats.Received[constants.DataTypeRequestTimeFrames] != 2 ||
+		stats.Received[constants.DataTypeRequestTimeFrameCollision] != 1 ||
+		stats.Received[250] != 1 {
+		t.Fatal("invalid received messages counters")
+	}
+}
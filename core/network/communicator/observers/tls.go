@@ -0,0 +1,108 @@
+package observers
+
+import (
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"geo-observers-blockchain/core/network/external"
+	"geo-observers-blockchain/core/utils"
+	"net"
+	"time"
+)
+
+// Connections between observers might be protected with mutual TLS.
+// Each observer presents self-signed certificate of it's own key (see keystore.TLSCertificate()).
+// Certificates are not verified against any authority:
+// instead, the public key of the certificate is compared with the key, registered in the observers configuration.
+
+var (
+	ErrPeerCertificateAbsent  = utils.Error("tls", "peer has not presented a certificate")
+	ErrPeerPubKeyMismatch     = utils.Error("tls", "peer certificate does not match observer's public key")
+	ErrPeerPubKeyUnknown      = utils.Error("tls", "peer certificate does not belong to any known observer")
+	ErrObserverPubKeyAbsent   = utils.Error("tls", "observer has no public key registered")
+	ErrUnsupportedPeerKeyType = utils.Error("tls", "peer certificate key is not an ECDSA key")
+)
+
+// ClientTLSConfig returns configuration of the outgoing connection to the observer with the public key specified.
+// Connection is rejected in case if the remote side presents certificate of other key.
+func ClientTLSConfig(certificate tls.Certificate, peerPubKey *ecdsa.PublicKey) *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+
+		// Chain verification is replaced by the public key check (see VerifyPeerCertificate).
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifyPeerCertificate(rawCerts, func(pubKey *ecdsa.PublicKey) error {
+				if !isEqualPubKey(pubKey, peerPubKey) {
+					return ErrPeerPubKeyMismatch
+				}
+
+				return nil
+			})
+		},
+	}
+}
+
+// ServerTLSConfig returns configuration of the incoming connections.
+// Remote side must present certificate of the key, for which "isKnownPeer" returns true.
+func ServerTLSConfig(certificate tls.Certificate, isKnownPeer func(*ecdsa.PublicKey) bool) *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+
+		// Chain verification is replaced by the public key check (see VerifyPeerCertificate).
+		ClientAuth: tls.RequireAnyClientCert,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifyPeerCertificate(rawCerts, func(pubKey *ecdsa.PublicKey) error {
+				if !isKnownPeer(pubKey) {
+					return ErrPeerPubKeyUnknown
+				}
+
+				return nil
+			})
+		},
+	}
+}
+
+// TLSDialer returns dialer (see ConnectionsMap.GetOrDial()),
+// that establishes TLS connection to the observer and checks it's public key.
+func TLSDialer(certificate tls.Certificate, timeout time.Duration) func(*external.Observer) (net.Conn, error) {
+	return func(observer *external.Observer) (conn net.Conn, err error) {
+		if observer.PubKey == nil {
+			return nil, ErrObserverPubKeyAbsent
+		}
+
+		dialer := &net.Dialer{Timeout: timeout}
+		return tls.DialWithDialer(
+			dialer, "tcp", fmt.Sprint(observer.Host, ":", observer.Port),
+			ClientTLSConfig(certificate, observer.PubKey))
+	}
+}
+
+func verifyPeerCertificate(rawCerts [][]byte, check func(*ecdsa.PublicKey) error) (err error) {
+	if len(rawCerts) == 0 {
+		return ErrPeerCertificateAbsent
+	}
+
+	certificate, err := x509.ParseCertificate(rawCerts[0])
+	if err != nil {
+		return
+	}
+
+	pubKey, isECDSA := certificate.PublicKey.(*ecdsa.PublicKey)
+	if !isECDSA {
+		return ErrUnsupportedPeerKeyType
+	}
+
+	return check(pubKey)
+}
+
+func isEqualPubKey(a, b *ecdsa.PublicKey) bool {
+	if a == nil || b == nil || a.X == nil || a.Y == nil || b.X == nil || b.Y == nil {
+		return false
+	}
+
+	return a.X.Cmp(b.X) == 0 && a.Y.Cmp(b.Y) == 0
+}
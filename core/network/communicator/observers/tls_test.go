@@ -0,0 +1,158 @@
+package observers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/tls"
+	"geo-observers-blockchain/core/crypto/keystore"
+	"geo-observers-blockchain/core/network/external"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newTestTLSIdentity generates observer's key and returns it's keystore and self-signed certificate.
+func newTestTLSIdentity(t *testing.T) (*keystore.KeyStore, tls.Certificate) {
+	k, err := keystore.NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certificate, err := k.TLSCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return k, certificate
+}
+
+// startTestTLSListener accepts TLS connections and reports results of the handshakes.
+// Data of the accepted connections is echoed back.
+func startTestTLSListener(t *testing.T, config *tls.Config) (listener net.Listener, handshakes chan error) {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handshakes = make(chan error, 8)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				err := conn.(*tls.Conn).Handshake()
+				handshakes <- err
+				if err == nil {
+					io.Copy(conn, conn)
+				}
+			}()
+		}
+	}()
+
+	return
+}
+
+func testObserverOf(t *testing.T, listener net.Listener, pubKey *ecdsa.PublicKey) *external.Observer {
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return external.NewObserver("127.0.0.1", uint16(portNumber), pubKey)
+}
+
+// Establishes mutual TLS connection between two known observers and sends the data through it.
+func TestTLSDialer_MutualAuthentication(t *testing.T) {
+	serverKeys, serverCertificate := newTestTLSIdentity(t)
+	clientKeys, clientCertificate := newTestTLSIdentity(t)
+
+	listener, handshakes := startTestTLSListener(t, ServerTLSConfig(serverCertificate, clientKeys.IsEqualPubKey))
+	defer listener.Close()
+
+	cm := NewConnectionsMap(0)
+	defer cm.Stop(context.Background())
+
+	observer := testObserverOf(t, listener, serverKeys.PublicKey())
+	w, err := cm.GetOrDial(observer, TLSDialer(clientCertificate, time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !w.IsTLS() {
+		t.Fatal("connection must be marked as TLS")
+	}
+
+	err = w.Enqueue([]byte{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	echo := make([]byte, 8)
+	w.Connection.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = io.ReadFull(w.Connection, echo)
+	if err != nil || echo[7] != 3 {
+		t.Fatal("data has not been transferred")
+	}
+
+	if <-handshakes != nil {
+		t.Fatal("client must be accepted")
+	}
+}
+
+// Remote side presents certificate of the key, other than registered for the observer:
+// connection must be rejected by the client.
+func TestTLSDialer_ServerPubKeyMismatch(t *testing.T) {
+	_, serverCertificate := newTestTLSIdentity(t)
+	otherKeys, _ := newTestTLSIdentity(t)
+	clientKeys, clientCertificate := newTestTLSIdentity(t)
+
+	listener, _ := startTestTLSListener(t, ServerTLSConfig(serverCertificate, clientKeys.IsEqualPubKey))
+	defer listener.Close()
+
+	cm := NewConnectionsMap(0)
+	defer cm.Stop(context.Background())
+
+	observer := testObserverOf(t, listener, otherKeys.PublicKey())
+	_, err := cm.GetOrDial(observer, TLSDialer(clientCertificate, time.Second))
+	if err == nil {
+		t.Fatal("server with mismatched certificate must be rejected")
+	}
+
+	if cm.Len() != 0 {
+		t.Fatal()
+	}
+}
+
+// Client presents certificate of the unknown key: connection must be rejected by the server.
+func TestServerTLSConfig_UnknownClient(t *testing.T) {
+	serverKeys, serverCertificate := newTestTLSIdentity(t)
+	knownKeys, _ := newTestTLSIdentity(t)
+	_, unknownCertificate := newTestTLSIdentity(t)
+
+	listener, handshakes := startTestTLSListener(t, ServerTLSConfig(serverCertificate, knownKeys.IsEqualPubKey))
+	defer listener.Close()
+
+	observer := testObserverOf(t, listener, serverKeys.PublicKey())
+	conn, err := TLSDialer(unknownCertificate, time.Second)(observer)
+	if err == nil {
+		defer conn.Close()
+	}
+
+	select {
+	case err := <-handshakes:
+		if err == nil {
+			t.Fatal("unknown client must be rejected")
+		}
+
+	case <-time.After(time.Second * 5):
+		t.Fatal("no handshake")
+	}
+}
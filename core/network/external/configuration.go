@@ -1,6 +1,9 @@
 package external
 
-import "geo-observers-blockchain/core/common/types/hash"
+import (
+	"geo-observers-blockchain/core/common/types/hash"
+	"sync"
+)
 
 type Configuration struct {
 	Observers []*Observer
@@ -11,6 +14,9 @@ type Configuration struct {
 	// Might be calculated locally and might be different from the revisions of other observers.
 	// At the moment, this number is simply increments on each new configuration received.
 	Revision uint64
+
+	registry     *ObserverRegistry
+	registryOnce sync.Once
 }
 
 func NewConfiguration(rev uint64, observers []*Observer) *Configuration {
@@ -40,3 +46,13 @@ func (c *Configuration) CurrentExternalChainHeight() uint64 {
 	// todo: implement proxy method calling middleware
 	return 1
 }
+
+// Registry returns index <-> public key mapping of the observers of this configuration.
+// Registry is built once, on the first call.
+func (c *Configuration) Registry() *ObserverRegistry {
+	c.registryOnce.Do(func() {
+		c.registry = NewObserverRegistry(c.Observers)
+	})
+
+	return c.registry
+}
@@ -0,0 +1,26 @@
+// Package externaltest provides utilities for the tests, that are using the observers configuration.
+package externaltest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"geo-observers-blockchain/core/network/external"
+	"testing"
+)
+
+// NewObservers creates observers with distinct public keys and ports (starting from 3000) on the local host.
+// Keys are generated on the same curve as the observers keys are (P-521, see keystore).
+func NewObservers(t testing.TB, count int) []*external.Observer {
+	observers := make([]*external.Observer, 0, count)
+	for i := 0; i < count; i++ {
+		pkey, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		observers = append(observers, external.NewObserver("127.0.0.1", uint16(3000+i), &pkey.PublicKey))
+	}
+
+	return observers
+}
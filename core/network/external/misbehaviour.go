@@ -0,0 +1,24 @@
+package external
+
+// MisbehaviourReporter collects reports of the remote observers, that has sent invalid data
+// (out of range votes, invalid instances, forged signatures, etc).
+// Is implemented by observers.Blacklist.
+type MisbehaviourReporter interface {
+	Report(host string) (isBlacklisted bool)
+}
+
+// ReportMisbehaviour reports the observer with the index specified (in the configuration "conf") to the "reporter".
+// Nil reporter, unknown indexes and the index of the current observer are ignored.
+// Returns true if the observer is blacklisted (as a result of this report or earlier).
+func ReportMisbehaviour(reporter MisbehaviourReporter, conf *Configuration, index uint16) (isBlacklisted bool) {
+	if reporter == nil || conf == nil || index == conf.CurrentObserverIndex {
+		return false
+	}
+
+	observer, err := conf.Registry().ObserverByIndex(index)
+	if err != nil {
+		return false
+	}
+
+	return reporter.Report(observer.Host)
+}
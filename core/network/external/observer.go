@@ -12,6 +12,11 @@ type Observer struct {
 	Port   uint16
 }
 
+// ObserverIdentity identifies the observer regardless of it's index in the configuration
+// (index of the same observer might change from one configuration to another).
+// It is derived from the observer's public key.
+type ObserverIdentity string
+
 // todo: add pub key
 func NewObserver(host string, port uint16, pubKey *ecdsa.PublicKey) *Observer {
 	return &Observer{
@@ -21,6 +26,21 @@ func NewObserver(host string, port uint16, pubKey *ecdsa.PublicKey) *Observer {
 	}
 }
 
+// Identity returns stable identity of the observer.
+// Returns empty identity in case if observer has no public key.
+func (o *Observer) Identity() ObserverIdentity {
+	if o.PubKey == nil || o.PubKey.X == nil || o.PubKey.Y == nil {
+		return ""
+	}
+
+	return PubKeyIdentity(o.PubKey)
+}
+
+// PubKeyIdentity returns identity of the observer with the public key specified (see Identity()).
+func PubKeyIdentity(pubKey *ecdsa.PublicKey) ObserverIdentity {
+	return ObserverIdentity(pubKeyIndexKey(pubKey))
+}
+
 func (o *Observer) Hash() hash.SHA256Container {
 	xData := o.PubKey.X.Bytes()
 	yData := o.PubKey.Y.Bytes()
@@ -0,0 +1,102 @@
+package external
+
+import (
+	"crypto/ecdsa"
+	"geo-observers-blockchain/core/common/errors"
+	"math"
+)
+
+// ObserverRegistry translates observer index to it's public key and vice versa.
+// All subsystems (ticker, pool, signatures verification) must use it instead of ad hoc lookups,
+// so the same observer is always identified in the same way.
+type ObserverRegistry struct {
+	observers []*Observer
+
+	// Public key (serialized X and Y coordinates) -> observer index.
+	indexes map[string]uint16
+}
+
+func NewObserverRegistry(observers []*Observer) *ObserverRegistry {
+	registry := &ObserverRegistry{
+		observers: observers,
+		indexes:   make(map[string]uint16, len(observers)),
+	}
+
+	for i, observer := range observers {
+		if observer == nil || observer.PubKey == nil {
+			continue
+		}
+
+		registry.indexes[pubKeyIndexKey(observer.PubKey)] = uint16(i)
+	}
+
+	return registry
+}
+
+// ObserverByIndex returns observer with the index specified.
+// Returns errors.InvalidObserverIndex in case if there is no such observer in configuration.
+func (r *ObserverRegistry) ObserverByIndex(index uint16) (observer *Observer, err error) {
+	if int(index) >= len(r.observers) || r.observers[index] == nil {
+		return nil, errors.InvalidObserverIndex
+	}
+
+	return r.observers[index], nil
+}
+
+// PubKeyByIndex returns public key of the observer with the index specified.
+// Returns errors.InvalidObserverIndex in case if there is no such observer in configuration.
+func (r *ObserverRegistry) PubKeyByIndex(index uint16) (pubKey *ecdsa.PublicKey, err error) {
+	observer, err := r.ObserverByIndex(index)
+	if err != nil {
+		return
+	}
+
+	if observer.PubKey == nil {
+		return nil, errors.InvalidObserverIndex
+	}
+
+	return observer.PubKey, nil
+}
+
+// IdentityByIndex returns stable identity of the observer with the index specified.
+// Returns errors.InvalidObserverIndex in case if there is no such observer in configuration.
+func (r *ObserverRegistry) IdentityByIndex(index uint16) (identity ObserverIdentity, err error) {
+	_, err = r.PubKeyByIndex(index)
+	if err != nil {
+		return
+	}
+
+	return r.observers[index].Identity(), nil
+}
+
+// IndexByPubKey returns index of the observer with the public key specified.
+// Returns errors.UnknownObserverPubKey in case if there is no such observer in configuration.
+func (r *ObserverRegistry) IndexByPubKey(pubKey *ecdsa.PublicKey) (index uint16, err error) {
+	if pubKey == nil || pubKey.X == nil || pubKey.Y == nil {
+		return math.MaxUint16, errors.NilParameter
+	}
+
+	index, isPresent := r.indexes[pubKeyIndexKey(pubKey)]
+	if !isPresent {
+		return math.MaxUint16, errors.UnknownObserverPubKey
+	}
+
+	return index, nil
+}
+
+// ContainsIdentity returns true if configuration contains observer with the identity specified.
+func (r *ObserverRegistry) ContainsIdentity(identity ObserverIdentity) bool {
+	_, isPresent := r.indexes[string(identity)]
+	return isPresent
+}
+
+// IsValidIndex returns true if configuration contains observer with the index specified.
+func (r *ObserverRegistry) IsValidIndex(index uint16) bool {
+	_, err := r.PubKeyByIndex(index)
+	return err == nil
+}
+
+func pubKeyIndexKey(pubKey *ecdsa.PublicKey) string {
+	// Coordinates are separated to prevent ambiguity of the variable length values.
+	return pubKey.X.Text(16) + ":" + pubKey.Y.Text(16)
+}
@@ -0,0 +1,61 @@
+package external_test
+
+import (
+	"geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/network/external"
+	"geo-observers-blockchain/core/network/external/externaltest"
+	"testing"
+)
+
+func TestObserverRegistry_RoundTrip(t *testing.T) {
+	conf := external.NewConfiguration(0, externaltest.NewObservers(t, 4))
+	registry := conf.Registry()
+
+	for i := range conf.Observers {
+		pubKey, err := registry.PubKeyByIndex(uint16(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		index, err := registry.IndexByPubKey(pubKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if index != uint16(i) {
+			t.Fatal("round-trip lookup returned other observer")
+		}
+	}
+
+	if conf.Registry() != registry {
+		t.Fatal("registry must be built only once")
+	}
+}
+
+func TestObserverRegistry_UnknownIndex(t *testing.T) {
+	registry := external.NewObserverRegistry(externaltest.NewObservers(t, 2))
+
+	_, err := registry.PubKeyByIndex(2)
+	if err != errors.InvalidObserverIndex {
+		t.Fatal()
+	}
+
+	if registry.IsValidIndex(2) || !registry.IsValidIndex(1) {
+		t.Fatal()
+	}
+}
+
+func TestObserverRegistry_UnknownPubKey(t *testing.T) {
+	registry := external.NewObserverRegistry(externaltest.NewObservers(t, 2))
+	other := externaltest.NewObservers(t, 1)[0]
+
+	_, err := registry.IndexByPubKey(other.PubKey)
+	if err != errors.UnknownObserverPubKey {
+		t.Fatal()
+	}
+
+	_, err = registry.IndexByPubKey(nil)
+	if err != errors.NilParameter {
+		t.Fatal()
+	}
+}
@@ -29,14 +29,80 @@ var (
 
 	// Time range during which remote observers might respond with their ticker states.
 	// WARN: This value must be at least 3 times less, than block generation time range.
-	// todo: ensure check of this constraint on ticker starting.
+	// Unless elapsed frames are accounted (see TickerSyncElapsedFramesPolicy),
+	// it must fit into block generation time range with TickerSynchronisationExchangeTimeRange reserve
+	// (it is checked on ticker start, see ticker.ValidateSettings()).
 	TickerSynchronisationTimeRange = time.Second * 20
 
+	// Time range, reserved in the block generation time range for the time frames exchange
+	// and the ticker start after the synchronisation.
+	TickerSynchronisationExchangeTimeRange = time.Second * 2
+
+	// Max. duration of the whole ticker synchronisation routine (including the ticker restart).
+	// In case if synchronisation is not finished in time - it is abandoned.
+	// WARN: This value must be greater, than ticker synchronisation time range.
+	TickerSynchronisationTimeout = time.Second * 30
+
 	// Time range during which remote observers might respond on synchronisation requests.
 	// WARN: This value must be at least 3 times less, than block generation time range.
 	// todo: ensure check of this constraint on ticker starting.
 	ComposerSynchronisationTimeRange = time.Second * 20
 
+	// Minimal delay before the next ticker tick.
+	// Prevents ticks from firing one by one in case if next frame timestamp is too close to the current moment.
+	TickerMinFrameTimeLeft = time.Millisecond * 10
+
+	// Time range after the ticker start, during which frames collisions, reported by other observers,
+	// does not lead to the resynchronisation.
+	TickerDesyncGracePeriod = time.Minute * 2
+
+	// Ticker is considered as desynchronized only in case if collisions was reported
+	// at least in TickerDesyncThreshold rounds of the last TickerDesyncWindowSize rounds.
+	TickerDesyncWindowSize = 5
+	TickerDesyncThreshold  = 3
+
+	// Interval of the periodic resynchronisation of the running ticker with other observers.
+	// Prevents permanent increasing of the clock delta and time frames shifting.
+	// Zero disables periodic resynchronisation.
+	TickerResyncInterval = time.Hour
+
+	// Max shift of the next frame timestamp, that might be applied by one periodic resynchronisation.
+	// Greater drift is corrected gradually, during several resynchronisations.
+	TickerResyncMaxAdjustment = time.Second * 5
+
+	// Drift of the time frames from the frames of the majority of other observers (measured on resynchronisation),
+	// that is reported to the log. Zero disables reporting.
+	TickerDriftWarningThreshold = time.Second
+
+	// Amount of ticks, that might be buffered in case if the reader of the ticks lags.
+	// Ticks emission never blocks: ticks beyond the buffer are dropped (and accounted).
+	// Zero means no buffering.
+	TickerTicksBufferSize = 0
+
+	// Max amount of requests from the remote observers, that might be enqueued for processing by the ticker.
+	// Requests, received when the queue is full, are dropped.
+	TickerIncomingRequestsQueueSize = 64
+
+	// Name of the algorithm, that is used by the ticker for deciding the current time frame
+	// on the base of the responses of the remote observers (see ticker.FrameConsensus).
+	TickerFrameConsensusAlgorithm = "majority"
+
+	// Max amount of external signatures, that might be verified in one batch (see keystore.CheckExternalSignatures).
+	// Signatures beyond the budget are not verified, so the peer can't burn CPU by sending many bogus signatures.
+	SignaturesVerificationBudget = KObserversMaxCount
+
+	// Period, during which signatures of the previous key of the observer are still accepted after key rotation
+	// (see keystore.Rotate), so the remote observers have time to receive the new public key.
+	KeyRotationGracePeriod = time.Hour * 24
+
+	// Policy of processing of the synchronisation, that has taken more than one time frame
+	// ("single-frame" or "elapsed-frames", see ticker.SyncPolicySingleFrame and ticker.SyncPolicyElapsedFrames).
+	TickerSyncElapsedFramesPolicy = "single-frame"
+
+	// Delay before the next attempt to process block candidate digest,
+	// that references claims, absent in the pool (missing claims are requested from the digest proposer).
+	ProducerMissingClaimsRetryPeriod = time.Second
+
 	// This period of time is used as a buffer time window:
 	// during this time window observer does not accepts any external events or messages,
 	// and prepares to process next ticker tick.
@@ -46,8 +112,110 @@ var (
 	// todo: drop this parameter at all.
 	BlockGenerationSilencePeriod = time.Second * 10
 
+	// Max amount of TSLs, for which numbers of the blocks are cached
+	// (the least recently queried ones are evicted first).
+	// Zero disables caching.
+	ChainTSLsPresenceIndexSize = 4096
+
+	// Max amount of records (claims and TSLs), that might be present in the pool.
+	// Zero means no limit.
+	PoolMaxRecords = 0
+
+	// If true - the oldest record, that has not collected majority of approves yet,
+	// is evicted from the full pool to free the space for the new one.
+	// Otherwise - new records are rejected until the pool would be freed.
+	PoolEvictOnFull = true
+
 	// todo: sync with the GEO engine
 	GEOTransactionMaxParticipantsCount = 700
+
+	// Max amount of messages, that might be enqueued for sending to one remote observer.
+	// In case if queue is full - message is rejected (sending never blocks).
+	ObserversConnectionSendQueueSize = 64
+
+	// Max time of writing one message to the remote observer's connection.
+	// Connection to the observer, that does not read the data, is dropped after it.
+	// Zero disables the timeout.
+	ObserversConnectionWriteTimeout = time.Second * 5
+
+	// Amount of consecutive failed writes to the remote observer's connection, that are tolerated.
+	// Connection is dropped (and established once more on the next sending) only after exceeding it.
+	ObserversConnectionWriteFailuresThreshold = 3
+
+	// Timeout of one attempt to connect to the remote observer (including TLS handshake, if enabled).
+	ObserversConnectionDialTimeout = time.Second * 5
+
+	// Max size of the data of the observers message frame (see observers.readFrame()).
+	// Frames with greater declared size are rejected without reading.
+	ObserversFrameBodyMaxSize = 1024 * 1024 * 16
+
+	// Timeout of the wire protocol version negotiation with the remote observer (see observers.ProtocolVersion).
+	// Zero disables the timeout.
+	ObserversProtocolHandshakeTimeout = time.Second * 5
+
+	// If true - connections between observers are protected with mutual TLS.
+	// Observers are authenticated by their public keys, registered in the observers configuration.
+	// All observers of the configuration must have the same value of this setting.
+	ObserversTLSEnabled = false
+
+	// If true - inbound connections are accepted only from the observers, that has proven their identity
+	// (signed the challenge with the key, registered in the observers configuration, see observers.AuthenticatePeer()),
+	// and this observer proves it's identity on each outbound connection.
+	// All observers of the configuration must have the same value of this setting.
+	ObserversAuthenticationEnabled = true
+
+	// Timeout of the remote observer authentication (see ObserversAuthenticationEnabled).
+	// Zero disables the timeout.
+	ObserversAuthenticationTimeout = time.Second * 5
+
+	// Amount of attempts to connect to the remote observer, before the sending is considered failed.
+	// Delay between the attempts starts from ObserversConnectionDialBackoff,
+	// and is doubled after each one failed attempt (up to ObserversConnectionDialMaxBackoff).
+	ObserversConnectionDialAttempts   = 3
+	ObserversConnectionDialBackoff    = time.Millisecond * 100
+	ObserversConnectionDialMaxBackoff = time.Second
+
+	// Interval of the periodic flushing of the connections to the remote observers (see ConnectionsMap.FlushAll()).
+	// Messages are flushed by the connection's writer right after writing,
+	// so this is only a safety net for the data, that has been buffered in some other way.
+	// Zero disables periodic flushing.
+	ObserversConnectionAutoFlushInterval = time.Duration(0)
+
+	// TCP keep-alive period of the connections to the remote observers.
+	// Allows to detect dead remote observers, that has not closed the connection.
+	// Zero disables keep-alive.
+	ObserversConnectionKeepAlivePeriod = time.Second * 30
+
+	// Remote observer, that has been reported as misbehaving (malformed messages, invalid signatures, etc)
+	// at least ObserversBlacklistThreshold times during ObserversBlacklistWindow,
+	// is blacklisted for ObserversBlacklistTTL: messages from it are dropped and no connections to it are established.
+	ObserversBlacklistThreshold = 5
+	ObserversBlacklistWindow    = time.Minute
+	ObserversBlacklistTTL       = time.Minute * 10
+
+	// If true - Nagle's algorithm is disabled on the connections to the remote observers,
+	// so small messages (for example, votes) are sent without delay.
+	ObserversConnectionNoDelay = true
+
+	// Max time of waiting for the pong from the remote observer (see observers.ConnectionsMap.Ping()).
+	// Connection to the observer, that has not responded in time, is dropped.
+	ObserversPingTimeout = time.Second * 2
+
+	// Interval of the periodic pinging of the connections to the remote observers.
+	// Measured round trip times are used for the time frames synchronisation.
+	// Zero disables periodic pinging.
+	ObserversPingInterval = time.Second * 30
+
+	// Max. duration of the observer shutdown (on SIGINT / SIGTERM).
+	// Subsystems, that are not stopped in time, are abandoned.
+	ShutdownTimeout = time.Second * 10
+
+	// If true - malformed claims received from the remote side are rejected
+	// (data must be consumed exactly, without any trailing or missing bytes),
+	// and the whole sequence of claims is rejected as well.
+	// If false - malformed claims are logged and skipped, the rest of the sequence is processed.
+	// Lenient mode is intended only for the migration and debugging purposes.
+	StrictUnmarshalling = true
 )
 
 var (
@@ -56,6 +224,10 @@ var (
 	OutputNetworkObserversReceiverDebug    = false
 	OutputNetworkObserversReceiverWarnings = false
 	OutputBlocksProducerDebug              = false
+
+	// If true - time frames responses, that has arrived after the deadline of their synchronisation round
+	// (and so has been discarded), are reported to the log.
+	OutputTickerLateResponsesWarnings = false
 )
 
 type networkInterface struct {
@@ -79,10 +251,17 @@ type nodes struct {
 	Network networkInterface `json:"network"`
 }
 
+type keyStore struct {
+	// Path of the observer's PEM encoded private key.
+	// If omitted - keystore.DefaultKeyPath is used.
+	Path string `json:"path"`
+}
+
 type Settings struct {
 	Debug     bool      `json:"debug"`
 	Observers observers `json:"observers"`
 	Nodes     nodes     `json:"nodes"`
+	KeyStore  keyStore  `json:"keystore"`
 }
 
 func LoadSettings() error {
@@ -121,6 +300,11 @@ func parseFlags() {
 		ObserversConsensusCount = 3
 		AverageBlockGenerationTimeRange = time.Second * 10
 		TickerSynchronisationTimeRange = time.Second * 2
+		TickerSynchronisationTimeout = time.Second * 3
+		TickerSynchronisationExchangeTimeRange = time.Second
+		TickerDesyncGracePeriod = time.Second * 20
+		TickerResyncInterval = time.Minute * 5
+		TickerResyncMaxAdjustment = time.Second
 		ComposerSynchronisationTimeRange = time.Second * 2
 		BlockGenerationSilencePeriod = time.Second * 2
 
@@ -129,6 +313,7 @@ func parseFlags() {
 		OutputNetworkObserversReceiverDebug = false
 		OutputNetworkObserversReceiverWarnings = false
 		OutputBlocksProducerDebug = true
+		OutputTickerLateResponsesWarnings = true
 	}
 }
 
@@ -0,0 +1,28 @@
+package ticker
+
+import (
+	"time"
+)
+
+// Clock is the source of the current time and of the timers for the ticker.
+// Real clock is used by default (see New()),
+// other implementations allow to control the time flow (for example, in tests).
+type Clock interface {
+	Now() time.Time
+
+	// After returns channel, that receives the current time once the duration has elapsed.
+	After(d time.Duration) <-chan time.Time
+}
+
+// RealClock is the clock of the system (time.Now() and time.After()).
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
@@ -0,0 +1,312 @@
+package ticker
+
+import (
+	"geo-observers-blockchain/core/network/communicator/observers/requests"
+	"geo-observers-blockchain/core/network/communicator/observers/responses"
+	"geo-observers-blockchain/core/network/external"
+	"geo-observers-blockchain/core/settings"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// FakeClock is the clock, time of which is changed only by Advance(),
+// so the ticker's logic might be tested deterministically.
+type FakeClock struct {
+	mutex   sync.Mutex
+	now     time.Time
+	waiters []*fakeClockWaiter
+}
+
+type fakeClockWaiter struct {
+	deadline time.Time
+	channel  chan time.Time
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// Buffered, so the advancing goroutine never blocks on the abandoned timers.
+	channel := make(chan time.Time, 1)
+	if d <= 0 {
+		channel <- c.now
+		return channel
+	}
+
+	c.waiters = append(c.waiters, &fakeClockWaiter{deadline: c.now.Add(d), channel: channel})
+	return channel
+}
+
+// Advance moves the time forward and fires all timers, deadlines of which has been reached.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = c.now.Add(d)
+
+	sort.Slice(c.waiters, func(i, j int) bool {
+		return c.waiters[i].deadline.Before(c.waiters[j].deadline)
+	})
+
+	pending := c.waiters[:0]
+	for _, waiter := range c.waiters {
+		if waiter.deadline.After(c.now) {
+			pending = append(pending, waiter)
+			continue
+		}
+
+		waiter.channel <- c.now
+	}
+	c.waiters = pending
+}
+
+// WaitersCount returns amount of the timers, that has not been fired yet.
+func (c *FakeClock) WaitersCount() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.waiters)
+}
+
+func newTestTickerWithClock(clock Clock) *Ticker {
+	ticker := newTestTicker()
+	ticker.clock = clock
+	return ticker
+}
+
+func TestFakeClock_After(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	timer := clock.After(time.Second)
+
+	clock.Advance(time.Millisecond * 999)
+	select {
+	case <-timer:
+		t.Fatal("timer must not be fired before the deadline")
+	default:
+	}
+
+	clock.Advance(time.Millisecond)
+	select {
+	case now := <-timer:
+		if !now.Equal(time.Unix(1, 0)) {
+			t.Fatal()
+		}
+	default:
+		t.Fatal("timer must be fired on the deadline")
+	}
+}
+
+// Time left to the next frame depends only on the clock.
+func TestTicker_NextFrameTimeLeft_FakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0))
+	ticker := newTestTickerWithClock(clock)
+	ticker.nextFrameTimestamp = clock.Now().Add(time.Second * 10)
+
+	if ticker.nextFrameTimeLeft() != time.Second*10 {
+		t.Fatal()
+	}
+
+	clock.Advance(time.Second * 4)
+	if ticker.nextFrameTimeLeft() != time.Second*6 {
+		t.Fatal()
+	}
+
+	// Frame is overdue by one second: next frame is scheduled on the next frames boundary.
+	clock.Advance(time.Second * 7)
+	if ticker.nextFrameTimeLeft() != settings.AverageBlockGenerationTimeRange-time.Second {
+		t.Fatal("overdue frame must be rescheduled")
+	}
+}
+
+// Time is frozen during the pause, and the rest of the frame is preserved on resume.
+func TestTicker_PauseResume_FakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0))
+	ticker := newTestTickerWithClock(clock)
+	ticker.nextFrameTimestamp = clock.Now().Add(time.Second * 10)
+
+	ticker.Pause()
+	<-ticker.internalEventsBus
+
+	clock.Advance(time.Hour)
+	if ticker.nextFrameTimeLeft() != time.Second*10 {
+		t.Fatal("time must be frozen during the pause")
+	}
+
+	ticker.Resume()
+	<-ticker.internalEventsBus
+
+	clock.Advance(time.Second * 3)
+	if ticker.nextFrameTimeLeft() != time.Second*7 {
+		t.Fatal("time left to the next frame must be preserved")
+	}
+}
+
+type testConfigurationReporter struct {
+	conf *external.Configuration
+}
+
+func (r *testConfigurationReporter) GetCurrentConfiguration() (*external.Configuration, error) {
+	return r.conf, nil
+}
+
+// Response to the time frames request reports the exact time left to the next frame.
+func TestTicker_ProcessTimeFrameRequest_FakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0))
+	ticker := newTestTickerWithClock(clock)
+	ticker.confReporter = &testConfigurationReporter{conf: &external.Configuration{CurrentObserverIndex: 3}}
+	ticker.frame = &EventTimeFrameEnd{Index: 5, Conf: newTestConfiguration(4)}
+	ticker.nextFrameTimestamp = clock.Now().Add(time.Second * 10)
+	ticker.isTickerRunning = true
+
+	clock.Advance(time.Second * 2)
+	err := ticker.processTimeFrameRequest(requests.NewSynchronisationTimeFrames())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := <-ticker.OutgoingResponsesTimeFrame
+	if response.ObserverIndex() != 3 || response.FrameIndex != 5 ||
+		time.Duration(response.NanosecondsLeft) != time.Second*8 {
+		t.Fatal("unexpected response: ", response)
+	}
+}
+
+// No responses are received: synchronisation finishes exactly on the deadline of the fake clock,
+// and the independent time frames flow is started.
+func TestTicker_SyncWithOtherObservers_FakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0))
+	ticker := newTestTickerWithClock(clock)
+
+	finished := make(chan struct{})
+	go func() {
+		ticker.syncWithOtherObservers()
+		close(finished)
+	}()
+
+	started := clock.Now()
+	for {
+		select {
+		case <-finished:
+			// The loop might advance the clock once more, before the finish is noticed.
+			elapsed := clock.Now().Sub(started)
+			if elapsed < settings.TickerSynchronisationTimeRange ||
+				elapsed > settings.TickerSynchronisationTimeRange+time.Millisecond*100 {
+				t.Fatal("synchronisation must be finished on the deadline: ", elapsed)
+			}
+
+			event := <-ticker.OutgoingEventsSynchronisationFinished
+			if !event.IsFallback || event.ResponsesCount != 0 {
+				t.Fatal()
+			}
+
+			<-ticker.internalEventsBus
+			timeLeft := ticker.nextFrameTimeLeft()
+			if timeLeft > settings.AverageBlockGenerationTimeRange ||
+				timeLeft < settings.AverageBlockGenerationTimeRange-time.Millisecond*50 {
+				t.Fatal("independent time frames flow must be started: ", timeLeft)
+			}
+			return
+
+		default:
+		}
+
+		// Either the sync loop waits for the timer, or it has been finished.
+		if clock.WaitersCount() > 0 {
+			clock.Advance(time.Millisecond * 50)
+			continue
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Ages of the responses are computed by the ticker's clock, not by the wall time.
+func TestTicker_ProcessMajorityOfFrameResponses_FakeClock(t *testing.T) {
+	defer setTestConsensusCount(2)()
+
+	clock := NewFakeClock(time.Unix(1000, 0))
+	ticker := newTestTickerWithClock(clock)
+
+	for i := uint16(0); i < 2; i++ {
+		frame, err := responses.NewValidatedTimeFrame(i, 3, uint64(time.Second*5), clock.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ticker.IncomingResponsesTimeFrame <- frame
+	}
+
+	clock.Advance(time.Second * 2)
+	timeOffset, nextFrameIndex, _, err := ticker.processMajorityOfFrameResponses()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if nextFrameIndex != 3 || time.Duration(timeOffset) != time.Second*3 {
+		t.Fatal("unexpected decision: ", nextFrameIndex, time.Duration(timeOffset))
+	}
+}
+
+// Resynchronisation is launched only when the resync interval elapses on the ticker's clock.
+func TestTicker_ResyncPeriodically_FakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0))
+	ticker := newTestTickerWithClock(clock)
+	ticker.isTickerRunning = true
+
+	stop := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		ticker.resyncPeriodically(stop)
+		close(finished)
+	}()
+
+	waitForFakeClockWaiter(t, clock)
+	clock.Advance(settings.TickerResyncInterval - time.Millisecond)
+	select {
+	case <-ticker.OutgoingRequestsTimeFrames:
+		t.Fatal("resynchronisation must not be launched before the interval elapses")
+	case <-time.After(time.Millisecond * 20):
+	}
+
+	clock.Advance(time.Millisecond)
+	select {
+	case <-ticker.OutgoingRequestsTimeFrames:
+	case <-time.After(time.Second):
+		t.Fatal("resynchronisation must be launched when the interval elapses")
+	}
+
+	// No responses are received: resynchronisation fails and the next interval is awaited.
+	waitForFakeClockWaiter(t, clock)
+	clock.Advance(settings.TickerSynchronisationTimeRange + time.Millisecond)
+	waitForFakeClockWaiter(t, clock)
+
+	close(stop)
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("resynchronisation loop must be stopped")
+	}
+}
+
+// waitForFakeClockWaiter blocks until some goroutine waits for the timer of the clock.
+func waitForFakeClockWaiter(t *testing.T, clock *FakeClock) {
+	deadline := time.Now().Add(time.Second)
+	for clock.WaitersCount() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("no timer has been requested from the clock")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,287 @@
+package ticker
+
+import (
+	"geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/network/communicator/observers/responses"
+	"geo-observers-blockchain/core/settings"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	FrameConsensusMajority = "majority"
+)
+
+// Policies of processing of the synchronisation, that has taken more than one time frame
+// (see settings.TickerSyncElapsedFramesPolicy).
+//
+// SyncPolicySingleFrame requires the whole synchronisation to be finished during one time frame
+// (synchronisation time range must be less than block generation time range, it is checked on ticker start).
+// Responses, after which more than one frame has been over during synchronisation, are dropped.
+// It is safe default: the decision is never made on the base of extrapolated data,
+// but it is not applicable for short block generation time ranges.
+//
+// SyncPolicyElapsedFrames allows synchronisation to take several time frames.
+// Frames elapsed since the response are accounted, so the precise current frame index is computed.
+// The longer synchronisation is - the more the result depends on the clock drift
+// and on the accuracy of block generation time range on the remote observers.
+const (
+	SyncPolicySingleFrame   = "single-frame"
+	SyncPolicyElapsedFrames = "elapsed-frames"
+)
+
+// FrameConsensus decides in which time frame the observers are at the moment,
+// and how much time is left to the next time frame,
+// based on the time frames responses collected from the remote observers during synchronisation.
+//
+// Alternative algorithms (median-based, trimmed mean, byzantine-tolerant agreement, etc)
+// might be registered via RegisterFrameConsensus() and selected
+// via settings.TickerFrameConsensusAlgorithm.
+type FrameConsensus interface {
+	// Decide returns the time offset to the next time frame (in nanoseconds) and the next time frame index.
+	// "frames" is never empty.
+	Decide(frames []*responses.TimeFrame, decision FrameDecisionContext) (
+		timeOffsetNanoseconds uint64, nextFrameIndex uint16, err error)
+}
+
+// FrameDecisionContext describes the circumstances, the frame consensus decision is made in.
+// Algorithms must not fetch them by themselves (for example, must not call time.Now()),
+// so the decision is deterministic for the same input.
+type FrameDecisionContext struct {
+	// Moment of the decision (current time of the ticker's clock, see Clock).
+	// Ages of the responses are computed relative to it.
+	Now time.Time
+
+	// Amount of observers in the current configuration.
+	// The only legitimate range of the frame indexes is [0, ObserversCount).
+	// In case if the configuration is not known yet (0) - settings.ObserversMaxCount is used instead.
+	ObserversCount int
+}
+
+// observersCount returns the amount of the legitimate frame indexes.
+func (c FrameDecisionContext) observersCount() int {
+	if c.ObserversCount <= 0 || c.ObserversCount > settings.ObserversMaxCount {
+		return settings.ObserversMaxCount
+	}
+
+	return c.ObserversCount
+}
+
+var (
+	frameConsensusAlgorithmsMutex sync.Mutex
+	frameConsensusAlgorithms      = map[string]FrameConsensus{
+		FrameConsensusMajority: &MajorityFrameConsensus{},
+	}
+)
+
+// RegisterFrameConsensus makes the algorithm available for selection via settings.
+// Algorithm with the same name would be replaced.
+func RegisterFrameConsensus(name string, algorithm FrameConsensus) error {
+	if algorithm == nil {
+		return errors.NilParameter
+	}
+
+	frameConsensusAlgorithmsMutex.Lock()
+	defer frameConsensusAlgorithmsMutex.Unlock()
+
+	frameConsensusAlgorithms[name] = algorithm
+	return nil
+}
+
+// frameConsensus returns registered algorithm with the name specified.
+// In case if there is no such algorithm - default one (majority) is returned.
+func frameConsensus(name string) FrameConsensus {
+	frameConsensusAlgorithmsMutex.Lock()
+	defer frameConsensusAlgorithmsMutex.Unlock()
+
+	algorithm, isPresent := frameConsensusAlgorithms[name]
+	if !isPresent {
+		log.WithFields(log.Fields{"prefix": "Ticker", "Algorithm": name}).Warn(
+			"Unknown frame consensus algorithm, default one would be used")
+
+		return frameConsensusAlgorithms[FrameConsensusMajority]
+	}
+
+	return algorithm
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+// MajorityFrameConsensus is the default frame consensus algorithm.
+// It finds the time frame index reported by the majority of the observers,
+// and takes the median time offset of the observers, that has fit into the majority.
+// The result is accepted only if at least settings.ObserversConsensusCount observers agree on the frame index.
+// Median is used instead of average, so one malicious (or badly clocked) observer
+// can't shift the time offset of the whole majority.
+type MajorityFrameConsensus struct{}
+
+func (c *MajorityFrameConsensus) Decide(frames []*responses.TimeFrame, decision FrameDecisionContext) (
+	timeOffsetNanoseconds uint64, nextFrameIndex uint16, err error) {
+
+	if len(frames) == 0 {
+		return 0, 0, errors.EmptySequence
+	}
+
+	rates, topFrameIndex := c.collectRates(frames, decision)
+	if len(rates) == 0 {
+		// All responses were out of range.
+		return 0, 0, errors.EmptySequence
+	}
+
+	m, _ := rates[topFrameIndex]
+	if len(*m) < settings.ObserversConsensusCount {
+		// Consensus on the frame index has not been reached.
+		return 0, 0, errors.EmptySequence
+	}
+
+	// Frames elapsed since the responses are already accounted by the rates,
+	// so the top frame index is the current frame index of the majority.
+	timeOffsetNanoseconds = c.medianNextFrameTTL(*m)
+	nextFrameIndex = topFrameIndex
+	return
+}
+
+// collectRates groups corrected time offsets of the responses by the reported frame indexes.
+// Returns the groups and the frame index, reported by the majority of observers.
+//
+// Frame indexes are received from the remote observers and can't be trusted:
+// the only legitimate range of them is [0, observers count of the current configuration),
+// so all other indexes are dropped before they would enter the map.
+// This way the map never contains more than observers count records.
+func (c *MajorityFrameConsensus) collectRates(frames []*responses.TimeFrame, decision FrameDecisionContext) (
+	rates map[uint16]*[]uint64, topFrameIndex uint16) {
+
+	rates = make(map[uint16]*[]uint64)
+
+	var (
+		topFrameVotesCount = 0
+		currentTTLsCount   = 0
+		now                = decision.Now
+		observersCount     = decision.observersCount()
+	)
+
+	for _, vote := range frames {
+		frameIndex := vote.FrameIndex
+		if int(frameIndex) >= observersCount {
+			continue
+		}
+
+		// Time left to the next frame of the remote observer at the moment of decision.
+		// Might be negative, if remote observer has switched to the next frame(s) since the response.
+		correctedNanosecondsLeft := int64(vote.NanosecondsLeft) - responseAge(vote, now).Nanoseconds()
+
+		frameIndex, correctedNanosecondsLeft, isValid := accountElapsedFrames(
+			frameIndex, correctedNanosecondsLeft, observersCount)
+		if !isValid {
+			continue
+		}
+
+		TTLs, isPresent := rates[frameIndex]
+
+		if isPresent {
+			*TTLs = append(*TTLs, uint64(correctedNanosecondsLeft))
+			currentTTLsCount = len(*TTLs)
+
+		} else {
+			rates[frameIndex] = &[]uint64{uint64(correctedNanosecondsLeft)}
+			currentTTLsCount = 1
+		}
+
+		if currentTTLsCount > topFrameVotesCount {
+			topFrameIndex = frameIndex
+			topFrameVotesCount = currentTTLsCount
+		}
+	}
+
+	return
+}
+
+// accountElapsedFrames processes the vote, which frame has been over before the synchronisation end
+// (corrected time left is not positive).
+// In case of SyncPolicySingleFrame the vote is accepted only if one frame has been over
+// (synchronisation is shorter than the time frame, so the frame might be switched once),
+// otherwise the vote is dropped, because it is based on the extrapolated data.
+// In case of SyncPolicyElapsedFrames the vote is always accepted.
+// Frame index of the accepted vote is increased by the amount of elapsed frames.
+// Returns false if the vote must be dropped.
+func accountElapsedFrames(frameIndex uint16, nanosecondsLeft int64, observersCount int) (
+	correctedFrameIndex uint16, correctedNanosecondsLeft int64, isValid bool) {
+
+	correctedFrameIndex, correctedNanosecondsLeft, elapsedFrames := skipElapsedFrames(
+		frameIndex, nanosecondsLeft, observersCount)
+	if elapsedFrames > 1 && settings.TickerSyncElapsedFramesPolicy != SyncPolicyElapsedFrames {
+		return frameIndex, nanosecondsLeft, false
+	}
+
+	return correctedFrameIndex, correctedNanosecondsLeft, true
+}
+
+// skipElapsedFrames moves frame index forward by the amount of frames, that has been over
+// since the moment, when the time left to the next frame has been measured
+// (time left is not positive in this case), so the time left to the next frame becomes positive.
+// Frame index is wrapped by the observers count of the current configuration.
+func skipElapsedFrames(frameIndex uint16, nanosecondsLeft int64, observersCount int) (
+	correctedFrameIndex uint16, correctedNanosecondsLeft int64, elapsedFrames int64) {
+
+	if nanosecondsLeft > 0 {
+		return frameIndex, nanosecondsLeft, 0
+	}
+
+	frameRange := int64(settings.AverageBlockGenerationTimeRange)
+	elapsedFrames = -nanosecondsLeft/frameRange + 1
+	correctedNanosecondsLeft = nanosecondsLeft + elapsedFrames*frameRange
+	correctedFrameIndex = uint16((int64(frameIndex) + elapsedFrames) % int64(observersCount))
+	return
+}
+
+// responseAge returns the time elapsed since the remote observer has measured the time left to the next frame.
+//
+// In case if the round trip time of the connection to the remote observer is known
+// (see responses.TimeFrame.ObserverRoundTripTime) - the network delay of the response is considered
+// to be the half of it, and is added to the time elapsed since the response receiving.
+// Round trip time of the connection is measured by the pings, so (unlike the round trip time of the request)
+// it does not include the time the request has been waiting for processing on the remote observer.
+//
+// Otherwise, in case if the round trip time of the request is known - remote observer is considered
+// to measure the time in the middle of the round trip, so the half of the round trip time is added
+// to the time elapsed since the response receiving. This way the network delay of the response is accounted too,
+// and the error on the links with asymmetric delays is limited to the half of the delays difference
+// (plus the half of the request processing delay).
+// Otherwise only the time elapsed since the response receiving is used.
+func responseAge(frame *responses.TimeFrame, now time.Time) time.Duration {
+	if frame.ObserverRoundTripTime > 0 {
+		return now.Sub(frame.Received) + frame.ObserverRoundTripTime/2
+	}
+
+	rtt, isKnown := frame.RoundTripTime()
+	if !isKnown {
+		return now.Sub(frame.Received)
+	}
+
+	return now.Sub(frame.RequestSent) - rtt/2
+}
+
+// medianNextFrameTTL returns median time offset.
+// In case of even amount of offsets - the average of the two middle ones is returned.
+// Returns 0 in case if no offset is present in offsets.
+func (c *MajorityFrameConsensus) medianNextFrameTTL(majorityOfTimeOffsets []uint64) uint64 {
+	count := len(majorityOfTimeOffsets)
+	if count == 0 {
+		return 0
+	}
+
+	// Offsets are sorted in the copy, so the collected rates are left untouched.
+	offsets := make([]uint64, count)
+	copy(offsets, majorityOfTimeOffsets)
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+
+	middle := count / 2
+	if count%2 == 1 {
+		return offsets[middle]
+	}
+
+	// Halves are summed separately to prevent overflow.
+	return offsets[middle-1]/2 + offsets[middle]/2 + (offsets[middle-1]%2+offsets[middle]%2)/2
+}
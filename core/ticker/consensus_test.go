@@ -0,0 +1,386 @@
+package ticker
+
+import (
+	"geo-observers-blockchain/core/common/errors"
+	"geo-observers-blockchain/core/network/communicator/observers/responses"
+	"geo-observers-blockchain/core/settings"
+	"math"
+	"testing"
+	"time"
+)
+
+// Feeds the algorithm with the responses, that contains huge amount of distinct invalid frame indexes,
+// and checks that they are not tracked, and the decision is made on the base of the valid responses only.
+func TestMajorityFrameConsensus_InvalidFrameIndexesAreDropped(t *testing.T) {
+	defer setTestConsensusCount(2)()
+
+	frames := make([]*responses.TimeFrame, 0)
+	for i := settings.ObserversMaxCount; i <= math.MaxUint16; i++ {
+		frame := responses.NewTimeFrame(nil, 0, uint16(i), 0)
+		frame.Received = time.Now()
+		frames = append(frames, frame)
+	}
+
+	for i := 0; i < 2; i++ {
+		frame, err := responses.NewValidatedTimeFrame(uint16(i), 3, uint64(time.Second), time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		frames = append(frames, frame)
+	}
+
+	c := &MajorityFrameConsensus{}
+	rates, topFrameIndex := c.collectRates(frames, newTestDecisionContext())
+	if len(rates) != 1 {
+		t.Fatal("only valid frame indexes must be tracked")
+	}
+
+	if topFrameIndex != 3 {
+		t.Fatal()
+	}
+
+	_, nextFrameIndex, err := c.Decide(frames, newTestDecisionContext())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if nextFrameIndex != 3 {
+		t.Fatal()
+	}
+}
+
+func TestMajorityFrameConsensus_OnlyInvalidFrameIndexes(t *testing.T) {
+	frames := []*responses.TimeFrame{
+		responses.NewTimeFrame(nil, 0, uint16(settings.ObserversMaxCount), 0),
+		responses.NewTimeFrame(nil, 1, math.MaxUint16, 0),
+	}
+
+	c := &MajorityFrameConsensus{}
+	_, _, err := c.Decide(frames, newTestDecisionContext())
+	if err != errors.EmptySequence {
+		t.Fatal()
+	}
+}
+
+// Simulates the link with asymmetric delays (request is delivered much faster than the response)
+// and checks that round trip based correction estimates the age of the response
+// closer to the truth than the correction based on the time of receiving only.
+func TestMajorityFrameConsensus_AsymmetricDelayCorrection(t *testing.T) {
+	var (
+		now           = time.Now()
+		sent          = now.Add(-time.Millisecond * 200)
+		requestDelay  = time.Millisecond * 5
+		responseDelay = time.Millisecond * 80
+		measured      = sent.Add(requestDelay)
+		received      = measured.Add(responseDelay)
+	)
+
+	frame, err := responses.NewValidatedTimeFrame(0, 1, uint64(time.Second), received)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frame.RequestSent = sent
+
+	trueAge := now.Sub(measured)
+	oneWayError := trueAge - now.Sub(received)
+	correctedError := trueAge - responseAge(frame, now)
+	if correctedError < 0 {
+		correctedError = -correctedError
+	}
+
+	if correctedError >= oneWayError {
+		t.Fatal("round trip based correction must be closer to the truth")
+	}
+
+	if correctedError != (responseDelay-requestDelay)/2 {
+		t.Fatal()
+	}
+}
+
+// Simulates observers with different asymmetric links and the delay of the requests processing
+// on the remote side, and checks that the time offset, corrected by the round trip times
+// of the connections, is closer to the truth than the offset, corrected by the time of receiving only.
+func TestMajorityFrameConsensus_ObserverRoundTripTimeCorrection(t *testing.T) {
+	defer setTestConsensusCount(3)()
+
+	var (
+		now                = time.Now()
+		nextFrameBeginning = now.Add(time.Second)
+		processingDelay    = time.Millisecond * 50
+		requestDelays      = []time.Duration{time.Millisecond * 5, time.Millisecond * 10, time.Millisecond * 2}
+		responseDelays     = []time.Duration{time.Millisecond * 80, time.Millisecond * 60, time.Millisecond * 100}
+	)
+
+	naiveFrames := make([]*responses.TimeFrame, 0, len(requestDelays))
+	correctedFrames := make([]*responses.TimeFrame, 0, len(requestDelays))
+	for i := range requestDelays {
+		measured := now.Add(-time.Millisecond * time.Duration(300+i*10))
+		received := measured.Add(responseDelays[i])
+
+		naive, err := responses.NewValidatedTimeFrame(
+			uint16(i), 1, uint64(nextFrameBeginning.Sub(measured)), received)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		corrected := *naive
+		corrected.RequestSent = measured.Add(-processingDelay - requestDelays[i])
+		corrected.ObserverRoundTripTime = requestDelays[i] + responseDelays[i]
+
+		naiveFrames = append(naiveFrames, naive)
+		correctedFrames = append(correctedFrames, &corrected)
+	}
+
+	c := &MajorityFrameConsensus{}
+	naiveOffset, _, err := c.Decide(naiveFrames, newTestDecisionContext())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	correctedOffset, _, err := c.Decide(correctedFrames, newTestDecisionContext())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trueOffset := time.Until(nextFrameBeginning)
+	absError := func(offset uint64) time.Duration {
+		d := time.Duration(offset) - trueOffset
+		if d < 0 {
+			return -d
+		}
+		return d
+	}
+
+	if absError(correctedOffset) >= absError(naiveOffset) {
+		t.Fatal("round trip time of the connection must bring the offset closer to the truth: ",
+			absError(correctedOffset), " vs ", absError(naiveOffset))
+	}
+}
+
+// Checks that the correction falls back to the time of receiving,
+// in case if the time of sending of the request is not echoed in the response.
+func TestMajorityFrameConsensus_CorrectionWithoutRoundTripTime(t *testing.T) {
+	now := time.Now()
+	received := now.Add(-time.Millisecond * 100)
+
+	frame, err := responses.NewValidatedTimeFrame(0, 1, uint64(time.Second), received)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if responseAge(frame, now) != now.Sub(received) {
+		t.Fatal()
+	}
+}
+
+// Simulates synchronisation, that has taken exactly two block generation time ranges,
+// and checks the outcome of each one policy of elapsed frames processing.
+func TestMajorityFrameConsensus_SyncSpansTwoFrames(t *testing.T) {
+	defer setTestConsensusCount(3)()
+	defaultPolicy := settings.TickerSyncElapsedFramesPolicy
+	defer func() { settings.TickerSyncElapsedFramesPolicy = defaultPolicy }()
+
+	frameRange := settings.AverageBlockGenerationTimeRange
+	received := time.Now().Add(-frameRange * 2)
+
+	frames := make([]*responses.TimeFrame, 0, 3)
+	for i := uint16(0); i < 3; i++ {
+		frame, err := responses.NewValidatedTimeFrame(i, 3, uint64(frameRange/2), received)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		frames = append(frames, frame)
+	}
+
+	c := &MajorityFrameConsensus{}
+
+	settings.TickerSyncElapsedFramesPolicy = SyncPolicySingleFrame
+	_, _, err := c.Decide(frames, newTestDecisionContext())
+	if err != errors.EmptySequence {
+		t.Fatal("responses of the finished frames must be dropped")
+	}
+
+	settings.TickerSyncElapsedFramesPolicy = SyncPolicyElapsedFrames
+	timeOffset, nextFrameIndex, err := c.Decide(frames, newTestDecisionContext())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if nextFrameIndex != 5 {
+		t.Fatal("elapsed frames must be accounted")
+	}
+
+	// Frame 3 was over in half of the range after the response, and frame 4 - in one more range,
+	// so half of the range is left in frame 5.
+	if time.Duration(timeOffset) > frameRange/2 || time.Duration(timeOffset) < frameRange/2-time.Second {
+		t.Fatal("invalid time offset")
+	}
+}
+
+// Checks that one observer with the broken clock can't shift the time offset of the majority.
+func TestMajorityFrameConsensus_MedianIgnoresOutlier(t *testing.T) {
+	defer setTestConsensusCount(3)()
+
+	now := time.Now()
+	TTLs := []time.Duration{time.Second * 10, time.Second * 11, time.Second * 12, time.Second * 119}
+
+	frames := make([]*responses.TimeFrame, 0, len(TTLs))
+	for i, TTL := range TTLs {
+		frame, err := responses.NewValidatedTimeFrame(uint16(i), 2, uint64(TTL), now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		frames = append(frames, frame)
+	}
+
+	c := &MajorityFrameConsensus{}
+	timeOffset, _, err := c.Decide(frames, newTestDecisionContext())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Median of 11s and 12s, corrected by the age of the responses.
+	expected := time.Millisecond * 11500
+	if time.Duration(timeOffset) > expected || time.Duration(timeOffset) < expected-time.Second {
+		t.Fatal("outlier must not affect the time offset")
+	}
+}
+
+func TestMajorityFrameConsensus_ConsensusNotReached(t *testing.T) {
+	defer setTestConsensusCount(3)()
+
+	frames := make([]*responses.TimeFrame, 0, 3)
+	for i := uint16(0); i < 3; i++ {
+		frame, err := responses.NewValidatedTimeFrame(i, i%2, uint64(time.Second), time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		frames = append(frames, frame)
+	}
+
+	c := &MajorityFrameConsensus{}
+	_, _, err := c.Decide(frames, newTestDecisionContext())
+	if err != errors.EmptySequence {
+		t.Fatal("decision must not be made without consensus")
+	}
+}
+
+func TestMajorityFrameConsensus_MedianNextFrameTTL(t *testing.T) {
+	c := &MajorityFrameConsensus{}
+	if c.medianNextFrameTTL(nil) != 0 {
+		t.Fatal()
+	}
+
+	offsets := []uint64{5, 1, 3}
+	if c.medianNextFrameTTL(offsets) != 3 {
+		t.Fatal()
+	}
+
+	if offsets[0] != 5 {
+		t.Fatal("offsets must not be reordered")
+	}
+
+	if c.medianNextFrameTTL([]uint64{math.MaxUint64, math.MaxUint64 - 2}) != math.MaxUint64-1 {
+		t.Fatal()
+	}
+}
+
+// newTestDecisionContext returns context of the decision, made at the current moment.
+func newTestDecisionContext() FrameDecisionContext {
+	return FrameDecisionContext{Now: time.Now()}
+}
+
+// setTestConsensusCount replaces consensus count and returns the function, that restores the previous one.
+func setTestConsensusCount(count int) (restore func()) {
+	defaultCount := settings.ObserversConsensusCount
+	settings.ObserversConsensusCount = count
+	return func() { settings.ObserversConsensusCount = defaultCount }
+}
+
+// Checks that one frame switch of the remote observer during synchronisation
+// is accounted in case of the single frame policy too.
+func TestMajorityFrameConsensus_FrameSwitchedDuringSync(t *testing.T) {
+	defer setTestConsensusCount(1)()
+
+	frameRange := settings.AverageBlockGenerationTimeRange
+	frame, err := responses.NewValidatedTimeFrame(0, 3, uint64(time.Second), time.Now().Add(-time.Second*2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &MajorityFrameConsensus{}
+	timeOffset, nextFrameIndex, err := c.Decide([]*responses.TimeFrame{frame}, newTestDecisionContext())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if nextFrameIndex != 4 {
+		t.Fatal("switched frame must be accounted")
+	}
+
+	expected := frameRange - time.Second
+	if time.Duration(timeOffset) > expected || time.Duration(timeOffset) < expected-time.Second {
+		t.Fatal("invalid time offset")
+	}
+}
+
+// Frame indexes, that are beyond the observers count of the current configuration, are dropped,
+// and the frame index of the switched frame is wrapped by the same count.
+func TestMajorityFrameConsensus_CurrentObserversCount(t *testing.T) {
+	defer setTestConsensusCount(2)()
+
+	now := time.Now()
+	frames := make([]*responses.TimeFrame, 0)
+	for i := uint16(0); i < 3; i++ {
+		// Frame 5 is legitimate for the max observers count, but not for the current configuration.
+		frame, err := responses.NewValidatedTimeFrame(i, 5, uint64(time.Second), now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		frames = append(frames, frame)
+	}
+
+	for i := uint16(3); i < 5; i++ {
+		// Frame 3 has been switched to the frame 0 during synchronisation.
+		frame, err := responses.NewValidatedTimeFrame(i, 3, uint64(time.Second), now.Add(-time.Second*2))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		frames = append(frames, frame)
+	}
+
+	c := &MajorityFrameConsensus{}
+	decision := FrameDecisionContext{Now: now, ObserversCount: 4}
+	rates, topFrameIndex := c.collectRates(frames, decision)
+	if len(rates) != 1 || topFrameIndex != 0 {
+		t.Fatal("frame indexes must be bounded by the current observers count")
+	}
+
+	_, nextFrameIndex, err := c.Decide(frames, decision)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if nextFrameIndex != 0 {
+		t.Fatal("switched frame index must be wrapped by the current observers count")
+	}
+}
+
+func TestTicker_FrameDecisionContext_ObserversCount(t *testing.T) {
+	ticker := newTestTicker()
+	if ticker.frameDecisionContext().observersCount() != settings.ObserversMaxCount {
+		t.Fatal("max observers count must be used in case if configuration is not known")
+	}
+
+	ticker.frame = &EventTimeFrameEnd{Index: 1, Conf: newTestConfiguration(4)}
+	if ticker.frameDecisionContext().ObserversCount != 4 {
+		t.Fatal()
+	}
+}
@@ -0,0 +1,75 @@
+package ticker
+
+import (
+	"geo-observers-blockchain/core/settings"
+	"time"
+)
+
+// desyncDetector decides when the ticker should be considered out of sync with other observers.
+// Single round, in which majority of observers has reported frame index collision,
+// might be caused by the transient network issues, so it must not lead to the resynchronisation.
+// Node is considered as desynchronized only when disagreements are present
+// in several rounds of the sliding window (see settings.TickerDesync* parameters).
+// Disagreements are ignored at all during the grace period after the ticker start.
+type desyncDetector struct {
+	gracePeriod time.Duration
+	windowSize  int
+	threshold   int
+
+	startedAt time.Time
+
+	// Results of the previous rounds (true == disagreement has been detected).
+	// Contains up to windowSize - 1 records, the current round is stored separately.
+	rounds              []bool
+	currentRoundFlagged bool
+}
+
+func newDesyncDetector() *desyncDetector {
+	return &desyncDetector{
+		gracePeriod: settings.TickerDesyncGracePeriod,
+		windowSize:  settings.TickerDesyncWindowSize,
+		threshold:   settings.TickerDesyncThreshold,
+	}
+}
+
+// start resets the detector's state and begins the grace period.
+func (d *desyncDetector) start(now time.Time) {
+	d.startedAt = now
+	d.rounds = d.rounds[:0]
+	d.currentRoundFlagged = false
+}
+
+// reportDisagreement marks current round as disagreed with the other observers.
+// Returns true if disagreements are sustained and the resynchronisation is needed.
+// In this case the detector is restarted.
+func (d *desyncDetector) reportDisagreement(now time.Time) (desynchronized bool) {
+	if now.Before(d.startedAt.Add(d.gracePeriod)) {
+		return false
+	}
+
+	d.currentRoundFlagged = true
+
+	disagreementsCount := 1
+	for _, flagged := range d.rounds {
+		if flagged {
+			disagreementsCount++
+		}
+	}
+
+	if disagreementsCount >= d.threshold {
+		d.start(now)
+		return true
+	}
+
+	return false
+}
+
+// nextRound closes current round and moves the window.
+func (d *desyncDetector) nextRound() {
+	d.rounds = append(d.rounds, d.currentRoundFlagged)
+	if len(d.rounds) > d.windowSize-1 {
+		d.rounds = d.rounds[len(d.rounds)-(d.windowSize-1):]
+	}
+
+	d.currentRoundFlagged = false
+}
@@ -0,0 +1,81 @@
+package ticker
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestDesyncDetector(now time.Time) *desyncDetector {
+	d := &desyncDetector{
+		gracePeriod: time.Minute,
+		windowSize:  5,
+		threshold:   3,
+	}
+
+	d.start(now)
+	return d
+}
+
+// Reports disagreements in single rounds, separated by the agreed rounds,
+// and checks that no resynchronisation is requested.
+func TestDesyncDetector_OneOffDisagreements(t *testing.T) {
+	now := time.Now()
+	d := newTestDesyncDetector(now)
+	now = now.Add(time.Minute * 2)
+
+	for round := 0; round < 20; round++ {
+		if round%3 == 0 {
+			if d.reportDisagreement(now) {
+				t.Fatal("one-off disagreements must not lead to the resynchronisation")
+			}
+
+			// Several reports in the same round are considered as one disagreement.
+			if d.reportDisagreement(now) {
+				t.Fatal()
+			}
+		}
+
+		d.nextRound()
+	}
+}
+
+// Reports disagreements in consecutive rounds and checks that resynchronisation is requested.
+func TestDesyncDetector_SustainedDisagreements(t *testing.T) {
+	now := time.Now()
+	d := newTestDesyncDetector(now)
+	now = now.Add(time.Minute * 2)
+
+	if d.reportDisagreement(now) {
+		t.Fatal()
+	}
+	d.nextRound()
+
+	if d.reportDisagreement(now) {
+		t.Fatal()
+	}
+	d.nextRound()
+
+	if !d.reportDisagreement(now) {
+		t.Fatal("sustained disagreements must lead to the resynchronisation")
+	}
+
+	// Detector must be restarted after the resynchronisation was requested,
+	// so the next disagreement is in the grace period.
+	d.nextRound()
+	if d.reportDisagreement(now) {
+		t.Fatal()
+	}
+}
+
+func TestDesyncDetector_GracePeriod(t *testing.T) {
+	now := time.Now()
+	d := newTestDesyncDetector(now)
+
+	for round := 0; round < 10; round++ {
+		if d.reportDisagreement(now.Add(time.Second * time.Duration(round))) {
+			t.Fatal("disagreements must be ignored during the grace period")
+		}
+
+		d.nextRound()
+	}
+}
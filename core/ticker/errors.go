@@ -3,7 +3,8 @@ package ticker
 import "geo-observers-blockchain/core/utils"
 
 var (
-	ErrInvalidSynchronisationTimeout = utils.Error("ticker", "synchronisation timeout is invalid")
+	ErrInvalidSynchronisationTimeout   = utils.Error("ticker", "synchronisation timeout is invalid")
+	ErrInvalidSynchronisationTimeRange = utils.Error("ticker", "synchronisation time range is invalid")
 
 	// todo: move errors here
 )
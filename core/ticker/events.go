@@ -18,6 +18,38 @@ type EventTimeFrameEnd struct {
 	FinalStageTimestamp time.Time
 }
 
+// EventConfigurationApplied is emitted each time when new observers configuration is installed into the ticker.
+type EventConfigurationApplied struct {
+	Conf *external.Configuration
+
+	// Frame index before and after the configuration change
+	// (index is remapped into the range of the new observers count).
+	PreviousFrameIndex uint16
+	FrameIndex         uint16
+}
+
+// EventSynchronisationFinished is emitted each time when synchronisation with other observers is finished
+// and the ticker is started (see Ticker.OutgoingEventsSynchronisationFinished).
+// Might be used for delaying any frame-dependent operations until the time frames are agreed.
+type EventSynchronisationFinished struct {
+	// Frame index, installed by the synchronisation.
+	FrameIndex uint16
+
+	// Amount of responses of other observers, that has been used.
+	ResponsesCount uint16
+
+	// Time left to the next frame at the moment of synchronisation finishing.
+	TimeOffset time.Duration
+
+	// Is set in case if no consensus with other observers has been reached (for example, no responses received),
+	// and the independent time frames flow with default parameters has been started.
+	IsFallback bool
+}
+
 // EventTickerStarted is emitted each time when internal ticker ticker is started,
 // for example (when synchronisation is finished).
 type EventTickerStarted struct{}
+
+// EventTickerPauseChanged is emitted each time when ticks emission is paused or resumed.
+// Interrupts internal events loop, so the ticks timer would be updated.
+type EventTickerPauseChanged struct{}
@@ -0,0 +1,163 @@
+package ticker
+
+import (
+	"geo-observers-blockchain/core/settings"
+	log "github.com/sirupsen/logrus"
+	"sync/atomic"
+	"time"
+)
+
+// resyncPeriodically resynchronises running ticker with other observers
+// each settings.TickerResyncInterval, until stop is closed.
+// Ticker is not stopped during resynchronisation.
+func (t *Ticker) resyncPeriodically(stop <-chan struct{}) {
+	if settings.TickerResyncInterval <= 0 {
+		return
+	}
+
+	for {
+		select {
+		case <-stop:
+			return
+
+		case <-t.clock.After(settings.TickerResyncInterval):
+			t.resyncWithOtherObservers()
+		}
+	}
+}
+
+// resyncWithOtherObservers collects time frames of other observers (as on synchronisation)
+// and merges the result into the running ticker (see mergeSyncResult()).
+// Ticker that is not running, or is paused, is left as is.
+func (t *Ticker) resyncWithOtherObservers() {
+	t.frameMutex.Lock()
+	isApplicable := t.isTickerRunning && t.pausedAt.IsZero()
+	t.frameMutex.Unlock()
+	if !isApplicable {
+		return
+	}
+
+	if !atomic.CompareAndSwapInt32(&t.isSyncInProgress, 0, 1) {
+		t.log().Debug("Synchronisation is already in progress, resynchronisation skipped")
+		return
+	}
+	defer atomic.StoreInt32(&t.isSyncInProgress, 0)
+
+	defer func() {
+		responsesCollected, deadline, _ := t.SyncProgress()
+		t.updateSyncProgress(responsesCollected, deadline, true)
+	}()
+
+	nextFrameOffset, nextFrameIndex, responsesCollected, err := t.processSync()
+	if err != nil {
+		t.log().WithFields(log.Fields{"ResponsesCount": responsesCollected}).Warn(
+			"Resynchronisation failed, current time frames flow is preserved: ", err)
+		return
+	}
+
+	drift, merged := t.mergeSyncResult(nextFrameIndex, time.Duration(nextFrameOffset), t.clock.Now())
+	if merged {
+		// Ticks timer must be recreated with the adjusted timestamp.
+		t.interruptInternalLoop()
+		t.log().WithFields(log.Fields{"ResponsesCount": responsesCollected, "Drift": drift}).Info(
+			"Resynchronisation is done")
+		return
+	}
+
+	// Drift is too big to be corrected gradually: ticker is out of sync with other observers.
+	t.log().WithFields(log.Fields{"ResponsesCount": responsesCollected, "Drift": drift}).Warn(
+		"Time frames flow is out of sync, frame index is replaced")
+
+	t.frameMutex.Lock()
+	t.frame = &EventTimeFrameEnd{
+		Index: nextFrameIndex,
+		Conf:  t.frame.Conf,
+	}
+	t.nextFrameTimestamp = t.clock.Now().Add(time.Duration(nextFrameOffset))
+	t.frameMutex.Unlock()
+
+	t.interruptInternalLoop()
+}
+
+// mergeSyncResult adjusts the next frame timestamp of the running ticker to the synchronisation result
+// (frame index and the time left to the next frame, decided by other observers) without the frame index change,
+// so no frame is emitted twice or skipped.
+//
+// Drift is the amount of time, by which the next frame of the ticker must be postponed
+// (negative drift means that the ticker is late). One merge shifts the next frame timestamp
+// at most by settings.TickerResyncMaxAdjustment, the rest of the drift is left to the next resynchronisations.
+// Next frame timestamp is never moved closer than settings.TickerMinFrameTimeLeft.
+//
+// Returns false (and leaves the ticker as is) in case if drift is not less than a half of the time frame:
+// such difference can't be considered as the clock drift.
+func (t *Ticker) mergeSyncResult(index uint16, timeLeft time.Duration, now time.Time) (drift time.Duration, merged bool) {
+	t.frameMutex.Lock()
+	defer t.frameMutex.Unlock()
+
+	if t.frame.Index == kInitialTimeFrameIndex {
+		return 0, false
+	}
+
+	frameRange := settings.AverageBlockGenerationTimeRange
+	framesAhead := framesDistance(t.frame.Index, index, framesInConfiguration(t.frame.Conf))
+	drift = timeLeft - t.nextFrameTimestamp.Sub(now) - time.Duration(framesAhead)*frameRange
+	t.recordDrift(drift)
+
+	if drift >= frameRange/2 || drift <= -frameRange/2 {
+		return drift, false
+	}
+
+	adjustment := drift
+	if adjustment > settings.TickerResyncMaxAdjustment {
+		adjustment = settings.TickerResyncMaxAdjustment
+	} else if adjustment < -settings.TickerResyncMaxAdjustment {
+		adjustment = -settings.TickerResyncMaxAdjustment
+	}
+
+	nextFrameTimestamp := t.nextFrameTimestamp.Add(adjustment)
+	if nextFrameTimestamp.Before(now.Add(settings.TickerMinFrameTimeLeft)) {
+		nextFrameTimestamp = now.Add(settings.TickerMinFrameTimeLeft)
+	}
+
+	t.nextFrameTimestamp = nextFrameTimestamp
+	return drift, true
+}
+
+// framesDistance returns amount of frames from one frame index to another one,
+// in the range [-framesCount/2, framesCount/2) (frame indexes are wrapped by frames count).
+func framesDistance(from, to uint16, framesCount int) int {
+	distance := (int(to) - int(from)) % framesCount
+	if distance < 0 {
+		distance += framesCount
+	}
+
+	if distance >= (framesCount+1)/2 {
+		distance -= framesCount
+	}
+
+	return distance
+}
+
+// recordDrift stores drift of the frames of the ticker from the frames of the majority of other observers
+// (see Drift()). Drift, that exceeds settings.TickerDriftWarningThreshold, is reported to the log.
+func (t *Ticker) recordDrift(drift time.Duration) {
+	atomic.StoreInt64(&t.drift, int64(drift))
+
+	absDrift := drift
+	if absDrift < 0 {
+		absDrift = -absDrift
+	}
+
+	if settings.TickerDriftWarningThreshold > 0 && absDrift > settings.TickerDriftWarningThreshold {
+		t.log().WithFields(log.Fields{"Drift": drift, "Threshold": settings.TickerDriftWarningThreshold}).Warn(
+			"Time frames of the observer diverge from the frames of the majority of other observers")
+	}
+}
+
+// Drift returns the last measured drift of the frames of the ticker from the frames
+// of the majority of other observers (positive drift means that the ticker is ahead of other observers).
+// Drift is measured on each resynchronisation of the running ticker. Returns 0 if it has not been measured yet.
+// It is safe to call this method from any goroutine.
+func (t *Ticker) Drift() time.Duration {
+	return time.Duration(atomic.LoadInt64(&t.drift))
+}
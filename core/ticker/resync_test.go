@@ -0,0 +1,113 @@
+package ticker
+
+import (
+	"geo-observers-blockchain/core/settings"
+	"testing"
+	"time"
+)
+
+func newTestRunningTicker(frameIndex uint16, nextFrameTimestamp time.Time) *Ticker {
+	ticker := newTestTicker()
+	ticker.frame.Index = frameIndex
+	ticker.nextFrameTimestamp = nextFrameTimestamp
+	ticker.isTickerRunning = true
+	return ticker
+}
+
+// Injects known drift of the local clock and checks that it is corrected without the frame index change.
+func TestTicker_MergeSyncResult_Drift(t *testing.T) {
+	now := time.Now()
+	ticker := newTestRunningTicker(5, now.Add(time.Second*10))
+
+	drift, merged := ticker.mergeSyncResult(5, time.Second*12, now)
+	if !merged || drift != time.Second*2 {
+		t.Fatal()
+	}
+
+	if ticker.Drift() != time.Second*2 || ticker.Status().Drift != time.Second*2 {
+		t.Fatal("drift must be exposed")
+	}
+
+	if !ticker.nextFrameTimestamp.Equal(now.Add(time.Second*12)) || ticker.frame.Index != 5 {
+		t.Fatal("next frame must be postponed by the drift, frame index must be preserved")
+	}
+}
+
+// Checks that the drift, greater than max adjustment, is corrected gradually.
+func TestTicker_MergeSyncResult_MaxAdjustment(t *testing.T) {
+	defaultMaxAdjustment := settings.TickerResyncMaxAdjustment
+	settings.TickerResyncMaxAdjustment = time.Second
+	defer func() { settings.TickerResyncMaxAdjustment = defaultMaxAdjustment }()
+
+	now := time.Now()
+	ticker := newTestRunningTicker(5, now.Add(time.Second*10))
+
+	for i := 1; i <= 3; i++ {
+		_, merged := ticker.mergeSyncResult(5, time.Second*13, now)
+		if !merged {
+			t.Fatal()
+		}
+
+		if !ticker.nextFrameTimestamp.Equal(now.Add(time.Second * time.Duration(10+i))) {
+			t.Fatal("drift must be corrected gradually")
+		}
+	}
+}
+
+// Simulates other observers, that are one frame ahead (including the wrap of the frame index),
+// and checks that late ticker's next frame is brought closer, but is not emitted immediately.
+func TestTicker_MergeSyncResult_NextFrame(t *testing.T) {
+	frameRange := settings.AverageBlockGenerationTimeRange
+	lastIndex := uint16(settings.ObserversMaxCount - 1)
+
+	now := time.Now()
+	ticker := newTestRunningTicker(lastIndex, now.Add(time.Second*2))
+
+	drift, merged := ticker.mergeSyncResult(0, frameRange-time.Second, now)
+	if !merged || drift != -time.Second*3 {
+		t.Fatal()
+	}
+
+	if !ticker.nextFrameTimestamp.Equal(now.Add(settings.TickerMinFrameTimeLeft)) {
+		t.Fatal("next frame timestamp must not be moved into the past")
+	}
+
+	if ticker.frame.Index != lastIndex {
+		t.Fatal("frame index must be changed only by the tick")
+	}
+}
+
+// Checks that the difference, that can't be considered as the clock drift, is not merged.
+func TestTicker_MergeSyncResult_OutOfSync(t *testing.T) {
+	now := time.Now()
+	timestamp := now.Add(time.Second * 10)
+	ticker := newTestRunningTicker(5, timestamp)
+
+	_, merged := ticker.mergeSyncResult(8, time.Second*10, now)
+	if merged || !ticker.nextFrameTimestamp.Equal(timestamp) {
+		t.Fatal()
+	}
+
+	if ticker.Drift() != -settings.AverageBlockGenerationTimeRange*3 {
+		t.Fatal("drift must be measured even if it is not merged")
+	}
+
+	ticker = newTestRunningTicker(kInitialTimeFrameIndex, timestamp)
+	_, merged = ticker.mergeSyncResult(0, time.Second*10, now)
+	if merged {
+		t.Fatal("not synchronised ticker must not be merged")
+	}
+}
+
+func TestTicker_Drift_NotMeasured(t *testing.T) {
+	if newTestTicker().Drift() != 0 {
+		t.Fatal()
+	}
+}
+
+func TestFramesDistance(t *testing.T) {
+	if framesDistance(1, 3, 4) != -2 || framesDistance(3, 0, 4) != 1 ||
+		framesDistance(0, 3, 4) != -1 || framesDistance(2, 2, 4) != 0 {
+		t.Fatal()
+	}
+}
@@ -1,6 +1,8 @@
 package ticker
 
 import (
+	"context"
+	"geo-observers-blockchain/core/common"
 	errors2 "geo-observers-blockchain/core/common/errors"
 	"geo-observers-blockchain/core/network/communicator/observers/requests"
 	"geo-observers-blockchain/core/network/communicator/observers/responses"
@@ -8,12 +10,11 @@ import (
 	"geo-observers-blockchain/core/settings"
 	log "github.com/sirupsen/logrus"
 	"math"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// todo: think about time synchronisation once in a week/month,
-//       to prevent permanent delta increasing and time frames shifting.
-
 const (
 	// WARN!
 	// Initial time frame index can't be 0, because it is valid index.
@@ -30,11 +31,18 @@ const (
 
 type Ticker struct {
 	OutgoingEventsTimeFrameEnd         chan *EventTimeFrameEnd
-	OutgoingRequestsTimeFrames         chan *requests.SynchronisationTimeFrames
-	IncomingRequestsTimeFrames         chan *requests.SynchronisationTimeFrames
-	OutgoingResponsesTimeFrame         chan *responses.TimeFrame
-	IncomingResponsesTimeFrame         chan *responses.TimeFrame
-	IncomingRequestsTimeFrameCollision chan *requests.TimeFrameCollision
+	OutgoingEventsConfigurationApplied chan *EventConfigurationApplied
+
+	// Only the last event is kept in case if events are not consumed.
+	OutgoingEventsSynchronisationFinished chan *EventSynchronisationFinished
+	OutgoingRequestsTimeFrames            chan *requests.SynchronisationTimeFrames
+	IncomingRequestsTimeFrames            chan *requests.SynchronisationTimeFrames
+	OutgoingResponsesTimeFrame            chan *responses.TimeFrame
+	IncomingResponsesTimeFrame            chan *responses.TimeFrame
+	IncomingRequestsTimeFrameCollision    chan *requests.TimeFrameCollision
+
+	// Observers configuration changes (see reconfigureFrames()).
+	IncomingEventsConfigurationChanged chan *external.EventConfigurationChanged
 
 	// Internal events bus is used for controlling internal events loop.
 	// For example, in case if synchronisation with external observers is finished,
@@ -42,19 +50,40 @@ type Ticker struct {
 	internalEventsBus chan interface{}
 
 	// External observers configuration reporter.
-	confReporter *external.Reporter
+	confReporter configurationReporter
+
+	// Source of the current time and of the timers (see Clock).
+	clock Clock
 
 	// Time left for the next time frame.
 	// By default, it is equal to the block generation time duration,
 	// but might be set to lover value after Sync() call.
+	// Must be accessed only under the frameMutex.
 	nextFrameTimestamp time.Time
 
+	// Moment when ticks emission was paused (see Pause()).
+	// Zero if ticker is not paused.
+	// Must be accessed only under the frameMutex.
+	pausedAt time.Time
+
 	// Time when synchronisation must be finished.
 	synchronisationDeadlineTimestamp time.Time
 
+	// Time of sending of the synchronisation request of the current (or the last) synchronisation round.
+	// Responses, that echo another time of sending, are late responses of the previous rounds.
+	// Accessed only by the synchronisation goroutine.
+	synchronisationRequestSent time.Time
+
+	// Set to 1 while synchronisation is in progress.
+	// Prevents several synchronisations from running concurrently
+	// (for example, on collision detected during the startup synchronisation).
+	isSyncInProgress int32
+
 	// If true - then ticker is synchronized and is generating new ticks.
 	// By default is set to "false", because it is expected,
 	// that ticker would be synchronized first.
+	// Is changed only by the internal events loop, under the frameMutex
+	// (so it might be read by the internal events loop without the lock).
 	isTickerRunning bool
 
 	// Frame == current time window.
@@ -67,31 +96,111 @@ type Ticker struct {
 	// process begins from the beginning.
 	frame *EventTimeFrameEnd
 
+	// Frame might be replaced from several goroutines
+	// (ticks processing, synchronisation, configuration change),
+	// so it must be accessed only under this lock.
+	frameMutex sync.Mutex
+
 	// Invalid frames reports
 	ObserversReportedInvalidIndex map[uint16]bool
+
+	// Prevents resynchronisation on the single disagreement with other observers.
+	desync *desyncDetector
+
+	// Algorithm, that is used for deciding the current time frame during synchronisation.
+	consensus FrameConsensus
+
+	// Source of the round trip times of the connections to the remote observers (see SetRoundTripTimes()).
+	// Might be nil.
+	roundTripTimes RoundTripTimesProvider
+
+	// Receives reports of the remote observers, that has responded with the frame indexes
+	// out of the legitimate range (see SetMisbehaviourReporter()). Might be nil.
+	misbehaviour external.MisbehaviourReporter
+
+	// Controls stopping of the internal events loop (see Stop()).
+	lifecycle common.Lifecycle
+
+	// Amount of requests from the remote observers, that has been dropped because of the full queue.
+	// Must be accessed atomically.
+	droppedRequestsCount uint64
+
+	// Amount of ticks, that has been dropped because nobody has read them in time.
+	// Must be accessed atomically.
+	droppedTicksCount uint64
+
+	// Last measured drift of the frames from the frames of the majority of other observers (see Drift()).
+	// Must be accessed atomically.
+	drift int64
+
+	// Synchronisation progress.
+	// It is updated by the synchronisation goroutine,
+	// but might be read from any other goroutine (see SyncProgress()).
+	progress syncProgress
+}
+
+type syncProgress struct {
+	mutex              sync.Mutex
+	responsesCollected int
+	deadline           time.Time
+	done               bool
 }
 
 func New(reporter *external.Reporter) *Ticker {
-	initialConfiguration, _ := reporter.GetCurrentConfiguration()
+	return NewWithClock(reporter, RealClock)
+}
+
+// NewWithClock returns ticker, that uses the clock specified instead of the system one.
+// Nil clock means RealClock.
+func NewWithClock(reporter *external.Reporter, clock Clock) *Ticker {
+	if clock == nil {
+		clock = RealClock
+	}
+
+	// Nil reporter must not be wrapped into the (non nil) interface.
+	var confSource configurationReporter
+	if reporter != nil {
+		confSource = reporter
+	}
+
+	initialConfiguration := loadInitialConfiguration(confSource)
 
 	return &Ticker{
-		// Outgoing events channel is not buffered.
+		// Outgoing events channel is not buffered by default.
 		// It is better to lost ticker tick, than process several ticks
 		// one by one without any delay, that might be considered as, malicious behaviour.
-		OutgoingEventsTimeFrameEnd: make(chan *EventTimeFrameEnd),
+		// Small buffer might be configured to tolerate momentary lag of the reader
+		// (see settings.TickerTicksBufferSize).
+		OutgoingEventsTimeFrameEnd: make(chan *EventTimeFrameEnd, settings.TickerTicksBufferSize),
+
+		// Configuration changes are rare, so one slot is enough.
+		OutgoingEventsConfigurationApplied: make(chan *EventConfigurationApplied, 1),
+
+		// Synchronisations are rare too.
+		OutgoingEventsSynchronisationFinished: make(chan *EventSynchronisationFinished, 1),
+
 		OutgoingRequestsTimeFrames: make(chan *requests.SynchronisationTimeFrames, 1),
 		OutgoingResponsesTimeFrame: make(chan *responses.TimeFrame, 1),
 
 		// On synchronization stage,
 		// ticker should be able to collect up to MAX OBSERVERS count of responses.
-		IncomingResponsesTimeFrame:         make(chan *responses.TimeFrame, settings.ObserversMaxCount),
-		IncomingRequestsTimeFrames:         make(chan *requests.SynchronisationTimeFrames, 1),
-		IncomingRequestsTimeFrameCollision: make(chan *requests.TimeFrameCollision, 1),
+		IncomingResponsesTimeFrame: make(chan *responses.TimeFrame, settings.ObserversMaxCount),
+
+		// Requests from the remote observers might arrive in bursts,
+		// so they are enqueued (see EnqueueRequest()).
+		IncomingRequestsTimeFrames:         make(chan *requests.SynchronisationTimeFrames, settings.TickerIncomingRequestsQueueSize),
+		IncomingRequestsTimeFrameCollision: make(chan *requests.TimeFrameCollision, settings.TickerIncomingRequestsQueueSize),
+
+		// Configuration changes are rare, so one slot is enough.
+		IncomingEventsConfigurationChanged: make(chan *external.EventConfigurationChanged, 1),
 
 		// Internal events bus is used to control and to interrupt internal events loop.
 		internalEventsBus: make(chan interface{}, 1),
 
-		confReporter: reporter,
+		confReporter: confSource,
+		clock:        clock,
+		consensus:    frameConsensus(settings.TickerFrameConsensusAlgorithm),
+		desync:       newDesyncDetector(),
 
 		frame: &EventTimeFrameEnd{
 			Index: kInitialTimeFrameIndex,
@@ -102,11 +211,67 @@ func New(reporter *external.Reporter) *Ticker {
 	}
 }
 
+// RoundTripTimesProvider is implemented by observers.Sender (see observers.ConnectionsMap.Ping()).
+type RoundTripTimesProvider interface {
+	RoundTripTimeByIndex(index uint16) (rtt time.Duration, isKnown bool)
+}
+
+// SetRoundTripTimes makes the ticker to account the round trip times of the connections to the remote observers
+// on synchronisation (see responses.TimeFrame.ObserverRoundTripTime).
+// Must be called before Run().
+func (t *Ticker) SetRoundTripTimes(provider RoundTripTimesProvider) {
+	t.roundTripTimes = provider
+}
+
+// SetMisbehaviourReporter makes the ticker to report the remote observers,
+// that has responded with the frame indexes out of the range of the current configuration.
+// Must be called before Run().
+func (t *Ticker) SetMisbehaviourReporter(reporter external.MisbehaviourReporter) {
+	t.misbehaviour = reporter
+}
+
+// configurationReporter is implemented by external.Reporter.
+type configurationReporter interface {
+	GetCurrentConfiguration() (*external.Configuration, error)
+}
+
+// loadInitialConfiguration returns current observers configuration.
+// In case if configuration can't be received - empty configuration (without observers) is returned,
+// so the frame configuration is never nil. Observers index of the empty configuration is unknown (MAX uint16).
+// Ticker with empty configuration keeps running, and is expected to be reconfigured via SetConfiguration().
+func loadInitialConfiguration(reporter configurationReporter) (conf *external.Configuration) {
+	var err error
+	if reporter == nil {
+		err = errors2.NilParameter
+	} else {
+		conf, err = reporter.GetCurrentConfiguration()
+		if err == nil && conf == nil {
+			err = errors2.NilParameter
+		}
+	}
+
+	if err != nil {
+		log.WithFields(log.Fields{"prefix": "Ticker"}).Error(
+			"Can't get current observers configuration, empty configuration would be used: ", err)
+
+		conf = external.NewConfiguration(0, nil)
+		conf.CurrentObserverIndex = math.MaxUint16
+	}
+
+	return
+}
+
 func (t *Ticker) Run(errors chan error) {
+	stop := t.lifecycle.Start()
+	defer t.lifecycle.Done()
+
 	shortLoop := func() {
 		select {
+		case <-stop:
+			return
 
-		// todo: reconfigure frames on external observers configuration change
+		case event := <-t.IncomingEventsConfigurationChanged:
+			errors2.SendErrorIfAny(t.reconfigureFrames(event), errors)
 
 		//case timeFramesRequest := <-t.IncomingRequestsTimeFrames:
 		//	errors2.SendErrorIfAny(
@@ -119,11 +284,20 @@ func (t *Ticker) Run(errors chan error) {
 	}
 
 	fullLoop := func() {
+		// Internal events and collisions reports are processed first,
+		// so they are never starved by the flood of time frames requests from the remote observers.
+		if t.processPrioritizedEvent(errors) {
+			return
+		}
+
 		select {
+		case <-stop:
+			return
 
-		// todo: reconfigure frames on external observers configuration change
+		case event := <-t.IncomingEventsConfigurationChanged:
+			errors2.SendErrorIfAny(t.reconfigureFrames(event), errors)
 
-		case _ = <-time.After(t.nextFrameTimeLeft()):
+		case _ = <-t.nextTickTimer():
 			t.processTick()
 
 		case timeFramesRequest := <-t.IncomingRequestsTimeFrames:
@@ -141,20 +315,13 @@ func (t *Ticker) Run(errors chan error) {
 	}
 
 	// WARN!
-	// Whole synchronisation flow MUST perform faster than one block generation timeout.
-	// At the moment, current logic does not support time frames synchronisation
-	// when 2 or more blocks was generated during synchronisation.
-	//
-	// todo: add support of short blocks timeouts.
-
-	var (
-		kMinimalTimeFramesExchangeTimeoutSeconds = 2
-		kMinimalAppropriateTimeoutSeconds        = int(settings.AverageBlockGenerationTimeRange.Seconds()) -
-			kMinimalTimeFramesExchangeTimeoutSeconds
-	)
-	if int(settings.TickerSynchronisationTimeRange.Seconds()) >= kMinimalAppropriateTimeoutSeconds {
-		// todo: replace panic
-		panic(ErrInvalidSynchronisationTimeout)
+	// Whole synchronisation flow MUST perform faster than one block generation timeout,
+	// unless elapsed frames are accounted (see SyncPolicyElapsedFrames).
+	err := ValidateSettings()
+	if err != nil {
+		t.log().Error("Ticker can't be started: ", err)
+		errors2.SendErrorIfAny(err, errors)
+		return
 	}
 
 	// Attempt to sync with other observers before any operations processing.
@@ -163,7 +330,18 @@ func (t *Ticker) Run(errors chan error) {
 	// Static assert check.
 	go t.syncWithOtherObservers()
 
+	// Running ticker is resynchronised periodically,
+	// to prevent permanent delta increasing and time frames shifting.
+	go t.resyncPeriodically(stop)
+
 	for {
+		select {
+		case <-stop:
+			t.log().Info("Stopped")
+			return
+		default:
+		}
+
 		if t.isTickerRunning {
 			fullLoop()
 
@@ -173,32 +351,248 @@ func (t *Ticker) Run(errors chan error) {
 	}
 }
 
+// ValidateSettings checks that synchronisation settings are consistent with the block generation time range.
+// Must be checked before the ticker start.
+func ValidateSettings() error {
+	return validateSynchronisationSettings(
+		settings.TickerSynchronisationTimeRange,
+		settings.TickerSynchronisationTimeout,
+		settings.AverageBlockGenerationTimeRange,
+		settings.TickerSyncElapsedFramesPolicy)
+}
+
+// validateSynchronisationSettings checks that synchronisation time range is positive,
+// that synchronisation timeout is greater than the synchronisation time range,
+// and (in case if elapsed frames are not accounted) that synchronisation time range
+// with the time frames exchange reserve is less than block generation time range.
+func validateSynchronisationSettings(
+	syncTimeRange, syncTimeout, blockGenerationTimeRange time.Duration, policy string) error {
+
+	if syncTimeRange <= 0 {
+		return ErrInvalidSynchronisationTimeRange
+	}
+
+	if syncTimeout <= syncTimeRange {
+		return ErrInvalidSynchronisationTimeout
+	}
+
+	if policy != SyncPolicyElapsedFrames &&
+		syncTimeRange+settings.TickerSynchronisationExchangeTimeRange >= blockGenerationTimeRange {
+		return ErrInvalidSynchronisationTimeRange
+	}
+
+	return nil
+}
+
+// Stop interrupts internal events loop and waits until it is finished.
+// Implements common.Subsystem.
+func (t *Ticker) Stop(ctx context.Context) error {
+	return t.lifecycle.Stop(ctx)
+}
+
+// EnqueueRequest schedules request from the remote observer for processing.
+// Never blocks: in case if the queue is full - request is dropped,
+// errors.ChannelTransferringFailed is returned, and the drop is accounted (see DroppedRequestsCount()).
+// It is safe to call this method from any goroutine.
+func (t *Ticker) EnqueueRequest(request requests.Request) (err error) {
+	switch request.(type) {
+	case *requests.SynchronisationTimeFrames:
+		select {
+		case t.IncomingRequestsTimeFrames <- request.(*requests.SynchronisationTimeFrames):
+			return nil
+		default:
+		}
+
+	case *requests.TimeFrameCollision:
+		select {
+		case t.IncomingRequestsTimeFrameCollision <- request.(*requests.TimeFrameCollision):
+			return nil
+		default:
+		}
+
+	default:
+		return errors2.UnexpectedDataType
+	}
+
+	atomic.AddUint64(&t.droppedRequestsCount, 1)
+	return errors2.ChannelTransferringFailed
+}
+
+// DroppedRequestsCount returns amount of requests, dropped because of the full queue.
+// It is safe to call this method from any goroutine.
+func (t *Ticker) DroppedRequestsCount() uint64 {
+	return atomic.LoadUint64(&t.droppedRequestsCount)
+}
+
+// DroppedTicksCount returns amount of ticks, dropped because they have not been consumed in time.
+// It is safe to call this method from any goroutine.
+func (t *Ticker) DroppedTicksCount() uint64 {
+	return atomic.LoadUint64(&t.droppedTicksCount)
+}
+
+// Status is a snapshot of the ticker state.
+type Status struct {
+	IsRunning            bool          `json:"running"`
+	IsPaused             bool          `json:"paused"`
+	IsSyncInProgress     bool          `json:"sync_in_progress"`
+	FrameIndex           uint16        `json:"frame"`
+	ObserversCount       int           `json:"observers"`
+	NextFrameTimeLeft    time.Duration `json:"next_frame_time_left"`
+	DroppedRequestsCount uint64        `json:"dropped_requests"`
+	DroppedTicksCount    uint64        `json:"dropped_ticks"`
+	Drift                time.Duration `json:"drift"`
+}
+
+// Status returns current state of the ticker.
+// Next frame time left is reported only for the running ticker.
+// It is safe to call this method from any goroutine.
+func (t *Ticker) Status() (status Status) {
+	t.frameMutex.Lock()
+	status.IsRunning = t.isTickerRunning
+	status.IsPaused = !t.pausedAt.IsZero()
+	status.FrameIndex = t.frame.Index
+	status.ObserversCount = observersInConfiguration(t.frame.Conf)
+	t.frameMutex.Unlock()
+
+	if status.IsRunning {
+		status.NextFrameTimeLeft = t.nextFrameTimeLeft()
+	}
+
+	status.IsSyncInProgress = atomic.LoadInt32(&t.isSyncInProgress) == 1
+	status.DroppedRequestsCount = t.DroppedRequestsCount()
+	status.DroppedTicksCount = t.DroppedTicksCount()
+	status.Drift = t.Drift()
+	return
+}
+
+// processPrioritizedEvent processes one pending internal event or, if there are no such events,
+// one pending collision report. Returns false if there was nothing to process.
+// Never blocks.
+func (t *Ticker) processPrioritizedEvent(errors chan error) (processed bool) {
+	select {
+	case event := <-t.internalEventsBus:
+		errors2.SendErrorIfAny(t.processInternalEvent(event), errors)
+		return true
+	default:
+	}
+
+	select {
+	case request := <-t.IncomingRequestsTimeFrameCollision:
+		errors2.SendErrorIfAny(t.processTimeFrameCollisionRequest(request), errors)
+		return true
+	default:
+	}
+
+	return false
+}
+
+// SyncProgress reports current state of the synchronisation with other observers:
+// amount of time frames responses collected, time when synchronisation must be finished,
+// and the flag that is set when synchronisation is done.
+// It is safe to call this method from any goroutine.
+func (t *Ticker) SyncProgress() (responsesCollected int, deadline time.Time, done bool) {
+	t.progress.mutex.Lock()
+	defer t.progress.mutex.Unlock()
+
+	return t.progress.responsesCollected, t.progress.deadline, t.progress.done
+}
+
+func (t *Ticker) updateSyncProgress(responsesCollected int, deadline time.Time, done bool) {
+	t.progress.mutex.Lock()
+	defer t.progress.mutex.Unlock()
+
+	t.progress.responsesCollected = responsesCollected
+	t.progress.deadline = deadline
+	t.progress.done = done
+}
+
 func (t *Ticker) syncWithOtherObservers() {
+	if !atomic.CompareAndSwapInt32(&t.isSyncInProgress, 0, 1) {
+		t.log().Debug("Synchronisation is already in progress, request dropped")
+		return
+	}
+	defer atomic.StoreInt32(&t.isSyncInProgress, 0)
+
+	defer func() {
+		responsesCollected, deadline, _ := t.SyncProgress()
+		t.updateSyncProgress(responsesCollected, deadline, true)
+	}()
+
+	// Whole synchronisation routine must be finished until this moment,
+	// otherwise it is abandoned (goroutine must never hang).
+	timeoutTimestamp := t.clock.Now().Add(settings.TickerSynchronisationTimeout)
+
 	setNextTick := func(offset time.Duration) {
-		t.nextFrameTimestamp = time.Now().Add(offset)
+		t.frameMutex.Lock()
+		t.nextFrameTimestamp = t.clock.Now().Add(offset)
+		t.frameMutex.Unlock()
 
 		// Interrupt internal loop, so this change would be processed.
-		t.internalEventsBus <- &EventTickerStarted{}
+		select {
+		case t.internalEventsBus <- &EventTickerStarted{}:
+		case <-t.clock.After(timeoutTimestamp.Sub(t.clock.Now())):
+			t.log().Error("Synchronisation timeout: internal events loop does not respond, synchronisation abandoned")
+		}
 	}
 
 	t.log().Info("Synchronization started")
 
 	nextFrameOffset, nextFrameIndex, responsesCollected, err := t.processSync()
-	if err == errors2.EmptySequence {
-		t.log().WithFields(
-			log.Fields{"ResponsesCount": 0}).Info("Synchronisation is done")
+	if t.clock.Now().After(timeoutTimestamp) {
+		t.log().Error("Synchronisation timeout, synchronisation abandoned")
+		return
+	}
+
+	if err != nil {
+		if err == errors2.EmptySequence {
+			t.log().WithFields(
+				log.Fields{"ResponsesCount": 0}).Info("Synchronisation is done")
+		} else {
+			t.log().WithFields(
+				log.Fields{"ResponsesCount": responsesCollected}).Warn("Synchronisation failed: ", err)
+		}
 		t.log().Warn("Independent time frames flow started")
 
-		// Use default block generation time range.
-		t.frame = &EventTimeFrameEnd{Index: nextFrameIndex}
+		// Decision of the failed synchronisation can't be trusted:
+		// use the first frame and the default block generation time range.
+		nextFrameIndex = 0
+		t.setFrameIndex(nextFrameIndex)
 		setNextTick(settings.AverageBlockGenerationTimeRange)
+		t.emitSynchronisationFinished(&EventSynchronisationFinished{
+			FrameIndex:     nextFrameIndex,
+			ResponsesCount: responsesCollected,
+			TimeOffset:     settings.AverageBlockGenerationTimeRange,
+			IsFallback:     true,
+		})
 
 	} else {
 		t.log().WithFields(
 			log.Fields{"ResponsesCount": responsesCollected}).Info("Synchronisation is done")
 
-		t.frame = &EventTimeFrameEnd{Index: nextFrameIndex}
+		t.setFrameIndex(nextFrameIndex)
 		setNextTick(time.Nanosecond * time.Duration(nextFrameOffset))
+		t.emitSynchronisationFinished(&EventSynchronisationFinished{
+			FrameIndex:     nextFrameIndex,
+			ResponsesCount: responsesCollected,
+			TimeOffset:     time.Nanosecond * time.Duration(nextFrameOffset),
+		})
+	}
+}
+
+// emitSynchronisationFinished reports synchronisation result.
+// Never blocks: in case if previous event has not been consumed yet - it is replaced.
+func (t *Ticker) emitSynchronisationFinished(event *EventSynchronisationFinished) {
+	for {
+		select {
+		case t.OutgoingEventsSynchronisationFinished <- event:
+			return
+		default:
+		}
+
+		select {
+		case <-t.OutgoingEventsSynchronisationFinished:
+		default:
+		}
 	}
 }
 
@@ -208,22 +602,34 @@ func (t *Ticker) processSync() (
 	// Request external observers for their current time frames data.
 	// Ticker would process all collected responses and
 	// would adjust it's own configuration in accordance to the majority.
+	request := requests.NewSynchronisationTimeFrames()
+	t.synchronisationRequestSent = request.Sent
+
+	// Responses of the previous rounds, that has arrived after their deadlines,
+	// must not be counted in this round.
+	t.discardLateFrameResponses()
+
 	select {
-	case t.OutgoingRequestsTimeFrames <- &requests.SynchronisationTimeFrames{}:
+	case t.OutgoingRequestsTimeFrames <- request:
 	default:
 		err = errors2.ChannelTransferringFailed
 		return
 	}
 
-	t.synchronisationDeadlineTimestamp = time.Now().Add(settings.TickerSynchronisationTimeRange)
+	t.synchronisationDeadlineTimestamp = t.clock.Now().Add(settings.TickerSynchronisationTimeRange)
+	t.updateSyncProgress(0, t.synchronisationDeadlineTimestamp, false)
 
 	for {
-		if time.Now().After(t.synchronisationDeadlineTimestamp) {
+		if t.clock.Now().After(t.synchronisationDeadlineTimestamp) {
 			break
 		}
 
-		time.Sleep(time.Millisecond * 50)
-		if len(t.IncomingResponsesTimeFrame) == settings.ObserversMaxCount {
+		<-t.clock.After(time.Millisecond * 50)
+
+		responsesCollected := len(t.IncomingResponsesTimeFrame)
+		t.updateSyncProgress(responsesCollected, t.synchronisationDeadlineTimestamp, false)
+
+		if responsesCollected == settings.ObserversMaxCount {
 			// There is no reason to wait longer.
 			// All responses has been collected.
 			break
@@ -237,7 +643,17 @@ func (t *Ticker) processInternalEvent(event interface{}) error {
 	switch event.(type) {
 	case *EventTickerStarted:
 		{
+			t.frameMutex.Lock()
 			t.isTickerRunning = true
+			t.frameMutex.Unlock()
+
+			t.desync.start(t.clock.Now())
+			return nil
+		}
+
+	case *EventTickerPauseChanged:
+		{
+			// Internal loop is interrupted, so the ticks timer would be recreated.
 			return nil
 		}
 
@@ -272,7 +688,8 @@ func (t *Ticker) processTimeFrameRequest(request *requests.SynchronisationTimeFr
 		nextFrameTimeLeft += settings.AverageBlockGenerationTimeRange
 	}
 
-	if t.frame.Index == kInitialTimeFrameIndex {
+	frame := t.currentFrame()
+	if frame.Index == kInitialTimeFrameIndex {
 		response = responses.NewTimeFrame(
 			request,
 			conf.CurrentObserverIndex,
@@ -283,7 +700,7 @@ func (t *Ticker) processTimeFrameRequest(request *requests.SynchronisationTimeFr
 		response = responses.NewTimeFrame(
 			request,
 			conf.CurrentObserverIndex,
-			t.frame.Index,
+			frame.Index,
 			uint64(t.nextFrameTimeLeft().Nanoseconds()))
 	}
 
@@ -304,18 +721,39 @@ func (t *Ticker) processTimeFrameCollisionRequest(request *requests.TimeFrameCol
 	// todo: collision report might be used for draining the node.
 	//       add some filter map[observer] -> reports count per time.
 
+	conf, err := t.confReporter.GetCurrentConfiguration()
+	if err != nil {
+		return
+	}
+
+	// Reports from the observers, that are not present in current configuration,
+	// must not be taken into account.
+	if !conf.Registry().IsValidIndex(request.ObserverIndex()) {
+		return errors2.InvalidObserverIndex
+	}
+
 	t.ObserversReportedInvalidIndex[request.ObserverIndex()] = true
 	if len(t.ObserversReportedInvalidIndex) > settings.ObserversConsensusCount {
 		t.log().Debug("!!! Collision detected")
-		t.syncWithOtherObservers()
+
+		if t.desync.reportDisagreement(t.clock.Now()) {
+			t.log().Warn("Sustained collisions detected, resynchronisation started")
+			t.syncWithOtherObservers()
+		}
 	}
 
 	return
 }
 
 func (t *Ticker) processTick() {
-	nextFrameNumber := t.frame.Index + 1
-	if nextFrameNumber == uint16(settings.ObserversMaxCount) {
+	t.frameMutex.Lock()
+	currentFrameNumber := t.normalizeFrameIndex(t.frame.Index, t.frame.Conf)
+
+	framesCount := framesInConfiguration(t.frame.Conf)
+
+	// Initial frame index overflows to 0 here.
+	nextFrameNumber := currentFrameNumber + 1
+	if int(nextFrameNumber) >= framesCount {
 		nextFrameNumber = 0
 	}
 
@@ -325,61 +763,300 @@ func (t *Ticker) processTick() {
 	t.frame = &EventTimeFrameEnd{
 		Index:               nextFrameNumber,
 		Conf:                t.frame.Conf,
-		FinalStageTimestamp: time.Now().Add(-settings.BlockGenerationSilencePeriod),
+		FinalStageTimestamp: t.clock.Now().Add(-settings.BlockGenerationSilencePeriod),
 	}
+	frame := t.frame
+	t.frameMutex.Unlock()
 
 	select {
-	case t.OutgoingEventsTimeFrameEnd <- t.frame:
+	case t.OutgoingEventsTimeFrameEnd <- frame:
 	default:
-		t.log().Error("tick transfer error")
+		// Ticks emission must never block.
+		dropped := atomic.AddUint64(&t.droppedTicksCount, 1)
+		t.log().WithFields(log.Fields{"FrameIndex": frame.Index, "DroppedTicksCount": dropped}).Error(
+			"Tick has been dropped: tick events are not consumed")
 	}
 
 	// Drop all index claims, collected during previous round.
 	t.ObserversReportedInvalidIndex = make(map[uint16]bool)
+	t.desync.nextRound()
+}
+
+// normalizeFrameIndex returns frame index brought back into the range of the observers count.
+// In case if the observers set has been shrunk (and current frame index has not been remapped) -
+// ticker must not emit frames of the nonexistent observers.
+// Initial frame index and configurations without observers are left as is.
+func (t *Ticker) normalizeFrameIndex(index uint16, conf *external.Configuration) uint16 {
+	observersCount := observersInConfiguration(conf)
+	if index == kInitialTimeFrameIndex || observersCount == 0 || int(index) < observersCount {
+		return index
+	}
+
+	normalized := uint16(int(index) % observersCount)
+	t.log().WithFields(log.Fields{
+		"FrameIndex":      index,
+		"ObserversCount":  observersCount,
+		"NormalizedIndex": normalized,
+	}).Warn("Frame index is out of observers range and has been normalized")
+
+	return normalized
+}
+
+// framesInConfiguration returns amount of time frames in one round.
+// Frames count is equal to the observers count of the configuration.
+// Configuration without observers does not limit frames (max observers count is used).
+func framesInConfiguration(conf *external.Configuration) int {
+	count := observersInConfiguration(conf)
+	if count == 0 {
+		return settings.ObserversMaxCount
+	}
+
+	return count
+}
+
+func observersInConfiguration(conf *external.Configuration) int {
+	if conf == nil {
+		return 0
+	}
+
+	return len(conf.Observers)
 }
 
 // nextFrameTimeLeft returns time duration to the next time frame.
 // Might be called several times during frame processing:
 // each time the result would be les than the previous,
 // so it is ok for events to interrupt internal events loop.
+//
+// Returned duration is never less than settings.TickerMinFrameTimeLeft:
+// in case if next frame timestamp is very close to the current moment,
+// ticks must not fire one by one without any delay and starve requests processing.
 func (t *Ticker) nextFrameTimeLeft() (d time.Duration) {
-	timeLeft := t.nextFrameTimestamp.Sub(time.Now())
+	t.frameMutex.Lock()
+	now := t.clock.Now()
+	if !t.pausedAt.IsZero() {
+		// Time is frozen during the pause.
+		now = t.pausedAt
+	}
+
+	timeLeft := t.nextFrameTimestamp.Sub(now)
 	if timeLeft <= 0 {
-		t.nextFrameTimestamp = time.Now().Add(
-			settings.AverageBlockGenerationTimeRange).Add(
-			timeLeft * time.Nanosecond * -1)
+		// Next frame timestamp might be far in the past (for example, if the process has been suspended),
+		// so it is moved forward by the whole count of the elapsed frames at once.
+		// Frames boundaries are preserved.
+		if settings.AverageBlockGenerationTimeRange > 0 {
+			elapsedFrames := -timeLeft/settings.AverageBlockGenerationTimeRange + 1
+			timeLeft += elapsedFrames * settings.AverageBlockGenerationTimeRange
 
-		return t.nextFrameTimeLeft()
+		} else {
+			timeLeft = 0
+		}
+
+		t.nextFrameTimestamp = now.Add(timeLeft)
+	}
+	t.frameMutex.Unlock()
+
+	if timeLeft < settings.TickerMinFrameTimeLeft {
+		return settings.TickerMinFrameTimeLeft
 	}
 
 	return timeLeft
 }
 
-func (t *Ticker) reconfigureFrames(e *external.EventConfigurationChanged) {
-	// todo: implement on the ethereum connection implementation stage
+// Pause stops ticks emission.
+// Current frame and the next frame timestamp are preserved,
+// so the ticker continues from the same state after Resume().
+// It is safe to call this method from any goroutine.
+func (t *Ticker) Pause() {
+	t.frameMutex.Lock()
+	if !t.pausedAt.IsZero() {
+		t.frameMutex.Unlock()
+		return
+	}
+
+	t.pausedAt = t.clock.Now()
+	t.frameMutex.Unlock()
+
+	t.interruptInternalLoop()
+	t.log().Info("Ticks emission paused")
 }
 
-// processMajorityAndCalculateAverageNextFrameTTL returns average time offset.
-// Returns 0 in case if no offset is present if offsets.
-func (t *Ticker) processMajorityAndCalculateAverageNextFrameTTL(majorityOfTimeOffsets []uint64) uint64 {
-	if len(majorityOfTimeOffsets) == 0 {
-		return 0
+// Resume restores ticks emission after Pause().
+// Next frame timestamp is shifted by the pause duration,
+// so the time left to the next frame is the same as it was on pause.
+// It is safe to call this method from any goroutine.
+func (t *Ticker) Resume() {
+	t.frameMutex.Lock()
+	if t.pausedAt.IsZero() {
+		t.frameMutex.Unlock()
+		return
 	}
 
-	// todo: Accept only majority of votes, (median, consensus)
-	//       (K%, K == consensus count).
+	pauseDuration := t.clock.Now().Sub(t.pausedAt)
+	t.nextFrameTimestamp = t.nextFrameTimestamp.Add(pauseDuration)
+	t.pausedAt = time.Time{}
+	t.frameMutex.Unlock()
+
+	t.interruptInternalLoop()
+	t.log().WithField("PauseDuration", pauseDuration).Info("Ticks emission resumed")
+}
+
+// IsPaused returns true if ticks emission is paused.
+func (t *Ticker) IsPaused() bool {
+	t.frameMutex.Lock()
+	defer t.frameMutex.Unlock()
+
+	return !t.pausedAt.IsZero()
+}
 
-	var total uint64 = 0
-	for _, ttl := range majorityOfTimeOffsets {
-		total += ttl
+// nextTickTimer returns channel, that fires when next tick must be processed.
+// Returns nil channel (that never fires) in case if ticker is paused.
+func (t *Ticker) nextTickTimer() <-chan time.Time {
+	if t.IsPaused() {
+		return nil
 	}
 
-	return total / uint64(len(majorityOfTimeOffsets))
+	return t.clock.After(t.nextFrameTimeLeft())
+}
+
+// interruptInternalLoop forces internal events loop to process changed state.
+// If internal events bus is full - loop would be interrupted by the pending event, so nothing is needed.
+func (t *Ticker) interruptInternalLoop() {
+	select {
+	case t.internalEventsBus <- &EventTickerPauseChanged{}:
+	default:
+	}
 }
 
-// processMajorityOfFrameResponses processes collected time frames responses,
-// finds the majority of the responses, checks if majority has reached the consensus,
-// and collects time offsets of the observers, that has fit into the majority.
+// FrameSchedule describes one of the upcoming time frames.
+type FrameSchedule struct {
+	Index uint16
+	Start time.Time
+}
+
+// UpcomingFrames returns schedule of the next k time frames:
+// index of each frame (wrapped in the range of current observers count) and the moment when it starts.
+// Frames are expected to be started each settings.AverageBlockGenerationTimeRange.
+// It is safe to call this method from any goroutine.
+func (t *Ticker) UpcomingFrames(k int) (schedule []FrameSchedule) {
+	if k <= 0 {
+		return
+	}
+
+	t.frameMutex.Lock()
+	index := t.frame.Index
+	start := t.nextFrameTimestamp
+	observersCount := settings.ObserversMaxCount
+	if t.frame.Conf != nil && len(t.frame.Conf.Observers) > 0 {
+		observersCount = len(t.frame.Conf.Observers)
+	}
+	t.frameMutex.Unlock()
+
+	schedule = make([]FrameSchedule, 0, k)
+	for i := 0; i < k; i++ {
+		if index == kInitialTimeFrameIndex {
+			index = 0
+		} else {
+			index = uint16((int(index) + 1) % observersCount)
+		}
+		if i > 0 {
+			start = start.Add(settings.AverageBlockGenerationTimeRange)
+		}
+
+		schedule = append(schedule, FrameSchedule{Index: index, Start: start})
+	}
+
+	return
+}
+
+// reconfigureFrames installs observers configuration, reported by the configuration change event.
+// Frames count follows the observers count of the new configuration (see SetConfiguration()).
+func (t *Ticker) reconfigureFrames(e *external.EventConfigurationChanged) error {
+	if e == nil {
+		return errors2.NilParameter
+	}
+
+	return t.SetConfiguration(e.Configuration)
+}
+
+// SetConfiguration atomically installs new observers configuration
+// and remaps current frame index into the range of the new observers count (see remapFrameIndex()).
+// Emits EventConfigurationApplied.
+// It is safe to call this method from any goroutine.
+func (t *Ticker) SetConfiguration(conf *external.Configuration) (err error) {
+	if conf == nil {
+		return errors2.NilParameter
+	}
+
+	t.frameMutex.Lock()
+	previousFrameIndex := t.frame.Index
+	frameIndex := remapFrameIndex(previousFrameIndex, t.frame.Conf, conf)
+
+	t.frame = &EventTimeFrameEnd{
+		Index:               frameIndex,
+		Conf:                conf,
+		FinalStageTimestamp: t.frame.FinalStageTimestamp,
+	}
+	t.frameMutex.Unlock()
+
+	event := &EventConfigurationApplied{
+		Conf:               conf,
+		PreviousFrameIndex: previousFrameIndex,
+		FrameIndex:         frameIndex,
+	}
+
+	select {
+	case t.OutgoingEventsConfigurationApplied <- event:
+		return nil
+
+	default:
+		return errors2.ChannelTransferringFailed
+	}
+}
+
+// remapFrameIndex returns index of the current frame in the new observers configuration.
+// In case if the observer of the current frame is present in the new configuration -
+// the frame follows the observer (index of the observer in the new configuration is returned),
+// so the observer does not lose it's time frame because of the other observers joining or leaving.
+// Otherwise frame index is wrapped by the new observers count.
+// Initial frame index is left as is, as well as any index in case of configuration without observers.
+func remapFrameIndex(index uint16, previous, conf *external.Configuration) uint16 {
+	if index == kInitialTimeFrameIndex || len(conf.Observers) == 0 {
+		return index
+	}
+
+	if previous != nil && int(index) < len(previous.Observers) && previous.Observers[index] != nil {
+		observerIndex, err := conf.Registry().IndexByPubKey(previous.Observers[index].PubKey)
+		if err == nil {
+			return observerIndex
+		}
+	}
+
+	return uint16(int(index) % len(conf.Observers))
+}
+
+// currentFrame returns current frame event.
+// Frames events are never changed after creation, so returned event might be read without the lock.
+func (t *Ticker) currentFrame() *EventTimeFrameEnd {
+	t.frameMutex.Lock()
+	defer t.frameMutex.Unlock()
+
+	return t.frame
+}
+
+// setFrameIndex replaces current frame by the frame with the index specified.
+// Current observers configuration is preserved.
+func (t *Ticker) setFrameIndex(index uint16) {
+	t.frameMutex.Lock()
+	defer t.frameMutex.Unlock()
+
+	t.frame = &EventTimeFrameEnd{
+		Index: index,
+		Conf:  t.frame.Conf,
+	}
+}
+
+// processMajorityOfFrameResponses processes collected time frames responses
+// via the frame consensus algorithm, selected in settings (see FrameConsensus).
 // Returns error in case if consensus has not been reached.
 func (t *Ticker) processMajorityOfFrameResponses() (
 	timeOffsetNanoseconds uint64, nextFrameIndex uint16, collectedResponsesCount uint16, err error) {
@@ -388,59 +1065,104 @@ func (t *Ticker) processMajorityOfFrameResponses() (
 		return 0, 0, 0, errors2.EmptySequence
 	}
 
-	rates := make(map[uint16]*[]uint64)
-
-	var (
-		i                  uint16
-		topFrameIndex      uint16
-		topFrameVotesCount = 0
-		currentTTLsCount   = 0
-		now                = time.Now()
-	)
+	decision := t.frameDecisionContext()
+	frames := make([]*responses.TimeFrame, 0, collectedResponsesCount)
+	for i := uint16(0); i < collectedResponsesCount; i++ {
+		frame := <-t.IncomingResponsesTimeFrame
+		if t.isLateFrameResponse(frame) {
+			t.logLateFrameResponse(frame)
+			continue
+		}
 
-	for i = 0; i < collectedResponsesCount; i++ {
-		vote := <-t.IncomingResponsesTimeFrame
-		frameIndex := vote.FrameIndex
+		if int(frame.FrameIndex) >= decision.observersCount() {
+			// Such vote is dropped by the consensus anyway,
+			// but the observer, that has sent it, must be reported.
+			t.reportOutOfRangeFrameResponse(frame)
+		}
 
-		TTLs, isPresent := rates[frameIndex]
+		t.setObserverRoundTripTime(frame)
+		frames = append(frames, frame)
+	}
 
-		// todo: add comment
-		timeOffset := now.Sub(vote.Received).Nanoseconds()
+	collectedResponsesCount = uint16(len(frames))
+	if collectedResponsesCount == 0 {
+		return 0, 0, 0, errors2.EmptySequence
+	}
 
-		var correctedNanosecondsLeft int64 = 0
-		correctedNanosecondsLeft = int64(settings.AverageBlockGenerationTimeRange) +
-			int64(vote.NanosecondsLeft) -
-			int64(timeOffset)
+	timeOffsetNanoseconds, nextFrameIndex, err = t.consensus.Decide(frames, decision)
+	return
+}
 
-		if isPresent {
-			*TTLs = append(*TTLs, uint64(correctedNanosecondsLeft))
-			currentTTLsCount = len(*TTLs)
+// reportOutOfRangeFrameResponse reports the observer, that has responded with the frame index,
+// that does not belong to the current observers configuration (see SetMisbehaviourReporter()).
+// Responses without the observer index can't be attributed to anyone, so they are ignored.
+func (t *Ticker) reportOutOfRangeFrameResponse(frame *responses.TimeFrame) {
+	if t.misbehaviour == nil || !frame.HasObserverIndex() {
+		return
+	}
 
-		} else {
-			rates[frameIndex] = &[]uint64{uint64(correctedNanosecondsLeft)}
-			currentTTLsCount = 1
-		}
+	external.ReportMisbehaviour(t.misbehaviour, t.currentFrame().Conf, frame.ObserverIndex())
+}
 
-		if currentTTLsCount > topFrameVotesCount {
-			topFrameIndex = frameIndex
-			topFrameVotesCount = currentTTLsCount
-		}
+// frameDecisionContext returns the circumstances of the frame consensus decision (see FrameConsensus).
+func (t *Ticker) frameDecisionContext() FrameDecisionContext {
+	return FrameDecisionContext{
+		Now:            t.clock.Now(),
+		ObserversCount: observersInConfiguration(t.currentFrame().Conf),
 	}
+}
 
-	m, _ := rates[topFrameIndex]
-	timeOffsetNanoseconds = t.processMajorityAndCalculateAverageNextFrameTTL(*m)
+// setObserverRoundTripTime attaches round trip time of the connection to the remote observer to the response
+// (if known), so the consensus is able to correct the time offset of the response by it.
+func (t *Ticker) setObserverRoundTripTime(frame *responses.TimeFrame) {
+	if t.roundTripTimes == nil || !frame.HasObserverIndex() {
+		return
+	}
 
-	frameOffset := 0
-	if timeOffsetNanoseconds > uint64(settings.AverageBlockGenerationTimeRange.Nanoseconds()) {
-		frameOffset = 1
+	rtt, isKnown := t.roundTripTimes.RoundTripTimeByIndex(frame.ObserverIndex())
+	if isKnown {
+		frame.ObserverRoundTripTime = rtt
 	}
+}
 
-	nextFrameIndex = topFrameIndex + uint16(frameOffset)
-	if nextFrameIndex >= uint16(settings.ObserversMaxCount) {
-		nextFrameIndex = 0
+// isLateFrameResponse returns true if the response belongs to the previous synchronisation round.
+// Responses without echoed time of sending (from observers of previous versions) can't be attributed
+// to any round, so they are always accepted.
+func (t *Ticker) isLateFrameResponse(frame *responses.TimeFrame) bool {
+	return !frame.RequestSent.IsZero() && !frame.RequestSent.Equal(t.synchronisationRequestSent)
+}
+
+// discardLateFrameResponses drops buffered responses of the previous synchronisation rounds.
+// All other responses are left in the buffer.
+func (t *Ticker) discardLateFrameResponses() {
+	buffered := len(t.IncomingResponsesTimeFrame)
+	for i := 0; i < buffered; i++ {
+		var frame *responses.TimeFrame
+		select {
+		case frame = <-t.IncomingResponsesTimeFrame:
+		default:
+			return
+		}
+
+		if t.isLateFrameResponse(frame) {
+			t.logLateFrameResponse(frame)
+			continue
+		}
+
+		select {
+		case t.IncomingResponsesTimeFrame <- frame:
+		default:
+			// Buffer has been filled by the responses arrived meanwhile.
+			return
+		}
 	}
+}
 
-	return
+func (t *Ticker) logLateFrameResponse(frame *responses.TimeFrame) {
+	if settings.OutputTickerLateResponsesWarnings {
+		t.log().WithFields(log.Fields{"Response": frame.String()}).Warn(
+			"Late time frame response of the previous synchronisation round discarded")
+	}
 }
 
 func (t *Ticker) log() *log.Entry {
@@ -0,0 +1,991 @@
+package ticker
+
+import (
+	"context"
+	"geo-observers-blockchain/core/common"
+	"geo-observers-blockchain/core/common/errors"
+	observersNet "geo-observers-blockchain/core/network/communicator/observers"
+	"geo-observers-blockchain/core/network/communicator/observers/requests"
+	"geo-observers-blockchain/core/network/communicator/observers/responses"
+	"geo-observers-blockchain/core/network/external"
+	"geo-observers-blockchain/core/network/external/externaltest"
+	"geo-observers-blockchain/core/settings"
+	"math"
+	"testing"
+	"time"
+)
+
+// newTestTicker creates ticker without external observers configuration reporter,
+// so it might be used without keys and configuration files present.
+func newTestTicker() *Ticker {
+	return &Ticker{
+		OutgoingEventsTimeFrameEnd:            make(chan *EventTimeFrameEnd),
+		OutgoingEventsConfigurationApplied:    make(chan *EventConfigurationApplied, 1),
+		OutgoingEventsSynchronisationFinished: make(chan *EventSynchronisationFinished, 1),
+		OutgoingRequestsTimeFrames:            make(chan *requests.SynchronisationTimeFrames, 1),
+		OutgoingResponsesTimeFrame:            make(chan *responses.TimeFrame, 1),
+		IncomingResponsesTimeFrame:            make(chan *responses.TimeFrame, settings.ObserversMaxCount),
+		IncomingRequestsTimeFrames:            make(chan *requests.SynchronisationTimeFrames, 1),
+		IncomingRequestsTimeFrameCollision:    make(chan *requests.TimeFrameCollision, 1),
+		IncomingEventsConfigurationChanged:    make(chan *external.EventConfigurationChanged, 1),
+		internalEventsBus:                     make(chan interface{}, 1),
+
+		frame: &EventTimeFrameEnd{
+			Index: kInitialTimeFrameIndex,
+			Conf:  &external.Configuration{},
+		},
+
+		ObserversReportedInvalidIndex: make(map[uint16]bool),
+		consensus:                     frameConsensus(settings.TickerFrameConsensusAlgorithm),
+		desync:                        newDesyncDetector(),
+		clock:                         RealClock,
+	}
+}
+
+// Launches synchronisation, sends several time frames responses during it,
+// and checks that collected responses count, reported by SyncProgress(), rises.
+func TestTicker_SyncProgress(t *testing.T) {
+	defaultSyncTimeRange := settings.TickerSynchronisationTimeRange
+	settings.TickerSynchronisationTimeRange = time.Second
+	defer func() { settings.TickerSynchronisationTimeRange = defaultSyncTimeRange }()
+
+	ticker := newTestTicker()
+
+	_, _, done := ticker.SyncProgress()
+	if done {
+		t.Fatal("synchronisation must not be reported as done before it's start")
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		ticker.syncWithOtherObservers()
+		close(finished)
+	}()
+
+	lastResponsesCollected := 0
+	for i := 0; i < 3; i++ {
+		ticker.IncomingResponsesTimeFrame <- responses.NewTimeFrame(nil, uint16(i), 0, 0)
+
+		// Wait for the sync loop to notice the response.
+		for {
+			time.Sleep(time.Millisecond * 10)
+			responsesCollected, deadline, done := ticker.SyncProgress()
+			if done {
+				t.Fatal("synchronisation must not be finished before the deadline")
+			}
+
+			if deadline.IsZero() {
+				continue
+			}
+
+			if responsesCollected > lastResponsesCollected {
+				lastResponsesCollected = responsesCollected
+				break
+			}
+		}
+	}
+
+	if lastResponsesCollected != 3 {
+		t.Fatal()
+	}
+
+	<-finished
+	_, _, done = ticker.SyncProgress()
+	if !done {
+		t.Fatal("synchronisation must be reported as done")
+	}
+}
+
+// Internal events bus is full and is not drained:
+// synchronisation goroutine must exit after the synchronisation timeout instead of hanging.
+func TestTicker_SyncWithOtherObservers_Timeout(t *testing.T) {
+	defaultSyncTimeRange := settings.TickerSynchronisationTimeRange
+	defaultSyncTimeout := settings.TickerSynchronisationTimeout
+	settings.TickerSynchronisationTimeRange = time.Millisecond * 100
+	settings.TickerSynchronisationTimeout = time.Millisecond * 300
+	defer func() {
+		settings.TickerSynchronisationTimeRange = defaultSyncTimeRange
+		settings.TickerSynchronisationTimeout = defaultSyncTimeout
+	}()
+
+	ticker := newTestTicker()
+	ticker.internalEventsBus <- &EventTickerStarted{}
+
+	finished := make(chan struct{})
+	go func() {
+		ticker.syncWithOtherObservers()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+	case <-time.After(time.Second * 2):
+		t.Fatal("synchronisation goroutine hangs")
+	}
+
+	if len(ticker.internalEventsBus) != 1 {
+		t.Fatal()
+	}
+}
+
+type fixedFrameConsensus struct {
+	framesReceived int
+}
+
+func (c *fixedFrameConsensus) Decide(frames []*responses.TimeFrame, decision FrameDecisionContext) (
+	timeOffsetNanoseconds uint64, nextFrameIndex uint16, err error) {
+
+	c.framesReceived = len(frames)
+	return 42, 7, nil
+}
+
+// Registers custom frame consensus algorithm, selects it via settings,
+// and checks that ticker uses it's decision instead of the default one.
+func TestTicker_FrameConsensus_Custom(t *testing.T) {
+	algorithm := &fixedFrameConsensus{}
+	err := RegisterFrameConsensus("fixed", algorithm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defaultAlgorithm := settings.TickerFrameConsensusAlgorithm
+	settings.TickerFrameConsensusAlgorithm = "fixed"
+	defer func() { settings.TickerFrameConsensusAlgorithm = defaultAlgorithm }()
+
+	ticker := newTestTicker()
+	for i := 0; i < 3; i++ {
+		ticker.IncomingResponsesTimeFrame <- responses.NewTimeFrame(nil, uint16(i), 1, 0)
+	}
+
+	timeOffset, nextFrameIndex, collectedResponsesCount, err := ticker.processMajorityOfFrameResponses()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if timeOffset != 42 || nextFrameIndex != 7 || collectedResponsesCount != 3 {
+		t.Fatal("decision of the custom algorithm must be used")
+	}
+
+	if algorithm.framesReceived != 3 {
+		t.Fatal("all collected responses must be passed to the algorithm")
+	}
+}
+
+type testRoundTripTimes map[uint16]time.Duration
+
+func (rtts testRoundTripTimes) RoundTripTimeByIndex(index uint16) (rtt time.Duration, isKnown bool) {
+	rtt, isKnown = rtts[index]
+	return
+}
+
+// Checks that the round trip times of the connections are attached to the responses before the consensus,
+// and that the responses without observer index are left as is.
+func TestTicker_ProcessMajorityOfFrameResponses_RoundTripTimes(t *testing.T) {
+	defer setTestConsensusCount(1)()
+
+	ticker := newTestTicker()
+	ticker.SetRoundTripTimes(testRoundTripTimes{0: time.Millisecond * 40})
+
+	known := responses.NewTimeFrame(nil, 0, 1, uint64(time.Second))
+	unknown := responses.NewTimeFrame(nil, 1, 1, uint64(time.Second))
+	legacy := &responses.TimeFrame{FrameIndex: 1, NanosecondsLeft: uint64(time.Second)}
+	for _, frame := range []*responses.TimeFrame{known, unknown, legacy} {
+		frame.Received = time.Now()
+		ticker.IncomingResponsesTimeFrame <- frame
+	}
+
+	_, _, _, err := ticker.processMajorityOfFrameResponses()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if known.ObserverRoundTripTime != time.Millisecond*40 ||
+		unknown.ObserverRoundTripTime != 0 || legacy.ObserverRoundTripTime != 0 {
+		t.Fatal()
+	}
+}
+
+// Checks that the observers, that has responded with the frame indexes out of the range of the current configuration,
+// are reported to the blacklist, and the rest observers are not.
+func TestTicker_ProcessMajorityOfFrameResponses_OutOfRangeReported(t *testing.T) {
+	defer setTestConsensusCount(1)()
+
+	conf := external.NewConfiguration(0, []*external.Observer{
+		external.NewObserver("10.0.0.1", 3000, nil),
+		external.NewObserver("10.0.0.2", 3000, nil),
+		external.NewObserver("10.0.0.3", 3000, nil),
+	})
+
+	blacklist := observersNet.NewBlacklist(1, time.Minute, time.Minute)
+	ticker := newTestTicker()
+	ticker.frame.Conf = conf
+	ticker.SetMisbehaviourReporter(blacklist)
+
+	outOfRange := responses.NewTimeFrame(nil, 1, 3, uint64(time.Second))
+	legitimate := responses.NewTimeFrame(nil, 2, 1, uint64(time.Second))
+	for _, frame := range []*responses.TimeFrame{outOfRange, legitimate} {
+		frame.Received = time.Now()
+		ticker.IncomingResponsesTimeFrame <- frame
+	}
+
+	_, _, _, err := ticker.processMajorityOfFrameResponses()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !blacklist.IsBlacklisted("10.0.0.2") {
+		t.Fatal("observer with out of range frame index must be blacklisted")
+	}
+
+	if blacklist.IsBlacklisted("10.0.0.3") || blacklist.IsBlacklisted("10.0.0.1") {
+		t.Fatal()
+	}
+}
+
+func TestRegisterFrameConsensus_Nil(t *testing.T) {
+	if RegisterFrameConsensus("nil", nil) != errors.NilParameter {
+		t.Fatal()
+	}
+}
+
+func newTestConfiguration(observersCount int) *external.Configuration {
+	observers := make([]*external.Observer, 0, observersCount)
+	for i := 0; i < observersCount; i++ {
+		observers = append(observers, external.NewObserver("127.0.0.1", uint16(3000+i), nil))
+	}
+
+	return external.NewConfiguration(0, observers)
+}
+
+// Swaps configuration between ticks and checks that frame index is remapped into the new observers range,
+// ticks are continued from the remapped index, and configuration applied event is emitted.
+func TestTicker_SetConfiguration(t *testing.T) {
+	ticker := newTestTicker()
+	ticker.OutgoingEventsTimeFrameEnd = make(chan *EventTimeFrameEnd, 1)
+
+	err := ticker.SetConfiguration(newTestConfiguration(8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-ticker.OutgoingEventsConfigurationApplied
+
+	ticker.setFrameIndex(4)
+	ticker.processTick()
+	frame := <-ticker.OutgoingEventsTimeFrameEnd
+	if frame.Index != 5 || len(frame.Conf.Observers) != 8 {
+		t.Fatal()
+	}
+
+	conf := newTestConfiguration(3)
+	err = ticker.SetConfiguration(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := <-ticker.OutgoingEventsConfigurationApplied
+	if event.Conf != conf || event.PreviousFrameIndex != 5 || event.FrameIndex != 2 {
+		t.Fatal("invalid configuration applied event")
+	}
+
+	ticker.processTick()
+	frame = <-ticker.OutgoingEventsTimeFrameEnd
+	if frame.Index != 0 || frame.Conf != conf {
+		t.Fatal("ticks must be continued from the remapped frame with the new configuration")
+	}
+}
+
+// Shrinks the observers set below the current frame index without remapping the index,
+// and checks that the next tick brings the index back into the range of the observers count.
+func TestTicker_ProcessTick_NormalizesFrameIndex(t *testing.T) {
+	ticker := newTestTicker()
+	ticker.OutgoingEventsTimeFrameEnd = make(chan *EventTimeFrameEnd, 1)
+
+	err := ticker.SetConfiguration(newTestConfiguration(8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-ticker.OutgoingEventsConfigurationApplied
+
+	ticker.setFrameIndex(6)
+	conf := newTestConfiguration(4)
+	ticker.frame = &EventTimeFrameEnd{Index: ticker.frame.Index, Conf: conf}
+
+	ticker.processTick()
+	frame := <-ticker.OutgoingEventsTimeFrameEnd
+	if frame.Index != 3 || frame.Conf != conf {
+		t.Fatal("frame index must be normalized into the observers range")
+	}
+}
+
+func TestTicker_SetConfiguration_Nil(t *testing.T) {
+	if newTestTicker().SetConfiguration(nil) != errors.NilParameter {
+		t.Fatal()
+	}
+}
+
+// Launches two synchronisations simultaneously and checks that only one of them is executed.
+func TestTicker_SyncWithOtherObservers_Concurrent(t *testing.T) {
+	defaultSyncTimeRange := settings.TickerSynchronisationTimeRange
+	settings.TickerSynchronisationTimeRange = time.Millisecond * 200
+	defer func() { settings.TickerSynchronisationTimeRange = defaultSyncTimeRange }()
+
+	ticker := newTestTicker()
+
+	// Channels are extended, so the second synchronisation (if executed) would not block.
+	ticker.OutgoingRequestsTimeFrames = make(chan *requests.SynchronisationTimeFrames, 2)
+	ticker.internalEventsBus = make(chan interface{}, 2)
+
+	start := make(chan struct{})
+	finished := make(chan struct{}, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			<-start
+			ticker.syncWithOtherObservers()
+			finished <- struct{}{}
+		}()
+	}
+
+	close(start)
+	<-finished
+	<-finished
+
+	if len(ticker.OutgoingRequestsTimeFrames) != 1 || len(ticker.internalEventsBus) != 1 {
+		t.Fatal("only one synchronisation must be executed")
+	}
+
+	_, _, done := ticker.SyncProgress()
+	if !done {
+		t.Fatal()
+	}
+}
+
+// Sets next frame timestamp very close to the current moment
+// and checks that returned delay is not less than the configured minimum.
+func TestTicker_NextFrameTimeLeft_Floor(t *testing.T) {
+	ticker := newTestTicker()
+	ticker.nextFrameTimestamp = time.Now().Add(time.Millisecond)
+
+	timeLeft := ticker.nextFrameTimeLeft()
+	if timeLeft != settings.TickerMinFrameTimeLeft {
+		t.Fatal("minimal delay must be enforced")
+	}
+
+	// Frame timestamp is not changed, so the frame is processed as usual after the delay.
+	ticker.nextFrameTimestamp = time.Now().Add(time.Second)
+	timeLeft = ticker.nextFrameTimeLeft()
+	if timeLeft <= settings.TickerMinFrameTimeLeft || timeLeft > time.Second {
+		t.Fatal()
+	}
+}
+
+// Sets next frame timestamp into the past and checks that the next frame is rescheduled
+// to the next frame boundary (floor must not affect overdue frames).
+func TestTicker_NextFrameTimeLeft_Overdue(t *testing.T) {
+	ticker := newTestTicker()
+	ticker.nextFrameTimestamp = time.Now().Add(-time.Second)
+
+	timeLeft := ticker.nextFrameTimeLeft()
+	if timeLeft > settings.AverageBlockGenerationTimeRange-time.Second ||
+		timeLeft < settings.AverageBlockGenerationTimeRange-time.Second*2 {
+		t.Fatal("overdue frame must be rescheduled")
+	}
+}
+
+// Sets next frame timestamp hours into the past (process has been suspended for a long time)
+// with very short frames, so the next frame must be found in one step, not frame by frame.
+func TestTicker_NextFrameTimeLeft_FarOverdue(t *testing.T) {
+	defaultRange := settings.AverageBlockGenerationTimeRange
+	settings.AverageBlockGenerationTimeRange = time.Microsecond * 3
+	defer func() { settings.AverageBlockGenerationTimeRange = defaultRange }()
+
+	clock := NewFakeClock(time.Unix(100000, 0))
+	ticker := newTestTickerWithClock(clock)
+	ticker.nextFrameTimestamp = clock.Now().Add(-time.Hour*5 - time.Microsecond)
+
+	started := time.Now()
+	ticker.nextFrameTimeLeft()
+	if time.Since(started) > time.Millisecond*100 {
+		t.Fatal("next frame must be found in constant time")
+	}
+
+	// Frames boundaries are preserved.
+	if !ticker.nextFrameTimestamp.Equal(clock.Now().Add(time.Microsecond * 2)) {
+		t.Fatal("unexpected next frame timestamp: ", ticker.nextFrameTimestamp)
+	}
+
+	// Current moment is exactly on the frames boundary: the next frame is one frame later.
+	ticker.nextFrameTimestamp = clock.Now().Add(-settings.AverageBlockGenerationTimeRange)
+	ticker.nextFrameTimeLeft()
+	if !ticker.nextFrameTimestamp.Equal(clock.Now().Add(settings.AverageBlockGenerationTimeRange)) {
+		t.Fatal()
+	}
+}
+
+// Checks that the schedule covers exactly k frames, wraps around the observers count,
+// and frames are spaced by the block generation time range.
+func TestTicker_UpcomingFrames(t *testing.T) {
+	ticker := newTestTicker()
+	ticker.frame = &EventTimeFrameEnd{Index: 2, Conf: newTestConfiguration(4)}
+	ticker.nextFrameTimestamp = time.Now().Add(time.Second)
+
+	schedule := ticker.UpcomingFrames(6)
+	if len(schedule) != 6 {
+		t.Fatal()
+	}
+
+	expectedIndexes := []uint16{3, 0, 1, 2, 3, 0}
+	for i, frame := range schedule {
+		if frame.Index != expectedIndexes[i] {
+			t.Fatal("invalid frame index")
+		}
+
+		expectedStart := ticker.nextFrameTimestamp.Add(settings.AverageBlockGenerationTimeRange * time.Duration(i))
+		if !frame.Start.Equal(expectedStart) {
+			t.Fatal("invalid frame start")
+		}
+	}
+
+	if len(ticker.UpcomingFrames(0)) != 0 {
+		t.Fatal()
+	}
+}
+
+// Not synchronised ticker must schedule frames starting from the index 0.
+func TestTicker_UpcomingFrames_InitialFrame(t *testing.T) {
+	ticker := newTestTicker()
+	ticker.frame = &EventTimeFrameEnd{Index: kInitialTimeFrameIndex, Conf: newTestConfiguration(3)}
+
+	schedule := ticker.UpcomingFrames(2)
+	if schedule[0].Index != 0 || schedule[1].Index != 1 {
+		t.Fatal()
+	}
+}
+
+// UpcomingFrames must be safe to call concurrently with ticks processing.
+func TestTicker_UpcomingFrames_Concurrent(t *testing.T) {
+	ticker := newTestTicker()
+	ticker.OutgoingEventsTimeFrameEnd = make(chan *EventTimeFrameEnd, 1)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			ticker.processTick()
+			<-ticker.OutgoingEventsTimeFrameEnd
+			ticker.nextFrameTimeLeft()
+		}
+		close(done)
+	}()
+
+	for i := 0; i < 100; i++ {
+		if len(ticker.UpcomingFrames(3)) != 3 {
+			t.Fatal()
+		}
+	}
+	<-done
+}
+
+// Pauses the ticker across several would-be ticks
+// and checks that frame index and time left to the next frame are preserved on resume.
+func TestTicker_PauseResume(t *testing.T) {
+	defaultRange := settings.AverageBlockGenerationTimeRange
+	settings.AverageBlockGenerationTimeRange = time.Millisecond * 50
+	defer func() { settings.AverageBlockGenerationTimeRange = defaultRange }()
+
+	ticker := newTestTicker()
+	ticker.frame = &EventTimeFrameEnd{Index: 2, Conf: newTestConfiguration(4)}
+	ticker.nextFrameTimestamp = time.Now().Add(time.Millisecond * 40)
+
+	ticker.Pause()
+	if !ticker.IsPaused() || ticker.nextTickTimer() != nil {
+		t.Fatal("ticks must not be emitted during the pause")
+	}
+	<-ticker.internalEventsBus
+
+	timeLeftOnPause := ticker.nextFrameTimeLeft()
+	time.Sleep(settings.AverageBlockGenerationTimeRange * 4)
+
+	// Time is frozen during the pause.
+	if ticker.nextFrameTimeLeft() != timeLeftOnPause {
+		t.Fatal()
+	}
+
+	ticker.Resume()
+	if ticker.IsPaused() || ticker.nextTickTimer() == nil {
+		t.Fatal()
+	}
+	<-ticker.internalEventsBus
+
+	if ticker.currentFrame().Index != 2 {
+		t.Fatal("frame index must be preserved")
+	}
+
+	timeLeft := ticker.nextFrameTimeLeft()
+	if timeLeft > timeLeftOnPause || timeLeft < timeLeftOnPause-time.Millisecond*20 {
+		t.Fatal("time left to the next frame must be preserved")
+	}
+}
+
+// Stops running ticker via the common subsystem interface and checks that the internal loop is finished.
+func TestTicker_Stop(t *testing.T) {
+	ticker := newTestTicker()
+
+	finished := make(chan struct{})
+	go func() {
+		ticker.Run(make(chan error, 16))
+		close(finished)
+	}()
+
+	// Wait for the loop to start (synchronisation is started by the loop).
+	for {
+		_, deadline, _ := ticker.SyncProgress()
+		if !deadline.IsZero() {
+			break
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	var subsystem common.Subsystem = ticker
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	err := subsystem.Stop(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("ticker loop is not stopped")
+	}
+}
+
+// Floods the ticker with time frames requests from the remote observers
+// and checks that the internal event is processed first anyway, and that the dropped requests are accounted.
+func TestTicker_EnqueueRequest_InternalEventsArePrioritized(t *testing.T) {
+	ticker := newTestTicker()
+	ticker.IncomingRequestsTimeFrames = make(chan *requests.SynchronisationTimeFrames, 8)
+
+	const requestsCount = 100
+	for i := 0; i < requestsCount; i++ {
+		_ = ticker.EnqueueRequest(&requests.SynchronisationTimeFrames{})
+	}
+
+	if ticker.DroppedRequestsCount() != requestsCount-8 {
+		t.Fatal("dropped requests must be accounted")
+	}
+
+	ticker.internalEventsBus <- &EventTickerStarted{}
+	if !ticker.processPrioritizedEvent(make(chan error, 1)) || !ticker.isTickerRunning {
+		t.Fatal("internal event must be processed first")
+	}
+
+	if len(ticker.IncomingRequestsTimeFrames) != 8 {
+		t.Fatal("peers requests must not be processed before internal events")
+	}
+
+	// Nothing prioritized is left.
+	if ticker.processPrioritizedEvent(make(chan error, 1)) {
+		t.Fatal()
+	}
+}
+
+func TestTicker_EnqueueRequest_UnexpectedType(t *testing.T) {
+	ticker := newTestTicker()
+	if ticker.EnqueueRequest(&requests.ChainTop{}) != errors.UnexpectedDataType {
+		t.Fatal()
+	}
+}
+
+type failingConfigurationReporter struct{}
+
+func (r *failingConfigurationReporter) GetCurrentConfiguration() (*external.Configuration, error) {
+	return nil, errors.NilParameter
+}
+
+// Checks that the ticker, created with the reporter that fails to report configuration,
+// is created with the empty (but not nil) configuration.
+func TestTicker_New_ConfigurationFallback(t *testing.T) {
+	conf := loadInitialConfiguration(&failingConfigurationReporter{})
+	if conf == nil || len(conf.Observers) != 0 || conf.CurrentObserverIndex != math.MaxUint16 {
+		t.Fatal("empty configuration expected")
+	}
+
+	ticker := New(nil)
+	frame := ticker.currentFrame()
+	if frame.Conf == nil || len(frame.Conf.Observers) != 0 || frame.Index != kInitialTimeFrameIndex {
+		t.Fatal("frame configuration must never be nil")
+	}
+
+	// Ticks must be processed with the empty configuration as well.
+	ticker.OutgoingEventsTimeFrameEnd = make(chan *EventTimeFrameEnd, 1)
+	ticker.processTick()
+	frame = <-ticker.OutgoingEventsTimeFrameEnd
+	if frame.Conf == nil || frame.Index != 0 {
+		t.Fatal()
+	}
+}
+
+// Injects late responses of the previous synchronisation round after it's deadline,
+// and checks that they do not affect the next round.
+func TestTicker_ProcessSync_LateResponsesDiscarded(t *testing.T) {
+	defer setTestConsensusCount(1)()
+
+	defaultSyncTimeRange := settings.TickerSynchronisationTimeRange
+	settings.TickerSynchronisationTimeRange = time.Millisecond * 100
+	defer func() { settings.TickerSynchronisationTimeRange = defaultSyncTimeRange }()
+
+	ticker := newTestTicker()
+	respond := func(frameIndex uint16) {
+		request := <-ticker.OutgoingRequestsTimeFrames
+		response := responses.NewTimeFrame(request, 0, frameIndex, uint64(time.Second))
+		response.Received = time.Now()
+		ticker.IncomingResponsesTimeFrame <- response
+	}
+
+	go respond(1)
+	_, nextFrameIndex, collected, err := ticker.processSync()
+	if err != nil || nextFrameIndex != 1 || collected != 1 {
+		t.Fatal()
+	}
+
+	// Responses to the first round, arrived after it's deadline.
+	firstRoundRequest := &requests.SynchronisationTimeFrames{Sent: ticker.synchronisationRequestSent}
+	for i := uint16(1); i < 3; i++ {
+		response := responses.NewTimeFrame(firstRoundRequest, i, 5, 0)
+		response.Received = time.Now()
+		ticker.IncomingResponsesTimeFrame <- response
+	}
+
+	go respond(3)
+	_, nextFrameIndex, collected, err = ticker.processSync()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if nextFrameIndex != 3 || collected != 1 {
+		t.Fatal("late responses must not be counted in the next round")
+	}
+}
+
+// Checks the boundary cases of the synchronisation settings validation.
+func TestValidateSynchronisationSettings(t *testing.T) {
+	var (
+		blockRange = time.Second * 10
+		reserve    = settings.TickerSynchronisationExchangeTimeRange
+		maxRange   = blockRange - reserve - time.Nanosecond
+	)
+
+	if validateSynchronisationSettings(maxRange, maxRange+time.Second, blockRange, SyncPolicySingleFrame) != nil {
+		t.Fatal("synchronisation time range must fit into the block generation time range")
+	}
+
+	err := validateSynchronisationSettings(maxRange+time.Nanosecond, maxRange+time.Second, blockRange, SyncPolicySingleFrame)
+	if err != ErrInvalidSynchronisationTimeRange {
+		t.Fatal("exchange reserve must be accounted")
+	}
+
+	// Synchronisation might span several frames, if elapsed frames are accounted.
+	if validateSynchronisationSettings(blockRange*2, blockRange*3, blockRange, SyncPolicyElapsedFrames) != nil {
+		t.Fatal()
+	}
+
+	if validateSynchronisationSettings(0, time.Second, blockRange, SyncPolicyElapsedFrames) != ErrInvalidSynchronisationTimeRange {
+		t.Fatal()
+	}
+
+	if validateSynchronisationSettings(time.Second, time.Second, blockRange, SyncPolicySingleFrame) != ErrInvalidSynchronisationTimeout {
+		t.Fatal("timeout must be greater than synchronisation time range")
+	}
+}
+
+// Checks that the ticker with invalid synchronisation settings reports the error instead of panic.
+func TestTicker_Run_InvalidSynchronisationSettings(t *testing.T) {
+	defaultSyncTimeRange := settings.TickerSynchronisationTimeRange
+	defaultSyncTimeout := settings.TickerSynchronisationTimeout
+	settings.TickerSynchronisationTimeRange = settings.AverageBlockGenerationTimeRange
+	settings.TickerSynchronisationTimeout = settings.AverageBlockGenerationTimeRange * 2
+	defer func() {
+		settings.TickerSynchronisationTimeRange = defaultSyncTimeRange
+		settings.TickerSynchronisationTimeout = defaultSyncTimeout
+	}()
+
+	errors := make(chan error, 1)
+	newTestTicker().Run(errors)
+
+	if <-errors != ErrInvalidSynchronisationTimeRange {
+		t.Fatal()
+	}
+}
+
+// Simulates synchronisation, that is longer than two block generation time ranges,
+// and checks that the frame index of the ticker is advanced by the amount of frames elapsed during it.
+func TestTicker_ProcessSync_SeveralFramesElapsed(t *testing.T) {
+	defer setTestConsensusCount(1)()
+
+	var (
+		defaultBlockRange    = settings.AverageBlockGenerationTimeRange
+		defaultSyncTimeRange = settings.TickerSynchronisationTimeRange
+		defaultPolicy        = settings.TickerSyncElapsedFramesPolicy
+	)
+	settings.AverageBlockGenerationTimeRange = time.Millisecond * 200
+	settings.TickerSynchronisationTimeRange = time.Millisecond * 500
+	settings.TickerSyncElapsedFramesPolicy = SyncPolicyElapsedFrames
+	defer func() {
+		settings.AverageBlockGenerationTimeRange = defaultBlockRange
+		settings.TickerSynchronisationTimeRange = defaultSyncTimeRange
+		settings.TickerSyncElapsedFramesPolicy = defaultPolicy
+	}()
+
+	ticker := newTestTicker()
+	go func() {
+		request := <-ticker.OutgoingRequestsTimeFrames
+		response := responses.NewTimeFrame(request, 0, 3, uint64(time.Millisecond*50))
+		response.Received = time.Now()
+		ticker.IncomingResponsesTimeFrame <- response
+	}()
+
+	// Remote observer switches frames in 50ms (4), 250ms (5), 450ms (6) and 650ms (7).
+	timeOffset, nextFrameIndex, _, err := ticker.processSync()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if nextFrameIndex != 6 {
+		t.Fatal("frames elapsed during synchronisation must be accounted")
+	}
+
+	if time.Duration(timeOffset) > time.Millisecond*200 {
+		t.Fatal("time offset must not exceed block generation time range")
+	}
+}
+
+// reconfigureTestTicker installs configuration with observers specified, sets frame index,
+// and then reconfigures the ticker with the next observers set. Returns frame index after reconfiguration.
+func reconfigureTestTicker(t *testing.T, observers []*external.Observer, index uint16, next []*external.Observer) uint16 {
+	ticker := newTestTicker()
+	err := ticker.SetConfiguration(external.NewConfiguration(0, observers))
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-ticker.OutgoingEventsConfigurationApplied
+
+	ticker.setFrameIndex(index)
+	err = ticker.reconfigureFrames(&external.EventConfigurationChanged{
+		Configuration: external.NewConfiguration(1, next)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := <-ticker.OutgoingEventsConfigurationApplied
+	if event.PreviousFrameIndex != index || ticker.currentFrame().Index != event.FrameIndex {
+		t.Fatal()
+	}
+
+	return event.FrameIndex
+}
+
+// Adds observers before and after the observer of the current frame,
+// and checks that the frame follows the observer.
+func TestTicker_ReconfigureFrames_Growth(t *testing.T) {
+	o := externaltest.NewObservers(t, 6)
+	index := reconfigureTestTicker(t, o[:4], 2, []*external.Observer{o[4], o[0], o[1], o[2], o[3], o[5]})
+	if index != 3 {
+		t.Fatal("frame must follow the observer")
+	}
+}
+
+// Removes observers before the observer of the current frame,
+// and checks that the frame follows the observer.
+func TestTicker_ReconfigureFrames_Shrinkage(t *testing.T) {
+	o := externaltest.NewObservers(t, 6)
+	index := reconfigureTestTicker(t, o, 4, []*external.Observer{o[0], o[2], o[4]})
+	if index != 2 {
+		t.Fatal("frame must follow the observer")
+	}
+}
+
+// Removes the observer of the current frame, which index is out of the new observers range,
+// and checks that the frame index is wrapped by the new observers count.
+func TestTicker_ReconfigureFrames_Wraparound(t *testing.T) {
+	o := externaltest.NewObservers(t, 6)
+	index := reconfigureTestTicker(t, o, 5, o[:3])
+	if index != 2 {
+		t.Fatal("frame index must be wrapped")
+	}
+}
+
+// Sends configuration change event to the running internal events loop
+// and checks that the configuration is applied.
+func TestTicker_ReconfigureFrames_EventsLoop(t *testing.T) {
+	defaultSyncTimeRange := settings.TickerSynchronisationTimeRange
+	settings.TickerSynchronisationTimeRange = time.Millisecond * 50
+	defer func() { settings.TickerSynchronisationTimeRange = defaultSyncTimeRange }()
+
+	ticker := newTestTicker()
+	go ticker.Run(make(chan error, 16))
+	defer ticker.Stop(context.Background())
+
+	// Synchronisation, started by the loop, must not outlive the test.
+	defer func() {
+		for {
+			_, _, done := ticker.SyncProgress()
+			if done {
+				return
+			}
+
+			time.Sleep(time.Millisecond * 10)
+		}
+	}()
+
+	conf := newTestConfiguration(3)
+	ticker.IncomingEventsConfigurationChanged <- &external.EventConfigurationChanged{Configuration: conf}
+
+	select {
+	case event := <-ticker.OutgoingEventsConfigurationApplied:
+		if event.Conf != conf {
+			t.Fatal()
+		}
+
+	case <-time.After(time.Second):
+		t.Fatal("configuration change event must be processed")
+	}
+
+	if ticker.reconfigureFrames(nil) != errors.NilParameter {
+		t.Fatal()
+	}
+}
+
+// Synchronises the ticker without responses of other observers and with one agreed response,
+// and checks the reported synchronisation results.
+func TestTicker_SyncWithOtherObservers_FinishedEvent(t *testing.T) {
+	defer setTestConsensusCount(1)()
+
+	defaultSyncTimeRange := settings.TickerSynchronisationTimeRange
+	settings.TickerSynchronisationTimeRange = time.Millisecond * 100
+	defer func() { settings.TickerSynchronisationTimeRange = defaultSyncTimeRange }()
+
+	ticker := newTestTicker()
+	ticker.syncWithOtherObservers()
+
+	event := <-ticker.OutgoingEventsSynchronisationFinished
+	if !event.IsFallback || event.ResponsesCount != 0 || event.TimeOffset != settings.AverageBlockGenerationTimeRange {
+		t.Fatal("fallback to the default parameters must be reported")
+	}
+
+	ticker = newTestTicker()
+	go func() {
+		request := <-ticker.OutgoingRequestsTimeFrames
+		response := responses.NewTimeFrame(request, 0, 3, uint64(time.Second*10))
+		response.Received = time.Now()
+		ticker.IncomingResponsesTimeFrame <- response
+	}()
+	ticker.syncWithOtherObservers()
+
+	event = <-ticker.OutgoingEventsSynchronisationFinished
+	if event.IsFallback || event.ResponsesCount != 1 || event.FrameIndex != 3 {
+		t.Fatal()
+	}
+
+	if event.TimeOffset > time.Second*10 || event.TimeOffset < time.Second*9 {
+		t.Fatal("invalid time offset")
+	}
+}
+
+type failingFrameConsensus struct{}
+
+func (c *failingFrameConsensus) Decide(frames []*responses.TimeFrame, decision FrameDecisionContext) (
+	timeOffsetNanoseconds uint64, nextFrameIndex uint16, err error) {
+
+	return 42, 7, errors.InvalidDataFormat
+}
+
+// Frame consensus algorithm fails with an arbitrary error:
+// it's decision must be ignored, and the independent time frames flow must be started.
+func TestTicker_SyncWithOtherObservers_ConsensusFailed(t *testing.T) {
+	defaultSyncTimeRange := settings.TickerSynchronisationTimeRange
+	settings.TickerSynchronisationTimeRange = time.Millisecond * 100
+	defer func() { settings.TickerSynchronisationTimeRange = defaultSyncTimeRange }()
+
+	ticker := newTestTicker()
+	ticker.consensus = &failingFrameConsensus{}
+	go func() {
+		request := <-ticker.OutgoingRequestsTimeFrames
+		response := responses.NewTimeFrame(request, 0, 3, uint64(time.Second*10))
+		response.Received = time.Now()
+		ticker.IncomingResponsesTimeFrame <- response
+	}()
+	ticker.syncWithOtherObservers()
+
+	event := <-ticker.OutgoingEventsSynchronisationFinished
+	if !event.IsFallback || event.FrameIndex != 0 || event.TimeOffset != settings.AverageBlockGenerationTimeRange {
+		t.Fatal("fallback to the default parameters must be reported: ", event)
+	}
+
+	if ticker.currentFrame().Index != 0 {
+		t.Fatal("decision of the failed consensus must not be applied")
+	}
+}
+
+// Checks that the unconsumed event is replaced by the next one.
+func TestTicker_EmitSynchronisationFinished_Replaces(t *testing.T) {
+	ticker := newTestTicker()
+	ticker.emitSynchronisationFinished(&EventSynchronisationFinished{FrameIndex: 1})
+	ticker.emitSynchronisationFinished(&EventSynchronisationFinished{FrameIndex: 2})
+
+	if len(ticker.OutgoingEventsSynchronisationFinished) != 1 {
+		t.Fatal()
+	}
+
+	if (<-ticker.OutgoingEventsSynchronisationFinished).FrameIndex != 2 {
+		t.Fatal("the last event must be kept")
+	}
+}
+
+// Checks that with the small observers set frame index cycles in the range of the observers count.
+func TestTicker_ProcessTick_WrapsByObserversCount(t *testing.T) {
+	ticker := newTestTicker()
+	ticker.OutgoingEventsTimeFrameEnd = make(chan *EventTimeFrameEnd, 1)
+	ticker.frame.Conf = newTestConfiguration(3)
+
+	expected := []uint16{0, 1, 2, 0, 1, 2, 0}
+	for _, index := range expected {
+		ticker.processTick()
+		frame := <-ticker.OutgoingEventsTimeFrameEnd
+		if frame.Index != index {
+			t.Fatal("frame index must cycle 0..N-1")
+		}
+	}
+}
+
+// Emits ticks without the reader and checks that they are dropped without blocking, and are accounted.
+func TestTicker_ProcessTick_DroppedTicks(t *testing.T) {
+	ticker := newTestTicker()
+	ticker.processTick()
+	ticker.processTick()
+
+	if ticker.DroppedTicksCount() != 2 || ticker.Status().DroppedTicksCount != 2 {
+		t.Fatal("dropped ticks must be accounted")
+	}
+}
+
+// Checks that the configured amount of ticks is buffered, and only the ticks beyond the buffer are dropped.
+func TestTicker_ProcessTick_TicksBuffer(t *testing.T) {
+	defaultBufferSize := settings.TickerTicksBufferSize
+	settings.TickerTicksBufferSize = 2
+	defer func() { settings.TickerTicksBufferSize = defaultBufferSize }()
+
+	ticker := New(nil)
+	for i := 0; i < 3; i++ {
+		ticker.processTick()
+	}
+
+	if len(ticker.OutgoingEventsTimeFrameEnd) != 2 || ticker.DroppedTicksCount() != 1 {
+		t.Fatal()
+	}
+
+	if (<-ticker.OutgoingEventsTimeFrameEnd).Index != 0 {
+		t.Fatal("buffered ticks must be kept in order")
+	}
+}
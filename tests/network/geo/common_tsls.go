@@ -3,9 +3,11 @@ package geo
 import (
 	"geo-observers-blockchain/core/common/types/transactions"
 	"geo-observers-blockchain/core/geo"
+	"geo-observers-blockchain/core/network/communicator/geo/api/v0/common"
 	"geo-observers-blockchain/core/network/communicator/geo/api/v0/requests"
 	"geo-observers-blockchain/core/network/communicator/geo/api/v0/responses"
 	"testing"
+	"time"
 )
 
 func RequestTSLAppend(t *testing.T, tsl *geo.TSL, observerIndex int) {
@@ -36,6 +38,26 @@ func CreateEmptyTSL(membersCount int) (tsl *geo.TSL) {
 	return
 }
 
+// RequestTSLIsPresentWithDeadline returns common.ErrResponseTimeout
+// in case if the observer does not respond until the deadline.
+func RequestTSLIsPresentWithDeadline(
+	t *testing.T, TxID *transactions.TxID, observerIndex int, deadline time.Time) (*responses.TSLIsPresent, error) {
+
+	conn := ConnectToObserver(t, observerIndex)
+	defer conn.Close()
+
+	request := requests.NewTSLIsPresentWithDeadline(TxID, deadline)
+	SendRequest(t, request, conn)
+
+	response := &responses.TSLIsPresent{}
+	err := common.ReceiveResponse(conn, response, request.Deadline())
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
 func RequestTSLIsPresent(t *testing.T, TxID *transactions.TxID, observerIndex int) *responses.TSLIsPresent {
 	conn := ConnectToObserver(t, observerIndex)
 	defer conn.Close()
@@ -47,3 +69,17 @@ func RequestTSLIsPresent(t *testing.T, TxID *transactions.TxID, observerIndex in
 	GetResponse(t, response, conn)
 	return response
 }
+
+func RequestTSLsArePresent(
+	t *testing.T, TxIDs []*transactions.TxID, observerIndex int) *responses.TSLsArePresent {
+
+	conn := ConnectToObserver(t, observerIndex)
+	defer conn.Close()
+
+	request := requests.NewTSLsArePresent(TxIDs)
+	SendRequest(t, request, conn)
+
+	response := &responses.TSLsArePresent{}
+	GetResponse(t, response, conn)
+	return response
+}
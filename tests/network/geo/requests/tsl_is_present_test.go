@@ -1,8 +1,12 @@
 package requests
 
 import (
+	"geo-observers-blockchain/core/common/types/transactions"
 	"geo-observers-blockchain/core/network/communicator/geo/api/v0/common"
+	"geo-observers-blockchain/core/network/communicator/geo/api/v0/responses"
+	testsCommon "geo-observers-blockchain/tests/network/geo"
 	"testing"
+	"time"
 )
 
 const (
@@ -15,3 +19,18 @@ func TestTSLIsPresentRequestID(t *testing.T) {
 		t.Fatal()
 	}
 }
+
+func TestTSLIsPresentWithDeadline(t *testing.T) {
+	// Positive: live observer responds before the deadline.
+	// Expected result: TSL is absent.
+
+	txID, _ := transactions.NewRandomTxID(1)
+	response, err := testsCommon.RequestTSLIsPresentWithDeadline(t, txID, 0, time.Now().Add(time.Second*3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.PresentInPool || response.PresentInBlock != 0 || response.Status != responses.TSLStatusAbsent {
+		t.Fatal("unknown TSL must be absent")
+	}
+}
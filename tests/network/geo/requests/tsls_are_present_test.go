@@ -0,0 +1,70 @@
+package requests
+
+import (
+	"geo-observers-blockchain/core/common/types/transactions"
+	"geo-observers-blockchain/core/network/communicator/geo/api/v0/common"
+	"geo-observers-blockchain/core/network/communicator/geo/api/v0/requests"
+	"geo-observers-blockchain/core/utils"
+	testsCommon "geo-observers-blockchain/tests/network/geo"
+	"testing"
+	"time"
+)
+
+const (
+	TSLsArePresentRequestID = 70
+)
+
+func TestTSLsArePresentRequestID(t *testing.T) {
+	if //noinspection GoBoolExpressions
+	TSLsArePresentRequestID != common.ReqTSLsArePresent {
+		t.Fatal()
+	}
+}
+
+func TestTSLsArePresentUnknownTransactions(t *testing.T) {
+	{
+		// Positive: several transaction IDs requested.
+		// Expected result: the same count of results, none of TSLs is present.
+
+		txIDs := make([]*transactions.TxID, 0, 4)
+		for i := 0; i < 4; i++ {
+			txID, _ := transactions.NewRandomTxID(1)
+			txIDs = append(txIDs, txID)
+		}
+
+		response := testsCommon.RequestTSLsArePresent(t, txIDs, 0)
+		if len(response.At) != len(txIDs) {
+			t.Fatal("Expected ", len(txIDs), " results, got ", len(response.At))
+		}
+
+		for _, presence := range response.At {
+			if presence.PresentInPool || presence.PresentInBlock != 0 {
+				t.Error("Expected TSL to be absent")
+			}
+		}
+	}
+
+	{
+		// Negative: too many transaction IDs are present in request.
+		// Expected result: connection drop.
+
+		conn := testsCommon.ConnectToObserver(t, 0)
+		defer conn.Close()
+
+		// Request is marshalled manually: TSLsArePresent.MarshalBinary() rejects too large batches.
+		count := requests.TSLsArePresentMaxCount + 1
+		data := utils.ChainByteSlices([]byte{common.ReqTSLsArePresent}, utils.MarshalUint16(uint16(count)))
+		for i := 0; i < count; i++ {
+			txID, _ := transactions.NewRandomTxID(1)
+			data = utils.ChainByteSlices(data, txID.Bytes[:])
+		}
+		testsCommon.SendData(t, conn, data)
+
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		one := []byte{0}
+		_, err := conn.Read(one)
+		if err == nil {
+			t.Error("Expected connection to be closed, but it seems that not.")
+		}
+	}
+}